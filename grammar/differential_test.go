@@ -0,0 +1,58 @@
+//go:build peg
+
+package grammar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/conneroisu/gix/internal/types"
+	"github.com/conneroisu/gix/pkg/lexer"
+	"github.com/conneroisu/gix/pkg/parser"
+)
+
+// TestDifferential parses every .nix file under testdata/ with both the
+// handwritten pkg/parser and this package's PEG-generated parser, and
+// fails if their ASTs print differently. It only runs when this package
+// has been built with `go generate ./grammar` against a real pigeon
+// install (see generate.go) and the `peg` build tag, since nix.peg.go
+// does not ship in the repo.
+func TestDifferential(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.nix")
+	if err != nil {
+		t.Fatalf("glob testdata: %v", err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no .nix files found in testdata")
+	}
+
+	for _, file := range files {
+		file := file
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			src, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("read %s: %v", file, err)
+			}
+
+			want, err := parser.New(lexer.New(string(src))).Parse()
+			if err != nil {
+				t.Fatalf("handwritten parser: %v", err)
+			}
+
+			got, err := Parse(file, src)
+			if err != nil {
+				t.Fatalf("PEG parser: %v", err)
+			}
+
+			gotExpr, ok := got.(types.Expr)
+			if !ok {
+				t.Fatalf("PEG parser returned %T, want types.Expr", got)
+			}
+
+			if want.String() != gotExpr.String() {
+				t.Errorf("AST mismatch for %s:\nhandwritten: %s\nPEG:         %s", file, want, gotExpr)
+			}
+		})
+	}
+}
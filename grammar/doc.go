@@ -0,0 +1,18 @@
+// Package grammar holds an executable PEG specification of the Nix
+// dialect this interpreter supports (nix.peg) and the scaffolding to turn
+// it into a second, generated parser.
+//
+// The generated parser is not a replacement for pkg/parser's handwritten
+// precedence-climbing implementation, which remains the one used by
+// pkg/eval and the CLI. Instead it exists as a differential-testing
+// oracle: differential_test.go runs both parsers over the .nix files in
+// testdata/ and fails if their ASTs disagree, which catches precedence
+// and associativity bugs that are easy to introduce by hand but hard to
+// get wrong in a declarative grammar.
+//
+// Generating the parser requires a PEG compiler such as pigeon
+// (github.com/mna/pigeon) on $PATH; run `go generate ./grammar` to
+// produce nix.peg.go. Until that's been run, differential_test.go (which
+// is built only with the `peg` build tag) has nothing to compare against,
+// so it's excluded from the default `go test ./...` run.
+package grammar
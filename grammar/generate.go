@@ -0,0 +1,5 @@
+package grammar
+
+// Regenerate the PEG-derived parser from nix.peg. Requires pigeon
+// (go install github.com/mna/pigeon@latest) on $PATH.
+//go:generate pigeon -o nix.peg.go nix.peg
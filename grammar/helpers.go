@@ -0,0 +1,288 @@
+package grammar
+
+import (
+	"strconv"
+
+	"github.com/conneroisu/gix/internal/types"
+)
+
+// The functions below are the semantic actions referenced from nix.peg.
+// pigeon (see generate.go) wires each grammar rule's `{ ... }` block to a
+// method on the generated parser that calls back into this package, so
+// these build the same *types.<X>Expr nodes pkg/parser's handwritten
+// parseX methods do, from whatever pigeon hands back for a rule's
+// sub-matches. Repetition (`x*`) and optional (`x?`) matches arrive as
+// []interface{} and interface{} (nil if absent) respectively; the exact
+// shapes are pinned down by running `go generate ./grammar` against a
+// real pigeon install and adjusting these signatures to match, which
+// hasn't been done in this environment.
+
+func binary(left types.Expr, op types.BinaryOp, right types.Expr) types.Expr {
+	return &types.BinaryExpr{Left: left, Op: op, Right: right}
+}
+
+func unary(op types.UnaryOp, e types.Expr) types.Expr {
+	return &types.UnaryExpr{Op: op, Expr: e}
+}
+
+func eqOp(op interface{}) types.BinaryOp {
+	if opText(op) == "==" {
+		return types.OpEq
+	}
+
+	return types.OpNEq
+}
+
+func cmpOp(op interface{}) types.BinaryOp {
+	switch opText(op) {
+	case "<=":
+		return types.OpLTE
+	case ">=":
+		return types.OpGTE
+	case "<":
+		return types.OpLT
+	default:
+		return types.OpGT
+	}
+}
+
+func addOp(op interface{}) types.BinaryOp {
+	if opText(op) == "+" {
+		return types.OpAdd
+	}
+
+	return types.OpSub
+}
+
+func mulOp(op interface{}) types.BinaryOp {
+	if opText(op) == "*" {
+		return types.OpMul
+	}
+
+	return types.OpDiv
+}
+
+// opText normalizes the []byte/string a choice rule like ("==" / "!=")
+// hands back into a comparable string.
+func opText(op interface{}) string {
+	switch v := op.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	default:
+		return ""
+	}
+}
+
+func letExpr(bindings []interface{}, body types.Expr) types.Expr {
+	let := &types.LetExpr{Body: body}
+	for _, b := range bindings {
+		binding, ok := b.(types.Binding)
+		if !ok {
+			continue
+		}
+		let.Bindings = append(let.Bindings, binding)
+	}
+
+	return let
+}
+
+func withExpr(scope, body types.Expr) types.Expr {
+	return &types.WithExpr{Expr: scope, Body: body}
+}
+
+func assertExpr(cond, body types.Expr) types.Expr {
+	return &types.AssertExpr{Cond: cond, Body: body}
+}
+
+func ifExpr(cond, then, els types.Expr) types.Expr {
+	return &types.IfExpr{Cond: cond, Then: then, Else: els}
+}
+
+func functionExpr(param types.Expr, body types.Expr) types.Expr {
+	ident, _ := param.(*types.IdentExpr)
+	name := ""
+	if ident != nil {
+		name = ident.Name
+	}
+
+	return &types.FunctionExpr{Param: name, Body: body}
+}
+
+func functionExprPattern(pattern *types.Pattern, body types.Expr) types.Expr {
+	return &types.FunctionExpr{Pattern: pattern, Body: body}
+}
+
+func attrSetExpr(recursive bool, bindings []interface{}, inherits []interface{}) types.Expr {
+	set := &types.AttrSetExpr{Recursive: recursive}
+	for _, b := range bindings {
+		binding, ok := b.(types.Binding)
+		if !ok {
+			continue
+		}
+		set.Bindings = append(set.Bindings, types.AttrBinding{Path: []string{binding.Name}, Value: binding.Value})
+	}
+	for _, i := range inherits {
+		inherit, ok := i.(types.InheritClause)
+		if !ok {
+			continue
+		}
+		set.Inherits = append(set.Inherits, inherit)
+	}
+
+	return set
+}
+
+func binding(path []string, value types.Expr) types.Binding {
+	name := ""
+	if len(path) > 0 {
+		name = path[0]
+	}
+
+	return types.Binding{Name: name, Value: value}
+}
+
+func inheritClause(from interface{}, names []interface{}) types.InheritClause {
+	clause := types.InheritClause{}
+	if src, ok := from.(types.Expr); ok {
+		clause.From = src
+	}
+	for _, n := range names {
+		ident, ok := n.(*types.IdentExpr)
+		if !ok {
+			continue
+		}
+		clause.Attrs = append(clause.Attrs, ident.Name)
+	}
+
+	return clause
+}
+
+func attrPath(first string, rest []interface{}) []string {
+	path := []string{first}
+	for _, part := range rest {
+		if s, ok := part.(string); ok {
+			path = append(path, s)
+		}
+	}
+
+	return path
+}
+
+func attrPattern(names interface{}, ellipsis interface{}) *types.Pattern {
+	p := &types.Pattern{Type: types.AttrSetPattern, Ellipsis: ellipsis != nil}
+	if group, ok := names.([]interface{}); ok {
+		for _, n := range group {
+			if ident, ok := n.(*types.IdentExpr); ok {
+				p.Attrs = append(p.Attrs, ident.Name)
+			}
+		}
+	}
+
+	return p
+}
+
+func listExpr(elems []interface{}) types.Expr {
+	list := &types.ListExpr{}
+	for _, e := range elems {
+		if expr, ok := e.(types.Expr); ok {
+			list.Elements = append(list.Elements, expr)
+		}
+	}
+
+	return list
+}
+
+func applySelectors(base types.Expr, path []interface{}, has interface{}) types.Expr {
+	expr := base
+	for _, p := range path {
+		part, ok := p.(string)
+		if !ok {
+			continue
+		}
+		expr = &types.SelectExpr{Expr: expr, AttrPath: []string{part}}
+	}
+	if attrs, ok := has.([]string); ok {
+		expr = &types.HasAttrExpr{Expr: expr, AttrPath: attrs}
+	}
+
+	return expr
+}
+
+func applyChain(first types.Expr, rest []interface{}) types.Expr {
+	expr := first
+	for _, r := range rest {
+		arg, ok := r.(types.Expr)
+		if !ok {
+			continue
+		}
+		expr = &types.ApplyExpr{Func: expr, Arg: arg}
+	}
+
+	return expr
+}
+
+func pathExpr(text string) types.Expr {
+	return &types.PathExpr{Value: text, IsAbsolute: len(text) > 0 && text[0] == '/'}
+}
+
+func floatExpr(text string) types.Expr {
+	v, _ := strconv.ParseFloat(text, 64)
+
+	return &types.FloatExpr{Value: v}
+}
+
+func intExpr(text string) types.Expr {
+	v, _ := strconv.ParseInt(text, 10, 64)
+
+	return &types.IntExpr{Value: v}
+}
+
+func boolExpr(text string) types.Expr {
+	return &types.BoolExpr{Value: text == "true"}
+}
+
+func nullExpr() types.Expr {
+	return &types.NullExpr{}
+}
+
+func identExpr(name string) types.Expr {
+	return &types.IdentExpr{Name: name}
+}
+
+func stringExpr(chars []interface{}) types.Expr {
+	var value string
+	for _, c := range chars {
+		if s, ok := c.(string); ok {
+			value += s
+		}
+	}
+
+	return &types.StringExpr{Value: value}
+}
+
+func interp(e types.Expr) string {
+	// String interpolation isn't evaluated by the differential oracle yet;
+	// fold it back into literal text so Strings with ${...} still compare
+	// structurally against the handwritten parser's (currently also
+	// non-evaluating) treatment of the expression's source text.
+	return e.String()
+}
+
+func escape(text string) string {
+	switch text {
+	case `\n`:
+		return "\n"
+	case `\t`:
+		return "\t"
+	case `\r`:
+		return "\r"
+	case `\\`:
+		return `\`
+	case `\"`:
+		return `"`
+	default:
+		return text
+	}
+}
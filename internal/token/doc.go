@@ -0,0 +1,17 @@
+// Package token provides the position-tracking abstraction shared by the
+// lexer and the packages downstream of it.
+//
+// A single source file can identify a position with a plain line and
+// column, but once a program spans several files (an import chain, for
+// example) those alone no longer say which file they're in. FileSet
+// solves this the way go/token's FileSet does: it hands out a Pos - a
+// single comparable int - for every byte offset across every file it
+// registers, and can resolve any Pos it issued back to a full Position
+// (filename, line, column, offset), letting diagnostics carry one small
+// value instead of a (filename, line, column) triple everywhere.
+//
+// This package is intentionally a scaled-down FileSet/File: it tracks
+// line starts and resolves positions, but doesn't yet offer go/token's
+// extras (line directives, concurrent-safe mutation, serialization).
+// Those can be added if a concrete need for them arises.
+package token
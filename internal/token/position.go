@@ -0,0 +1,129 @@
+package token
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Pos is an opaque, comparable handle for a byte offset within a file
+// registered with a FileSet. The zero Pos, NoPos, doesn't refer to any
+// position.
+type Pos int
+
+// NoPos is the zero value of Pos. FileSet.Position(NoPos) always reports
+// the zero Position.
+const NoPos Pos = 0
+
+// IsValid reports whether p was actually issued by a FileSet, as opposed
+// to being the zero value.
+func (p Pos) IsValid() bool {
+	return p != NoPos
+}
+
+// Position is the human-readable form of a Pos: which file it falls in,
+// and its line, column, and byte offset within that file.
+type Position struct {
+	Filename string
+	Offset   int // byte offset within the file, 0-based
+	Line     int // 1-based
+	Column   int // 0-based, matching lexer.Token's existing convention
+}
+
+// IsValid reports whether p has a usable Line - the zero Position, from
+// resolving a Pos no FileSet issued, does not.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	if p.Filename == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+}
+
+// File records the line-start offsets of one source file added to a
+// FileSet, so a Pos falling within it can be converted to a Position
+// without re-scanning the source. Callers populate it via AddLine as they
+// scan forward through the file - see lexer.NewFile.
+type File struct {
+	name  string
+	base  int   // Pos of the file's first byte
+	size  int   // length of the file's content in bytes
+	lines []int // byte offset of the start of each line; lines[0] == 0
+}
+
+// AddLine records that a new line starts at the given byte offset into
+// the file. Offsets must be added in increasing order - the order a
+// lexer discovers '\n' characters while scanning forward - and offsets
+// at or before the last one recorded are ignored.
+func (f *File) AddLine(offset int) {
+	if n := len(f.lines); n == 0 || f.lines[n-1] < offset {
+		f.lines = append(f.lines, offset)
+	}
+}
+
+// Pos returns the Pos corresponding to a byte offset within this file.
+func (f *File) Pos(offset int) Pos {
+	return Pos(f.base + offset)
+}
+
+// Position converts a Pos known to fall within this file to a Position.
+func (f *File) Position(p Pos) Position {
+	offset := int(p) - f.base
+
+	// The last recorded line start at or before offset is the line
+	// offset falls on.
+	line := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset }) - 1
+	if line < 0 {
+		line = 0
+	}
+
+	return Position{
+		Filename: f.name,
+		Offset:   offset,
+		Line:     line + 1,
+		Column:   offset - f.lines[line],
+	}
+}
+
+// FileSet registers source files and assigns each a disjoint range of Pos
+// values, so a Pos alone identifies both a file and an offset within it -
+// the same approach go/token's FileSet uses to let diagnostics from
+// different files share one flat position type.
+type FileSet struct {
+	files []*File
+	base  int // Pos the next AddFile call will start at
+}
+
+// NewFileSet creates an empty FileSet.
+func NewFileSet() *FileSet {
+	return &FileSet{base: 1}
+}
+
+// AddFile registers a new file of the given size (its content's length in
+// bytes) and returns a *File the caller populates with that file's line
+// starts as it's scanned - see lexer.NewFile.
+func (s *FileSet) AddFile(filename string, size int) *File {
+	f := &File{name: filename, base: s.base, size: size, lines: []int{0}}
+	s.files = append(s.files, f)
+	// +1 keeps adjacent files' Pos ranges from touching, so a Pos one
+	// past the end of a file is never mistaken for the next file's first
+	// byte.
+	s.base += size + 1
+
+	return f
+}
+
+// Position resolves p to a human-readable Position, or the zero Position
+// if p doesn't fall within any file this FileSet has registered.
+func (s *FileSet) Position(p Pos) Position {
+	for _, f := range s.files {
+		if int(p) >= f.base && int(p) <= f.base+f.size {
+			return f.Position(p)
+		}
+	}
+
+	return Position{}
+}
@@ -12,14 +12,38 @@ type Node interface {
 	// String returns a string representation of the node
 	String() string
 
-	// Position returns the source position of the node
+	// TokenLiteral returns the literal text of the token most closely
+	// associated with the node (e.g. "let" for a LetExpr, "+" for an
+	// addition BinaryExpr). It is mainly useful for error messages and
+	// debugging, where String() would be too verbose.
+	TokenLiteral() string
+
+	// Position returns the starting source position of the node
 	Position() SourcePos
+
+	// Span returns the full source range the node was parsed from.
+	Span() Span
+
+	// End returns the source position just past the node's last token -
+	// the same position as Span().End. It's a convenience for callers
+	// (LSP hover, error underlines, source-preserving rewrites) that only
+	// care about where a node ends, mirroring how Position is a
+	// convenience for Span().Start.
+	End() SourcePos
 }
 
 // SourcePos represents a position in the source code.
 type SourcePos struct {
 	Line   int // 1-based line number
-	Column int // 0-based column number
+	Column int // 1-based column number
+	Offset int // 0-based byte offset into the source
+}
+
+// Span represents a node's full extent in the source, from the start of
+// its first token to the end of its last one.
+type Span struct {
+	Start SourcePos
+	End   SourcePos
 }
 
 // Expr represents an expression node in the AST.
@@ -32,14 +56,43 @@ type Expr interface {
 
 // baseNode provides common functionality for all AST nodes.
 type baseNode struct {
-	pos SourcePos
+	span Span
 }
 
-func (n baseNode) Position() SourcePos { return n.pos }
+func (n baseNode) Position() SourcePos { return n.span.Start }
+func (n baseNode) Span() Span          { return n.span }
+func (n baseNode) End() SourcePos      { return n.span.End }
+
+// SetSpan records the full source range a node was parsed from. Exposed
+// so the parser can attach spans generically (by type-asserting a node
+// to the unexported spanSetter interface it satisfies through this
+// pointer-receiver method) instead of every parse function having to
+// thread Span values through its own constructor.
+func (n *baseNode) SetSpan(s Span) { n.span = s }
 
-// WithPos creates a new base node with the given position.
+// WithPos creates a new base node starting at the given position, with no
+// end position recorded. Prefer WithSpan when the node's end position is
+// known (e.g. from the last token consumed while parsing it).
 func WithPos(line, column int) baseNode {
-	return baseNode{pos: SourcePos{Line: line, Column: column}}
+	return baseNode{span: Span{Start: SourcePos{Line: line, Column: column}}}
+}
+
+// WithSpan creates a new base node covering the given start and end
+// positions.
+func WithSpan(start, end SourcePos) baseNode {
+	return baseNode{span: Span{Start: start, End: end}}
+}
+
+// WithRange creates a new base node covering the given start and end
+// line/column pairs, for callers that have raw positions on hand (e.g.
+// from a token pair) rather than already-built SourcePos values. Offset
+// is left zero on both ends; use WithSpan directly when byte offsets
+// matter.
+func WithRange(startLine, startColumn, endLine, endColumn int) baseNode {
+	return WithSpan(
+		SourcePos{Line: startLine, Column: startColumn},
+		SourcePos{Line: endLine, Column: endColumn},
+	)
 }
 
 // ============================================================================
@@ -53,7 +106,8 @@ type IntExpr struct {
 }
 
 func (e *IntExpr) String() string { return strconv.FormatInt(e.Value, 10) }
-func (e *IntExpr) exprNode()      {}
+func (e *IntExpr) TokenLiteral() string { return e.String() }
+func (e *IntExpr) exprNode() {}
 
 // FloatExpr represents a floating-point literal.
 type FloatExpr struct {
@@ -62,7 +116,8 @@ type FloatExpr struct {
 }
 
 func (e *FloatExpr) String() string { return fmt.Sprintf("%g", e.Value) }
-func (e *FloatExpr) exprNode()      {}
+func (e *FloatExpr) TokenLiteral() string { return e.String() }
+func (e *FloatExpr) exprNode() {}
 
 // StringExpr represents a string literal.
 type StringExpr struct {
@@ -79,8 +134,53 @@ func (e *StringExpr) String() string {
 
 	return fmt.Sprintf(`"%s"`, strings.ReplaceAll(e.Value, `"`, `\"`))
 }
+func (e *StringExpr) TokenLiteral() string { return e.String() }
 func (e *StringExpr) exprNode() {}
 
+// StringPart is one piece of an interpolated string literal: either a run
+// of literal text (Expr nil) or an antiquoted "${ ... }" expression
+// (Literal "").
+type StringPart struct {
+	Literal string
+	Expr    Expr
+}
+
+// InterpStringExpr represents a string literal containing at least one
+// "${ ... }" interpolation, as an alternating sequence of literal and
+// expression parts. A literal with no interpolation at all parses to the
+// simpler StringExpr instead.
+type InterpStringExpr struct {
+	baseNode
+	Parts []StringPart
+	// IsIndented indicates if this was a '' string literal
+	IsIndented bool
+}
+
+func (e *InterpStringExpr) String() string {
+	var sb strings.Builder
+
+	quote := `"`
+	if e.IsIndented {
+		quote = "''"
+	}
+
+	sb.WriteString(quote)
+	for _, part := range e.Parts {
+		if part.Expr != nil {
+			sb.WriteString("${")
+			sb.WriteString(part.Expr.String())
+			sb.WriteString("}")
+		} else {
+			sb.WriteString(part.Literal)
+		}
+	}
+	sb.WriteString(quote)
+
+	return sb.String()
+}
+func (e *InterpStringExpr) TokenLiteral() string { return e.String() }
+func (e *InterpStringExpr) exprNode() {}
+
 // BoolExpr represents a boolean literal (true/false).
 type BoolExpr struct {
 	baseNode
@@ -88,7 +188,8 @@ type BoolExpr struct {
 }
 
 func (e *BoolExpr) String() string { return strconv.FormatBool(e.Value) }
-func (e *BoolExpr) exprNode()      {}
+func (e *BoolExpr) TokenLiteral() string { return e.String() }
+func (e *BoolExpr) exprNode() {}
 
 // NullExpr represents the null literal.
 type NullExpr struct {
@@ -96,7 +197,8 @@ type NullExpr struct {
 }
 
 func (e *NullExpr) String() string { return "null" }
-func (e *NullExpr) exprNode()      {}
+func (e *NullExpr) TokenLiteral() string { return "null" }
+func (e *NullExpr) exprNode() {}
 
 // PathExpr represents a path literal.
 type PathExpr struct {
@@ -107,7 +209,38 @@ type PathExpr struct {
 }
 
 func (e *PathExpr) String() string { return e.Value }
-func (e *PathExpr) exprNode()      {}
+func (e *PathExpr) TokenLiteral() string { return e.Value }
+func (e *PathExpr) exprNode() {}
+
+// InterpPathExpr represents a path literal containing at least one
+// "${ ... }" interpolation, e.g. ./foo/${name}.nix, as an alternating
+// sequence of literal and expression parts - the same encoding
+// InterpStringExpr uses for "${ ... }" inside a string. A path literal
+// with no interpolation at all parses to the simpler PathExpr instead.
+type InterpPathExpr struct {
+	baseNode
+	Parts []StringPart
+	// IsAbsolute indicates if the path is absolute (/...) vs relative (./...)
+	IsAbsolute bool
+}
+
+func (e *InterpPathExpr) String() string {
+	var sb strings.Builder
+
+	for _, part := range e.Parts {
+		if part.Expr != nil {
+			sb.WriteString("${")
+			sb.WriteString(part.Expr.String())
+			sb.WriteString("}")
+		} else {
+			sb.WriteString(part.Literal)
+		}
+	}
+
+	return sb.String()
+}
+func (e *InterpPathExpr) TokenLiteral() string { return e.String() }
+func (e *InterpPathExpr) exprNode() {}
 
 // IdentExpr represents an identifier (variable reference).
 type IdentExpr struct {
@@ -116,7 +249,8 @@ type IdentExpr struct {
 }
 
 func (e *IdentExpr) String() string { return e.Name }
-func (e *IdentExpr) exprNode()      {}
+func (e *IdentExpr) TokenLiteral() string { return e.Name }
+func (e *IdentExpr) exprNode() {}
 
 // ============================================================================
 // Compound Expressions
@@ -136,6 +270,7 @@ func (e *ListExpr) String() string {
 
 	return fmt.Sprintf("[ %s ]", strings.Join(elems, " "))
 }
+func (e *ListExpr) TokenLiteral() string { return "[" }
 func (e *ListExpr) exprNode() {}
 
 // AttrSetExpr represents an attribute set { k1 = v1; k2 = v2; ... }.
@@ -170,18 +305,26 @@ func (e *AttrSetExpr) String() string {
 
 	return strings.Join(parts, " ")
 }
+func (e *AttrSetExpr) TokenLiteral() string { return "{" }
 func (e *AttrSetExpr) exprNode() {}
 
 // AttrBinding represents a single binding in an attribute set.
 type AttrBinding struct {
 	Path  []string // Attribute path (e.g., ["a", "b"] for a.b = ...)
 	Value Expr     // The value expression
+	// Span covers the binding as a whole, from its first path segment to
+	// its closing ';' - wider than Value.Span(), so a diagnostic about
+	// the binding itself (e.g. a duplicate attribute name) can point at
+	// "a.b = ..." rather than just the value on the right of '='.
+	Span Span
 }
 
 // InheritClause represents an inherit statement.
 type InheritClause struct {
 	From  Expr     // nil for plain inherit, otherwise the source set
 	Attrs []string // Attribute names to inherit
+	// Span covers the clause as a whole, from 'inherit' to its closing ';'.
+	Span Span
 }
 
 func (i InheritClause) String() string {
@@ -242,6 +385,7 @@ type BinaryExpr struct {
 func (e *BinaryExpr) String() string {
 	return fmt.Sprintf("(%s %s %s)", e.Left, e.Op, e.Right)
 }
+func (e *BinaryExpr) TokenLiteral() string { return e.Op.String() }
 func (e *BinaryExpr) exprNode() {}
 
 // UnaryOp represents a unary operator.
@@ -273,6 +417,7 @@ type UnaryExpr struct {
 func (e *UnaryExpr) String() string {
 	return fmt.Sprintf("(%s%s)", e.Op, e.Expr)
 }
+func (e *UnaryExpr) TokenLiteral() string { return e.Op.String() }
 func (e *UnaryExpr) exprNode() {}
 
 // ============================================================================
@@ -288,31 +433,40 @@ type IfExpr struct {
 }
 
 func (e *IfExpr) String() string {
-	return fmt.Sprintf("if %s then %s else %s", e.Cond, e.Then, e.Else)
+	return fmt.Sprintf("(if %s then %s else %s)", e.Cond, e.Then, e.Else)
 }
+func (e *IfExpr) TokenLiteral() string { return "if" }
 func (e *IfExpr) exprNode() {}
 
 // LetExpr represents a let expression.
 type LetExpr struct {
 	baseNode
-	Bindings []Binding // Variable bindings
-	Body     Expr      // Body expression
+	Bindings []Binding       // Variable bindings
+	Inherits []InheritClause // Inherit statements
+	Body     Expr            // Body expression
 }
 
 func (e *LetExpr) String() string {
 	var bindings []string
+	for _, inherit := range e.Inherits {
+		bindings = append(bindings, inherit.String())
+	}
 	for _, b := range e.Bindings {
 		bindings = append(bindings, fmt.Sprintf("%s = %s;", b.Name, b.Value))
 	}
 
-	return fmt.Sprintf("let %s in %s", strings.Join(bindings, " "), e.Body)
+	return fmt.Sprintf("(let %s in %s)", strings.Join(bindings, " "), e.Body)
 }
+func (e *LetExpr) TokenLiteral() string { return "let" }
 func (e *LetExpr) exprNode() {}
 
 // Binding represents a single binding in a let expression.
 type Binding struct {
 	Name  string
 	Value Expr
+	// Span covers the binding as a whole, from its name to its closing
+	// ';' - see AttrBinding.Span for why this is wider than Value.Span().
+	Span Span
 }
 
 // WithExpr represents a with expression.
@@ -323,8 +477,9 @@ type WithExpr struct {
 }
 
 func (e *WithExpr) String() string {
-	return fmt.Sprintf("with %s; %s", e.Expr, e.Body)
+	return fmt.Sprintf("(with %s; %s)", e.Expr, e.Body)
 }
+func (e *WithExpr) TokenLiteral() string { return "with" }
 func (e *WithExpr) exprNode() {}
 
 // AssertExpr represents an assert expression.
@@ -335,8 +490,9 @@ type AssertExpr struct {
 }
 
 func (e *AssertExpr) String() string {
-	return fmt.Sprintf("assert %s; %s", e.Cond, e.Body)
+	return fmt.Sprintf("(assert %s; %s)", e.Cond, e.Body)
 }
+func (e *AssertExpr) TokenLiteral() string { return "assert" }
 func (e *AssertExpr) exprNode() {}
 
 // ============================================================================
@@ -349,15 +505,17 @@ type FunctionExpr struct {
 	Param   string   // Parameter name (for simple functions)
 	Pattern *Pattern // Parameter pattern (for pattern matching)
 	Body    Expr     // Function body
+	Doc     string   // Doc comment immediately preceding the function, if any
 }
 
 func (e *FunctionExpr) String() string {
 	if e.Pattern != nil {
-		return fmt.Sprintf("%s: %s", e.Pattern, e.Body)
+		return fmt.Sprintf("(%s: %s)", e.Pattern, e.Body)
 	}
 
-	return fmt.Sprintf("%s: %s", e.Param, e.Body)
+	return fmt.Sprintf("(%s: %s)", e.Param, e.Body)
 }
+func (e *FunctionExpr) TokenLiteral() string { return e.Param }
 func (e *FunctionExpr) exprNode() {}
 
 // Pattern represents a function parameter pattern.
@@ -405,6 +563,7 @@ type ApplyExpr struct {
 func (e *ApplyExpr) String() string {
 	return fmt.Sprintf("(%s %s)", e.Func, e.Arg)
 }
+func (e *ApplyExpr) TokenLiteral() string { return e.Func.TokenLiteral() }
 func (e *ApplyExpr) exprNode() {}
 
 // ============================================================================
@@ -427,6 +586,7 @@ func (e *SelectExpr) String() string {
 
 	return s
 }
+func (e *SelectExpr) TokenLiteral() string { return "." }
 func (e *SelectExpr) exprNode() {}
 
 // HasAttrExpr represents attribute existence test (e ? attrpath).
@@ -439,6 +599,7 @@ type HasAttrExpr struct {
 func (e *HasAttrExpr) String() string {
 	return fmt.Sprintf("%s ? %s", e.Expr, strings.Join(e.AttrPath, "."))
 }
+func (e *HasAttrExpr) TokenLiteral() string { return "?" }
 func (e *HasAttrExpr) exprNode() {}
 
 // ============================================================================
@@ -460,4 +621,44 @@ func (e *InheritExpr) String() string {
 
 	return fmt.Sprintf("inherit (%s) %s", e.From, strings.Join(e.Attrs, " "))
 }
+func (e *InheritExpr) TokenLiteral() string { return "inherit" }
 func (e *InheritExpr) exprNode() {}
+
+// ErrorExpr is a placeholder produced by the parser's error-recovery
+// mode in place of a production that failed to parse (what some ASTs,
+// e.g. Go's cmd/compile/internal/syntax, call a BadExpr). It retains
+// the literal text of the tokens skipped while recovering and a
+// diagnostic message, so a partial AST can still be built around a
+// syntax error instead of the whole parse aborting on the first one.
+// See Parser.EnableErrorRecovery.
+type ErrorExpr struct {
+	baseNode
+	Message string
+	Tokens  []string // literal text of the tokens skipped while recovering
+}
+
+func (e *ErrorExpr) String() string       { return fmt.Sprintf("<error: %s>", e.Message) }
+func (e *ErrorExpr) TokenLiteral() string { return "<error>" }
+func (e *ErrorExpr) exprNode()            {}
+
+// File pairs a parsed expression with the source text it was parsed
+// from. ParseIncremental needs the original text to apply a TextEdit
+// and to locate which already-parsed subtrees still cover unedited
+// bytes.
+type File struct {
+	Source string
+	Root   Expr
+}
+
+// String renders the file's root expression in canonical form. Unlike a
+// Monkey-style Program, a Nix file has exactly one top-level expression
+// rather than a sequence of statements, so there's nothing to join - this
+// just delegates to Root, returning "" for a file that failed to parse
+// anything.
+func (f *File) String() string {
+	if f.Root == nil {
+		return ""
+	}
+
+	return f.Root.String()
+}
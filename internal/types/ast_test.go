@@ -0,0 +1,35 @@
+package types
+
+import "testing"
+
+// TestNodeEndMatchesSpanEnd covers the End convenience method added
+// alongside Position: it should always agree with Span().End, for a node
+// built through any of the baseNode constructors.
+func TestNodeEndMatchesSpanEnd(t *testing.T) {
+	n := &IntExpr{Value: 1, baseNode: WithSpan(
+		SourcePos{Line: 1, Column: 0},
+		SourcePos{Line: 1, Column: 3},
+	)}
+
+	if n.End() != n.Span().End {
+		t.Fatalf("End() = %+v, want %+v", n.End(), n.Span().End)
+	}
+	if n.End() != (SourcePos{Line: 1, Column: 3}) {
+		t.Fatalf("End() = %+v, want {Line:1 Column:3}", n.End())
+	}
+}
+
+// TestWithRangeMatchesWithSpan covers WithRange, the raw-int convenience
+// constructor: it should build the exact same baseNode as calling
+// WithSpan directly with the equivalent SourcePos values.
+func TestWithRangeMatchesWithSpan(t *testing.T) {
+	got := WithRange(1, 0, 2, 5)
+	want := WithSpan(
+		SourcePos{Line: 1, Column: 0},
+		SourcePos{Line: 2, Column: 5},
+	)
+
+	if got != want {
+		t.Fatalf("WithRange(1, 0, 2, 5) = %+v, want %+v", got, want)
+	}
+}
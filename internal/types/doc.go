@@ -53,4 +53,24 @@
 //   - String() method for debugging and pretty-printing
 //   - Type safety through Go's type system
 //   - Consistent traversal patterns for evaluation
+//   - Span() method reporting the node's full source range, for
+//     diagnostics and source extraction
+//
+// Binding, AttrBinding, and InheritClause aren't Expr nodes themselves
+// (a binding is a name/value pair, not an evaluable expression) but each
+// carries its own Span covering the whole binding, so a diagnostic about
+// the binding - a duplicate attribute name, say - can point at "a.b = ..."
+// rather than just the value expression to the right of '='.
+//
+// String() renders a canonical, fully-parenthesized form of the subtree
+// rather than reproducing the original source formatting - (1 + (2 * 3)),
+// (if p then a else b) - so it stays stable across re-parses: parsing a
+// node's String() output and calling String() again on the result
+// reproduces the same text. File.String() delegates to its Root.
+//
+// Sexpr and Fdump render a different, field-named form - (BinaryExpr
+// Op=+ Left=(IntExpr Value=1) Right=(IdentExpr Name=x)) - meant for
+// golden strings in table-driven parser tests rather than for looking
+// like Nix source; Fdump's includePos parameter controls whether each
+// node's Span is included.
 package types
@@ -0,0 +1,261 @@
+package types
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Sexpr renders n as a Lisp-style s-expression with field names, e.g.
+// "(BinaryExpr Op=+ Left=(IntExpr Value=1) Right=(IdentExpr Name=x))".
+// Unlike the String() methods (which try to reproduce Nix source), this
+// form is unambiguous about node structure, making it suitable as golden
+// output in table-driven parser tests - modeled on how Starlark's
+// parse_test.go compares parse trees. Source positions are omitted; call
+// Fdump directly with includePos true to pin them instead.
+func Sexpr(n Node) string {
+	var sb strings.Builder
+	Fdump(&sb, n, false)
+	return sb.String()
+}
+
+// Fdump writes n to w in the same form Sexpr returns. When includePos is
+// true, every node gains a leading Pos=startLine:startCol-endLine:endCol
+// field, for tests that care about source positions rather than just
+// tree shape.
+func Fdump(w io.Writer, n Node, includePos bool) {
+	io.WriteString(w, sexpr(n, includePos))
+}
+
+// sexpr is the exhaustive switch over every concrete Expr type, the same
+// convention Walk and pkg/parser/dump.go's dumpExpr use instead of
+// reflection. It panics on any other Node, the same way Walk does, since
+// that only happens if a new Expr type was added without updating this
+// switch.
+func sexpr(n Node, includePos bool) string {
+	switch e := n.(type) {
+	case *IntExpr:
+		return node(e, includePos, fmt.Sprintf("Value=%d", e.Value))
+
+	case *FloatExpr:
+		return node(e, includePos, fmt.Sprintf("Value=%s", strconv.FormatFloat(e.Value, 'g', -1, 64)))
+
+	case *StringExpr:
+		return node(e, includePos,
+			fmt.Sprintf("Value=%s", strconv.Quote(e.Value)),
+			fmt.Sprintf("IsIndented=%t", e.IsIndented))
+
+	case *InterpStringExpr:
+		return node(e, includePos,
+			fmt.Sprintf("IsIndented=%t", e.IsIndented),
+			fmt.Sprintf("Parts=%s", parts(e.Parts, includePos)))
+
+	case *BoolExpr:
+		return node(e, includePos, fmt.Sprintf("Value=%t", e.Value))
+
+	case *NullExpr:
+		return node(e, includePos)
+
+	case *PathExpr:
+		return node(e, includePos,
+			fmt.Sprintf("Value=%s", strconv.Quote(e.Value)),
+			fmt.Sprintf("IsAbsolute=%t", e.IsAbsolute))
+
+	case *InterpPathExpr:
+		return node(e, includePos,
+			fmt.Sprintf("IsAbsolute=%t", e.IsAbsolute),
+			fmt.Sprintf("Parts=%s", parts(e.Parts, includePos)))
+
+	case *IdentExpr:
+		return node(e, includePos, fmt.Sprintf("Name=%s", e.Name))
+
+	case *ListExpr:
+		return node(e, includePos, fmt.Sprintf("Elements=%s", exprList(e.Elements, includePos)))
+
+	case *AttrSetExpr:
+		return node(e, includePos,
+			fmt.Sprintf("Recursive=%t", e.Recursive),
+			fmt.Sprintf("Bindings=%s", attrBindingList(e.Bindings, includePos)),
+			fmt.Sprintf("Inherits=%s", inheritList(e.Inherits, includePos)))
+
+	case *BinaryExpr:
+		return node(e, includePos,
+			fmt.Sprintf("Op=%s", e.Op),
+			fmt.Sprintf("Left=%s", sexpr(e.Left, includePos)),
+			fmt.Sprintf("Right=%s", sexpr(e.Right, includePos)))
+
+	case *UnaryExpr:
+		return node(e, includePos,
+			fmt.Sprintf("Op=%s", e.Op),
+			fmt.Sprintf("Expr=%s", sexpr(e.Expr, includePos)))
+
+	case *IfExpr:
+		return node(e, includePos,
+			fmt.Sprintf("Cond=%s", sexpr(e.Cond, includePos)),
+			fmt.Sprintf("Then=%s", sexpr(e.Then, includePos)),
+			fmt.Sprintf("Else=%s", sexpr(e.Else, includePos)))
+
+	case *LetExpr:
+		return node(e, includePos,
+			fmt.Sprintf("Bindings=%s", bindingList(e.Bindings, includePos)),
+			fmt.Sprintf("Inherits=%s", inheritList(e.Inherits, includePos)),
+			fmt.Sprintf("Body=%s", sexpr(e.Body, includePos)))
+
+	case *WithExpr:
+		return node(e, includePos,
+			fmt.Sprintf("Expr=%s", sexpr(e.Expr, includePos)),
+			fmt.Sprintf("Body=%s", sexpr(e.Body, includePos)))
+
+	case *AssertExpr:
+		return node(e, includePos,
+			fmt.Sprintf("Cond=%s", sexpr(e.Cond, includePos)),
+			fmt.Sprintf("Body=%s", sexpr(e.Body, includePos)))
+
+	case *FunctionExpr:
+		fields := []string{fmt.Sprintf("Param=%s", quoteOrNil(e.Param))}
+		if e.Pattern != nil {
+			fields = append(fields, fmt.Sprintf("Pattern=%s", pattern(e.Pattern)))
+		}
+		fields = append(fields, fmt.Sprintf("Body=%s", sexpr(e.Body, includePos)))
+		return node(e, includePos, fields...)
+
+	case *ApplyExpr:
+		return node(e, includePos,
+			fmt.Sprintf("Func=%s", sexpr(e.Func, includePos)),
+			fmt.Sprintf("Arg=%s", sexpr(e.Arg, includePos)))
+
+	case *SelectExpr:
+		fields := []string{
+			fmt.Sprintf("Expr=%s", sexpr(e.Expr, includePos)),
+			fmt.Sprintf("AttrPath=%s", stringList(e.AttrPath)),
+		}
+		if e.Default != nil {
+			fields = append(fields, fmt.Sprintf("Default=%s", sexpr(e.Default, includePos)))
+		}
+		return node(e, includePos, fields...)
+
+	case *HasAttrExpr:
+		return node(e, includePos,
+			fmt.Sprintf("Expr=%s", sexpr(e.Expr, includePos)),
+			fmt.Sprintf("AttrPath=%s", stringList(e.AttrPath)))
+
+	case *InheritExpr:
+		fields := []string{fmt.Sprintf("From=%s", exprOrNil(e.From, includePos))}
+		fields = append(fields, fmt.Sprintf("Attrs=%s", stringList(e.Attrs)))
+		return node(e, includePos, fields...)
+
+	case *ErrorExpr:
+		return node(e, includePos, fmt.Sprintf("Message=%s", strconv.Quote(e.Message)))
+
+	default:
+		panic(fmt.Sprintf("types.Sexpr: unexpected node type %T", n))
+	}
+}
+
+// node assembles "(TypeName field field ...)", inserting a Pos field
+// right after the type name when includePos is true.
+func node(n Node, includePos bool, fields ...string) string {
+	name := fmt.Sprintf("%T", n)
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimPrefix(name, "*")
+
+	out := make([]string, 0, len(fields)+2)
+	out = append(out, name)
+
+	if includePos {
+		out = append(out, fmt.Sprintf("Pos=%s", posField(n)))
+	}
+
+	out = append(out, fields...)
+
+	return "(" + strings.Join(out, " ") + ")"
+}
+
+func posField(n Node) string {
+	start, end := n.Position(), n.End()
+	return fmt.Sprintf("%d:%d-%d:%d", start.Line, start.Column, end.Line, end.Column)
+}
+
+func exprOrNil(e Expr, includePos bool) string {
+	if e == nil {
+		return "nil"
+	}
+	return sexpr(e, includePos)
+}
+
+func quoteOrNil(s string) string {
+	if s == "" {
+		return "nil"
+	}
+	return s
+}
+
+func stringList(ss []string) string {
+	quoted := make([]string, len(ss))
+	for i, s := range ss {
+		quoted[i] = strconv.Quote(s)
+	}
+	return "[" + strings.Join(quoted, " ") + "]"
+}
+
+func exprList(exprs []Expr, includePos bool) string {
+	rendered := make([]string, len(exprs))
+	for i, e := range exprs {
+		rendered[i] = sexpr(e, includePos)
+	}
+	return "[" + strings.Join(rendered, " ") + "]"
+}
+
+func parts(ps []StringPart, includePos bool) string {
+	rendered := make([]string, len(ps))
+	for i, p := range ps {
+		if p.Expr != nil {
+			rendered[i] = sexpr(p.Expr, includePos)
+		} else {
+			rendered[i] = strconv.Quote(p.Literal)
+		}
+	}
+	return "[" + strings.Join(rendered, " ") + "]"
+}
+
+func attrBindingList(bs []AttrBinding, includePos bool) string {
+	rendered := make([]string, len(bs))
+	for i, b := range bs {
+		rendered[i] = fmt.Sprintf("(Binding Path=%s Value=%s)", stringList(b.Path), sexpr(b.Value, includePos))
+	}
+	return "[" + strings.Join(rendered, " ") + "]"
+}
+
+func bindingList(bs []Binding, includePos bool) string {
+	rendered := make([]string, len(bs))
+	for i, b := range bs {
+		rendered[i] = fmt.Sprintf("(Binding Name=%s Value=%s)", b.Name, sexpr(b.Value, includePos))
+	}
+	return "[" + strings.Join(rendered, " ") + "]"
+}
+
+func inheritList(cs []InheritClause, includePos bool) string {
+	rendered := make([]string, len(cs))
+	for i, c := range cs {
+		rendered[i] = fmt.Sprintf("(Inherit From=%s Attrs=%s)", exprOrNil(c.From, includePos), stringList(c.Attrs))
+	}
+	return "[" + strings.Join(rendered, " ") + "]"
+}
+
+// pattern renders a function parameter Pattern. It never carries source
+// positions of its own (Pattern isn't a Node), so includePos doesn't
+// apply here.
+func pattern(p *Pattern) string {
+	switch p.Type {
+	case IdentPattern:
+		return fmt.Sprintf("(Pattern Type=Ident Name=%s)", p.Name)
+	case AttrSetPattern:
+		return fmt.Sprintf("(Pattern Type=AttrSet Name=%s Attrs=%s Ellipsis=%t)",
+			quoteOrNil(p.Name), stringList(p.Attrs), p.Ellipsis)
+	default:
+		panic(fmt.Sprintf("types.Sexpr: unexpected Pattern type %d", p.Type))
+	}
+}
@@ -0,0 +1,95 @@
+package types
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestSexprRendersFieldNames covers the shape Sexpr is meant to produce:
+// a Lisp-style tree naming every field, stable enough to use as a golden
+// string in a table-driven parser test.
+func TestSexprRendersFieldNames(t *testing.T) {
+	n := &BinaryExpr{
+		Left:  &IntExpr{Value: 1},
+		Op:    OpAdd,
+		Right: &IdentExpr{Name: "x"},
+	}
+
+	got := Sexpr(n)
+	want := `(BinaryExpr Op=+ Left=(IntExpr Value=1) Right=(IdentExpr Name=x))`
+
+	if got != want {
+		t.Fatalf("Sexpr() = %s, want %s", got, want)
+	}
+}
+
+// TestSexprOmitsSourcePos covers the default includePos=false behavior:
+// two otherwise-identical nodes built with different spans must render
+// identically, so golden strings don't need updating every time a
+// position shifts.
+func TestSexprOmitsSourcePos(t *testing.T) {
+	a := &IntExpr{Value: 1, baseNode: WithRange(1, 0, 1, 1)}
+	b := &IntExpr{Value: 1, baseNode: WithRange(5, 2, 5, 3)}
+
+	if Sexpr(a) != Sexpr(b) {
+		t.Fatalf("Sexpr ignoring position: got %s and %s, want them equal", Sexpr(a), Sexpr(b))
+	}
+}
+
+// TestFdumpIncludesSourcePos covers the opt-in Pos field: with
+// includePos true, two nodes at different spans must render
+// differently, and the Pos field itself must match the node's Span.
+func TestFdumpIncludesSourcePos(t *testing.T) {
+	n := &IntExpr{Value: 1, baseNode: WithRange(1, 0, 1, 1)}
+
+	var buf bytes.Buffer
+	Fdump(&buf, n, true)
+
+	want := `(IntExpr Pos=1:0-1:1 Value=1)`
+	if buf.String() != want {
+		t.Fatalf("Fdump() = %s, want %s", buf.String(), want)
+	}
+}
+
+// TestSexprNestedStructures covers the slice/struct fields (bindings and
+// inherits) that don't fit the simple Field=value case.
+func TestSexprNestedStructures(t *testing.T) {
+	n := &LetExpr{
+		Bindings: []Binding{
+			{Name: "a", Value: &IntExpr{Value: 1}},
+		},
+		Inherits: []InheritClause{
+			{Attrs: []string{"b"}},
+		},
+		Body: &IdentExpr{Name: "a"},
+	}
+
+	got := Sexpr(n)
+	want := `(LetExpr Bindings=[(Binding Name=a Value=(IntExpr Value=1))] ` +
+		`Inherits=[(Inherit From=nil Attrs=["b"])] Body=(IdentExpr Name=a))`
+
+	if got != want {
+		t.Fatalf("Sexpr() = %s, want %s", got, want)
+	}
+}
+
+// TestSexprPanicsOnUnknownNodeType covers the exhaustive-switch
+// convention shared with Walk: a Node that isn't one of the concrete
+// Expr types should panic rather than silently rendering nothing.
+func TestSexprPanicsOnUnknownNodeType(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected Sexpr to panic on an unrecognized Node type")
+		}
+	}()
+
+	Sexpr(unknownNode{})
+}
+
+type unknownNode struct{}
+
+func (unknownNode) String() string       { return "" }
+func (unknownNode) TokenLiteral() string { return "" }
+func (unknownNode) Position() SourcePos  { return SourcePos{} }
+func (unknownNode) Span() Span           { return Span{} }
+func (unknownNode) End() SourcePos       { return SourcePos{} }
@@ -0,0 +1,141 @@
+package types
+
+import "fmt"
+
+// Visitor's Visit method is invoked by Walk for each node encountered
+// with Walk(v, node). If the result w is not nil, Walk visits each of
+// node's children with w, then calls w.Visit(nil). Modeled directly on
+// go/ast.Visitor.
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in source order: it calls v.Visit(node), then -
+// if that returned a non-nil Visitor - recurses into node's children
+// with it (in the order they'd appear in the original source, except
+// that an attrset's or let's inherit clauses are all visited before
+// its bindings) before
+// finally calling v.Visit(nil) to signal that node is done. Every
+// concrete Expr produced by pkg/parser is handled; Walk panics on any
+// other Node, the same way go/ast.Walk does, since that only happens if
+// a new Expr type was added without updating Walk.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	// Leaves: nothing to recurse into.
+	case *IntExpr, *FloatExpr, *StringExpr, *BoolExpr, *NullExpr,
+		*PathExpr, *IdentExpr, *ErrorExpr:
+
+	case *InterpStringExpr:
+		for _, part := range n.Parts {
+			if part.Expr != nil {
+				Walk(v, part.Expr)
+			}
+		}
+
+	case *InterpPathExpr:
+		for _, part := range n.Parts {
+			if part.Expr != nil {
+				Walk(v, part.Expr)
+			}
+		}
+
+	case *ListExpr:
+		for _, elem := range n.Elements {
+			Walk(v, elem)
+		}
+
+	case *AttrSetExpr:
+		for _, inherit := range n.Inherits {
+			if inherit.From != nil {
+				Walk(v, inherit.From)
+			}
+		}
+		for _, binding := range n.Bindings {
+			Walk(v, binding.Value)
+		}
+
+	case *BinaryExpr:
+		Walk(v, n.Left)
+		Walk(v, n.Right)
+
+	case *UnaryExpr:
+		Walk(v, n.Expr)
+
+	case *IfExpr:
+		Walk(v, n.Cond)
+		Walk(v, n.Then)
+		Walk(v, n.Else)
+
+	case *LetExpr:
+		for _, inherit := range n.Inherits {
+			if inherit.From != nil {
+				Walk(v, inherit.From)
+			}
+		}
+		for _, b := range n.Bindings {
+			Walk(v, b.Value)
+		}
+		Walk(v, n.Body)
+
+	case *WithExpr:
+		Walk(v, n.Expr)
+		Walk(v, n.Body)
+
+	case *AssertExpr:
+		Walk(v, n.Cond)
+		Walk(v, n.Body)
+
+	case *FunctionExpr:
+		// Pattern carries only attribute names (Pattern.Attrs), not
+		// default-value expressions, so there's nothing under it to walk.
+		Walk(v, n.Body)
+
+	case *ApplyExpr:
+		Walk(v, n.Func)
+		Walk(v, n.Arg)
+
+	case *SelectExpr:
+		Walk(v, n.Expr)
+		if n.Default != nil {
+			Walk(v, n.Default)
+		}
+
+	case *HasAttrExpr:
+		Walk(v, n.Expr)
+
+	case *InheritExpr:
+		if n.From != nil {
+			Walk(v, n.From)
+		}
+
+	default:
+		panic(fmt.Sprintf("types.Walk: unexpected node type %T", node))
+	}
+
+	v.Visit(nil)
+}
+
+// inspector adapts a plain func(Node) bool into a Visitor, the same way
+// go/ast builds Inspect on top of Walk.
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+
+	return nil
+}
+
+// Inspect traverses an AST in source order exactly like Walk, calling
+// fn for each node starting with node itself. Walk stops descending
+// into a node's children as soon as fn returns false for it. This
+// covers the common case of a stateless visit without requiring the
+// caller to implement Visitor directly - mirrors go/ast.Inspect.
+func Inspect(node Node, fn func(Node) bool) {
+	Walk(inspector(fn), node)
+}
@@ -0,0 +1,90 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestInspectVisitsInSourceOrder builds (1 + 2) * 3 by hand and checks
+// Inspect visits the whole tree depth-first, left to right.
+func TestInspectVisitsInSourceOrder(t *testing.T) {
+	one := &IntExpr{Value: 1}
+	two := &IntExpr{Value: 2}
+	three := &IntExpr{Value: 3}
+	sum := &BinaryExpr{Left: one, Op: OpAdd, Right: two}
+	product := &BinaryExpr{Left: sum, Op: OpMul, Right: three}
+
+	var got []Node
+	Inspect(product, func(n Node) bool {
+		if n != nil {
+			got = append(got, n)
+		}
+
+		return true
+	})
+
+	want := []Node{product, sum, one, two, three}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+// TestInspectCanPruneASubtree covers stopping descent into one branch
+// while still visiting the rest of the tree.
+func TestInspectCanPruneASubtree(t *testing.T) {
+	pruned := &IntExpr{Value: 1}
+	kept := &IntExpr{Value: 2}
+	ifExpr := &IfExpr{Cond: &BoolExpr{Value: true}, Then: pruned, Else: kept}
+
+	var visited []Node
+	Inspect(ifExpr, func(n Node) bool {
+		if n == pruned {
+			return false
+		}
+		if n != nil {
+			visited = append(visited, n)
+		}
+
+		return true
+	})
+
+	for _, n := range visited {
+		if n == pruned {
+			t.Fatalf("expected pruned subtree to be skipped, but it was visited")
+		}
+	}
+	if len(visited) == 0 || visited[len(visited)-1] != kept {
+		t.Fatalf("expected kept to still be visited, got %v", visited)
+	}
+}
+
+// TestWalkCallsVisitNilAfterChildren covers the go/ast-style contract:
+// a stateful Visitor sees a nil node once it's done with every child of
+// the node that returned it.
+func TestWalkCallsVisitNilAfterChildren(t *testing.T) {
+	left := &IntExpr{Value: 1}
+	right := &IntExpr{Value: 2}
+	expr := &BinaryExpr{Left: left, Op: OpAdd, Right: right}
+
+	var events []Node
+	rec := recordingVisitor{events: &events}
+	Walk(rec, expr)
+
+	want := []Node{expr, left, nil, right, nil, nil}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("got %v, want %v", events, want)
+	}
+}
+
+type recordingVisitor struct {
+	events *[]Node
+}
+
+func (r recordingVisitor) Visit(n Node) Visitor {
+	*r.events = append(*r.events, n)
+	if n == nil {
+		return nil
+	}
+
+	return r
+}
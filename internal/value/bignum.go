@@ -0,0 +1,118 @@
+package value
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// BigInt is a Nix integer too large to fit in an int64. It only appears
+// when an Evaluator is constructed with eval.WithArbitraryPrecision(true)
+// and ordinary int64 arithmetic would otherwise overflow. Type still
+// reports TypeInt - Nix has one integer type regardless of how gix
+// represents a particular value internally.
+type BigInt struct {
+	Int *big.Int
+}
+
+// NewBigInt wraps i as a Value. i is not copied - callers must not
+// mutate it afterward.
+func NewBigInt(i *big.Int) BigInt { return BigInt{Int: i} }
+
+func (b BigInt) Type() Type     { return TypeInt }
+func (b BigInt) String() string { return b.Int.String() }
+
+func (b BigInt) Equals(v Value) bool {
+	v, err := Force(v)
+	if err != nil {
+		return false
+	}
+
+	other, ok := AsRat(v)
+	if !ok {
+		return false
+	}
+
+	self, _ := AsRat(b)
+
+	return self.Cmp(other) == 0
+}
+
+// Rat is an exact fraction, produced by dividing two Nix integers that
+// don't divide evenly when the Evaluator was constructed with
+// eval.WithArbitraryPrecision(true) - see evalDiv in pkg/eval/operators.go.
+// Type reports TypeFloat since Nix has no rational literal syntax or
+// builtins.typeOf result of its own; a Rat behaves like a Float to user
+// code and is only ever distinguished from one internally, so further
+// arithmetic on a division result stays exact until something demands a
+// concrete Float (String, toString, or a builtin that isn't
+// rational-aware).
+type Rat struct {
+	Rat *big.Rat
+}
+
+// NewRat wraps r as a Value. r is not copied - callers must not mutate
+// it afterward.
+func NewRat(r *big.Rat) Rat { return Rat{Rat: r} }
+
+func (r Rat) Type() Type { return TypeFloat }
+
+func (r Rat) String() string {
+	f, _ := r.Rat.Float64()
+
+	return fmt.Sprintf("%g", f)
+}
+
+func (r Rat) Equals(v Value) bool {
+	v, err := Force(v)
+	if err != nil {
+		return false
+	}
+
+	other, ok := AsRat(v)
+	if !ok {
+		return false
+	}
+
+	return r.Rat.Cmp(other) == 0
+}
+
+// AsRat converts a numeric Value (Int, Float, BigInt, or Rat) to an
+// exact *big.Rat, the common representation CompareNumeric and the
+// BigInt/Rat Equals methods use to compare across all four numeric
+// kinds without a lossy round trip through float64 first. It reports
+// false for NaN/Inf (which have no rational value) and for any
+// non-numeric Value.
+func AsRat(v Value) (*big.Rat, bool) {
+	switch n := v.(type) {
+	case Int:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case Float:
+		r := new(big.Rat)
+		if r.SetFloat64(float64(n)) == nil {
+			return nil, false
+		}
+
+		return r, true
+	case BigInt:
+		return new(big.Rat).SetInt(n.Int), true
+	case Rat:
+		return n.Rat, true
+	default:
+		return nil, false
+	}
+}
+
+// CompareNumeric compares a and b as exact rationals, reporting -1, 0,
+// or 1 the way big.Rat.Cmp does, and ok=false if either isn't numeric
+// (Int, Float, BigInt, or Rat). evalLess/evalGreater in pkg/eval fall
+// back to this once either operand is a BigInt or Rat, where the plain
+// int64/float64 fast paths no longer apply.
+func CompareNumeric(a, b Value) (cmp int, ok bool) {
+	ra, ok1 := AsRat(a)
+	rb, ok2 := AsRat(b)
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+
+	return ra.Cmp(rb), true
+}
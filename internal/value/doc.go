@@ -43,6 +43,12 @@
 //   - Function: User-defined functions with closures
 //   - Builtin: Built-in functions implemented in Go
 //
+// Lazy Types:
+//   - Thunk: A suspended, memoized computation installed for let and
+//     rec { } bindings so they can be bound simultaneously and resolved
+//     on demand; Force resolves a Value that might be a Thunk to its
+//     underlying value
+//
 // The Environment interface provides variable scoping and binding management.
 // It supports lexical scoping with proper closure semantics for functions.
 //
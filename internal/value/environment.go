@@ -1,9 +1,15 @@
 package value
 
+import (
+	"hash/fnv"
+	"sort"
+)
+
 // Env implements the Environment interface with lexical scoping.
 type Env struct {
-	bindings map[string]Value
-	parent   *Env
+	bindings  map[string]Value
+	parent    *Env
+	withScope *Thunk // attrset pushed by an enclosing `with`, or nil
 }
 
 // NewEnv creates a new empty environment.
@@ -13,23 +19,73 @@ func NewEnv() *Env {
 	}
 }
 
-// Get looks up a variable in the environment.
+// Get looks up a variable in the environment. The entire lexical chain
+// (ordinary bindings from let, function parameters, rec attrsets) is
+// searched first; only if that misses are this environment's enclosing
+// with-scopes consulted, innermost first. This matches Nix's rule that
+// `with` bindings are the lowest-priority lookup source - a lexical
+// binding anywhere in scope always wins over any `with`, no matter how
+// deeply nested the `with` is relative to it.
 func (e *Env) Get(name string) (Value, bool) {
+	return e.get(name, nil)
+}
+
+func (e *Env) get(name string, withScopes []*Thunk) (Value, bool) {
 	if val, ok := e.bindings[name]; ok {
 		return val, true
 	}
+	if e.withScope != nil {
+		withScopes = append(withScopes, e.withScope)
+	}
 	if e.parent != nil {
-		return e.parent.Get(name)
+		return e.parent.get(name, withScopes)
+	}
+
+	// Lexical chain exhausted - fall back to with-scopes, innermost
+	// first, forcing each attrset thunk only on demand.
+	for _, scope := range withScopes {
+		attrsVal, err := scope.Force()
+		if err != nil {
+			continue
+		}
+		attrs, ok := attrsVal.(*Attrs)
+		if !ok {
+			continue
+		}
+		if val, ok := attrs.Get(name); ok {
+			return val, true
+		}
 	}
 
 	return nil, false
 }
 
+// PushWith returns a new child environment with attrsThunk attached as a
+// with-scope - the attrset introduced by `with attrsThunk; body`. Unlike
+// Extend, this doesn't make the attrset's keys participate in normal
+// binding lookup; they're only reachable through the with-scope fallback
+// in Get.
+func (e *Env) PushWith(attrsThunk *Thunk) *Env {
+	return &Env{
+		bindings:  make(map[string]Value),
+		parent:    e,
+		withScope: attrsThunk,
+	}
+}
+
 // Set binds a variable in the current environment.
 func (e *Env) Set(name string, value Value) {
 	e.bindings[name] = value
 }
 
+// SetLazy binds name to a thunked value in the current environment. The
+// thunk's expression isn't evaluated until something forces it, which
+// lets simultaneous bindings (let, rec { }) refer to each other
+// regardless of declaration order.
+func (e *Env) SetLazy(name string, thunk *Thunk) {
+	e.bindings[name] = thunk
+}
+
 // Extend creates a new child environment.
 func (e *Env) Extend() Environment {
 	return &Env{
@@ -48,6 +104,75 @@ func (e *Env) WithBindings(bindings map[string]Value) *Env {
 	return child
 }
 
+// Names returns the sorted names bound directly in this environment,
+// not including names only visible through a parent. Used by tools such
+// as the REPL's :b command to report what's currently in scope.
+func (e *Env) Names() []string {
+	names := make([]string, 0, len(e.bindings))
+	for name := range e.bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// AllNames returns the sorted, deduplicated names visible from e: every
+// binding anywhere in its lexical chain (this environment and every
+// parent), plus the keys of any enclosing with-scope attrset that has
+// already been forced. A with-scope thunk that hasn't been evaluated yet
+// is left alone, consistent with the rest of this type never forcing a
+// thunk on a caller's behalf. Unlike Names, which only reports the
+// current frame, this is meant for callers - such as the REPL's
+// completer - that want everything currently reachable by name,
+// regardless of which frame it's bound in.
+func (e *Env) AllNames() []string {
+	seen := make(map[string]bool)
+
+	for cur := e; cur != nil; cur = cur.parent {
+		for name := range cur.bindings {
+			seen[name] = true
+		}
+
+		if cur.withScope != nil && cur.withScope.state == thunkDone && cur.withScope.err == nil {
+			if attrs, ok := cur.withScope.result.(*Attrs); ok {
+				for _, k := range attrs.Keys() {
+					seen[k] = true
+				}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Fingerprint returns a best-effort hash of the names bound across e's
+// entire lexical chain (this environment and every parent up to the
+// root), not the values those names hold. It doesn't uniquely identify
+// an environment's contents - two environments binding the same names
+// to different values hash the same - but it's enough for a consumer
+// like the eval cache (see pkg/eval's SaveCache) to notice the common
+// case where a closure's captured scope has changed shape (a binding
+// added, removed, or renamed) since the closure was cached.
+func (e *Env) Fingerprint() uint64 {
+	h := fnv.New64a()
+	for cur := e; cur != nil; cur = cur.parent {
+		for _, name := range cur.Names() {
+			h.Write([]byte(name))
+			h.Write([]byte{0})
+		}
+		h.Write([]byte{1})
+	}
+
+	return h.Sum64()
+}
+
 // Clone creates a shallow copy of the environment.
 func (e *Env) Clone() *Env {
 	bindings := make(map[string]Value)
@@ -56,7 +181,8 @@ func (e *Env) Clone() *Env {
 	}
 
 	return &Env{
-		bindings: bindings,
-		parent:   e.parent,
+		bindings:  bindings,
+		parent:    e.parent,
+		withScope: e.withScope,
 	}
 }
@@ -0,0 +1,173 @@
+package value
+
+import "errors"
+
+// thunkState tracks how far a Thunk has progressed towards a memoized
+// result, so a second Force call can detect a binding forcing itself
+// (the "blackhole" case, e.g. `let x = x + 1; in x`) instead of recursing
+// forever.
+type thunkState byte
+
+const (
+	thunkPending thunkState = iota
+	thunkInProgress
+	thunkDone
+)
+
+// Thunk is a suspended computation representing a not-yet-evaluated
+// binding. Nix's `let` and `rec { }` bind every name in a scope
+// simultaneously, before any of their values are computed, so a
+// binding's expression must not run until something actually demands
+// its value. Thunk defers that work and memoizes the result so repeated
+// references are only computed once. It implements Value so it can be
+// stored directly in an Env or Attrs alongside ordinary values; callers
+// that inspect a Value should go through Force first to resolve it.
+type Thunk struct {
+	state   thunkState
+	compute func() (Value, error)
+	result  Value
+	err     error
+}
+
+// NewThunk wraps compute in a Thunk that runs it at most once, the
+// first time Force is called.
+func NewThunk(compute func() (Value, error)) *Thunk {
+	return &Thunk{compute: compute}
+}
+
+// Force runs the thunk's computation if it hasn't already, memoizing
+// and returning the result. Calling Force again while the first call is
+// still in progress (a binding that forces itself before producing a
+// value, such as `let x = x + 1; in x`) reports an error rather than
+// recursing forever. A binding that merely returns itself unforced is
+// caught by the package-level Force instead.
+func (t *Thunk) Force() (Value, error) {
+	switch t.state {
+	case thunkDone:
+		return t.result, t.err
+	case thunkInProgress:
+		return nil, errors.New("infinite recursion encountered")
+	}
+
+	t.state = thunkInProgress
+	t.result, t.err = t.compute()
+	t.state = thunkDone
+	t.compute = nil // release the closure and whatever it captured
+
+	return t.result, t.err
+}
+
+// Type forces the thunk and returns the resulting value's type.
+func (t *Thunk) Type() Type {
+	v, err := t.Force()
+	if err != nil {
+		return TypeNull
+	}
+
+	return v.Type()
+}
+
+// String forces the thunk and returns the resulting value's string form.
+func (t *Thunk) String() string {
+	v, err := t.Force()
+	if err != nil {
+		return "<error: " + err.Error() + ">"
+	}
+
+	return v.String()
+}
+
+// Equals forces the thunk and delegates to the resulting value.
+func (t *Thunk) Equals(v Value) bool {
+	forced, err := t.Force()
+	if err != nil {
+		return false
+	}
+
+	return forced.Equals(v)
+}
+
+// Force resolves v to a non-Thunk Value, forcing it (and any thunk it
+// in turn resolves to) as needed. It's a no-op for values that are
+// already evaluated. Every operator, builtin, or other code that
+// inspects a Value's concrete type should call Force on it first, since
+// lazy bindings (let, rec { }) hand out Thunks rather than values.
+//
+// A binding like `let x = x; in x` doesn't recurse synchronously - its
+// Thunk's compute() returns the very same (still-unforced) Thunk as its
+// result, rather than calling Force on it - so Thunk.state's
+// thunkInProgress check never sees it; the Thunk finishes thunkDone with
+// result pointing at itself, and naively unwrapping it here would spin
+// forever. Force instead tracks every Thunk pointer it has already
+// unwrapped and reports the same "infinite recursion" error as soon as
+// one reappears, which also catches longer cycles like
+// `let x = y; y = x; in x`.
+func Force(v Value) (Value, error) {
+	var seen map[*Thunk]struct{}
+
+	for {
+		t, ok := v.(*Thunk)
+		if !ok {
+			return v, nil
+		}
+
+		if _, dup := seen[t]; dup {
+			return nil, errors.New("infinite recursion encountered")
+		}
+		if seen == nil {
+			seen = make(map[*Thunk]struct{})
+		}
+		seen[t] = struct{}{}
+
+		forced, err := t.Force()
+		if err != nil {
+			return nil, err
+		}
+		v = forced
+	}
+}
+
+// DeepForce forces v and, for an *Attrs or *List, every value reachable
+// from it - the way `nix-instantiate --strict` or a REPL's :print forces
+// a whole result before printing instead of leaving nested thunks
+// unevaluated. Attrs are updated in place via Set; List's elements field
+// is unexported, so a list's forced elements come back as a new *List
+// built with NewList rather than a mutated original.
+func DeepForce(v Value) (Value, error) {
+	forced, err := Force(v)
+	if err != nil {
+		return nil, err
+	}
+
+	switch val := forced.(type) {
+	case *Attrs:
+		for _, k := range val.Keys() {
+			child, _ := val.Get(k)
+
+			childForced, err := DeepForce(child)
+			if err != nil {
+				return nil, err
+			}
+
+			val.Set(k, childForced)
+		}
+
+		return val, nil
+
+	case *List:
+		elems := val.Elements()
+		out := make([]Value, len(elems))
+
+		for i, e := range elems {
+			out[i], err = DeepForce(e)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return NewList(out...), nil
+
+	default:
+		return forced, nil
+	}
+}
@@ -0,0 +1,50 @@
+package value
+
+import "testing"
+
+// TestForceDetectsSelfReferentialThunk exercises the actual Force unwrap
+// loop, not just Thunk.Force's synchronous re-entrancy check: the thunk's
+// compute returns the thunk itself as its result (the shape `let x = x;
+// in x` produces, since evalIdent hands back the still-unforced binding
+// rather than calling Force on it), so by the time Force sees it the
+// thunk is already thunkDone with result pointing at itself. Without
+// cycle tracking in Force's loop, unwrapping that never terminates.
+func TestForceDetectsSelfReferentialThunk(t *testing.T) {
+	var self *Thunk
+	self = NewThunk(func() (Value, error) { return self, nil })
+
+	_, err := Force(self)
+	if err == nil {
+		t.Fatal("expected Force to report an error instead of looping forever")
+	}
+}
+
+// TestForceDetectsMutualReferenceCycle covers a longer cycle than a
+// thunk pointing directly at itself, e.g. `let x = y; y = x; in x`.
+func TestForceDetectsMutualReferenceCycle(t *testing.T) {
+	var a, b *Thunk
+	a = NewThunk(func() (Value, error) { return b, nil })
+	b = NewThunk(func() (Value, error) { return a, nil })
+
+	_, err := Force(a)
+	if err == nil {
+		t.Fatal("expected Force to report an error instead of looping forever")
+	}
+}
+
+// TestForceResolvesAcyclicChain covers the non-cycle case: a chain of
+// thunks that terminates in a concrete value must still resolve to it,
+// since Force's cycle tracking shouldn't mistake a deep chain for a
+// cycle.
+func TestForceResolvesAcyclicChain(t *testing.T) {
+	inner := NewThunk(func() (Value, error) { return Int(42), nil })
+	outer := NewThunk(func() (Value, error) { return inner, nil })
+
+	got, err := Force(outer)
+	if err != nil {
+		t.Fatalf("Force returned unexpected error: %v", err)
+	}
+	if got != Int(42) {
+		t.Fatalf("Force() = %v, want 42", got)
+	}
+}
@@ -22,6 +22,35 @@ const (
 	TypeBuiltin
 )
 
+// String returns a human-readable name for the type, used by diagnostics
+// and the REPL's :t command (e.g. "int", "list", "function").
+func (t Type) String() string {
+	switch t {
+	case TypeNull:
+		return "null"
+	case TypeBool:
+		return "bool"
+	case TypeInt:
+		return "int"
+	case TypeFloat:
+		return "float"
+	case TypeString:
+		return "string"
+	case TypePath:
+		return "path"
+	case TypeList:
+		return "list"
+	case TypeAttrs:
+		return "attrs"
+	case TypeFunction:
+		return "function"
+	case TypeBuiltin:
+		return "builtin"
+	default:
+		return fmt.Sprintf("Type(%d)", byte(t))
+	}
+}
+
 // Value is the interface all Nix values must implement.
 type Value interface {
 	Type() Type
@@ -35,6 +64,10 @@ type Null struct{}
 func (Null) Type() Type     { return TypeNull }
 func (Null) String() string { return "null" }
 func (Null) Equals(v Value) bool {
+	v, err := Force(v)
+	if err != nil {
+		return false
+	}
 	_, ok := v.(Null)
 
 	return ok
@@ -46,6 +79,10 @@ type Bool bool
 func (b Bool) Type() Type     { return TypeBool }
 func (b Bool) String() string { return fmt.Sprintf("%t", b) }
 func (b Bool) Equals(v Value) bool {
+	v, err := Force(v)
+	if err != nil {
+		return false
+	}
 	other, ok := v.(Bool)
 
 	return ok && b == other
@@ -57,6 +94,10 @@ type Int int64
 func (i Int) Type() Type     { return TypeInt }
 func (i Int) String() string { return fmt.Sprintf("%d", i) }
 func (i Int) Equals(v Value) bool {
+	v, err := Force(v)
+	if err != nil {
+		return false
+	}
 	other, ok := v.(Int)
 
 	return ok && i == other
@@ -68,20 +109,103 @@ type Float float64
 func (f Float) Type() Type     { return TypeFloat }
 func (f Float) String() string { return fmt.Sprintf("%g", f) }
 func (f Float) Equals(v Value) bool {
+	v, err := Force(v)
+	if err != nil {
+		return false
+	}
 	other, ok := v.(Float)
 
 	return ok && f == other
 }
 
-// String represents a string value.
-type String string
+// StringContextElem is one entry in a String's Context, recording why
+// the string depends on a store path: either it references a named
+// output of a derivation (DrvPath set) or it references a source path
+// added to the store as-is (Path set).
+type StringContextElem struct {
+	DrvPath string // e.g. "/nix/store/xxx-foo.drv"; empty for a plain path reference
+	Output  string // output name within DrvPath, e.g. "out"; only meaningful when DrvPath != ""
+	Path    string // plain source path this entry references; set when DrvPath is empty
+}
+
+// StringContext records which derivation outputs or source paths a
+// String's contents depend on. Entries are keyed by DrvContextElem/
+// PathContextElem so that referencing two different outputs of the same
+// derivation produces two entries instead of one overwriting the other.
+type StringContext map[string]StringContextElem
+
+// DrvContextElem returns the (key, element) pair for a context entry
+// referencing the named output of a derivation.
+func DrvContextElem(drvPath, output string) (string, StringContextElem) {
+	return drvPath + "!" + output, StringContextElem{DrvPath: drvPath, Output: output}
+}
+
+// PathContextElem returns the (key, element) pair for a context entry
+// referencing a plain source path.
+func PathContextElem(path string) (string, StringContextElem) {
+	return path, StringContextElem{Path: path}
+}
+
+// UnionContext merges two string contexts, used when an operation (such
+// as string concatenation) produces a result that depends on everything
+// either input did. Either argument may be nil.
+func UnionContext(a, b StringContext) StringContext {
+	if len(a) == 0 {
+		return b
+	}
+	if len(b) == 0 {
+		return a
+	}
+
+	out := make(StringContext, len(a)+len(b))
+	for k, v := range a {
+		out[k] = v
+	}
+	for k, v := range b {
+		out[k] = v
+	}
+
+	return out
+}
+
+// String represents a string value. Raw is its textual content;
+// Context records which derivations or source paths it was built from,
+// the way real Nix tracks string contexts so that passing a string
+// built from a derivation's output to `derivation` discovers that
+// dependency automatically instead of requiring it to be declared by
+// hand.
+type String struct {
+	Raw     string
+	Context StringContext
+}
+
+// NewString creates a context-free string, the common case for string
+// literals and values produced by ordinary string builtins.
+func NewString(s string) String { return String{Raw: s} }
+
+// NewStringWithContext creates a string carrying the given context,
+// used where a result is derived from context-bearing inputs (string
+// concatenation, toString on a path or derivation attribute).
+func NewStringWithContext(s string, ctx StringContext) String {
+	return String{Raw: s, Context: ctx}
+}
 
 func (s String) Type() Type     { return TypeString }
-func (s String) String() string { return fmt.Sprintf(`"%s"`, string(s)) }
+func (s String) String() string { return fmt.Sprintf(`"%s"`, s.Raw) }
+
+// HasContext reports whether s carries any dependency context.
+func (s String) HasContext() bool { return len(s.Context) > 0 }
+
 func (s String) Equals(v Value) bool {
+	v, err := Force(v)
+	if err != nil {
+		return false
+	}
 	other, ok := v.(String)
 
-	return ok && s == other
+	// Like upstream Nix, string equality compares only the contents -
+	// context is provenance metadata, not part of the value itself.
+	return ok && s.Raw == other.Raw
 }
 
 // Path represents a path value.
@@ -90,6 +214,10 @@ type Path string
 func (p Path) Type() Type     { return TypePath }
 func (p Path) String() string { return string(p) }
 func (p Path) Equals(v Value) bool {
+	v, err := Force(v)
+	if err != nil {
+		return false
+	}
 	other, ok := v.(Path)
 
 	return ok && p == other
@@ -126,11 +254,19 @@ func (l *List) String() string {
 }
 
 func (l *List) Equals(v Value) bool {
+	v, err := Force(v)
+	if err != nil {
+		return false
+	}
 	other, ok := v.(*List)
 	if !ok || len(l.elems) != len(other.elems) {
 		return false
 	}
 	for i, e := range l.elems {
+		// Elements may themselves be unforced thunks (list elements are
+		// lazy); Value.Equals implementations force their argument, and
+		// Thunk.Equals forces the receiver, so this forces both sides
+		// regardless of which one is still a thunk.
 		if !e.Equals(other.elems[i]) {
 			return false
 		}
@@ -197,13 +333,17 @@ func (a *Attrs) String() string {
 }
 
 func (a *Attrs) Equals(v Value) bool {
+	v, err := Force(v)
+	if err != nil {
+		return false
+	}
 	other, ok := v.(*Attrs)
 	if !ok || len(a.attrs) != len(other.attrs) {
 		return false
 	}
-	for k, v := range a.attrs {
+	for k, val := range a.attrs {
 		otherV, ok := other.attrs[k]
-		if !ok || !v.Equals(otherV) {
+		if !ok || !val.Equals(otherV) {
 			return false
 		}
 	}
@@ -216,6 +356,7 @@ type Function struct {
 	param string
 	body  interface{} // AST node
 	env   Environment
+	doc   string
 }
 
 // NewFunction creates a new function.
@@ -230,26 +371,67 @@ func (f *Function) Param() string     { return f.param }
 func (f *Function) Body() interface{} { return f.body }
 func (f *Function) Env() Environment  { return f.env }
 
+// Doc returns the /** ... */ doc comment captured from the source
+// immediately preceding this function's definition, or "" if it has
+// none.
+func (f *Function) Doc() string { return f.doc }
+
+// SetDoc attaches a doc comment to the function. Separate from
+// NewFunction since the evaluator only knows a function's doc comment
+// (carried on its types.FunctionExpr) after the Function has already
+// been constructed from the AST node.
+func (f *Function) SetDoc(doc string) { f.doc = doc }
+
+// ParamDoc documents a single parameter of a Builtin, for introspection
+// via the doc built-in.
+type ParamDoc struct {
+	Name string
+	Doc  string
+}
+
 // Builtin represents a built-in function.
 type Builtin struct {
-	name string
-	fn   func([]Value) (Value, error)
+	name    string
+	fn      func([]Value) (Value, error)
+	doc     string
+	params  []ParamDoc
+	example string
 }
 
-// NewBuiltin creates a new builtin function.
+// NewBuiltin creates a new builtin function with no documentation
+// attached.
 func NewBuiltin(name string, fn func([]Value) (Value, error)) *Builtin {
 	return &Builtin{name: name, fn: fn}
 }
 
+// NewDocumentedBuiltin creates a builtin function along with the
+// documentation the doc built-in and `gix doc` surface for it.
+func NewDocumentedBuiltin(
+	name string,
+	fn func([]Value) (Value, error),
+	doc string,
+	params []ParamDoc,
+	example string,
+) *Builtin {
+	return &Builtin{name: name, fn: fn, doc: doc, params: params, example: example}
+}
+
 func (b *Builtin) Type() Type     { return TypeBuiltin }
 func (b *Builtin) String() string { return fmt.Sprintf("<BUILTIN %s>", b.name) }
 func (b *Builtin) Equals(v Value) bool {
+	v, err := Force(v)
+	if err != nil {
+		return false
+	}
 	other, ok := v.(*Builtin)
 
 	return ok && b.name == other.name
 }
 func (b *Builtin) Name() string                      { return b.name }
 func (b *Builtin) Apply(args []Value) (Value, error) { return b.fn(args) }
+func (b *Builtin) Doc() string                        { return b.doc }
+func (b *Builtin) Params() []ParamDoc                 { return b.params }
+func (b *Builtin) Example() string                    { return b.example }
 
 // Environment represents variable bindings.
 type Environment interface {
@@ -263,5 +445,5 @@ func MakeNull() Value           { return Null{} }
 func MakeBool(b bool) Value     { return Bool(b) }
 func MakeInt(i int64) Value     { return Int(i) }
 func MakeFloat(f float64) Value { return Float(f) }
-func MakeString(s string) Value { return String(s) }
+func MakeString(s string) Value { return NewString(s) }
 func MakePath(p string) Value   { return Path(p) }
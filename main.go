@@ -28,16 +28,23 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/conneroisu/gix/internal/types"
+	"github.com/conneroisu/gix/internal/value"
+	"github.com/conneroisu/gix/pkg/derivation"
 	"github.com/conneroisu/gix/pkg/eval"
+	"github.com/conneroisu/gix/pkg/format"
 	"github.com/conneroisu/gix/pkg/lexer"
+	"github.com/conneroisu/gix/pkg/options"
 	"github.com/conneroisu/gix/pkg/parser"
+	"github.com/conneroisu/gix/pkg/repl"
 )
 
 // main is the entry point for the gix CLI.
@@ -48,13 +55,27 @@ import (
 //   - Interactive mode: starts a REPL session
 //   - File mode: evaluates a Nix file
 //   - Default: shows help if no arguments provided
+//
+// -e/-i/-h and evaluation-shaping flags (--json, --strict, --attr, -I,
+// --arg, ...) are parsed together: --arg/--argstr take two tokens each,
+// which flag.FlagSet can't express on its own, so opts.ExtractArgs pulls
+// them out of os.Args before the FlagSet sees the rest.
 func main() {
+	opts := options.New()
+
+	rest, err := opts.ExtractArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gix: %v\n", err)
+		os.Exit(1)
+	}
+
 	var (
 		interactive = flag.Bool("i", false, "Interactive REPL mode")
 		expression  = flag.String("e", "", "Evaluate expression")
 		help        = flag.Bool("h", false, "Show help")
 	)
-	flag.Parse()
+	opts.RegisterFlags(flag.CommandLine)
+	_ = flag.CommandLine.Parse(rest)
 
 	if *help {
 		showHelp()
@@ -62,16 +83,25 @@ func main() {
 		return
 	}
 
-	if *expression != "" {
+	switch {
+	case opts.ReadFrom != "":
+		evalFile(opts.ReadFrom, opts)
+	case *expression != "":
 		// Evaluate expression from command line
-		evalExpression(*expression, ".")
-	} else if *interactive {
+		evalExpression(*expression, ".", opts)
+	case *interactive || flag.Arg(0) == "repl":
 		// Start REPL
 		startREPL()
-	} else if flag.NArg() > 0 {
+	case flag.Arg(0) == "build":
+		buildCommand(flag.Args()[1:])
+	case flag.Arg(0) == "doc":
+		docCommand(flag.Args()[1:])
+	case flag.Arg(0) == "fmt":
+		fmtCommand(flag.Args()[1:])
+	case flag.NArg() > 0:
 		// Evaluate file
-		evalFile(flag.Arg(0))
-	} else {
+		evalFile(flag.Arg(0), opts)
+	default:
 		showHelp()
 	}
 }
@@ -87,30 +117,218 @@ func showHelp() {
 	fmt.Println("  gix [options] [file]")
 	fmt.Println()
 	fmt.Println("Options:")
-	fmt.Println("  -i          Interactive REPL mode")
-	fmt.Println("  -e EXPR     Evaluate expression")
-	fmt.Println("  -h          Show this help")
+	fmt.Println("  -i             Interactive REPL mode")
+	fmt.Println("  -e EXPR        Evaluate expression")
+	fmt.Println("  -h             Show this help")
+	fmt.Println("  -strict        Deep-force the result before printing")
+	fmt.Println("  -json          Print the result as JSON")
+	fmt.Println("  -xml           Print the result as XML")
+	fmt.Println("  -parse         Pretty-print the AST instead of evaluating")
+	fmt.Println("  -reduce        Run the optimizer before evaluating")
+	fmt.Println("  -attr PATH     Select a dotted attribute path from the result")
+	fmt.Println("  -read-from F   Read the expression to evaluate from file F")
+	fmt.Println("  -I PATH        Add a search path for <...> imports (accumulates)")
+	fmt.Println("  --arg NAME EXPR      Bind NAME to the evaluated Nix expression EXPR")
+	fmt.Println("  --argstr NAME VALUE  Bind NAME to the literal string VALUE")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  gix -e '1 + 2'")
 	fmt.Println("  gix -i")
+	fmt.Println("  gix repl")
 	fmt.Println("  gix file.nix")
+	fmt.Println("  gix -json -attr hello --arg x 1 file.nix")
+	fmt.Println("  gix build drv.nix")
+	fmt.Println("  gix build -dry-run drv.nix")
+	fmt.Println("  gix doc lib.nix lib.strings")
+	fmt.Println("  gix fmt file.nix")
+	fmt.Println("  gix fmt -write file.nix")
 }
 
-// evalExpression evaluates a single Nix expression and prints the result.
-//
-// This function implements the complete evaluation pipeline:
+// fmtCommand implements `gix fmt <file.nix>`: it parses file and prints
+// format.Format's canonical rendering to stdout, or back to the file
+// itself with -write. See pkg/format for what "canonical" covers today
+// - notably not comment preservation.
+func fmtCommand(args []string) {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := fs.Bool("write", false, "Overwrite the file with its formatted form instead of printing to stdout")
+	_ = fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "usage: gix fmt [-write] <file.nix>")
+		os.Exit(1)
+	}
+
+	filename := fs.Arg(0)
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+		os.Exit(1)
+	}
+
+	l := lexer.New(string(content))
+	p := parser.NewWithFilename(l, filename)
+	ast, err := p.Parse()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Parse error: %v\n", err)
+		os.Exit(1)
+	}
+
+	formatted := format.Format(ast)
+
+	if *write {
+		if err := os.WriteFile(filename, []byte(formatted+"\n"), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing file: %v\n", err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	fmt.Println(formatted)
+}
+
+// docEntry is one documented lambda found while walking an attrset tree
+// for the doc command, serialized directly to JSON by -json.
+type docEntry struct {
+	Path    string   `json:"path"`
+	Content string   `json:"content"`
+	Params  []string `json:"params"`
+	Line    int      `json:"line,omitempty"`
+	Column  int      `json:"column,omitempty"`
+}
+
+// docCommand implements `gix doc <file.nix> <attr.path>`: it evaluates
+// file, walks down attr.path (dot-separated) to find the starting
+// point, then recursively walks every nested attribute set from there,
+// collecting one docEntry per function it finds - documented or not, so
+// callers can see what's still missing docs.
+func docCommand(args []string) {
+	fs := flag.NewFlagSet("doc", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Emit JSON instead of Markdown")
+	_ = fs.Parse(args)
+
+	if fs.NArg() < 2 {
+		fmt.Fprintln(os.Stderr, "usage: gix doc [-json] <file.nix> <attr.path>")
+		os.Exit(1)
+	}
+
+	filename, attrPath := fs.Arg(0), fs.Arg(1)
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+		os.Exit(1)
+	}
+
+	l := lexer.New(string(content))
+	p := parser.NewWithFilename(l, filename)
+	ast, err := p.Parse()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Parse error: %v\n", err)
+		os.Exit(1)
+	}
+
+	e := eval.New(filepath.Dir(filename))
+	result, err := e.Eval(ast)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Evaluation error: %v\n", err)
+		os.Exit(1)
+	}
+
+	target := result
+	for _, name := range strings.Split(attrPath, ".") {
+		attrs, ok := target.(*value.Attrs)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "gix doc: %s is not an attribute set\n", attrPath)
+			os.Exit(1)
+		}
+
+		val, ok := attrs.Get(name)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "gix doc: attribute %q not found\n", name)
+			os.Exit(1)
+		}
+
+		target, err = value.Force(val)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Evaluation error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var entries []docEntry
+	walkDocTree(attrPath, target, &entries)
+
+	if *asJSON {
+		out, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gix doc: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(out))
+
+		return
+	}
+
+	for _, entry := range entries {
+		fmt.Printf("## %s\n\n", entry.Path)
+		if entry.Content != "" {
+			fmt.Printf("%s\n\n", entry.Content)
+		} else {
+			fmt.Println("_(undocumented)_")
+		}
+		if len(entry.Params) > 0 {
+			fmt.Printf("Params: %s\n\n", strings.Join(entry.Params, ", "))
+		}
+	}
+}
+
+// walkDocTree recursively collects a docEntry for every function
+// reachable from val, qualifying each with its dotted path from the
+// original attr.path given on the command line.
+func walkDocTree(path string, val value.Value, entries *[]docEntry) {
+	switch v := val.(type) {
+	case *value.Function:
+		entry := docEntry{Path: path, Content: v.Doc(), Params: []string{v.Param()}}
+		if node, ok := v.Body().(types.Node); ok {
+			pos := node.Position()
+			entry.Line, entry.Column = pos.Line, pos.Column
+		}
+		*entries = append(*entries, entry)
+
+	case *value.Builtin:
+		params := make([]string, len(v.Params()))
+		for i, p := range v.Params() {
+			params[i] = p.Name
+		}
+		*entries = append(*entries, docEntry{Path: path, Content: v.Doc(), Params: params})
+
+	case *value.Attrs:
+		for _, name := range v.Keys() {
+			child, _ := v.Get(name)
+			child, err := value.Force(child)
+			if err != nil {
+				continue
+			}
+			walkDocTree(path+"."+name, child, entries)
+		}
+	}
+}
+
+// evalExpression evaluates a single Nix expression and prints the
+// result, shaped by opts:
 //  1. Lexical analysis to tokenize the input string
-//  2. Syntactic analysis to build an Abstract Syntax Tree (AST)
-//  3. Semantic evaluation to compute the final value
-//  4. Pretty-printing of the result
-//
-// Parameters:
-//   - expr: The Nix expression string to evaluate
-//   - baseDir: The base directory for resolving relative paths
+//  2. Syntactic analysis to build an Abstract Syntax Tree (AST) - if
+//     opts.ParseOnly, pretty-print it and stop here
+//  3. Semantic evaluation to compute the final value, with opts.Reduce
+//     enabling the constant-folding optimizer
+//  4. opts.Attr/--arg/--argstr selection and application via
+//     FindAlongAttrPath/AutoApply, then opts.Strict deep-forcing
+//  5. Printing the result as Nix syntax, JSON, or XML per opts
 //
 // If any step fails, the function prints an error message and exits with status 1.
-func evalExpression(expr string, baseDir string) {
+func evalExpression(expr string, baseDir string, opts *options.Options) {
 	// Tokenize the input expression
 	l := lexer.New(expr)
 
@@ -122,16 +340,64 @@ func evalExpression(expr string, baseDir string) {
 		os.Exit(1)
 	}
 
+	if opts.ParseOnly {
+		fmt.Println(format.Format(ast))
+
+		return
+	}
+
 	// Evaluate the AST to produce a value
-	e := eval.New(baseDir)
+	e := eval.New(baseDir, eval.WithOptimize(opts.Reduce))
+
+	autoArgs, err := opts.AutoArgs(e)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Evaluation error: %v\n", err)
+		os.Exit(1)
+	}
+
 	result, err := e.Eval(ast)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Evaluation error: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Display the result
-	fmt.Println(result.String())
+	switch {
+	case opts.Attr != "":
+		result, err = e.FindAlongAttrPath(result, opts.Attr, autoArgs)
+	case autoArgs.Len() > 0:
+		result, err = e.AutoApply(result, autoArgs)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Evaluation error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if opts.Strict {
+		result, err = value.DeepForce(result)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Evaluation error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	switch {
+	case opts.JSON:
+		out, err := options.RenderJSON(result)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gix: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(out)
+	case opts.XML:
+		out, err := options.RenderXML(result)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gix: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(out)
+	default:
+		fmt.Println(result.String())
+	}
 }
 
 // evalFile reads and evaluates a Nix file from the filesystem.
@@ -142,9 +408,10 @@ func evalExpression(expr string, baseDir string) {
 //
 // Parameters:
 //   - filename: Path to the Nix file to evaluate
+//   - opts: output/evaluation shaping flags, forwarded to evalExpression
 //
 // If the file cannot be read, the function prints an error and exits with status 1.
-func evalFile(filename string) {
+func evalFile(filename string, opts *options.Options) {
 	// Read the entire file content
 	content, err := os.ReadFile(filename)
 	if err != nil {
@@ -154,104 +421,93 @@ func evalFile(filename string) {
 
 	// Use the file's directory as the base for path resolution
 	baseDir := filepath.Dir(filename)
-	evalExpression(string(content), baseDir)
+	evalExpression(string(content), baseDir, opts)
 }
 
 // startREPL starts an interactive Read-Eval-Print Loop for the Nix interpreter.
 //
 // The REPL provides an interactive environment where users can:
-//   - Enter Nix expressions line by line
+//   - Enter Nix expressions, including ones spanning multiple lines
 //   - See immediate evaluation results
-//   - Use special commands (prefixed with ':')
+//   - Use special commands (prefixed with ':') to inspect types, bindings,
+//     and pretty-printed values, or to load a file
 //   - Maintain state across multiple evaluations
 //
 // The REPL continues until the user types ":quit", ":q", or sends EOF (Ctrl+D).
-// Each expression is evaluated in the same environment, so variable bindings
-// persist across lines.
-//
-// Special commands:
-//   - :quit, :q  - Exit the REPL
-//   - :help, :h  - Show available commands
+// See pkg/repl for the implementation and the full list of meta-commands.
 func startREPL() {
-	fmt.Println("gix repl - Type :quit to exit")
-	fmt.Println()
-
-	// Create a scanner for reading user input line by line
-	scanner := bufio.NewScanner(os.Stdin)
-
-	// Create a single evaluator instance to maintain state across evaluations
-	e := eval.New(".")
-
-	for {
-		// Display the prompt and wait for input
-		fmt.Print("nix-repl> ")
-		if !scanner.Scan() {
-			// EOF or error, break the loop
-			break
-		}
-
-		// Get and clean up the input line
-		line := scanner.Text()
-		line = strings.TrimSpace(line)
+	repl.New(os.Stdin, os.Stdout, ".").Run()
+}
 
-		// Skip empty lines
-		if line == "" {
-			continue
-		}
+// buildCommand implements `gix build <expr.nix>`: it evaluates the
+// given file to an attribute set, converts it to a derivation.FromAttrs
+// and realises it with a Builder.
+//
+// Only the target derivation itself is built, not its InputDrvs - the
+// evaluator currently only records input derivations as store paths
+// (see derivation.Derivation.InputDrvs), not as the Derivation values
+// BuildOrder/BuildAll need to resolve a dependency graph, so recursive
+// builds aren't wired up yet. Once derivations carry references to
+// their actual input Derivation values, this can call derivation.BuildAll
+// instead of Builder.Build directly.
+func buildCommand(args []string) {
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Print what would be built instead of building it")
+	_ = fs.Parse(args)
 
-		// Handle quit commands
-		if line == ":quit" || line == ":q" {
-			break
-		}
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "usage: gix build [-dry-run] <file.nix>")
+		os.Exit(1)
+	}
 
-		// Handle other REPL commands (prefixed with ':')
-		if strings.HasPrefix(line, ":") {
-			handleReplCommand(line)
+	filename := fs.Arg(0)
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+		os.Exit(1)
+	}
 
-			continue
-		}
+	l := lexer.New(string(content))
+	p := parser.NewWithFilename(l, filename)
+	ast, err := p.Parse()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Parse error: %v\n", err)
+		os.Exit(1)
+	}
 
-		// Parse and evaluate the Nix expression
-		l := lexer.New(line)
-		p := parser.New(l)
-		ast, err := p.Parse()
-		if err != nil {
-			fmt.Printf("Parse error: %v\n", err)
+	e := eval.New(filepath.Dir(filename))
+	result, err := e.Eval(ast)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Evaluation error: %v\n", err)
+		os.Exit(1)
+	}
 
-			continue
-		}
+	attrs, ok := result.(*value.Attrs)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "gix build: expression must evaluate to a derivation, got %v\n", result.Type())
+		os.Exit(1)
+	}
 
-		result, err := e.Eval(ast)
-		if err != nil {
-			fmt.Printf("Evaluation error: %v\n", err)
+	drv, err := derivation.FromAttrs(attrs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gix build: %v\n", err)
+		os.Exit(1)
+	}
 
-			continue
-		}
+	var builder derivation.Builder
+	if *dryRun {
+		builder = derivation.NewDryRunBuilder(os.Stdout)
+	} else {
+		builder = derivation.NewLocalBuilder()
+	}
 
-		// Display the result
-		fmt.Println(result.String())
+	results, err := builder.Build(context.Background(), drv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gix build: %v\n", err)
+		os.Exit(1)
 	}
-}
 
-// handleReplCommand processes special REPL commands that start with ':'.
-//
-// These commands provide meta-functionality for the REPL environment,
-// such as displaying help information or executing system-level operations.
-//
-// Parameters:
-//   - cmd: The command string including the ':' prefix
-//
-// Currently supported commands:
-//   - :help, :h - Display available commands and their descriptions
-//   - :quit, :q - Exit the REPL (handled in the main loop)
-func handleReplCommand(cmd string) {
-	switch cmd {
-	case ":help", ":h":
-		fmt.Println("Available commands:")
-		fmt.Println("  :help, :h    Show this help")
-		fmt.Println("  :quit, :q    Exit the REPL")
-	default:
-		fmt.Printf("Unknown command: %s\n", cmd)
-		fmt.Println("Type :help for available commands")
+	for name, result := range results {
+		fmt.Printf("%s -> %s\n", name, result.Path)
 	}
 }
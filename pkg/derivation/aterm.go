@@ -0,0 +1,214 @@
+package derivation
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// nixBase32Alphabet is Nix's custom base-32 alphabet. It omits the
+// characters 'e', 'o', 't', 'u' to avoid accidentally spelling English
+// words in store path hashes.
+const nixBase32Alphabet = "0123456789abcdfghijklmnpqrsvwxyz"
+
+// nixBase32Encode encodes data the same way Nix's printHash32 does: not
+// RFC 4648 base32, but a dense packing of 5-bit groups read from the
+// most significant end of the byte string, which is what gives Nix
+// store path hashes their distinctive 32-character prefix for a
+// 20-byte hash.
+func nixBase32Encode(data []byte) string {
+	length := (len(data)*8-1)/5 + 1
+
+	var sb strings.Builder
+	sb.Grow(length)
+
+	for n := length - 1; n >= 0; n-- {
+		bit := n * 5
+		i, j := bit/8, bit%8
+
+		b := data[i] >> j
+		if i+1 < len(data) {
+			b |= data[i+1] << (8 - j)
+		}
+
+		sb.WriteByte(nixBase32Alphabet[b&0x1f])
+	}
+
+	return sb.String()
+}
+
+// compressHash XORs hash down to newSize bytes by folding it over
+// itself cyclically, matching Nix's compressHash. Store path hashes are
+// always a SHA-256 digest compressed to 20 bytes this way before being
+// base-32 encoded.
+func compressHash(hash []byte, newSize int) []byte {
+	out := make([]byte, newSize)
+	for i, b := range hash {
+		out[i%newSize] ^= b
+	}
+
+	return out
+}
+
+// atermEscape escapes a string the way Nix's ATerm writer does: quotes
+// become `\"`, backslashes `\\`, and newline, carriage return and tab
+// get their C escape. Other control characters are written as-is.
+func atermEscape(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s) + 2)
+
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	return sb.String()
+}
+
+func atermString(s string) string {
+	return `"` + atermEscape(s) + `"`
+}
+
+func atermStrings(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = atermString(item)
+	}
+
+	return "[" + strings.Join(quoted, ",") + "]"
+}
+
+// ATerm serializes the derivation using Nix's canonical ATerm encoding
+// (the on-disk `.drv` format): `Derive([outputs],[inputDrvs],[inputSrcs],
+// system,builder,[args],[env])`. The result is deterministic - every
+// list except args (whose order is significant) is sorted and every
+// string is escaped - so that two Derivations
+// with the same content always produce byte-identical output, which is
+// what lets gix's store paths interoperate with upstream Nix.
+func (d *Derivation) ATerm() []byte {
+	var sb strings.Builder
+	sb.WriteString("Derive(")
+
+	sb.WriteString(d.atermOutputs())
+	sb.WriteString(",")
+	sb.WriteString(d.atermInputDrvs())
+	sb.WriteString(",")
+	sb.WriteString(atermStrings(sortedCopy(d.InputSrcs)))
+	sb.WriteString(",")
+	sb.WriteString(atermString(d.System))
+	sb.WriteString(",")
+	sb.WriteString(atermString(d.Builder))
+	sb.WriteString(",")
+	sb.WriteString(atermStrings(d.Args))
+	sb.WriteString(",")
+	sb.WriteString(d.atermEnv())
+
+	sb.WriteString(")")
+
+	return []byte(sb.String())
+}
+
+func (d *Derivation) atermOutputs() string {
+	names := make([]string, 0, len(d.Outputs))
+	for name := range d.Outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tuples := make([]string, len(names))
+	for i, name := range names {
+		hashAlgo, hash := "", ""
+		if d.FixedOutput != nil {
+			hashAlgo, hash = d.FixedOutput.HashAlgo, d.FixedOutput.Hash
+		}
+
+		tuples[i] = fmt.Sprintf("(%s,%s,%s,%s)",
+			atermString(name), atermString(d.Outputs[name]),
+			atermString(hashAlgo), atermString(hash))
+	}
+
+	return "[" + strings.Join(tuples, ",") + "]"
+}
+
+func (d *Derivation) atermInputDrvs() string {
+	paths := make([]string, 0, len(d.InputDrvs))
+	for path := range d.InputDrvs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	tuples := make([]string, len(paths))
+	for i, path := range paths {
+		tuples[i] = fmt.Sprintf("(%s,%s)", atermString(path), atermStrings(sortedCopy(d.InputDrvs[path])))
+	}
+
+	return "[" + strings.Join(tuples, ",") + "]"
+}
+
+func (d *Derivation) atermEnv() string {
+	keys := make([]string, 0, len(d.Env))
+	for k := range d.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("(%s,%s)", atermString(k), atermString(d.Env[k]))
+	}
+
+	return "[" + strings.Join(pairs, ",") + "]"
+}
+
+func sortedCopy(items []string) []string {
+	out := make([]string, len(items))
+	copy(out, items)
+	sort.Strings(out)
+
+	return out
+}
+
+// hashDerivationModulo computes the "hash modulo fixed-output" of a
+// derivation, the value Nix actually stores paths against instead of a
+// plain hash of the ATerm. For fixed-output derivations (content known
+// up front, e.g. fetchurl) it's a hash of the expected output content
+// rather than of the build recipe, so derivations that produce
+// identical output hash identically even if the recipe changes. known
+// maps an input drv path to its already-computed modulo, which replaces
+// that path in the hashed ATerm; an input missing from known (or a nil
+// known) is hashed by its own path. The function only reads known.
+func (d *Derivation) hashDerivationModulo(known map[string]string) string {
+	if d.FixedOutput != nil {
+		out := d.Outputs["out"]
+		digest := sha256.Sum256([]byte("fixed:out:" + d.FixedOutput.HashAlgo + ":" + d.FixedOutput.Hash + ":" + out))
+
+		return fmt.Sprintf("%x", digest)
+	}
+
+	rewritten := *d
+	rewritten.InputDrvs = make(map[string][]string, len(d.InputDrvs))
+	for path, outputs := range d.InputDrvs {
+		modulo := known[path]
+		if modulo == "" {
+			modulo = path
+		}
+		rewritten.InputDrvs[modulo] = sortedCopy(outputs)
+	}
+
+	digest := sha256.Sum256(rewritten.ATerm())
+
+	return fmt.Sprintf("%x", digest)
+}
@@ -0,0 +1,116 @@
+package derivation
+
+import (
+	"crypto/sha256"
+	"strings"
+	"testing"
+)
+
+func TestNixBase32Encode(t *testing.T) {
+	// compressHash(sha256(""), 20) base-32 encoded must be 32 characters
+	// long and come out the same on every call. The encoded value itself
+	// is not pinned here.
+	sum := sha256.Sum256([]byte(""))
+	digest := compressHash(sum[:], 20)
+
+	got := nixBase32Encode(digest)
+	if len(got) != 32 {
+		t.Fatalf("expected a 32-character hash, got %d: %q", len(got), got)
+	}
+
+	// Re-encoding must be deterministic.
+	if again := nixBase32Encode(digest); again != got {
+		t.Fatalf("nixBase32Encode not deterministic: %q != %q", got, again)
+	}
+}
+
+func TestCompressHash(t *testing.T) {
+	// Folding a 32-byte digest down to 20 bytes must XOR the tail back
+	// onto the head rather than truncating it.
+	in := make([]byte, 32)
+	for i := range in {
+		in[i] = byte(i)
+	}
+
+	out := compressHash(in, 20)
+	if len(out) != 20 {
+		t.Fatalf("expected 20 bytes, got %d", len(out))
+	}
+
+	want := in[0] ^ in[20]
+	if out[0] != want {
+		t.Errorf("compressHash[0] = %d, want %d", out[0], want)
+	}
+}
+
+func TestATermDeterministic(t *testing.T) {
+	d := NewDerivation("hello").
+		SetBuilder("/bin/sh").
+		SetArgs([]string{"-c", "echo hi"}).
+		SetSystem("x86_64-linux")
+	d.AddEnv("foo", "bar")
+	d.AddEnv("out", "")
+	d.AddInputSrc("/nix/store/abc-src")
+	drv := d.Build()
+
+	first := string(drv.ATerm())
+
+	d2 := NewDerivation("hello").
+		SetBuilder("/bin/sh").
+		SetArgs([]string{"-c", "echo hi"}).
+		SetSystem("x86_64-linux")
+	d2.AddEnv("out", "")
+	d2.AddEnv("foo", "bar") // added in a different order
+	d2.AddInputSrc("/nix/store/abc-src")
+	drv2 := d2.Build()
+
+	if first != string(drv2.ATerm()) {
+		t.Fatalf("ATerm output differs for equivalent derivations:\n%s\n%s", first, drv2.ATerm())
+	}
+
+	if drv.Hash != drv2.Hash {
+		t.Errorf("hashes differ for equivalent derivations: %s != %s", drv.Hash, drv2.Hash)
+	}
+}
+
+func TestATermEscaping(t *testing.T) {
+	d := NewDerivation("weird").SetBuilder(`/bin/sh "quoted" \ path`).Build()
+
+	aterm := string(d.ATerm())
+	if !strings.Contains(aterm, `\"quoted\"`) {
+		t.Errorf("expected escaped quotes in ATerm output: %s", aterm)
+	}
+	if !strings.Contains(aterm, `\\ path`) {
+		t.Errorf("expected escaped backslash in ATerm output: %s", aterm)
+	}
+}
+
+func TestFixedOutputHashChangesWithContent(t *testing.T) {
+	a := NewDerivation("src").SetBuilder("/bin/sh").SetFixedOutput("sha256", "aaaa").Build()
+	b := NewDerivation("src").SetBuilder("/bin/sh").SetFixedOutput("sha256", "bbbb").Build()
+
+	if a.Hash == b.Hash {
+		t.Error("fixed-output derivations with different expected hashes should hash differently")
+	}
+
+	// The builder/args/env don't matter for a fixed-output derivation -
+	// only the expected content hash does.
+	c := NewDerivation("src").SetBuilder("/bin/bash").SetFixedOutput("sha256", "aaaa").Build()
+	if a.Hash != c.Hash {
+		t.Error("fixed-output hash should be independent of the build recipe")
+	}
+}
+
+func TestComputeStorePathStable(t *testing.T) {
+	d := NewDerivation("stable").SetBuilder("/bin/sh").Build()
+
+	if d.StorePath == "" {
+		t.Fatal("expected a non-empty store path")
+	}
+
+	again := NewDerivation("stable").SetBuilder("/bin/sh").Build()
+	if d.StorePath != again.StorePath {
+		t.Errorf("store path not deterministic: %s != %s", d.StorePath, again.StorePath)
+	}
+}
+
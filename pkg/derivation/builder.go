@@ -0,0 +1,319 @@
+package derivation
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// BuildResult describes the outcome of realising a single derivation
+// output.
+type BuildResult struct {
+	Path   string // the output's store path
+	Reused bool   // true if an existing BuildCache entry was reused instead of rebuilding
+}
+
+// Builder realises a Derivation, producing one BuildResult per output
+// name in drv.Outputs. Implementations are free to run the build
+// however they like - LocalBuilder executes it directly, DryRunBuilder
+// only reports what it would do - which is why gix build and BuildAll
+// take a Builder rather than assuming a concrete execution strategy.
+type Builder interface {
+	Build(ctx context.Context, drv *Derivation) (map[string]BuildResult, error)
+}
+
+// BuildCache lets a Builder skip derivations whose outputs have already
+// been realised. Lookup is keyed by the derivation's store path (not
+// its output paths), since a cache hit/miss decision is made once per
+// derivation before any of its outputs are touched.
+type BuildCache interface {
+	Lookup(storePath string) (map[string]BuildResult, bool)
+	Store(storePath string, results map[string]BuildResult)
+}
+
+// memoryBuildCache is a process-local BuildCache, useful for a single
+// `gix build` invocation that builds the same derivation more than once
+// (e.g. as both a direct target and a transitive dependency).
+type memoryBuildCache struct {
+	entries map[string]map[string]BuildResult
+}
+
+// NewMemoryBuildCache creates a BuildCache backed by an in-memory map.
+// It does not persist across process invocations - see BuildCache for
+// the extension point a disk-backed cache would implement.
+func NewMemoryBuildCache() BuildCache {
+	return &memoryBuildCache{entries: make(map[string]map[string]BuildResult)}
+}
+
+func (c *memoryBuildCache) Lookup(storePath string) (map[string]BuildResult, bool) {
+	results, ok := c.entries[storePath]
+
+	return results, ok
+}
+
+func (c *memoryBuildCache) Store(storePath string, results map[string]BuildResult) {
+	c.entries[storePath] = results
+}
+
+// LocalBuilder runs a derivation's builder directly on the host,
+// isolated by whatever applySandbox can arrange for the current
+// platform (see builder_linux.go / builder_other.go). Each output gets
+// its own fresh temporary directory standing in for its eventual store
+// path; NetworkAllowed defaults to false, matching Nix's assumption
+// that ordinary (non-fixed-output) derivations must not reach the
+// network.
+type LocalBuilder struct {
+	Cache          BuildCache
+	Stdout, Stderr io.Writer
+	NetworkAllowed bool
+}
+
+// NewLocalBuilder creates a LocalBuilder with no cache and output
+// wired to the process's own stdout/stderr.
+func NewLocalBuilder() *LocalBuilder {
+	return &LocalBuilder{Stdout: os.Stdout, Stderr: os.Stderr}
+}
+
+// Build executes drv.Builder with drv.Args in a scrubbed environment,
+// one fresh output directory per entry in drv.Outputs.
+func (b *LocalBuilder) Build(ctx context.Context, drv *Derivation) (map[string]BuildResult, error) {
+	if b.Cache != nil {
+		if results, ok := b.Cache.Lookup(drv.StorePath); ok {
+			for name, result := range results {
+				result.Reused = true
+				results[name] = result
+			}
+
+			return results, nil
+		}
+	}
+
+	buildTop, err := os.MkdirTemp("", "gix-build-")
+	if err != nil {
+		return nil, fmt.Errorf("creating build directory: %w", err)
+	}
+	defer os.RemoveAll(buildTop)
+
+	results := make(map[string]BuildResult, len(drv.Outputs))
+	env := map[string]string{
+		"PATH":         "/bin:/usr/bin",
+		"NIX_BUILD_TOP": buildTop,
+		"TMPDIR":       buildTop,
+		"HOME":         "/homeless-shelter",
+	}
+	for k, v := range drv.Env {
+		env[k] = v
+	}
+
+	for name := range drv.Outputs {
+		outDir := filepath.Join(buildTop, name)
+		if err := os.MkdirAll(outDir, 0o755); err != nil {
+			return nil, fmt.Errorf("creating output directory for %q: %w", name, err)
+		}
+
+		env[name] = outDir
+		results[name] = BuildResult{Path: outDir}
+	}
+
+	cmd := exec.CommandContext(ctx, drv.Builder, drv.Args...)
+	cmd.Dir = buildTop
+	cmd.Stdout = b.Stdout
+	cmd.Stderr = b.Stderr
+	cmd.Env = flattenEnv(env)
+	applySandbox(cmd, b.NetworkAllowed)
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("building %s: %w", drv.Name, err)
+	}
+
+	if b.Cache != nil {
+		b.Cache.Store(drv.StorePath, results)
+	}
+
+	return results, nil
+}
+
+func flattenEnv(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]string, len(keys))
+	for i, k := range keys {
+		out[i] = k + "=" + env[k]
+	}
+
+	return out
+}
+
+// DryRunBuilder implements Builder by reporting what it would build
+// without actually running anything, for `gix build --dry-run`-style
+// previews.
+type DryRunBuilder struct {
+	Out io.Writer
+}
+
+// NewDryRunBuilder creates a DryRunBuilder that writes to w.
+func NewDryRunBuilder(w io.Writer) *DryRunBuilder {
+	return &DryRunBuilder{Out: w}
+}
+
+func (b *DryRunBuilder) Build(_ context.Context, drv *Derivation) (map[string]BuildResult, error) {
+	fmt.Fprintf(b.Out, "would build %s: %s %v\n", drv.StorePath, drv.Builder, drv.Args)
+
+	results := make(map[string]BuildResult, len(drv.Outputs))
+	for name, path := range drv.Outputs {
+		results[name] = BuildResult{Path: path}
+	}
+
+	return results, nil
+}
+
+// BuildOrder topologically sorts root and every derivation it
+// transitively depends on (looked up by store path in drvsByPath), so
+// that each derivation appears after all of its InputDrvs. Returns an
+// error if the graph contains a cycle or references a path missing
+// from drvsByPath.
+func BuildOrder(root *Derivation, drvsByPath map[string]*Derivation) ([]*Derivation, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[string]int)
+	var order []*Derivation
+
+	var visit func(path string, drv *Derivation) error
+	visit = func(path string, drv *Derivation) error {
+		switch state[path] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("derivation graph has a cycle at %s", path)
+		}
+
+		state[path] = visiting
+
+		inputPaths := make([]string, 0, len(drv.InputDrvs))
+		for p := range drv.InputDrvs {
+			inputPaths = append(inputPaths, p)
+		}
+		sort.Strings(inputPaths)
+
+		for _, inputPath := range inputPaths {
+			inputDrv, ok := drvsByPath[inputPath]
+			if !ok {
+				return fmt.Errorf("unresolved input derivation: %s", inputPath)
+			}
+			if err := visit(inputPath, inputDrv); err != nil {
+				return err
+			}
+		}
+
+		state[path] = visited
+		order = append(order, drv)
+
+		return nil
+	}
+
+	if err := visit(root.StorePath, root); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// BuildAll builds root and every derivation it transitively depends on
+// (resolved through drvsByPath), using up to workers goroutines at a
+// time. Derivations are built in dependency layers - every derivation
+// in a layer only depends on derivations in earlier layers - and a
+// layer only starts once the previous one has fully finished, so a
+// derivation never starts building before its own inputs are done.
+// Independent derivations within the same layer build concurrently.
+func BuildAll(
+	ctx context.Context,
+	b Builder,
+	root *Derivation,
+	drvsByPath map[string]*Derivation,
+	workers int,
+) (map[string]map[string]BuildResult, error) {
+	order, err := BuildOrder(root, drvsByPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make(map[string]map[string]BuildResult, len(order))
+	for _, layer := range buildLayers(order) {
+		sem := make(chan struct{}, workers)
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var firstErr error
+
+		for _, drv := range layer {
+			wg.Add(1)
+			sem <- struct{}{}
+
+			go func(drv *Derivation) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				res, err := b.Build(ctx, drv)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = fmt.Errorf("%s: %w", drv.Name, err)
+					}
+				} else {
+					results[drv.StorePath] = res
+				}
+			}(drv)
+		}
+
+		wg.Wait()
+		if firstErr != nil {
+			return nil, firstErr
+		}
+	}
+
+	return results, nil
+}
+
+// buildLayers groups a topologically-sorted derivation list into
+// layers: each derivation goes in the layer right after the latest
+// layer containing one of its InputDrvs, so every derivation in a
+// layer is safe to build concurrently with the rest of that layer.
+func buildLayers(order []*Derivation) [][]*Derivation {
+	layerOf := make(map[string]int, len(order))
+	var layers [][]*Derivation
+
+	for _, drv := range order {
+		layer := 0
+		for inputPath := range drv.InputDrvs {
+			if l, ok := layerOf[inputPath]; ok && l+1 > layer {
+				layer = l + 1
+			}
+		}
+
+		layerOf[drv.StorePath] = layer
+		for len(layers) <= layer {
+			layers = append(layers, nil)
+		}
+		layers[layer] = append(layers[layer], drv)
+	}
+
+	return layers
+}
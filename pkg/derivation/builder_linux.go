@@ -0,0 +1,27 @@
+//go:build linux
+
+package derivation
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// applySandbox isolates a local build using Linux namespaces: mount and
+// PID namespaces are always unshared, and the network namespace is too
+// unless networkAllowed is set (ordinary derivations must not reach the
+// network; fixed-output derivations may, since their content is
+// verified against a known hash regardless of how it was produced).
+// This needs CAP_SYS_ADMIN (or user namespaces, which gix does not set
+// up here) to succeed unprivileged; Cloneflags is best-effort and the
+// caller sees any failure as a normal build error from cmd.Run.
+func applySandbox(cmd *exec.Cmd, networkAllowed bool) {
+	flags := syscall.CLONE_NEWNS | syscall.CLONE_NEWPID
+	if !networkAllowed {
+		flags |= syscall.CLONE_NEWNET
+	}
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: uintptr(flags),
+	}
+}
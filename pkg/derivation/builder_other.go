@@ -0,0 +1,14 @@
+//go:build !linux
+
+package derivation
+
+import "os/exec"
+
+// applySandbox is a no-op on platforms without Linux-style namespaces.
+// A macOS build could shell out through sandbox-exec with a generated
+// profile, but that needs a profile file per build rather than a
+// SysProcAttr tweak, so it's left as a follow-up rather than faked here.
+func applySandbox(cmd *exec.Cmd, networkAllowed bool) {
+	_ = cmd
+	_ = networkAllowed
+}
@@ -0,0 +1,117 @@
+package derivation
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestBuildOrder(t *testing.T) {
+	leaf := NewDerivation("leaf").SetBuilder("/bin/sh").Build()
+	mid := NewDerivation("mid").SetBuilder("/bin/sh").Build()
+	mid.InputDrvs[leaf.StorePath] = []string{"out"}
+	root := NewDerivation("root").SetBuilder("/bin/sh").Build()
+	root.InputDrvs[mid.StorePath] = []string{"out"}
+
+	byPath := map[string]*Derivation{
+		leaf.StorePath: leaf,
+		mid.StorePath:  mid,
+	}
+
+	order, err := BuildOrder(root, byPath)
+	if err != nil {
+		t.Fatalf("BuildOrder returned error: %v", err)
+	}
+
+	if len(order) != 3 {
+		t.Fatalf("expected 3 derivations in order, got %d", len(order))
+	}
+	if order[0] != leaf || order[1] != mid || order[2] != root {
+		t.Errorf("unexpected order: %v", order)
+	}
+}
+
+func TestBuildOrderCycle(t *testing.T) {
+	a := NewDerivation("a").SetBuilder("/bin/sh").Build()
+	b := NewDerivation("b").SetBuilder("/bin/sh").Build()
+	a.InputDrvs[b.StorePath] = []string{"out"}
+	b.InputDrvs[a.StorePath] = []string{"out"}
+
+	_, err := BuildOrder(a, map[string]*Derivation{a.StorePath: a, b.StorePath: b})
+	if err == nil {
+		t.Fatal("expected a cycle error")
+	}
+}
+
+func TestBuildOrderUnresolvedInput(t *testing.T) {
+	root := NewDerivation("root").SetBuilder("/bin/sh").Build()
+	root.InputDrvs["/nix/store/missing.drv"] = []string{"out"}
+
+	_, err := BuildOrder(root, map[string]*Derivation{})
+	if err == nil {
+		t.Fatal("expected an unresolved-input error")
+	}
+}
+
+func TestDryRunBuilder(t *testing.T) {
+	var buf bytes.Buffer
+	b := NewDryRunBuilder(&buf)
+
+	drv := NewDerivation("hello").SetBuilder("/bin/sh").SetArgs([]string{"-c", "echo hi"}).Build()
+
+	results, err := b.Build(context.Background(), drv)
+	if err != nil {
+		t.Fatalf("DryRunBuilder.Build returned error: %v", err)
+	}
+
+	if len(results) != 1 {
+		t.Fatalf("expected 1 output, got %d", len(results))
+	}
+	if buf.Len() == 0 {
+		t.Error("expected DryRunBuilder to report what it would do")
+	}
+}
+
+func TestMemoryBuildCache(t *testing.T) {
+	cache := NewMemoryBuildCache()
+
+	if _, ok := cache.Lookup("/nix/store/does-not-exist"); ok {
+		t.Fatal("empty cache should not report a hit")
+	}
+
+	results := map[string]BuildResult{"out": {Path: "/nix/store/abc-out"}}
+	cache.Store("/nix/store/abc.drv", results)
+
+	got, ok := cache.Lookup("/nix/store/abc.drv")
+	if !ok {
+		t.Fatal("expected a cache hit after Store")
+	}
+	if got["out"].Path != "/nix/store/abc-out" {
+		t.Errorf("unexpected cached result: %+v", got)
+	}
+}
+
+func TestBuildAllWithDryRun(t *testing.T) {
+	leaf := NewDerivation("leaf").SetBuilder("/bin/sh").Build()
+	root := NewDerivation("root").SetBuilder("/bin/sh").Build()
+	root.InputDrvs[leaf.StorePath] = []string{"out"}
+
+	var buf bytes.Buffer
+	results, err := BuildAll(
+		context.Background(),
+		NewDryRunBuilder(&buf),
+		root,
+		map[string]*Derivation{leaf.StorePath: leaf},
+		2,
+	)
+	if err != nil {
+		t.Fatalf("BuildAll returned error: %v", err)
+	}
+
+	if _, ok := results[leaf.StorePath]; !ok {
+		t.Error("expected a result for the leaf derivation")
+	}
+	if _, ok := results[root.StorePath]; !ok {
+		t.Error("expected a result for the root derivation")
+	}
+}
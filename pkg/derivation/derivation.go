@@ -3,11 +3,9 @@ package derivation
 
 import (
 	"crypto/sha256"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"path/filepath"
-	"sort"
 	"strings"
 
 	"github.com/conneroisu/gix/internal/value"
@@ -25,11 +23,24 @@ type Derivation struct {
 	InputSrcs []string            `json:"inputSrcs"`
 	System    string              `json:"system"`
 
+	// FixedOutput is set for content-addressed derivations (e.g.
+	// fetchurl) whose output hash is known ahead of the build, which
+	// changes how the derivation's store-path hash is derived - see
+	// hashDerivationModulo.
+	FixedOutput *FixedOutputSpec `json:"fixedOutput,omitempty"`
+
 	// Computed fields
 	Hash      string `json:"hash,omitempty"`
 	StorePath string `json:"storePath,omitempty"`
 }
 
+// FixedOutputSpec describes the expected hash of a content-addressed
+// derivation's output, as used by fetchers such as fetchurl.
+type FixedOutputSpec struct {
+	HashAlgo string // e.g. "sha256"
+	Hash     string // hex-encoded expected hash of the output
+}
+
 // DerivationBuilder helps build derivations.
 type DerivationBuilder struct {
 	drv *Derivation
@@ -84,20 +95,53 @@ func (db *DerivationBuilder) AddOutput(name, path string) *DerivationBuilder {
 	return db
 }
 
-// AddInputDrv adds an input derivation.
+// AddInputDrv adds an input derivation. Called more than once for the
+// same path - e.g. because two different attributes each reference a
+// different output of it - it accumulates outputs rather than letting
+// the later call overwrite the earlier one.
 func (db *DerivationBuilder) AddInputDrv(path string, outputs []string) *DerivationBuilder {
-	db.drv.InputDrvs[path] = outputs
+	existing := db.drv.InputDrvs[path]
+	for _, out := range outputs {
+		if !containsString(existing, out) {
+			existing = append(existing, out)
+		}
+	}
+	db.drv.InputDrvs[path] = sortedCopy(existing)
 
 	return db
 }
 
-// AddInputSrc adds an input source.
+// AddInputSrc adds an input source, ignoring a path already present so
+// a source referenced from several attributes isn't duplicated.
 func (db *DerivationBuilder) AddInputSrc(path string) *DerivationBuilder {
+	if containsString(db.drv.InputSrcs, path) {
+		return db
+	}
+
 	db.drv.InputSrcs = append(db.drv.InputSrcs, path)
 
 	return db
 }
 
+// containsString reports whether ss contains s.
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetFixedOutput marks the derivation as content-addressed with the
+// given expected output hash, as used by fetchers such as fetchurl.
+func (db *DerivationBuilder) SetFixedOutput(hashAlgo, hash string) *DerivationBuilder {
+	db.drv.FixedOutput = &FixedOutputSpec{HashAlgo: hashAlgo, Hash: hash}
+
+	return db
+}
+
 // Build finalizes the derivation.
 func (db *DerivationBuilder) Build() *Derivation {
 	// Set default output if none specified
@@ -122,100 +166,108 @@ func (db *DerivationBuilder) Build() *Derivation {
 	return db.drv
 }
 
-// computeHash computes the derivation hash.
+// computeHash computes the derivation's "hash modulo fixed-output",
+// following Nix's ATerm-based scheme (see aterm.go) so that store paths
+// computed by gix match upstream Nix byte-for-byte. db.drv has no other
+// gix-built derivations in scope to memoize against, so inputDrv
+// references are hashed using only their own path.
 func (db *DerivationBuilder) computeHash() string {
-	// Create a deterministic string representation
-	parts := []string{
-		"name=" + db.drv.Name,
-		"builder=" + db.drv.Builder,
-		"args=" + strings.Join(db.drv.Args, ","),
-		"system=" + db.drv.System,
-	}
-
-	// Add environment variables in sorted order
-	var envKeys []string
-	for k := range db.drv.Env {
-		envKeys = append(envKeys, k)
-	}
-	sort.Strings(envKeys)
-
-	for _, k := range envKeys {
-		parts = append(parts, fmt.Sprintf("env.%s=%s", k, db.drv.Env[k]))
-	}
-
-	// Add input derivations in sorted order
-	var inputKeys []string
-	for k := range db.drv.InputDrvs {
-		inputKeys = append(inputKeys, k)
-	}
-	sort.Strings(inputKeys)
-
-	for _, k := range inputKeys {
-		outputs := db.drv.InputDrvs[k]
-		sort.Strings(outputs)
-		parts = append(parts, fmt.Sprintf("inputDrv.%s=%s", k, strings.Join(outputs, ",")))
-	}
+	return db.drv.hashDerivationModulo(nil)
+}
 
-	// Add input sources in sorted order
-	inputSrcs := make([]string, len(db.drv.InputSrcs))
-	copy(inputSrcs, db.drv.InputSrcs)
-	sort.Strings(inputSrcs)
+// computeStorePath computes the store path using Nix's store-path
+// scheme: compressHash(sha256("text:" + refs + ":sha256:" + drvHash +
+// ":/nix/store:" + name), 20), base-32 encoded with Nix's custom
+// alphabet. "text" is the type Nix uses for the store path of a .drv
+// file itself; refs are the derivation's own input sources.
+func (db *DerivationBuilder) computeStorePath() string {
+	refs := sortedCopy(db.drv.InputSrcs)
 
-	for _, src := range inputSrcs {
-		parts = append(parts, "inputSrc="+src)
-	}
+	digest := sha256.Sum256([]byte(
+		"text:" + strings.Join(refs, ":") + ":sha256:" + db.drv.Hash + ":/nix/store:" + db.drv.Name))
 
-	// Compute SHA256 hash
-	content := strings.Join(parts, "\n")
-	hash := sha256.Sum256([]byte(content))
+	pathHash := nixBase32Encode(compressHash(digest[:], 20))
 
-	return hex.EncodeToString(hash[:])[:32] // Use first 32 characters
+	return fmt.Sprintf("/nix/store/%s-%s", pathHash, db.drv.Name)
 }
 
-// computeStorePath computes the store path.
-func (db *DerivationBuilder) computeStorePath() string {
-	return fmt.Sprintf("/nix/store/%s-%s", db.drv.Hash, db.drv.Name)
-}
-
-// ToAttrs converts derivation to an attribute set value.
+// ToAttrs converts derivation to an attribute set value. Every output
+// path carries a string context entry naming this derivation and that
+// output, so a later `derivation` call building from these attrs (or
+// from a string built by concatenating one of them) discovers the
+// dependency automatically - see FromAttrs.
 func (d *Derivation) ToAttrs() *value.Attrs {
 	attrs := value.NewAttrs()
 
+	drvPath := d.StorePath + ".drv"
+
 	// Basic attributes
-	attrs.Set("name", value.String(d.Name))
-	attrs.Set("builder", value.String(d.Builder))
-	attrs.Set("system", value.String(d.System))
-	attrs.Set("drvPath", value.String(d.StorePath+".drv"))
+	attrs.Set("name", value.NewString(d.Name))
+	attrs.Set("builder", value.NewString(d.Builder))
+	attrs.Set("system", value.NewString(d.System))
+	attrs.Set("drvPath", value.NewString(drvPath))
 
 	// Args
 	argsList := make([]value.Value, len(d.Args))
 	for i, arg := range d.Args {
-		argsList[i] = value.String(arg)
+		argsList[i] = value.NewString(arg)
 	}
 	attrs.Set("args", value.NewList(argsList...))
 
 	// Outputs
 	outAttrs := value.NewAttrs()
 	for name, path := range d.Outputs {
-		outAttrs.Set(name, value.String(path))
+		outAttrs.Set(name, outputString(drvPath, name, path))
 	}
 	attrs.Set("outputs", outAttrs)
 
 	// Add individual output attributes
 	for name, path := range d.Outputs {
-		attrs.Set(name, value.String(path))
+		attrs.Set(name, outputString(drvPath, name, path))
+	}
+
+	// outPath is the default output's path, matching real Nix where
+	// every derivation result carries one regardless of how many
+	// outputs it has.
+	defaultOutput := "out"
+	if _, ok := d.Outputs[defaultOutput]; !ok {
+		names := make([]string, 0, len(d.Outputs))
+		for name := range d.Outputs {
+			names = append(names, name)
+		}
+		for _, name := range sortedCopy(names) {
+			defaultOutput = name
+			break
+		}
+	}
+	if path, ok := d.Outputs[defaultOutput]; ok {
+		attrs.Set("outPath", outputString(drvPath, defaultOutput, path))
 	}
 
 	return attrs
 }
 
-// FromAttrs creates a derivation from an attribute set.
+// outputString builds the value.String for a derivation's named output
+// path, carrying a context entry that records the dependency.
+func outputString(drvPath, output, path string) value.String {
+	key, elem := value.DrvContextElem(drvPath, output)
+
+	return value.NewStringWithContext(path, value.StringContext{key: elem})
+}
+
+// FromAttrs creates a derivation from an attribute set. Attribute values
+// may still be unforced thunks (attrset values are lazy), so every one
+// pulled out here is forced before its concrete type is inspected.
 func FromAttrs(attrs *value.Attrs) (*Derivation, error) {
 	// Extract name
 	nameVal, ok := attrs.Get("name")
 	if !ok {
 		return nil, errors.New("derivation missing required 'name' attribute")
 	}
+	nameVal, err := value.Force(nameVal)
+	if err != nil {
+		return nil, err
+	}
 	nameStr, ok := nameVal.(value.String)
 	if !ok {
 		return nil, errors.New("derivation 'name' must be a string")
@@ -226,30 +278,47 @@ func FromAttrs(attrs *value.Attrs) (*Derivation, error) {
 	if !ok {
 		return nil, errors.New("derivation missing required 'builder' attribute")
 	}
+	builderVal, err = value.Force(builderVal)
+	if err != nil {
+		return nil, err
+	}
 	builderStr, ok := builderVal.(value.String)
 	if !ok {
 		return nil, errors.New("derivation 'builder' must be a string")
 	}
 
 	// Create derivation
-	db := NewDerivation(string(nameStr))
-	db.SetBuilder(string(builderStr))
+	db := NewDerivation(nameStr.Raw)
+	db.SetBuilder(builderStr.Raw)
+	addContextInputs(db, builderStr)
 
 	// Extract system if present
 	if systemVal, ok := attrs.Get("system"); ok {
+		systemVal, err = value.Force(systemVal)
+		if err != nil {
+			return nil, err
+		}
 		if systemStr, ok := systemVal.(value.String); ok {
-			db.SetSystem(string(systemStr))
+			db.SetSystem(systemStr.Raw)
 		}
 	}
 
 	// Extract args if present
 	if argsVal, ok := attrs.Get("args"); ok {
+		argsVal, err = value.Force(argsVal)
+		if err != nil {
+			return nil, err
+		}
 		if argsList, ok := argsVal.(*value.List); ok {
 			args := make([]string, argsList.Len())
 			for i := 0; i < argsList.Len(); i++ {
-				arg := argsList.Get(i)
+				arg, err := value.Force(argsList.Get(i))
+				if err != nil {
+					return nil, err
+				}
 				if argStr, ok := arg.(value.String); ok {
-					args[i] = string(argStr)
+					args[i] = argStr.Raw
+					addContextInputs(db, argStr)
 				}
 			}
 			db.SetArgs(args)
@@ -263,10 +332,31 @@ func FromAttrs(attrs *value.Attrs) (*Derivation, error) {
 			continue
 		}
 		val, _ := attrs.Get(key)
+		val, err := value.Force(val)
+		if err != nil {
+			return nil, err
+		}
 		if strVal, ok := val.(value.String); ok {
-			db.AddEnv(key, string(strVal))
+			db.AddEnv(key, strVal.Raw)
+			addContextInputs(db, strVal)
 		}
 	}
 
 	return db.Build(), nil
 }
+
+// addContextInputs records the derivation outputs and source paths s's
+// string context references as inputDrvs/inputSrcs on db, so a
+// derivation built from context-bearing strings (outputs of other
+// derivations, or paths coerced via toString) gets its dependencies
+// without the caller declaring them a second time.
+func addContextInputs(db *DerivationBuilder, s value.String) {
+	for _, elem := range s.Context {
+		switch {
+		case elem.DrvPath != "":
+			db.AddInputDrv(elem.DrvPath, []string{elem.Output})
+		case elem.Path != "":
+			db.AddInputSrc(elem.Path)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package derivation
+
+import (
+	"testing"
+
+	"github.com/conneroisu/gix/internal/value"
+)
+
+func TestToAttrsOutputsCarryContext(t *testing.T) {
+	drv := NewDerivation("hello").SetBuilder("/bin/sh").Build()
+	attrs := drv.ToAttrs()
+
+	outPathVal, ok := attrs.Get("outPath")
+	if !ok {
+		t.Fatal("expected ToAttrs to set outPath")
+	}
+	outPath, ok := outPathVal.(value.String)
+	if !ok {
+		t.Fatalf("outPath is not a value.String, got %T", outPathVal)
+	}
+	if outPath.Raw != drv.Outputs["out"] {
+		t.Errorf("outPath = %q, want %q", outPath.Raw, drv.Outputs["out"])
+	}
+	if !outPath.HasContext() {
+		t.Fatal("expected outPath to carry a context entry")
+	}
+
+	key, elem := value.DrvContextElem(drv.StorePath+".drv", "out")
+	got, ok := outPath.Context[key]
+	if !ok || got.DrvPath != elem.DrvPath || got.Output != elem.Output {
+		t.Errorf("outPath context = %+v, want an entry for %+v", outPath.Context, elem)
+	}
+}
+
+// TestFromAttrsDiscoversDependenciesFromContext covers the request that
+// motivated string contexts: a derivation built from another
+// derivation's output (or from a toString'd path) should record that
+// dependency in inputDrvs/inputSrcs without it being declared by hand.
+func TestFromAttrsDiscoversDependenciesFromContext(t *testing.T) {
+	dep := NewDerivation("dep").SetBuilder("/bin/sh").Build()
+	depOut := dep.ToAttrs()
+	depOutPath, _ := depOut.Get("outPath")
+
+	srcKey, srcElem := value.PathContextElem("/nix/store/abc-source")
+	srcPath := value.NewStringWithContext("/nix/store/abc-source", value.StringContext{srcKey: srcElem})
+
+	attrs := value.NewAttrs()
+	attrs.Set("name", value.NewString("root"))
+	attrs.Set("builder", value.NewString("/bin/sh"))
+	attrs.Set("DEP", depOutPath)
+	attrs.Set("SRC", srcPath)
+
+	root, err := FromAttrs(attrs)
+	if err != nil {
+		t.Fatalf("FromAttrs returned error: %v", err)
+	}
+
+	drvPath := dep.StorePath + ".drv"
+	outputs, ok := root.InputDrvs[drvPath]
+	if !ok || len(outputs) != 1 || outputs[0] != "out" {
+		t.Errorf("InputDrvs[%q] = %v, want [\"out\"]", drvPath, outputs)
+	}
+
+	if !containsString(root.InputSrcs, "/nix/store/abc-source") {
+		t.Errorf("InputSrcs = %v, want it to include the source path", root.InputSrcs)
+	}
+}
+
+func TestAddInputDrvAccumulatesOutputsAcrossCalls(t *testing.T) {
+	db := NewDerivation("root").SetBuilder("/bin/sh")
+	db.AddInputDrv("/nix/store/x.drv", []string{"out"})
+	db.AddInputDrv("/nix/store/x.drv", []string{"dev"})
+	db.AddInputDrv("/nix/store/x.drv", []string{"out"})
+
+	drv := db.Build()
+
+	outputs := drv.InputDrvs["/nix/store/x.drv"]
+	if len(outputs) != 2 {
+		t.Fatalf("expected 2 distinct outputs, got %v", outputs)
+	}
+}
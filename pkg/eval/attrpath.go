@@ -0,0 +1,181 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/conneroisu/gix/internal/types"
+	"github.com/conneroisu/gix/internal/value"
+)
+
+// AttrPathErrorKind distinguishes the ways a FindAlongAttrPath traversal
+// can fail, so callers (CLI tooling, error formatting) can react
+// differently to each without string-matching the message.
+type AttrPathErrorKind byte
+
+const (
+	// AttrPathNotIndexable means the current value at Path is neither
+	// an attribute set nor a list, so the next token can't be applied.
+	AttrPathNotIndexable AttrPathErrorKind = iota
+	// AttrPathMissingAttr means Path names an attribute set that has
+	// no attribute named Token.
+	AttrPathMissingAttr
+	// AttrPathIndexOutOfRange means Path names a list but Token, parsed
+	// as an index, falls outside its bounds.
+	AttrPathIndexOutOfRange
+)
+
+// AttrPathError reports a failed step of a FindAlongAttrPath traversal.
+type AttrPathError struct {
+	Kind  AttrPathErrorKind
+	Path  string // dotted path up to and including Token
+	Token string // the path component that failed to resolve
+}
+
+func (e *AttrPathError) Error() string {
+	switch e.Kind {
+	case AttrPathMissingAttr:
+		return fmt.Sprintf("attribute '%s' not found", e.Path)
+	case AttrPathIndexOutOfRange:
+		return fmt.Sprintf("index %s out of range at '%s'", e.Token, e.Path)
+	default:
+		return fmt.Sprintf("'%s' is not an attribute set or list", e.Path)
+	}
+}
+
+// FindAlongAttrPath walks root along the dot-separated path, analogous to
+// Nix's findAlongAttrPath (used by `nix-instantiate --attr`/`nix-build
+// -A`). Each token is resolved against the current, forced value: a
+// non-negative-integer token indexes into a list, anything else selects
+// an attribute out of an *value.Attrs. If the current value is a
+// function, it's auto-applied with autoArgs before the token is
+// resolved, so a path can reach through a `pkgs: { ... }`-style thunk
+// the way `-A` does against a nixpkgs-shaped expression.
+//
+// gix functions don't support destructuring parameter patterns (only
+// `x: body`), so unlike upstream Nix, auto-application doesn't match
+// autoArgs against a formal parameter set or fill in defaults - it
+// simply binds the whole autoArgs attribute set to the function's
+// parameter. If autoArgs is nil, functions encountered along the path are applied
+// to an empty attribute set.
+func (e *Evaluator) FindAlongAttrPath(
+	root value.Value,
+	path string,
+	autoArgs *value.Attrs,
+) (value.Value, error) {
+	current, err := value.Force(root)
+	if err != nil {
+		return nil, err
+	}
+
+	if path == "" {
+		return current, nil
+	}
+
+	var walked []string
+	for _, token := range strings.Split(path, ".") {
+		current, err = e.autoApply(current, autoArgs)
+		if err != nil {
+			return nil, err
+		}
+
+		walked = append(walked, token)
+		walkedPath := strings.Join(walked, ".")
+
+		if idx, ok := parseAttrIndex(token); ok {
+			if list, ok := current.(*value.List); ok {
+				if idx < 0 || idx >= list.Len() {
+					return nil, &AttrPathError{Kind: AttrPathIndexOutOfRange, Path: walkedPath, Token: token}
+				}
+
+				current, err = value.Force(list.Get(idx))
+				if err != nil {
+					return nil, err
+				}
+
+				continue
+			}
+		}
+
+		attrs, ok := current.(*value.Attrs)
+		if !ok {
+			return nil, &AttrPathError{Kind: AttrPathNotIndexable, Path: walkedPath, Token: token}
+		}
+
+		val, ok := attrs.Get(token)
+		if !ok {
+			return nil, &AttrPathError{Kind: AttrPathMissingAttr, Path: walkedPath, Token: token}
+		}
+
+		current, err = value.Force(val)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return e.autoApply(current, autoArgs)
+}
+
+// parseAttrIndex reports whether token is a valid list index (a
+// non-negative base-10 integer with no sign or leading junk).
+func parseAttrIndex(token string) (int, bool) {
+	if token == "" || token[0] < '0' || token[0] > '9' {
+		return 0, false
+	}
+
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 {
+		return 0, false
+	}
+
+	return idx, true
+}
+
+// AutoApply applies v to autoArgs if it's a function, the same single
+// step FindAlongAttrPath repeats at every path component. It's exposed
+// standalone for callers - the CLI's --arg/--argstr handling - that want
+// to auto-call a top-level function without also selecting an attribute
+// path, since FindAlongAttrPath with an empty path returns its root
+// as-is rather than auto-applying it.
+func (e *Evaluator) AutoApply(v value.Value, autoArgs *value.Attrs) (value.Value, error) {
+	return e.autoApply(v, autoArgs)
+}
+
+// autoApply applies v if it's a function, passing autoArgs (or an empty
+// attrset if autoArgs is nil) as the sole argument; any other value is
+// returned unchanged.
+func (e *Evaluator) autoApply(v value.Value, autoArgs *value.Attrs) (value.Value, error) {
+	args := autoArgs
+	if args == nil {
+		args = value.NewAttrs()
+	}
+
+	switch fn := v.(type) {
+	case *value.Function:
+		body, ok := fn.Body().(types.Expr)
+		if !ok {
+			return nil, errors.New("invalid function body")
+		}
+
+		fnEnv := fn.Env().Extend().(*value.Env)
+		fnEnv.Set(fn.Param(), args)
+
+		result, err := e.evalExpr(body, fnEnv)
+		if err != nil {
+			return nil, err
+		}
+
+		return value.Force(result)
+	case *value.Builtin:
+		result, err := fn.Apply([]value.Value{args})
+		if err != nil {
+			return nil, err
+		}
+
+		return value.Force(result)
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,107 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/conneroisu/gix/internal/types"
+)
+
+// mergeAttrBindings rewrites an attrset's bindings so that a dotted-path
+// write into an inline, non-recursive attrset literal merges with it
+// instead of colliding as an attribute path conflict - e.g.
+// `{ a = { b = 1; }; a.c = 2; }` behaves like `{ a = { b = 1; c = 2; }; }`,
+// matching real Nix. It also rejects two bindings that write the exact
+// same full path as a duplicate-attribute error naming that path, which
+// evalAttrSet's per-binding evaluation has no way to catch on its own
+// (it would just silently let the later one win).
+//
+// Only a plain attrset literal with no inherit clauses of its own is
+// eligible to merge this way: that's the case that's unambiguous
+// without re-running scope resolution (a `rec { }` literal's bindings
+// can see each other and the outer `with`/`rec` scope differently
+// depending on where they end up, and an inherited name can't be
+// relocated by a textual rewrite). Anything else that collides with a
+// longer path - a variable reference, a `rec { }`, a literal with
+// inherits - is still reported as an attribute path conflict by
+// setNestedAttr, the existing behavior.
+func mergeAttrBindings(bindings []types.AttrBinding) ([]types.AttrBinding, error) {
+	out := append([]types.AttrBinding(nil), bindings...)
+
+	for {
+		mergedAny := false
+
+		for i, b := range out {
+			lit, ok := b.Value.(*types.AttrSetExpr)
+			if !ok || lit.Recursive || len(lit.Inherits) > 0 {
+				continue
+			}
+
+			if !anyStrictlyDeeper(out, i, b.Path) {
+				continue
+			}
+
+			replacement := make([]types.AttrBinding, len(lit.Bindings))
+			for j, inner := range lit.Bindings {
+				replacement[j] = types.AttrBinding{
+					Path:  append(append([]string{}, b.Path...), inner.Path...),
+					Value: inner.Value,
+					Span:  inner.Span,
+				}
+			}
+
+			rewritten := make([]types.AttrBinding, 0, len(out)-1+len(replacement))
+			rewritten = append(rewritten, out[:i]...)
+			rewritten = append(rewritten, replacement...)
+			rewritten = append(rewritten, out[i+1:]...)
+			out = rewritten
+			mergedAny = true
+
+			break // indices shifted underneath the loop; rescan from the top
+		}
+
+		if !mergedAny {
+			break
+		}
+	}
+
+	seen := make(map[string]bool, len(out))
+	for _, b := range out {
+		path := strings.Join(b.Path, ".")
+		if seen[path] {
+			return nil, fmt.Errorf("attribute '%s' already defined", path)
+		}
+		seen[path] = true
+	}
+
+	return out, nil
+}
+
+// anyStrictlyDeeper reports whether some binding in bindings other than
+// the one at index self has a path that extends prefix (i.e. prefix is
+// a strict prefix of it), meaning the literal at prefix needs to be
+// unrolled to merge with that deeper write.
+func anyStrictlyDeeper(bindings []types.AttrBinding, self int, prefix []string) bool {
+	for i, b := range bindings {
+		if i != self && len(b.Path) > len(prefix) && pathHasPrefix(b.Path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pathHasPrefix reports whether prefix is a prefix of path.
+func pathHasPrefix(path, prefix []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+
+	for i, seg := range prefix {
+		if path[i] != seg {
+			return false
+		}
+	}
+
+	return true
+}
@@ -3,15 +3,18 @@ package eval
 import (
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 
+	"github.com/conneroisu/gix/internal/types"
 	"github.com/conneroisu/gix/internal/value"
 	"github.com/conneroisu/gix/pkg/derivation"
 )
 
-// registerBuiltins populates the evaluator with all standard Nix built-in functions.
-// This creates the standard library that's available in all Nix expressions,
-// including type checking, data manipulation, and system functions.
+// registerBuiltins populates the evaluator's registry with all standard
+// Nix built-in functions. This creates the standard library that's
+// available in all Nix expressions, including type checking, data
+// manipulation, and system functions.
 //
 // The built-ins are organized into categories:
 // - Constants: true, false, null
@@ -19,84 +22,73 @@ import (
 // - Conversion: toString
 // - List operations: length, head, tail, elem
 // - Attribute operations: attrNames, attrValues, hasAttr, getAttr
-// - Math functions: add, sub, mul, div
+// - Math functions: add, sub, mul, div, bitAnd, bitOr, bitXor, quot, rem, ceil, floor
+// - Evaluation control: seq, deepSeq
 // - System functions: derivation.
 func (e *Evaluator) registerBuiltins() {
-	// Built-in constants - fundamental values available in all expressions
-	e.builtins["true"] = value.Bool(true)   // Boolean true constant
-	e.builtins["false"] = value.Bool(false) // Boolean false constant
-	e.builtins["null"] = value.Null{}       // Null constant
+	r := e.registry
 
 	// Type checking functions - runtime type inspection
 	// Nix examples: isNull null → true, isBool "hello" → false
-	e.registerBuiltin("isNull", 1, builtinIsNull)         // isNull value → bool
-	e.registerBuiltin("isBool", 1, builtinIsBool)         // isBool value → bool
-	e.registerBuiltin("isInt", 1, builtinIsInt)           // isInt value → bool
-	e.registerBuiltin("isFloat", 1, builtinIsFloat)       // isFloat value → bool
-	e.registerBuiltin("isString", 1, builtinIsString)     // isString value → bool
-	e.registerBuiltin("isList", 1, builtinIsList)         // isList value → bool
-	e.registerBuiltin("isAttrs", 1, builtinIsAttrs)       // isAttrs value → bool
-	e.registerBuiltin("isFunction", 1, builtinIsFunction) // isFunction value → bool
+	r.Register("isNull", 1, builtinIsNull)         // isNull value → bool
+	r.Register("isBool", 1, builtinIsBool)         // isBool value → bool
+	r.Register("isInt", 1, builtinIsInt)           // isInt value → bool
+	r.Register("isFloat", 1, builtinIsFloat)       // isFloat value → bool
+	r.Register("isString", 1, builtinIsString)     // isString value → bool
+	r.Register("isList", 1, builtinIsList)         // isList value → bool
+	r.Register("isAttrs", 1, builtinIsAttrs)       // isAttrs value → bool
+	r.Register("isFunction", 1, builtinIsFunction) // isFunction value → bool
 
 	// Conversion functions - type transformations
 	// Nix example: toString 42 → "42"
-	e.registerBuiltin("toString", 1, builtinToString) // toString value → string
+	r.Register("toString", 1, builtinToString) // toString value → string
 
 	// List operations - working with sequences
 	// Nix examples: length [1 2 3] → 3, head [1 2 3] → 1
-	e.registerBuiltin("length", 1, builtinLength) // length list|string|attrs → int
-	e.registerBuiltin("head", 1, builtinHead)     // head list → value
-	e.registerBuiltin("tail", 1, builtinTail)     // tail list → list
-	e.registerBuiltin("elem", 2, builtinElem)     // elem value list → bool
+	r.Register("length", 1, builtinLength)      // length list|string|attrs → int
+	r.Register("head", 1, builtinHead)          // head list → value
+	r.Register("tail", 1, builtinTail)          // tail list → list
+	r.RegisterPrimOpApp("elem", 2, builtinElem) // elem value list → bool
 
 	// Attribute set operations - working with key-value mappings
 	// Nix examples: attrNames {x=1; y=2;} → ["x" "y"]
-	e.registerBuiltin("attrNames", 1, builtinAttrNames)   // attrNames attrs → list
-	e.registerBuiltin("attrValues", 1, builtinAttrValues) // attrValues attrs → list
-	e.registerBuiltin("hasAttr", 2, builtinHasAttr)       // hasAttr name attrs → bool
-	e.registerBuiltin("getAttr", 2, builtinGetAttr)       // getAttr name attrs → value
+	r.Register("attrNames", 1, builtinAttrNames)      // attrNames attrs → list
+	r.Register("attrValues", 1, builtinAttrValues)    // attrValues attrs → list
+	r.RegisterPrimOpApp("hasAttr", 2, builtinHasAttr) // hasAttr name attrs → bool
+	r.RegisterPrimOpApp("getAttr", 2, builtinGetAttr) // getAttr name attrs → value
 
 	// Mathematical functions - arithmetic operations
 	// Nix examples: add 1 2 → 3, mul 3 4 → 12
-	e.registerBuiltin("add", 2, builtinAdd) // add a b → number
-	e.registerBuiltin("sub", 2, builtinSub) // sub a b → number
-	e.registerBuiltin("mul", 2, builtinMul) // mul a b → number
-	e.registerBuiltin("div", 2, builtinDiv) // div a b → number
+	r.RegisterPrimOpApp("add", 2, builtinAdd) // add a b → number
+	r.RegisterPrimOpApp("sub", 2, builtinSub) // sub a b → number
+	r.RegisterPrimOpApp("mul", 2, builtinMul) // mul a b → number
+	r.RegisterPrimOpApp("div", 2, builtinDiv) // div a b → number
+
+	// Integer-only arithmetic - bitwise ops and truncating division.
+	// Nix examples: bitAnd 6 3 → 2, quot 7 2 → 3, rem 7 2 → 1
+	r.RegisterPrimOpApp("bitAnd", 2, builtinBitAnd) // bitAnd a b → int
+	r.RegisterPrimOpApp("bitOr", 2, builtinBitOr)   // bitOr a b → int
+	r.RegisterPrimOpApp("bitXor", 2, builtinBitXor) // bitXor a b → int
+	r.RegisterPrimOpApp("quot", 2, builtinQuot)     // quot a b → int, truncated toward zero
+	r.RegisterPrimOpApp("rem", 2, builtinRem)       // rem a b → int, sign of dividend
+
+	// Rounding functions - float to int, Nix's own ceil/floor.
+	// Nix examples: ceil 1.5 → 2, floor 1.5 → 1
+	r.Register("ceil", 1, builtinCeil)   // ceil float → int
+	r.Register("floor", 1, builtinFloor) // floor float → int
+
+	// Evaluation control - forcing otherwise-lazy values explicitly
+	// Nix examples: seq 1 2 → 2, deepSeq { a = [ 1 ]; } 2 → 2
+	r.RegisterPrimOpApp("seq", 2, builtinSeq)         // seq e1 e2 → e2, having forced e1
+	r.RegisterPrimOpApp("deepSeq", 2, builtinDeepSeq) // deepSeq e1 e2 → e2, having fully forced e1
 
 	// System functions - Nix-specific operations
 	// Nix example: derivation {name="hello"; builder="/bin/sh"; args=["-c" "echo hello"];}
-	e.registerBuiltin("derivation", 1, builtinDerivation) // derivation attrs → attrs
-}
+	r.Register("derivation", 1, builtinDerivation) // derivation attrs → attrs
 
-// registerBuiltin wraps a built-in function implementation with arity checking.
-// This ensures that built-in functions receive the correct number of arguments,
-// providing clear error messages when called incorrectly.
-//
-// Parameters:
-// - name: The function name as it appears in Nix expressions
-// - arity: Expected number of arguments (e.g., 1 for unary, 2 for binary)
-// - fn: The Go implementation function
-//
-// Example usage in Go:
-//
-//	e.registerBuiltin("add", 2, builtinAdd)  // Registers add as 2-argument function
-func (e *Evaluator) registerBuiltin(
-	name string,
-	arity int,
-	fn func([]value.Value) (value.Value, error),
-) {
-	// Create wrapper that validates argument count before calling implementation
-	wrapped := func(args []value.Value) (value.Value, error) {
-		if len(args) != arity {
-			// Provide clear error message for incorrect argument count
-			return nil, fmt.Errorf("%s expects %d argument(s), got %d", name, arity, len(args))
-		}
-
-		// Argument count is correct - delegate to actual implementation
-		return fn(args)
-	}
-	// Register the wrapped function in the built-ins registry
-	e.builtins[name] = value.NewBuiltin(name, wrapped)
+	// Documentation introspection - mirrors builtins.doc.
+	// Nix example: doc (x: x + 1) → { content = "..."; isPrimop = false; ... }
+	r.Register("doc", 1, builtinDoc) // doc function → attrs
 }
 
 // =============================================================================
@@ -108,7 +100,7 @@ func (e *Evaluator) registerBuiltin(
 // builtinIsNull checks if a value is null.
 // Nix usage: isNull null → true, isNull 42 → false
 // Go implementation: checks if value implements value.Null interface.
-func builtinIsNull(args []value.Value) (value.Value, error) {
+func builtinIsNull(_ *Evaluator, args []value.Value) (value.Value, error) {
 	// Use Go type assertion to check if the value is null
 	_, isNull := args[0].(value.Null)
 
@@ -118,7 +110,7 @@ func builtinIsNull(args []value.Value) (value.Value, error) {
 // builtinIsBool checks if a value is a boolean.
 // Nix usage: isBool true → true, isBool "hello" → false
 // Go implementation: checks if value implements value.Bool interface.
-func builtinIsBool(args []value.Value) (value.Value, error) {
+func builtinIsBool(_ *Evaluator, args []value.Value) (value.Value, error) {
 	// Use Go type assertion to check if the value is a boolean
 	_, isBool := args[0].(value.Bool)
 
@@ -128,27 +120,35 @@ func builtinIsBool(args []value.Value) (value.Value, error) {
 // builtinIsInt checks if a value is an integer.
 // Nix usage: isInt 42 → true, isInt 3.14 → false
 // Go implementation: checks if value implements value.Int interface.
-func builtinIsInt(args []value.Value) (value.Value, error) {
-	// Use Go type assertion to check if the value is an integer
-	_, isInt := args[0].(value.Int)
-
-	return value.Bool(isInt), nil
+func builtinIsInt(_ *Evaluator, args []value.Value) (value.Value, error) {
+	// A value.BigInt is still a Nix int - it only exists because the
+	// native int64 one would have overflowed - so it counts here too.
+	switch args[0].(type) {
+	case value.Int, value.BigInt:
+		return value.Bool(true), nil
+	default:
+		return value.Bool(false), nil
+	}
 }
 
 // builtinIsFloat checks if a value is a floating-point number.
 // Nix usage: isFloat 3.14 → true, isFloat 42 → false
 // Go implementation: checks if value implements value.Float interface.
-func builtinIsFloat(args []value.Value) (value.Value, error) {
-	// Use Go type assertion to check if the value is a float
-	_, isFloat := args[0].(value.Float)
-
-	return value.Bool(isFloat), nil
+func builtinIsFloat(_ *Evaluator, args []value.Value) (value.Value, error) {
+	// A value.Rat is still a Nix float from user code's perspective -
+	// Nix has no fraction literal or type of its own - so it counts here too.
+	switch args[0].(type) {
+	case value.Float, value.Rat:
+		return value.Bool(true), nil
+	default:
+		return value.Bool(false), nil
+	}
 }
 
 // builtinIsString checks if a value is a string.
 // Nix usage: isString "hello" → true, isString 42 → false
 // Go implementation: checks if value implements value.String interface.
-func builtinIsString(args []value.Value) (value.Value, error) {
+func builtinIsString(_ *Evaluator, args []value.Value) (value.Value, error) {
 	// Use Go type assertion to check if the value is a string
 	_, isString := args[0].(value.String)
 
@@ -158,7 +158,7 @@ func builtinIsString(args []value.Value) (value.Value, error) {
 // builtinIsList checks if a value is a list.
 // Nix usage: isList [1 2 3] → true, isList {x=1;} → false
 // Go implementation: checks if value is a pointer to value.List.
-func builtinIsList(args []value.Value) (value.Value, error) {
+func builtinIsList(_ *Evaluator, args []value.Value) (value.Value, error) {
 	// Use Go type assertion to check if the value is a list
 	_, isList := args[0].(*value.List)
 
@@ -168,7 +168,7 @@ func builtinIsList(args []value.Value) (value.Value, error) {
 // builtinIsAttrs checks if a value is an attribute set.
 // Nix usage: isAttrs {x=1; y=2;} → true, isAttrs [1 2 3] → false
 // Go implementation: checks if value is a pointer to value.Attrs.
-func builtinIsAttrs(args []value.Value) (value.Value, error) {
+func builtinIsAttrs(_ *Evaluator, args []value.Value) (value.Value, error) {
 	// Use Go type assertion to check if the value is an attribute set
 	_, isAttrs := args[0].(*value.Attrs)
 
@@ -178,7 +178,7 @@ func builtinIsAttrs(args []value.Value) (value.Value, error) {
 // builtinIsFunction checks if a value is a function (user-defined or built-in).
 // Nix usage: isFunction (x: x + 1) → true, isFunction length → true, isFunction 42 → false
 // Go implementation: checks if value is either *value.Function or *value.Builtin.
-func builtinIsFunction(args []value.Value) (value.Value, error) {
+func builtinIsFunction(_ *Evaluator, args []value.Value) (value.Value, error) {
 	// Check if value is either a user function or built-in function
 	switch args[0].(type) {
 	case *value.Function, *value.Builtin:
@@ -196,41 +196,113 @@ func builtinIsFunction(args []value.Value) (value.Value, error) {
 // builtinToString converts various value types to their string representations.
 // Nix usage: toString 42 → "42", toString true → "true", toString 3.14 → "3.14"
 // Go implementation: uses type switch and Go's standard conversion functions.
-func builtinToString(args []value.Value) (value.Value, error) {
-	// Convert value to string based on its type
-	switch v := args[0].(type) {
+func builtinToString(e *Evaluator, args []value.Value) (value.Value, error) {
+	return e.coerceToString(args[0])
+}
+
+// coerceToString implements Nix's implicit string coercion, shared by
+// builtinToString and string interpolation ("${ ... }"): Int/Float/Bool/
+// Null render to their canonical text, a Path or a derivation attrset
+// carries its own string context along (so building on the result, e.g.
+// passing it to another derivation, still records the dependency), an
+// attrset with a __toString attribute defers to calling it with the
+// attrset itself (Nix's mechanism for giving arbitrary values a custom
+// string representation), and anything else is a type error.
+func (e *Evaluator) coerceToString(v value.Value) (value.String, error) {
+	switch v := v.(type) {
 	case value.String:
 		// Already a string - return as-is
 		return v, nil
 	case value.Int:
 		// Convert integer to decimal string representation
-		return value.String(strconv.FormatInt(int64(v), 10)), nil
+		return value.NewString(strconv.FormatInt(int64(v), 10)), nil
 	case value.Float:
 		// Convert float to string with automatic precision
-		return value.String(strconv.FormatFloat(float64(v), 'f', -1, 64)), nil
+		return value.NewString(strconv.FormatFloat(float64(v), 'f', -1, 64)), nil
+	case value.BigInt:
+		// A value.BigInt is exact, unlike Float, so render its decimal
+		// digits directly instead of routing through a float64 that
+		// would lose precision.
+		return value.NewString(v.Int.String()), nil
+	case value.Rat:
+		// The one place a value.Rat demotes to a string is here: there's
+		// no Nix syntax for a fraction literal, so toString/interpolation
+		// render the same decimal approximation Float would.
+		f, _ := v.Rat.Float64()
+
+		return value.NewString(strconv.FormatFloat(f, 'f', -1, 64)), nil
 	case value.Bool:
 		// Convert boolean to "true" or "false"
 		if v {
-			return value.String("true"), nil
+			return value.NewString("true"), nil
 		}
 
-		return value.String("false"), nil
+		return value.NewString("false"), nil
 	case value.Null:
-		return value.String("null"), nil
+		return value.NewString("null"), nil
 	case value.Path:
-		return value.String(v), nil
+		// A path carries context referencing itself, so passing it on to
+		// derivation (e.g. as a source file) is discovered as an
+		// inputSrc without the caller declaring it separately.
+		path := string(v)
+		key, elem := value.PathContextElem(path)
+
+		return value.NewStringWithContext(path, value.StringContext{key: elem}), nil
+	case *value.Attrs:
+		// An attrset with its own __toString takes priority over the
+		// outPath convenience below, the same order real Nix checks
+		// them in - it's how a derivation can override its own default
+		// stringification.
+		if toString, ok := v.Get("__toString"); ok {
+			result, err := e.applyFunction(toString, v)
+			if err != nil {
+				return value.String{}, err
+			}
+
+			result, err = value.Force(result)
+			if err != nil {
+				return value.String{}, err
+			}
+
+			s, ok := result.(value.String)
+			if !ok {
+				return value.String{}, fmt.Errorf("__toString must return a string, got %v", result.Type())
+			}
+
+			return s, nil
+		}
+
+		// A derivation's result attrset stringifies to its default
+		// output path, context and all - the same convenience real Nix
+		// gets by special-casing derivation attrsets in coerceToString.
+		outPath, ok := v.Get("outPath")
+		if !ok {
+			return value.String{}, fmt.Errorf("cannot convert %v to string", v.Type())
+		}
+
+		outPath, err := value.Force(outPath)
+		if err != nil {
+			return value.String{}, err
+		}
+
+		s, ok := outPath.(value.String)
+		if !ok {
+			return value.String{}, fmt.Errorf("cannot convert %v to string", v.Type())
+		}
+
+		return s, nil
 	default:
-		return nil, fmt.Errorf("cannot convert %v to string", v.Type())
+		return value.String{}, fmt.Errorf("cannot convert %v to string", v.Type())
 	}
 }
 
 // List operations.
-func builtinLength(args []value.Value) (value.Value, error) {
+func builtinLength(_ *Evaluator, args []value.Value) (value.Value, error) {
 	switch v := args[0].(type) {
 	case *value.List:
 		return value.Int(v.Len()), nil
 	case value.String:
-		return value.Int(len(v)), nil
+		return value.Int(len(v.Raw)), nil
 	case *value.Attrs:
 		return value.Int(v.Len()), nil
 	default:
@@ -238,7 +310,7 @@ func builtinLength(args []value.Value) (value.Value, error) {
 	}
 }
 
-func builtinHead(args []value.Value) (value.Value, error) {
+func builtinHead(_ *Evaluator, args []value.Value) (value.Value, error) {
 	list, ok := args[0].(*value.List)
 	if !ok {
 		return nil, fmt.Errorf("head expects a list, got %v", args[0].Type())
@@ -251,7 +323,7 @@ func builtinHead(args []value.Value) (value.Value, error) {
 	return list.Get(0), nil
 }
 
-func builtinTail(args []value.Value) (value.Value, error) {
+func builtinTail(_ *Evaluator, args []value.Value) (value.Value, error) {
 	list, ok := args[0].(*value.List)
 	if !ok {
 		return nil, fmt.Errorf("tail expects a list, got %v", args[0].Type())
@@ -266,7 +338,7 @@ func builtinTail(args []value.Value) (value.Value, error) {
 	return value.NewList(elements[1:]...), nil
 }
 
-func builtinElem(args []value.Value) (value.Value, error) {
+func builtinElem(_ *Evaluator, args []value.Value) (value.Value, error) {
 	elem := args[0]
 	list, ok := args[1].(*value.List)
 	if !ok {
@@ -283,7 +355,7 @@ func builtinElem(args []value.Value) (value.Value, error) {
 }
 
 // Attribute set operations.
-func builtinAttrNames(args []value.Value) (value.Value, error) {
+func builtinAttrNames(_ *Evaluator, args []value.Value) (value.Value, error) {
 	attrs, ok := args[0].(*value.Attrs)
 	if !ok {
 		return nil, fmt.Errorf("attrNames expects an attribute set, got %v", args[0].Type())
@@ -292,13 +364,13 @@ func builtinAttrNames(args []value.Value) (value.Value, error) {
 	keys := attrs.Keys()
 	names := make([]value.Value, len(keys))
 	for i, k := range keys {
-		names[i] = value.String(k)
+		names[i] = value.NewString(k)
 	}
 
 	return value.NewList(names...), nil
 }
 
-func builtinAttrValues(args []value.Value) (value.Value, error) {
+func builtinAttrValues(_ *Evaluator, args []value.Value) (value.Value, error) {
 	attrs, ok := args[0].(*value.Attrs)
 	if !ok {
 		return nil, fmt.Errorf("attrValues expects an attribute set, got %v", args[0].Type())
@@ -314,7 +386,7 @@ func builtinAttrValues(args []value.Value) (value.Value, error) {
 	return value.NewList(values...), nil
 }
 
-func builtinHasAttr(args []value.Value) (value.Value, error) {
+func builtinHasAttr(_ *Evaluator, args []value.Value) (value.Value, error) {
 	name, ok := args[0].(value.String)
 	if !ok {
 		return nil, fmt.Errorf("hasAttr expects a string as first argument, got %v", args[0].Type())
@@ -328,12 +400,12 @@ func builtinHasAttr(args []value.Value) (value.Value, error) {
 		)
 	}
 
-	_, exists := attrs.Get(string(name))
+	_, exists := attrs.Get(name.Raw)
 
 	return value.Bool(exists), nil
 }
 
-func builtinGetAttr(args []value.Value) (value.Value, error) {
+func builtinGetAttr(_ *Evaluator, args []value.Value) (value.Value, error) {
 	name, ok := args[0].(value.String)
 	if !ok {
 		return nil, fmt.Errorf("getAttr expects a string as first argument, got %v", args[0].Type())
@@ -347,33 +419,206 @@ func builtinGetAttr(args []value.Value) (value.Value, error) {
 		)
 	}
 
-	val, exists := attrs.Get(string(name))
+	val, exists := attrs.Get(name.Raw)
 	if !exists {
-		return nil, fmt.Errorf("attribute '%s' not found", name)
+		return nil, fmt.Errorf("attribute '%s' not found", name.Raw)
 	}
 
 	return val, nil
 }
 
 // Math functions.
-func builtinAdd(args []value.Value) (value.Value, error) {
-	return evalAdd(args[0], args[1])
+func builtinAdd(e *Evaluator, args []value.Value) (value.Value, error) {
+	return e.evalAdd(args[0], args[1])
+}
+
+func builtinSub(e *Evaluator, args []value.Value) (value.Value, error) {
+	return e.evalSub(args[0], args[1])
+}
+
+func builtinMul(e *Evaluator, args []value.Value) (value.Value, error) {
+	return e.evalMul(args[0], args[1])
+}
+
+func builtinDiv(e *Evaluator, args []value.Value) (value.Value, error) {
+	return e.evalDiv(args[0], args[1])
+}
+
+// asInt requires v to be a value.Int, returning a descriptive error
+// naming fn otherwise - shared by the integer-only builtins below, which
+// (unlike add/sub/mul/div) don't accept a mixed int/float operand.
+func asInt(fn string, v value.Value) (int64, error) {
+	i, ok := v.(value.Int)
+	if !ok {
+		return 0, fmt.Errorf("%s expects an int, got %v", fn, v.Type())
+	}
+
+	return int64(i), nil
+}
+
+func builtinBitAnd(_ *Evaluator, args []value.Value) (value.Value, error) {
+	a, err := asInt("bitAnd", args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := asInt("bitAnd", args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return value.Int(a & b), nil
+}
+
+func builtinBitOr(_ *Evaluator, args []value.Value) (value.Value, error) {
+	a, err := asInt("bitOr", args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := asInt("bitOr", args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return value.Int(a | b), nil
+}
+
+func builtinBitXor(_ *Evaluator, args []value.Value) (value.Value, error) {
+	a, err := asInt("bitXor", args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := asInt("bitXor", args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return value.Int(a ^ b), nil
 }
 
-func builtinSub(args []value.Value) (value.Value, error) {
-	return evalSub(args[0], args[1])
+func builtinQuot(_ *Evaluator, args []value.Value) (value.Value, error) {
+	a, err := asInt("quot", args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := asInt("quot", args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if b == 0 {
+		return nil, errors.New("division by zero")
+	}
+
+	// MinInt64 / -1 overflows int64 the same way MinInt64 * -1 does.
+	if a == math.MinInt64 && b == -1 {
+		return nil, fmt.Errorf("integer overflow in division: %d / %d", a, b)
+	}
+
+	return value.Int(a / b), nil
 }
 
-func builtinMul(args []value.Value) (value.Value, error) {
-	return evalMul(args[0], args[1])
+func builtinRem(_ *Evaluator, args []value.Value) (value.Value, error) {
+	a, err := asInt("rem", args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := asInt("rem", args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if b == 0 {
+		return nil, errors.New("division by zero")
+	}
+
+	return value.Int(a % b), nil
+}
+
+// asFloat64 accepts either an Int or a Float and widens it to float64 -
+// ceil/floor take either in real Nix (`ceil 2` is valid, not just
+// `ceil 2.0`).
+func asFloat64(fn string, v value.Value) (float64, error) {
+	switch n := v.(type) {
+	case value.Int:
+		return float64(n), nil
+	case value.Float:
+		return float64(n), nil
+	default:
+		return 0, fmt.Errorf("%s expects a number, got %v", fn, v.Type())
+	}
 }
 
-func builtinDiv(args []value.Value) (value.Value, error) {
-	return evalDiv(args[0], args[1])
+func builtinCeil(_ *Evaluator, args []value.Value) (value.Value, error) {
+	f, err := asFloat64("ceil", args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return value.Int(int64(math.Ceil(f))), nil
+}
+
+func builtinFloor(_ *Evaluator, args []value.Value) (value.Value, error) {
+	f, err := asFloat64("floor", args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return value.Int(int64(math.Floor(f))), nil
+}
+
+// Evaluation-control functions. Every builtin's arguments already arrive
+// forced to WHNF - curriedBuiltin forces each collected argument before
+// invoking the implementation - so seq's only remaining job is to
+// discard the first, now-forced argument and return the second.
+func builtinSeq(_ *Evaluator, args []value.Value) (value.Value, error) {
+	return args[1], nil
+}
+
+// builtinDeepSeq forces args[0] recursively - every list element and
+// attrset value it (transitively) contains, not just args[0] itself -
+// before returning args[1], matching Nix's deepSeq.
+func builtinDeepSeq(_ *Evaluator, args []value.Value) (value.Value, error) {
+	if err := forceDeep(args[0]); err != nil {
+		return nil, err
+	}
+
+	return args[1], nil
+}
+
+// forceDeep forces v, then recurses into list elements and attrset
+// values so every thunk reachable from v gets forced too.
+func forceDeep(v value.Value) error {
+	v, err := value.Force(v)
+	if err != nil {
+		return err
+	}
+
+	switch val := v.(type) {
+	case *value.List:
+		for i := range val.Len() {
+			if err := forceDeep(val.Get(i)); err != nil {
+				return err
+			}
+		}
+	case *value.Attrs:
+		for _, key := range val.Keys() {
+			elem, _ := val.Get(key)
+			if err := forceDeep(elem); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
 }
 
 // Derivation functions.
-func builtinDerivation(args []value.Value) (value.Value, error) {
+func builtinDerivation(_ *Evaluator, args []value.Value) (value.Value, error) {
 	attrs, ok := args[0].(*value.Attrs)
 	if !ok {
 		return nil, fmt.Errorf("derivation expects an attribute set, got %v", args[0].Type())
@@ -388,3 +633,60 @@ func builtinDerivation(args []value.Value) (value.Value, error) {
 	// Return as attribute set
 	return drv.ToAttrs(), nil
 }
+
+// builtinDoc implements the doc introspection primop: given a function
+// value (user-defined or built-in), it returns an attrset describing
+// its documentation - { content, position, params, isPrimop }, plus
+// example for every built-in (an empty string when it has none).
+// Functions with no doc comment
+// still return a result, just with an empty content string, so callers
+// don't need to special-case undocumented functions.
+func builtinDoc(_ *Evaluator, args []value.Value) (value.Value, error) {
+	result := value.NewAttrs()
+
+	switch fn := args[0].(type) {
+	case *value.Function:
+		result.Set("content", value.NewString(fn.Doc()))
+		result.Set("isPrimop", value.Bool(false))
+		result.Set("position", functionPosition(fn))
+		result.Set("params", value.NewList(value.NewString(fn.Param())))
+
+	case *value.Builtin:
+		params := make([]value.Value, len(fn.Params()))
+		for i, p := range fn.Params() {
+			paramAttrs := value.NewAttrs()
+			paramAttrs.Set("name", value.NewString(p.Name))
+			paramAttrs.Set("doc", value.NewString(p.Doc))
+			params[i] = paramAttrs
+		}
+
+		result.Set("content", value.NewString(fn.Doc()))
+		result.Set("isPrimop", value.Bool(true))
+		result.Set("position", value.Null{})
+		result.Set("params", value.NewList(params...))
+		result.Set("example", value.NewString(fn.Example()))
+
+	default:
+		return nil, fmt.Errorf("doc expects a function, got %v", args[0].Type())
+	}
+
+	return result, nil
+}
+
+// functionPosition reports where a user-defined function was defined,
+// if its body happens to carry position information (every AST node
+// does, via types.Node, but Function.Body is stored as interface{}
+// since the value package can't import the types package).
+func functionPosition(fn *value.Function) value.Value {
+	node, ok := fn.Body().(types.Node)
+	if !ok {
+		return value.Null{}
+	}
+
+	pos := node.Position()
+	posAttrs := value.NewAttrs()
+	posAttrs.Set("line", value.Int(pos.Line))
+	posAttrs.Set("column", value.Int(pos.Column))
+
+	return posAttrs
+}
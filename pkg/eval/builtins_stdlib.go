@@ -0,0 +1,978 @@
+package eval
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/conneroisu/gix/internal/value"
+)
+
+// registerStdlibBuiltins populates the parts of the standard library that
+// go beyond the original hand-picked core in registerBuiltins: the
+// higher-order list/attrset functions real Nix code leans on constantly
+// (map, filter, foldl', ...), JSON and file-system access, and the
+// error-handling primitives (abort, throw, tryEval). Kept in its own
+// file/function rather than folded into registerBuiltins because of its
+// size - registerBuiltins already reads as a manifest of the original
+// core, and this is a second, larger wave added on top of it.
+//
+// Not implemented here: split, match and replaceStrings. Real Nix's
+// versions are backed by C++'s std::regex in extended-POSIX mode, which
+// differs from Go's RE2-based regexp package in enough edge cases
+// (backreferences are simply unsupported by RE2; some character-class
+// and anchoring behavior differs) that a naive regexp.Compile-based
+// implementation would silently diverge from upstream on exactly the
+// inputs most worth getting right. Left as a follow-up rather than
+// shipped as a plausible-looking but subtly incompatible approximation.
+func (e *Evaluator) registerStdlibBuiltins() {
+	r := e.registry
+
+	// Higher-order list operations.
+	r.RegisterPrimOpApp("map", 2, builtinMap)
+	r.RegisterPrimOpApp("filter", 2, builtinFilter)
+	// foldl' is registered under its real Nix name so builtins."foldl'"
+	// works, but this lexer's identifier rule (see lexer.isLetter) has
+	// no apostrophe production, so the bare name can't be typed as an
+	// expression the way it can in upstream Nix.
+	r.RegisterPrimOpApp("foldl'", 3, builtinFoldl)
+	r.RegisterPrimOpApp("genList", 2, builtinGenList)
+	r.Register("concatLists", 1, builtinConcatLists)
+	r.RegisterPrimOpApp("concatMap", 2, builtinConcatMap)
+	r.RegisterPrimOpApp("sort", 2, builtinSort)
+
+	// Attribute set operations.
+	r.Register("listToAttrs", 1, builtinListToAttrs)
+	r.RegisterPrimOpApp("mapAttrs", 2, builtinMapAttrs)
+	r.RegisterPrimOpApp("removeAttrs", 2, builtinRemoveAttrs)
+	r.RegisterPrimOpApp("intersectAttrs", 2, builtinIntersectAttrs)
+	r.RegisterPrimOpApp("catAttrs", 2, builtinCatAttrs)
+
+	// String operations.
+	r.Register("stringLength", 1, builtinStringLength)
+	r.RegisterPrimOpApp("substring", 3, builtinSubstring)
+	r.RegisterPrimOpApp("concatStringsSep", 2, builtinConcatStringsSep)
+
+	// String context introspection - see value.StringContext. These let
+	// Nix code (nixpkgs' stdenv in particular) inspect and rebuild the
+	// dependency metadata derivation threads through string values.
+	r.Register("unsafeDiscardStringContext", 1, builtinUnsafeDiscardStringContext)
+	r.Register("hasContext", 1, builtinHasContext)
+	r.Register("getContext", 1, builtinGetContext)
+	r.RegisterPrimOpApp("appendContext", 2, builtinAppendContext)
+
+	// JSON codec.
+	r.Register("toJSON", 1, builtinToJSON)
+	r.Register("fromJSON", 1, builtinFromJSON)
+
+	// File-system access, resolved against the evaluator's base directory
+	// the same way path literals are (see resolvePath).
+	r.Register("readFile", 1, builtinReadFile)
+	r.Register("pathExists", 1, builtinPathExists)
+
+	// Error handling. tryEval is registered lazily (see RegisterLazy):
+	// it needs to catch an error that forcing its argument would raise,
+	// which requires that forcing not have already happened before its
+	// implementation runs.
+	r.Register("abort", 1, builtinAbort)
+	r.Register("throw", 1, builtinThrow)
+	r.RegisterLazy("tryEval", 1, builtinTryEval)
+
+	// Nix-specific version/name parsing, used throughout nixpkgs.
+	r.RegisterPrimOpApp("compareVersions", 2, builtinCompareVersions)
+	r.Register("parseDrvName", 1, builtinParseDrvName)
+}
+
+// Higher-order list operations.
+
+func builtinMap(e *Evaluator, args []value.Value) (value.Value, error) {
+	fn := args[0]
+
+	list, ok := args[1].(*value.List)
+	if !ok {
+		return nil, fmt.Errorf("map expects a list as second argument, got %v", args[1].Type())
+	}
+
+	elements := list.Elements()
+	results := make([]value.Value, len(elements))
+	for i, elem := range elements {
+		elem := elem
+		results[i] = value.NewThunk(func() (value.Value, error) {
+			return e.applyFunction(fn, elem)
+		})
+	}
+
+	return value.NewList(results...), nil
+}
+
+func builtinFilter(e *Evaluator, args []value.Value) (value.Value, error) {
+	fn := args[0]
+
+	list, ok := args[1].(*value.List)
+	if !ok {
+		return nil, fmt.Errorf("filter expects a list as second argument, got %v", args[1].Type())
+	}
+
+	var kept []value.Value
+	for _, elem := range list.Elements() {
+		result, err := e.applyFunction(fn, elem)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err = value.Force(result)
+		if err != nil {
+			return nil, err
+		}
+
+		keep, ok := result.(value.Bool)
+		if !ok {
+			return nil, fmt.Errorf("filter predicate must return a bool, got %v", result.Type())
+		}
+
+		if keep {
+			kept = append(kept, elem)
+		}
+	}
+
+	return value.NewList(kept...), nil
+}
+
+// builtinFoldl implements foldl' - the strict left fold that's by far
+// the one actually used in nixpkgs (plain foldl is effectively
+// deprecated in upstream Nix). "Strict" here means each accumulator step
+// is forced before continuing, matching the upstream behavior that
+// lends the primed name its meaning.
+func builtinFoldl(e *Evaluator, args []value.Value) (value.Value, error) {
+	fn := args[0]
+	acc := args[1]
+
+	list, ok := args[2].(*value.List)
+	if !ok {
+		return nil, fmt.Errorf("foldl' expects a list as third argument, got %v", args[2].Type())
+	}
+
+	for _, elem := range list.Elements() {
+		applied, err := e.applyFunction(fn, acc)
+		if err != nil {
+			return nil, err
+		}
+
+		next, err := e.applyFunction(applied, elem)
+		if err != nil {
+			return nil, err
+		}
+
+		acc, err = value.Force(next)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return acc, nil
+}
+
+func builtinGenList(e *Evaluator, args []value.Value) (value.Value, error) {
+	fn := args[0]
+
+	n, ok := args[1].(value.Int)
+	if !ok {
+		return nil, fmt.Errorf("genList expects an int as second argument, got %v", args[1].Type())
+	}
+
+	if n < 0 {
+		return nil, fmt.Errorf("genList expects a non-negative length, got %d", n)
+	}
+
+	results := make([]value.Value, n)
+	for i := range results {
+		i := i
+		results[i] = value.NewThunk(func() (value.Value, error) {
+			return e.applyFunction(fn, value.Int(i))
+		})
+	}
+
+	return value.NewList(results...), nil
+}
+
+func builtinConcatLists(_ *Evaluator, args []value.Value) (value.Value, error) {
+	outer, ok := args[0].(*value.List)
+	if !ok {
+		return nil, fmt.Errorf("concatLists expects a list, got %v", args[0].Type())
+	}
+
+	var result []value.Value
+	for _, elem := range outer.Elements() {
+		elem, err := value.Force(elem)
+		if err != nil {
+			return nil, err
+		}
+
+		inner, ok := elem.(*value.List)
+		if !ok {
+			return nil, fmt.Errorf("concatLists expects a list of lists, got element of type %v", elem.Type())
+		}
+
+		result = append(result, inner.Elements()...)
+	}
+
+	return value.NewList(result...), nil
+}
+
+func builtinConcatMap(e *Evaluator, args []value.Value) (value.Value, error) {
+	mapped, err := builtinMap(e, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return builtinConcatLists(e, []value.Value{mapped})
+}
+
+// builtinSort implements Nix's sort: a stable sort driven by a
+// user-supplied "less than" comparator, matching sort's own semantics
+// (it takes a binary predicate, not a three-way comparator).
+func builtinSort(e *Evaluator, args []value.Value) (value.Value, error) {
+	less := args[0]
+
+	list, ok := args[1].(*value.List)
+	if !ok {
+		return nil, fmt.Errorf("sort expects a list as second argument, got %v", args[1].Type())
+	}
+
+	elements := list.Elements()
+	sorted := append([]value.Value(nil), elements...)
+
+	var sortErr error
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sortErr != nil {
+			return false
+		}
+
+		result, err := e.applyFunction(less, sorted[i])
+		if err != nil {
+			sortErr = err
+			return false
+		}
+
+		result, err = e.applyFunction(result, sorted[j])
+		if err != nil {
+			sortErr = err
+			return false
+		}
+
+		result, err = value.Force(result)
+		if err != nil {
+			sortErr = err
+			return false
+		}
+
+		b, ok := result.(value.Bool)
+		if !ok {
+			sortErr = fmt.Errorf("sort comparator must return a bool, got %v", result.Type())
+			return false
+		}
+
+		return bool(b)
+	})
+
+	if sortErr != nil {
+		return nil, sortErr
+	}
+
+	return value.NewList(sorted...), nil
+}
+
+// Attribute set operations.
+
+func builtinListToAttrs(_ *Evaluator, args []value.Value) (value.Value, error) {
+	list, ok := args[0].(*value.List)
+	if !ok {
+		return nil, fmt.Errorf("listToAttrs expects a list, got %v", args[0].Type())
+	}
+
+	result := value.NewAttrs()
+	for _, elem := range list.Elements() {
+		elem, err := value.Force(elem)
+		if err != nil {
+			return nil, err
+		}
+
+		entry, ok := elem.(*value.Attrs)
+		if !ok {
+			return nil, fmt.Errorf("listToAttrs expects a list of { name, value } attrsets, got %v", elem.Type())
+		}
+
+		nameVal, ok := entry.Get("name")
+		if !ok {
+			return nil, errors.New(`listToAttrs: entry is missing required attribute "name"`)
+		}
+
+		nameVal, err = value.Force(nameVal)
+		if err != nil {
+			return nil, err
+		}
+
+		name, ok := nameVal.(value.String)
+		if !ok {
+			return nil, fmt.Errorf("listToAttrs: \"name\" must be a string, got %v", nameVal.Type())
+		}
+
+		attrVal, ok := entry.Get("value")
+		if !ok {
+			return nil, errors.New(`listToAttrs: entry is missing required attribute "value"`)
+		}
+
+		// Earlier entries win on a duplicate name, matching real Nix.
+		if _, exists := result.Get(name.Raw); !exists {
+			result.Set(name.Raw, attrVal)
+		}
+	}
+
+	return result, nil
+}
+
+func builtinMapAttrs(e *Evaluator, args []value.Value) (value.Value, error) {
+	fn := args[0]
+
+	attrs, ok := args[1].(*value.Attrs)
+	if !ok {
+		return nil, fmt.Errorf("mapAttrs expects an attribute set as second argument, got %v", args[1].Type())
+	}
+
+	result := value.NewAttrs()
+	for _, key := range attrs.Keys() {
+		key := key
+		val, _ := attrs.Get(key)
+		result.Set(key, value.NewThunk(func() (value.Value, error) {
+			applied, err := e.applyFunction(fn, value.NewString(key))
+			if err != nil {
+				return nil, err
+			}
+
+			return e.applyFunction(applied, val)
+		}))
+	}
+
+	return result, nil
+}
+
+func builtinRemoveAttrs(_ *Evaluator, args []value.Value) (value.Value, error) {
+	attrs, ok := args[0].(*value.Attrs)
+	if !ok {
+		return nil, fmt.Errorf("removeAttrs expects an attribute set as first argument, got %v", args[0].Type())
+	}
+
+	names, ok := args[1].(*value.List)
+	if !ok {
+		return nil, fmt.Errorf("removeAttrs expects a list as second argument, got %v", args[1].Type())
+	}
+
+	remove := make(map[string]bool, names.Len())
+	for _, elem := range names.Elements() {
+		elem, err := value.Force(elem)
+		if err != nil {
+			return nil, err
+		}
+
+		name, ok := elem.(value.String)
+		if !ok {
+			return nil, fmt.Errorf("removeAttrs expects a list of strings, got element of type %v", elem.Type())
+		}
+
+		remove[name.Raw] = true
+	}
+
+	result := value.NewAttrs()
+	for _, key := range attrs.Keys() {
+		if remove[key] {
+			continue
+		}
+
+		val, _ := attrs.Get(key)
+		result.Set(key, val)
+	}
+
+	return result, nil
+}
+
+func builtinIntersectAttrs(_ *Evaluator, args []value.Value) (value.Value, error) {
+	first, ok := args[0].(*value.Attrs)
+	if !ok {
+		return nil, fmt.Errorf("intersectAttrs expects an attribute set as first argument, got %v", args[0].Type())
+	}
+
+	second, ok := args[1].(*value.Attrs)
+	if !ok {
+		return nil, fmt.Errorf("intersectAttrs expects an attribute set as second argument, got %v", args[1].Type())
+	}
+
+	result := value.NewAttrs()
+	for _, key := range second.Keys() {
+		if val, ok := first.Get(key); ok {
+			result.Set(key, val)
+		}
+	}
+
+	return result, nil
+}
+
+func builtinCatAttrs(_ *Evaluator, args []value.Value) (value.Value, error) {
+	name, ok := args[0].(value.String)
+	if !ok {
+		return nil, fmt.Errorf("catAttrs expects a string as first argument, got %v", args[0].Type())
+	}
+
+	list, ok := args[1].(*value.List)
+	if !ok {
+		return nil, fmt.Errorf("catAttrs expects a list as second argument, got %v", args[1].Type())
+	}
+
+	var result []value.Value
+	for _, elem := range list.Elements() {
+		elem, err := value.Force(elem)
+		if err != nil {
+			return nil, err
+		}
+
+		attrs, ok := elem.(*value.Attrs)
+		if !ok {
+			return nil, fmt.Errorf("catAttrs expects a list of attrsets, got element of type %v", elem.Type())
+		}
+
+		if val, ok := attrs.Get(name.Raw); ok {
+			result = append(result, val)
+		}
+	}
+
+	return value.NewList(result...), nil
+}
+
+// String operations.
+
+func builtinStringLength(_ *Evaluator, args []value.Value) (value.Value, error) {
+	s, ok := args[0].(value.String)
+	if !ok {
+		return nil, fmt.Errorf("stringLength expects a string, got %v", args[0].Type())
+	}
+
+	return value.Int(len(s.Raw)), nil
+}
+
+// builtinSubstring implements Nix's substring start len s: a start past
+// the end of the string returns "" rather than erroring, and a len that
+// overruns the remaining length is clamped - both match upstream's
+// permissive behavior rather than raising an out-of-range error.
+func builtinSubstring(_ *Evaluator, args []value.Value) (value.Value, error) {
+	start, ok := args[0].(value.Int)
+	if !ok {
+		return nil, fmt.Errorf("substring expects an int as first argument, got %v", args[0].Type())
+	}
+
+	length, ok := args[1].(value.Int)
+	if !ok {
+		return nil, fmt.Errorf("substring expects an int as second argument, got %v", args[1].Type())
+	}
+
+	s, ok := args[2].(value.String)
+	if !ok {
+		return nil, fmt.Errorf("substring expects a string as third argument, got %v", args[2].Type())
+	}
+
+	if start < 0 {
+		return nil, fmt.Errorf("substring: negative start %d", start)
+	}
+
+	str := s.Raw
+	if int(start) >= len(str) {
+		return value.NewString(""), nil
+	}
+
+	end := len(str)
+	if length >= 0 && int(start)+int(length) < end {
+		end = int(start) + int(length)
+	}
+
+	return value.NewString(str[start:end]), nil
+}
+
+func builtinConcatStringsSep(_ *Evaluator, args []value.Value) (value.Value, error) {
+	sep, ok := args[0].(value.String)
+	if !ok {
+		return nil, fmt.Errorf("concatStringsSep expects a string as first argument, got %v", args[0].Type())
+	}
+
+	list, ok := args[1].(*value.List)
+	if !ok {
+		return nil, fmt.Errorf("concatStringsSep expects a list as second argument, got %v", args[1].Type())
+	}
+
+	parts := make([]string, list.Len())
+	for i, elem := range list.Elements() {
+		elem, err := value.Force(elem)
+		if err != nil {
+			return nil, err
+		}
+
+		s, ok := elem.(value.String)
+		if !ok {
+			return nil, fmt.Errorf("concatStringsSep expects a list of strings, got element of type %v", elem.Type())
+		}
+
+		parts[i] = s.Raw
+	}
+
+	return value.NewString(strings.Join(parts, sep.Raw)), nil
+}
+
+// String context operations. A string's Context records which
+// derivation outputs or source paths it depends on (see
+// value.StringContext); these built-ins let Nix code inspect that
+// metadata or strip it, the way real Nix's string context API works.
+
+func builtinUnsafeDiscardStringContext(_ *Evaluator, args []value.Value) (value.Value, error) {
+	s, ok := args[0].(value.String)
+	if !ok {
+		return nil, fmt.Errorf("unsafeDiscardStringContext expects a string, got %v", args[0].Type())
+	}
+
+	return value.NewString(s.Raw), nil
+}
+
+func builtinHasContext(_ *Evaluator, args []value.Value) (value.Value, error) {
+	s, ok := args[0].(value.String)
+	if !ok {
+		return nil, fmt.Errorf("hasContext expects a string, got %v", args[0].Type())
+	}
+
+	return value.Bool(s.HasContext()), nil
+}
+
+// builtinGetContext implements Nix's getContext: it returns an
+// attrset keyed by the store paths s's context references, each value
+// an attrset describing why - { outputs = [...]; } for a derivation
+// (one entry per output referenced) or { path = true; } for a plain
+// source path.
+func builtinGetContext(_ *Evaluator, args []value.Value) (value.Value, error) {
+	s, ok := args[0].(value.String)
+	if !ok {
+		return nil, fmt.Errorf("getContext expects a string, got %v", args[0].Type())
+	}
+
+	drvOutputs := make(map[string][]string)
+	result := value.NewAttrs()
+	for _, elem := range s.Context {
+		if elem.DrvPath != "" {
+			drvOutputs[elem.DrvPath] = append(drvOutputs[elem.DrvPath], elem.Output)
+			continue
+		}
+
+		entry := value.NewAttrs()
+		entry.Set("path", value.Bool(true))
+		result.Set(elem.Path, entry)
+	}
+
+	for drvPath, outputs := range drvOutputs {
+		sort.Strings(outputs)
+
+		outs := make([]value.Value, len(outputs))
+		for i, o := range outputs {
+			outs[i] = value.NewString(o)
+		}
+
+		entry := value.NewAttrs()
+		entry.Set("outputs", value.NewList(outs...))
+		result.Set(drvPath, entry)
+	}
+
+	return result, nil
+}
+
+// builtinAppendContext implements Nix's appendContext: given a string
+// and a context attrset in the shape getContext returns, it adds those
+// entries to the string's existing context.
+func builtinAppendContext(_ *Evaluator, args []value.Value) (value.Value, error) {
+	s, ok := args[0].(value.String)
+	if !ok {
+		return nil, fmt.Errorf("appendContext expects a string as first argument, got %v", args[0].Type())
+	}
+
+	ctxAttrs, ok := args[1].(*value.Attrs)
+	if !ok {
+		return nil, fmt.Errorf("appendContext expects an attribute set as second argument, got %v", args[1].Type())
+	}
+
+	added := value.StringContext{}
+	for _, drvOrPath := range ctxAttrs.Keys() {
+		entryVal, _ := ctxAttrs.Get(drvOrPath)
+		entryVal, err := value.Force(entryVal)
+		if err != nil {
+			return nil, err
+		}
+
+		entry, ok := entryVal.(*value.Attrs)
+		if !ok {
+			return nil, fmt.Errorf("appendContext: context entry for %q must be an attribute set", drvOrPath)
+		}
+
+		outputsVal, hasOutputs := entry.Get("outputs")
+		if !hasOutputs {
+			key, elem := value.PathContextElem(drvOrPath)
+			added[key] = elem
+
+			continue
+		}
+
+		outputsVal, err = value.Force(outputsVal)
+		if err != nil {
+			return nil, err
+		}
+
+		outputsList, ok := outputsVal.(*value.List)
+		if !ok {
+			return nil, fmt.Errorf("appendContext: \"outputs\" for %q must be a list", drvOrPath)
+		}
+
+		for _, outVal := range outputsList.Elements() {
+			outVal, err := value.Force(outVal)
+			if err != nil {
+				return nil, err
+			}
+
+			outStr, ok := outVal.(value.String)
+			if !ok {
+				return nil, fmt.Errorf("appendContext: output name for %q must be a string", drvOrPath)
+			}
+
+			key, elem := value.DrvContextElem(drvOrPath, outStr.Raw)
+			added[key] = elem
+		}
+	}
+
+	return value.NewStringWithContext(s.Raw, value.UnionContext(s.Context, added)), nil
+}
+
+// JSON codec.
+
+func builtinToJSON(_ *Evaluator, args []value.Value) (value.Value, error) {
+	native, err := valueToJSONNative(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(native)
+	if err != nil {
+		return nil, fmt.Errorf("toJSON: %w", err)
+	}
+
+	return value.NewString(string(encoded)), nil
+}
+
+// valueToJSONNative converts a forced Value into plain Go data
+// (map[string]any, []any, string, float64, bool, nil) that
+// encoding/json can marshal directly.
+func valueToJSONNative(v value.Value) (interface{}, error) {
+	v, err := value.Force(v)
+	if err != nil {
+		return nil, err
+	}
+
+	switch val := v.(type) {
+	case value.Null:
+		return nil, nil
+	case value.Bool:
+		return bool(val), nil
+	case value.Int:
+		return int64(val), nil
+	case value.Float:
+		return float64(val), nil
+	case value.String:
+		return val.Raw, nil
+	case value.Path:
+		return string(val), nil
+	case *value.List:
+		elements := val.Elements()
+		out := make([]interface{}, len(elements))
+		for i, elem := range elements {
+			out[i], err = valueToJSONNative(elem)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return out, nil
+	case *value.Attrs:
+		out := make(map[string]interface{}, val.Len())
+		for _, key := range val.Keys() {
+			elem, _ := val.Get(key)
+			out[key], err = valueToJSONNative(elem)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return out, nil
+	default:
+		return nil, fmt.Errorf("toJSON: cannot convert %v to JSON", v.Type())
+	}
+}
+
+func builtinFromJSON(_ *Evaluator, args []value.Value) (value.Value, error) {
+	s, ok := args[0].(value.String)
+	if !ok {
+		return nil, fmt.Errorf("fromJSON expects a string, got %v", args[0].Type())
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(s.Raw))
+	decoder.UseNumber()
+
+	var native interface{}
+	if err := decoder.Decode(&native); err != nil {
+		return nil, fmt.Errorf("fromJSON: %w", err)
+	}
+
+	return jsonNativeToValue(native), nil
+}
+
+// jsonNativeToValue converts data decoded by encoding/json (with
+// UseNumber, so integers survive round-tripping instead of always
+// becoming Float) into the corresponding Value.
+func jsonNativeToValue(native interface{}) value.Value {
+	switch v := native.(type) {
+	case nil:
+		return value.Null{}
+	case bool:
+		return value.Bool(v)
+	case json.Number:
+		if i, err := strconv.ParseInt(v.String(), 10, 64); err == nil {
+			return value.Int(i)
+		}
+
+		f, _ := v.Float64()
+
+		return value.Float(f)
+	case string:
+		return value.NewString(v)
+	case []interface{}:
+		elements := make([]value.Value, len(v))
+		for i, elem := range v {
+			elements[i] = jsonNativeToValue(elem)
+		}
+
+		return value.NewList(elements...)
+	case map[string]interface{}:
+		attrs := value.NewAttrs()
+		for key, elem := range v {
+			attrs.Set(key, jsonNativeToValue(elem))
+		}
+
+		return attrs
+	default:
+		// Unreachable for anything encoding/json's Decoder can produce.
+		return value.Null{}
+	}
+}
+
+// File-system access.
+
+func builtinReadFile(e *Evaluator, args []value.Value) (value.Value, error) {
+	path, err := pathArg("readFile", args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := os.ReadFile(e.resolvePath(path))
+	if err != nil {
+		return nil, fmt.Errorf("readFile: %w", err)
+	}
+
+	return value.NewString(string(contents)), nil
+}
+
+func builtinPathExists(e *Evaluator, args []value.Value) (value.Value, error) {
+	path, err := pathArg("pathExists", args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = os.Stat(e.resolvePath(path))
+
+	return value.Bool(err == nil), nil
+}
+
+// pathArg accepts either a value.Path or a value.String as the path
+// argument real Nix's file-system built-ins both allow.
+func pathArg(fn string, v value.Value) (string, error) {
+	switch p := v.(type) {
+	case value.Path:
+		return string(p), nil
+	case value.String:
+		return p.Raw, nil
+	default:
+		return "", fmt.Errorf("%s expects a path or string, got %v", fn, v.Type())
+	}
+}
+
+// Error handling.
+
+func builtinAbort(_ *Evaluator, args []value.Value) (value.Value, error) {
+	msg, ok := args[0].(value.String)
+	if !ok {
+		return nil, fmt.Errorf("abort: %s", args[0].String())
+	}
+
+	return nil, fmt.Errorf("evaluation aborted with the following error message: '%s'", msg.Raw)
+}
+
+func builtinThrow(_ *Evaluator, args []value.Value) (value.Value, error) {
+	msg, ok := args[0].(value.String)
+	if !ok {
+		return nil, fmt.Errorf("%s", args[0].String())
+	}
+
+	return nil, errors.New(msg.Raw)
+}
+
+// builtinTryEval implements tryEval, registered via RegisterLazy so its
+// one argument arrives unforced: forcing it here, inside a recovered
+// scope, is the entire point, since a normal (eager) built-in would have
+// already let any error in it propagate before this function ever ran.
+func builtinTryEval(_ *Evaluator, args []value.Value) (value.Value, error) {
+	result := value.NewAttrs()
+
+	forced, err := value.Force(args[0])
+	if err != nil {
+		result.Set("success", value.Bool(false))
+		result.Set("value", value.Bool(false))
+
+		return result, nil
+	}
+
+	result.Set("success", value.Bool(true))
+	result.Set("value", forced)
+
+	return result, nil
+}
+
+// Version/name parsing.
+
+// builtinCompareVersions implements Nix's dotted-component version
+// comparison: components are split on '.' and '-', each pair compared
+// numerically if both sides are purely numeric and lexically otherwise,
+// and a version with extra trailing components is "greater" the same
+// way "1.0.1" is greater than "1.0" in upstream Nix.
+func builtinCompareVersions(_ *Evaluator, args []value.Value) (value.Value, error) {
+	a, ok := args[0].(value.String)
+	if !ok {
+		return nil, fmt.Errorf("compareVersions expects a string as first argument, got %v", args[0].Type())
+	}
+
+	b, ok := args[1].(value.String)
+	if !ok {
+		return nil, fmt.Errorf("compareVersions expects a string as second argument, got %v", args[1].Type())
+	}
+
+	return value.Int(compareVersionStrings(a.Raw, b.Raw)), nil
+}
+
+func versionComponents(v string) []string {
+	return strings.FieldsFunc(v, func(r rune) bool {
+		return r == '.' || r == '-'
+	})
+}
+
+func compareVersionStrings(a, b string) int {
+	ca, cb := versionComponents(a), versionComponents(b)
+
+	for i := 0; i < len(ca) || i < len(cb); i++ {
+		var pa, pb string
+		if i < len(ca) {
+			pa = ca[i]
+		}
+		if i < len(cb) {
+			pb = cb[i]
+		}
+
+		if cmp := compareVersionComponent(pa, pb); cmp != 0 {
+			return cmp
+		}
+	}
+
+	return 0
+}
+
+func compareVersionComponent(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	na, aIsNum := parseVersionNumber(a)
+	nb, bIsNum := parseVersionNumber(b)
+
+	if aIsNum && bIsNum {
+		switch {
+		case na < nb:
+			return -1
+		case na > nb:
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	if a < b {
+		return -1
+	}
+
+	return 1
+}
+
+func parseVersionNumber(s string) (int64, bool) {
+	if s == "" {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+
+	return n, err == nil
+}
+
+// builtinParseDrvName implements Nix's parseDrvName, splitting a
+// "<name>-<version>" derivation name at the first hyphen that's
+// immediately followed by a digit - the same heuristic upstream uses,
+// so "nix-1.2.3" splits into name "nix" and version "1.2.3", and
+// "gcc-wrapper-10.3.0" splits into name "gcc-wrapper" and version
+// "10.3.0" (the earlier hyphen before "wrapper" doesn't qualify, since
+// it's followed by a letter, not a digit).
+func builtinParseDrvName(_ *Evaluator, args []value.Value) (value.Value, error) {
+	s, ok := args[0].(value.String)
+	if !ok {
+		return nil, fmt.Errorf("parseDrvName expects a string, got %v", args[0].Type())
+	}
+
+	name, version := splitDrvName(s.Raw)
+
+	result := value.NewAttrs()
+	result.Set("name", value.NewString(name))
+	result.Set("version", value.NewString(version))
+
+	return result, nil
+}
+
+func splitDrvName(s string) (name, version string) {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '-' {
+			continue
+		}
+
+		rest := s[i+1:]
+		if rest != "" && rest[0] >= '0' && rest[0] <= '9' {
+			return s[:i], rest
+		}
+	}
+
+	return s, ""
+}
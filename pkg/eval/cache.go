@@ -0,0 +1,747 @@
+package eval
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/conneroisu/gix/internal/types"
+	"github.com/conneroisu/gix/internal/value"
+	"github.com/conneroisu/gix/pkg/lexer"
+	"github.com/conneroisu/gix/pkg/parser"
+)
+
+// This file implements gix's on-disk value cache: a compact binary
+// serialization of a value.Value tree, inspired by the indexed export
+// format Go's own compiler uses to persist type information between
+// packages (cmd/compile/internal/importer/iimport.go). Evaluating a
+// large expression is pure CPU cost with no side effects, so if the
+// source hasn't changed, the evaluated result can simply be replayed
+// from disk instead of recomputed.
+//
+// A cache file is a string table (deduplicating every string the value
+// tree references - attribute names, string contents, context entries)
+// followed by an object table: one record per value, in the post order
+// encodeValue visits them, so every reference a record makes to another
+// value is an index into a strictly earlier part of the table. Null,
+// Bool, Int, Float, String, Path, List, and Attrs serialize completely;
+// Function, Builtin, and every Thunk (forced or not - encodeValue does
+// not look inside one) are cut at that boundary and replaced with a cacheStub recording only
+// where they came from, since what they'd compute depends on captured
+// source and environment this format makes no attempt to serialize.
+
+// cacheMagic identifies gix's binary value-cache format at the start of
+// every file SaveCache writes, so LoadCache can reject anything else
+// quickly instead of misinterpreting arbitrary bytes.
+const cacheMagic = "GIXC"
+
+// cacheFormatVersion is the binary layout of the cache file itself -
+// record tags, header shape, and so on. Bump it whenever that layout
+// changes; bump BuiltinsVersion instead when the built-in library
+// changes (see its doc comment in registry.go).
+const cacheFormatVersion uint32 = 1
+
+// valueTag identifies which kind of record a cache entry holds.
+type valueTag byte
+
+const (
+	tagNull valueTag = iota
+	tagBool
+	tagInt
+	tagFloat
+	tagString
+	tagPath
+	tagList
+	tagAttrs
+	tagStub
+)
+
+// cacheStub stands in for a Function, Builtin, or Thunk that SaveCache
+// encountered - anything it doesn't record as plain data. It satisfies
+// value.Value so LoadCache can put it back in a List or Attrs exactly
+// where the original sat, but it isn't a working function or a usable
+// builtin: code that tries to call it past that point gets an ordinary
+// "not a function" type error, which is the intended behavior
+// - a cacheStub means "re-evaluate this part from source," not "here is
+// the cached result."
+type cacheStub struct {
+	pos     string
+	envHash uint64
+}
+
+func (s *cacheStub) Type() value.Type { return value.TypeFunction }
+func (s *cacheStub) String() string   { return fmt.Sprintf("<CACHED-STUB %s>", s.pos) }
+func (s *cacheStub) Equals(value.Value) bool { return false }
+
+// cacheWriter accumulates the string and object tables SaveCache writes
+// out, deduplicating strings and assigning each encoded value the next
+// free object-table index.
+type cacheWriter struct {
+	strIndex map[string]uint32
+	strings  []string
+	records  [][]byte
+}
+
+func newCacheWriter() *cacheWriter {
+	return &cacheWriter{strIndex: make(map[string]uint32)}
+}
+
+func (w *cacheWriter) internString(s string) uint32 {
+	if idx, ok := w.strIndex[s]; ok {
+		return idx
+	}
+
+	idx := uint32(len(w.strings))
+	w.strings = append(w.strings, s)
+	w.strIndex[s] = idx
+
+	return idx
+}
+
+func (w *cacheWriter) addRecord(rec []byte) uint32 {
+	idx := uint32(len(w.records))
+	w.records = append(w.records, rec)
+
+	return idx
+}
+
+// encodeValue serializes val into w's object table, encoding any values
+// it contains first so every index it records points strictly earlier
+// in the table, and returns val's own index. A value that isn't one of
+// the plain data types (a Function, a Builtin, or a Thunk, whether or
+// not it has been forced - see SaveCache) is recorded as a cacheStub
+// instead of being recursed into.
+func (w *cacheWriter) encodeValue(val value.Value) uint32 {
+	switch v := val.(type) {
+	case value.Null:
+		return w.addRecord([]byte{byte(tagNull)})
+
+	case value.Bool:
+		b := byte(0)
+		if v {
+			b = 1
+		}
+
+		return w.addRecord([]byte{byte(tagBool), b})
+
+	case value.Int:
+		buf := make([]byte, 9)
+		buf[0] = byte(tagInt)
+		binary.LittleEndian.PutUint64(buf[1:], uint64(int64(v)))
+
+		return w.addRecord(buf)
+
+	case value.Float:
+		buf := make([]byte, 9)
+		buf[0] = byte(tagFloat)
+		binary.LittleEndian.PutUint64(buf[1:], math.Float64bits(float64(v)))
+
+		return w.addRecord(buf)
+
+	case value.String:
+		return w.encodeString(v)
+
+	case value.Path:
+		buf := make([]byte, 5)
+		buf[0] = byte(tagPath)
+		binary.LittleEndian.PutUint32(buf[1:], w.internString(string(v)))
+
+		return w.addRecord(buf)
+
+	case *value.List:
+		return w.encodeList(v)
+
+	case *value.Attrs:
+		return w.encodeAttrs(v)
+
+	default:
+		// *value.Function, *value.Builtin, *value.Thunk (and anything
+		// else not covered above) - see cacheStub.
+		return w.encodeStub(val)
+	}
+}
+
+func (w *cacheWriter) encodeString(s value.String) uint32 {
+	rawIdx := w.internString(s.Raw)
+
+	entries := make([]value.StringContextElem, 0, len(s.Context))
+	for _, elem := range s.Context {
+		entries = append(entries, elem)
+	}
+	// Map iteration order is random; sort so the same String always
+	// serializes to the same bytes.
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].DrvPath+"!"+entries[i].Output+entries[i].Path <
+			entries[j].DrvPath+"!"+entries[j].Output+entries[j].Path
+	})
+
+	buf := make([]byte, 9, 9+12*len(entries))
+	buf[0] = byte(tagString)
+	binary.LittleEndian.PutUint32(buf[1:5], rawIdx)
+	binary.LittleEndian.PutUint32(buf[5:9], uint32(len(entries)))
+
+	for _, elem := range entries {
+		var entry [12]byte
+		binary.LittleEndian.PutUint32(entry[0:4], w.internString(elem.DrvPath))
+		binary.LittleEndian.PutUint32(entry[4:8], w.internString(elem.Output))
+		binary.LittleEndian.PutUint32(entry[8:12], w.internString(elem.Path))
+		buf = append(buf, entry[:]...)
+	}
+
+	return w.addRecord(buf)
+}
+
+func (w *cacheWriter) encodeList(l *value.List) uint32 {
+	elems := l.Elements()
+	indices := make([]uint32, len(elems))
+	for i, el := range elems {
+		indices[i] = w.encodeValue(el)
+	}
+
+	buf := make([]byte, 5, 5+4*len(indices))
+	buf[0] = byte(tagList)
+	binary.LittleEndian.PutUint32(buf[1:5], uint32(len(indices)))
+
+	for _, idx := range indices {
+		var tmp [4]byte
+		binary.LittleEndian.PutUint32(tmp[:], idx)
+		buf = append(buf, tmp[:]...)
+	}
+
+	return w.addRecord(buf)
+}
+
+func (w *cacheWriter) encodeAttrs(a *value.Attrs) uint32 {
+	keys := a.Keys() // already sorted
+
+	buf := make([]byte, 5, 5+8*len(keys))
+	buf[0] = byte(tagAttrs)
+	binary.LittleEndian.PutUint32(buf[1:5], uint32(len(keys)))
+
+	for _, k := range keys {
+		val, _ := a.Get(k)
+		keyIdx := w.internString(k)
+		valIdx := w.encodeValue(val)
+
+		var tmp [8]byte
+		binary.LittleEndian.PutUint32(tmp[0:4], keyIdx)
+		binary.LittleEndian.PutUint32(tmp[4:8], valIdx)
+		buf = append(buf, tmp[:]...)
+	}
+
+	return w.addRecord(buf)
+}
+
+// encodeStub records just enough about val to identify it in
+// diagnostics - a source position for a Function, a name for a
+// Builtin - plus, for a Function, a fingerprint of its captured
+// environment's shape (see value.Env.Fingerprint).
+func (w *cacheWriter) encodeStub(val value.Value) uint32 {
+	pos := "<unknown>"
+
+	var envHash uint64
+
+	switch v := val.(type) {
+	case *value.Function:
+		if node, ok := v.Body().(types.Node); ok {
+			p := node.Position()
+			pos = fmt.Sprintf("%d:%d", p.Line, p.Column)
+		}
+		if env, ok := v.Env().(*value.Env); ok {
+			envHash = env.Fingerprint()
+		}
+
+	case *value.Builtin:
+		pos = "<builtin:" + v.Name() + ">"
+
+	case *value.Thunk:
+		pos = "<unevaluated thunk>"
+	}
+
+	buf := make([]byte, 13)
+	buf[0] = byte(tagStub)
+	binary.LittleEndian.PutUint32(buf[1:5], w.internString(pos))
+	binary.LittleEndian.PutUint64(buf[5:13], envHash)
+
+	return w.addRecord(buf)
+}
+
+// SaveCache serializes val (typically the result of a prior Eval call)
+// to path in gix's binary cache format. It doesn't force anything in
+// val and doesn't unwrap Thunks - a List element or Attrs value held in
+// a Thunk is recorded as a cacheStub even if that Thunk has already
+// been forced, the same as a Function or Builtin would be. Only values
+// stored directly as plain data serialize in full.
+func (e *Evaluator) SaveCache(path string, val value.Value) error {
+	w := newCacheWriter()
+	root := w.encodeValue(val)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("SaveCache: %w", err)
+	}
+	defer f.Close()
+
+	bw := bufio.NewWriter(f)
+
+	if _, err := bw.WriteString(cacheMagic); err != nil {
+		return fmt.Errorf("SaveCache: %w", err)
+	}
+	if err := writeUint32(bw, cacheFormatVersion); err != nil {
+		return fmt.Errorf("SaveCache: %w", err)
+	}
+	if err := writeUint32(bw, uint32(BuiltinsVersion)); err != nil {
+		return fmt.Errorf("SaveCache: %w", err)
+	}
+
+	if err := writeUint32(bw, uint32(len(w.strings))); err != nil {
+		return fmt.Errorf("SaveCache: %w", err)
+	}
+	for _, s := range w.strings {
+		if err := writeBlock(bw, []byte(s)); err != nil {
+			return fmt.Errorf("SaveCache: %w", err)
+		}
+	}
+
+	if err := writeUint32(bw, uint32(len(w.records))); err != nil {
+		return fmt.Errorf("SaveCache: %w", err)
+	}
+	for _, rec := range w.records {
+		if err := writeBlock(bw, rec); err != nil {
+			return fmt.Errorf("SaveCache: %w", err)
+		}
+	}
+
+	if err := writeUint32(bw, root); err != nil {
+		return fmt.Errorf("SaveCache: %w", err)
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("SaveCache: %w", err)
+	}
+
+	return nil
+}
+
+// LoadCache reads a value previously written by SaveCache from path. It
+// rejects files written by an incompatible cache format or a
+// differently-versioned build of gix's built-ins (see BuiltinsVersion)
+// rather than risk returning a value that doesn't match what the
+// current evaluator would have computed.
+func (e *Evaluator) LoadCache(path string) (value.Value, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("LoadCache: %w", err)
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+
+	magic := make([]byte, len(cacheMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != cacheMagic {
+		return nil, fmt.Errorf("LoadCache: %s is not a gix cache file", path)
+	}
+
+	formatVersion, err := readUint32(br)
+	if err != nil {
+		return nil, fmt.Errorf("LoadCache: %w", err)
+	}
+	if formatVersion != cacheFormatVersion {
+		return nil, fmt.Errorf(
+			"LoadCache: %s was written by cache format v%d, this gix reads v%d",
+			path, formatVersion, cacheFormatVersion,
+		)
+	}
+
+	builtinsVersion, err := readUint32(br)
+	if err != nil {
+		return nil, fmt.Errorf("LoadCache: %w", err)
+	}
+	if builtinsVersion != uint32(BuiltinsVersion) {
+		return nil, fmt.Errorf(
+			"LoadCache: %s was written by built-ins layout v%d, this gix is v%d - discard and re-evaluate",
+			path, builtinsVersion, BuiltinsVersion,
+		)
+	}
+
+	strs, err := readStrings(br)
+	if err != nil {
+		return nil, fmt.Errorf("LoadCache: %w", err)
+	}
+
+	records, err := readRecords(br)
+	if err != nil {
+		return nil, fmt.Errorf("LoadCache: %w", err)
+	}
+
+	rootIdx, err := readUint32(br)
+	if err != nil {
+		return nil, fmt.Errorf("LoadCache: %w", err)
+	}
+
+	values, err := decodeRecords(strs, records)
+	if err != nil {
+		return nil, fmt.Errorf("LoadCache: %w", err)
+	}
+
+	if int(rootIdx) >= len(values) {
+		return nil, fmt.Errorf("LoadCache: %s has an out-of-range root index", path)
+	}
+
+	return values[rootIdx], nil
+}
+
+// decodeRecords turns raw (records in the order SaveCache wrote them -
+// every index a later record references is strictly earlier in this
+// slice) into the value.Value each one represents.
+func decodeRecords(strs []string, raw [][]byte) ([]value.Value, error) {
+	values := make([]value.Value, len(raw))
+
+	for i, rec := range raw {
+		if len(rec) == 0 {
+			return nil, fmt.Errorf("record %d is empty", i)
+		}
+
+		body := rec[1:]
+
+		switch valueTag(rec[0]) {
+		case tagNull:
+			values[i] = value.Null{}
+
+		case tagBool:
+			if len(body) < 1 {
+				return nil, fmt.Errorf("record %d: truncated bool", i)
+			}
+			values[i] = value.Bool(body[0] != 0)
+
+		case tagInt:
+			if len(body) < 8 {
+				return nil, fmt.Errorf("record %d: truncated int", i)
+			}
+			values[i] = value.Int(int64(binary.LittleEndian.Uint64(body)))
+
+		case tagFloat:
+			if len(body) < 8 {
+				return nil, fmt.Errorf("record %d: truncated float", i)
+			}
+			values[i] = value.Float(math.Float64frombits(binary.LittleEndian.Uint64(body)))
+
+		case tagString:
+			s, err := decodeString(strs, body)
+			if err != nil {
+				return nil, fmt.Errorf("record %d: %w", i, err)
+			}
+			values[i] = s
+
+		case tagPath:
+			idx, err := stringAt(strs, body, 0)
+			if err != nil {
+				return nil, fmt.Errorf("record %d: %w", i, err)
+			}
+			values[i] = value.Path(idx)
+
+		case tagList:
+			l, err := decodeList(values, body)
+			if err != nil {
+				return nil, fmt.Errorf("record %d: %w", i, err)
+			}
+			values[i] = l
+
+		case tagAttrs:
+			a, err := decodeAttrs(strs, values, body)
+			if err != nil {
+				return nil, fmt.Errorf("record %d: %w", i, err)
+			}
+			values[i] = a
+
+		case tagStub:
+			if len(body) < 12 {
+				return nil, fmt.Errorf("record %d: truncated stub", i)
+			}
+			pos, err := stringAt(strs, body, 0)
+			if err != nil {
+				return nil, fmt.Errorf("record %d: %w", i, err)
+			}
+			values[i] = &cacheStub{pos: pos, envHash: binary.LittleEndian.Uint64(body[4:12])}
+
+		default:
+			return nil, fmt.Errorf("record %d: unknown tag %d", i, rec[0])
+		}
+	}
+
+	return values, nil
+}
+
+func decodeString(strs []string, body []byte) (value.String, error) {
+	if len(body) < 8 {
+		return value.String{}, fmt.Errorf("truncated string header")
+	}
+
+	raw, err := stringAt(strs, body, 0)
+	if err != nil {
+		return value.String{}, err
+	}
+
+	count := binary.LittleEndian.Uint32(body[4:8])
+	off := 8
+
+	ctx := value.StringContext{}
+
+	for c := uint32(0); c < count; c++ {
+		if len(body) < off+12 {
+			return value.String{}, fmt.Errorf("truncated string context entry %d", c)
+		}
+
+		drvPath, err := stringAt(strs, body, off)
+		if err != nil {
+			return value.String{}, err
+		}
+		output, err := stringAt(strs, body, off+4)
+		if err != nil {
+			return value.String{}, err
+		}
+		path, err := stringAt(strs, body, off+8)
+		if err != nil {
+			return value.String{}, err
+		}
+
+		off += 12
+
+		if drvPath != "" {
+			key, elem := value.DrvContextElem(drvPath, output)
+			ctx[key] = elem
+		} else if path != "" {
+			key, elem := value.PathContextElem(path)
+			ctx[key] = elem
+		}
+	}
+
+	if len(ctx) == 0 {
+		return value.NewString(raw), nil
+	}
+
+	return value.NewStringWithContext(raw, ctx), nil
+}
+
+func decodeList(values []value.Value, body []byte) (*value.List, error) {
+	if len(body) < 4 {
+		return nil, fmt.Errorf("truncated list header")
+	}
+
+	count := binary.LittleEndian.Uint32(body[0:4])
+	elems := make([]value.Value, count)
+
+	for j := uint32(0); j < count; j++ {
+		off := 4 + 4*j
+		if len(body) < int(off)+4 {
+			return nil, fmt.Errorf("truncated list element %d", j)
+		}
+
+		idx := binary.LittleEndian.Uint32(body[off : off+4])
+		if int(idx) >= len(values) {
+			return nil, fmt.Errorf("list element %d references out-of-range index %d", j, idx)
+		}
+
+		elems[j] = values[idx]
+	}
+
+	return value.NewList(elems...), nil
+}
+
+func decodeAttrs(strs []string, values []value.Value, body []byte) (*value.Attrs, error) {
+	if len(body) < 4 {
+		return nil, fmt.Errorf("truncated attrs header")
+	}
+
+	count := binary.LittleEndian.Uint32(body[0:4])
+	attrs := value.NewAttrs()
+
+	for j := uint32(0); j < count; j++ {
+		off := 4 + 8*j
+		if len(body) < int(off)+8 {
+			return nil, fmt.Errorf("truncated attrs entry %d", j)
+		}
+
+		key, err := stringAt(strs, body, int(off))
+		if err != nil {
+			return nil, err
+		}
+
+		valIdx := binary.LittleEndian.Uint32(body[off+4 : off+8])
+		if int(valIdx) >= len(values) {
+			return nil, fmt.Errorf("attrs entry %q references out-of-range index %d", key, valIdx)
+		}
+
+		attrs.Set(key, values[valIdx])
+	}
+
+	return attrs, nil
+}
+
+// stringAt reads a uint32 string-table index out of body at off and
+// resolves it against strs.
+func stringAt(strs []string, body []byte, off int) (string, error) {
+	if len(body) < off+4 {
+		return "", fmt.Errorf("truncated string index at offset %d", off)
+	}
+
+	idx := binary.LittleEndian.Uint32(body[off : off+4])
+	if int(idx) >= len(strs) {
+		return "", fmt.Errorf("string index %d out of range", idx)
+	}
+
+	return strs[idx], nil
+}
+
+// Low-level binary helpers. Every multi-byte integer is little-endian;
+// every variable-length block (a string, or a record) is written as a
+// uint32 byte count followed by that many bytes, so reading one back
+// never needs to understand its contents first.
+
+func writeUint32(w io.Writer, v uint32) error {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	_, err := w.Write(buf[:])
+
+	return err
+}
+
+func readUint32(r io.Reader) (uint32, error) {
+	var buf [4]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint32(buf[:]), nil
+}
+
+func writeBlock(w io.Writer, b []byte) error {
+	if err := writeUint32(w, uint32(len(b))); err != nil {
+		return err
+	}
+	_, err := w.Write(b)
+
+	return err
+}
+
+func readBlock(r io.Reader) ([]byte, error) {
+	n, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+func readStrings(r io.Reader) ([]string, error) {
+	count, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+
+	strs := make([]string, count)
+	for i := range strs {
+		b, err := readBlock(r)
+		if err != nil {
+			return nil, err
+		}
+		strs[i] = string(b)
+	}
+
+	return strs, nil
+}
+
+func readRecords(r io.Reader) ([][]byte, error) {
+	count, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([][]byte, count)
+	for i := range records {
+		rec, err := readBlock(r)
+		if err != nil {
+			return nil, err
+		}
+		records[i] = rec
+	}
+
+	return records, nil
+}
+
+// CacheKey computes the content-addressed identifier gix's on-disk eval
+// cache uses for a piece of source: the hex-encoded SHA256 of
+// BuiltinsVersion and the source's normalized text. Folding in
+// BuiltinsVersion means a built-ins change that bumps it invalidates
+// every previously computed key, rather than risk EvalCached returning
+// a cache entry some older build of gix produced under different
+// semantics.
+func CacheKey(source string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "gix-builtins-v%d\n", BuiltinsVersion)
+	h.Write([]byte(normalizeSource(source)))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// normalizeSource irons out source differences that don't change what
+// an expression means - line-ending style and trailing whitespace - so
+// the same program saved by two different editors still hits the same
+// cache entry.
+func normalizeSource(source string) string {
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+
+	lines := strings.Split(source, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n") + "\n"
+}
+
+// EvalCached evaluates source the same way Eval does, but first checks
+// the on-disk cache under cacheDir (keyed by CacheKey) for a previously
+// serialized result, returning that directly - skipping parsing and
+// evaluation entirely - on a hit. A miss evaluates normally and writes
+// the result back for next time; a failure to populate the cache
+// (a read-only cacheDir, for instance) is not treated as an evaluation
+// error, since the cache is purely an accelerator.
+func (e *Evaluator) EvalCached(source, cacheDir string) (value.Value, error) {
+	cachePath := filepath.Join(cacheDir, CacheKey(source)+".gixc")
+
+	if cached, err := e.LoadCache(cachePath); err == nil {
+		return cached, nil
+	}
+
+	l := lexer.New(source)
+	p := parser.New(l)
+
+	ast, err := p.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := e.Eval(ast)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(cacheDir, 0o755); err == nil {
+		_ = e.SaveCache(cachePath, result)
+	}
+
+	return result, nil
+}
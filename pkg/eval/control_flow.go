@@ -15,6 +15,11 @@ func (e *Evaluator) evalIf(expr *types.IfExpr, env value.Environment) (value.Val
 		return nil, err
 	}
 
+	cond, err = value.Force(cond)
+	if err != nil {
+		return nil, err
+	}
+
 	condBool, ok := cond.(value.Bool)
 	if !ok {
 		return nil, fmt.Errorf("if condition must be boolean, got %v", cond.Type())
@@ -27,48 +32,72 @@ func (e *Evaluator) evalIf(expr *types.IfExpr, env value.Environment) (value.Val
 	}
 }
 
-// evalLet evaluates a let expression.
+// evalLet evaluates a let expression. Nix's `let` binds every name in
+// one simultaneous scope rather than threading an accumulating
+// environment through each binding in turn, so mutually-recursive
+// bindings like `let a = b + 1; b = 2; in a` must work regardless of
+// declaration order. We install every binding as a Thunk before
+// evaluating any of them, so a binding's expression can freely
+// reference sibling names (including itself) - resolution happens on
+// demand when something forces the thunk.
 func (e *Evaluator) evalLet(expr *types.LetExpr, env value.Environment) (value.Value, error) {
-	// Create new environment for let bindings
-	letEnv := env.Extend()
+	letEnv := env.Extend().(*value.Env)
 
-	// Evaluate all bindings in order
-	// Note: In real Nix, let bindings can be mutually recursive
-	// For simplicity, we evaluate them in order
 	for _, binding := range expr.Bindings {
-		val, err := e.evalExpr(binding.Value, letEnv)
-		if err != nil {
-			return nil, fmt.Errorf("error in let binding %s: %w", binding.Name, err)
-		}
-		letEnv.Set(binding.Name, val)
+		name, valueExpr := binding.Name, binding.Value
+		letEnv.SetLazy(name, value.NewThunk(func() (value.Value, error) {
+			val, err := e.evalExpr(valueExpr, letEnv)
+			if err != nil {
+				return nil, fmt.Errorf("error in let binding %s: %w", name, err)
+			}
+
+			return val, nil
+		}))
 	}
 
+	// inherit clauses introduce more names into the same simultaneous
+	// scope, so they're just as visible to sibling bindings as ordinary
+	// ones (and vice versa).
+	e.installInherits(expr.Inherits, env, letEnv, nil)
+
 	// Evaluate body in the new environment
 	return e.evalExpr(expr.Body, letEnv)
 }
 
-// evalWith evaluates a with expression.
+// evalWith evaluates a with expression. The scope attrset is evaluated
+// once, in the enclosing environment, and attached to a new environment
+// as a with-scope rather than copied in as ordinary bindings: with-scope
+// names only come into play when an identifier isn't found anywhere in
+// the lexical chain (see Env.Get), so a `let`, function parameter, or
+// `rec` binding of the same name always wins, and an outer `with` is
+// only consulted once every inner one has missed.
 func (e *Evaluator) evalWith(expr *types.WithExpr, env value.Environment) (value.Value, error) {
-	// Evaluate the expression that provides the scope
-	scopeVal, err := e.evalExpr(expr.Expr, env)
-	if err != nil {
-		return nil, err
-	}
-
-	// It must be an attribute set
-	attrs, ok := scopeVal.(*value.Attrs)
+	baseEnv, ok := env.(*value.Env)
 	if !ok {
-		return nil, fmt.Errorf("with expression requires attribute set, got %v", scopeVal.Type())
+		return nil, errors.New("with requires a lexical environment")
 	}
 
-	// Create new environment with attributes from the set
-	withEnv := env.Extend()
-	for _, key := range attrs.Keys() {
-		val, _ := attrs.Get(key)
-		withEnv.Set(key, val)
-	}
+	scopeExpr := expr.Expr
+	scopeThunk := value.NewThunk(func() (value.Value, error) {
+		scopeVal, err := e.evalExpr(scopeExpr, env)
+		if err != nil {
+			return nil, err
+		}
+
+		scopeVal, err = value.Force(scopeVal)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := scopeVal.(*value.Attrs); !ok {
+			return nil, fmt.Errorf("with expression requires attribute set, got %v", scopeVal.Type())
+		}
+
+		return scopeVal, nil
+	})
+
+	withEnv := baseEnv.PushWith(scopeThunk)
 
-	// Evaluate body in the new environment
 	return e.evalExpr(expr.Body, withEnv)
 }
 
@@ -80,6 +109,11 @@ func (e *Evaluator) evalAssert(expr *types.AssertExpr, env value.Environment) (v
 		return nil, err
 	}
 
+	cond, err = value.Force(cond)
+	if err != nil {
+		return nil, err
+	}
+
 	condBool, ok := cond.(value.Bool)
 	if !ok {
 		return nil, fmt.Errorf("assert condition must be boolean, got %v", cond.Type())
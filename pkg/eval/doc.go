@@ -18,15 +18,48 @@
 //   - operators.go: Binary and unary operator implementations
 //   - control_flow.go: Control flow constructs (if, let, with, assert)
 //   - functions.go: Function application and closure handling
-//   - builtins.go: Built-in function library
+//   - builtins.go: Core built-in function library
+//   - builtins_stdlib.go: Higher-order list/attrset, JSON, and error-handling built-ins
+//   - import.go: import/scopedImport and the cross-evaluator import cache
+//   - fetch.go: fetchurl/fetchTarball and the local fetch cache
+//   - cache.go: binary on-disk cache of evaluated values (SaveCache/LoadCache/EvalCached)
+//   - registry.go: BuiltinRegistry, the Builtin interface, and the curried-application
+//     helpers every built-in (standard or embedder-supplied) goes through
+//   - optimize (subpackage): optional pre-evaluation AST rewrite pass (see WithOptimize)
+//
+// Extending the standard library:
+//
+// An embedder can add domain-specific built-ins (a custom fetcher, a
+// derivation backend) without forking this package, via either the
+// bare-function form (WithExtraBuiltin) or the interface form
+// (WithBuiltins, (*Evaluator).RegisterBuiltin, and the Builtin interface)
+// - mirroring how Starlark's Universe or HIL's function table let a host
+// app extend the language. Both forms register through the same
+// BuiltinRegistry and get the same automatic currying across multiple
+// Nix arguments that length, map, and the rest of the standard library
+// already rely on.
 //
 // Evaluation Strategy:
 //
-// The evaluator implements eager evaluation with lazy semantics where appropriate:
-//   - Function arguments are evaluated when passed (eager)
-//   - Let bindings are evaluated when accessed (lazy-ish)
-//   - Attribute sets support recursive references
-//   - Short-circuit evaluation for logical operators
+// The evaluator is call-by-need, matching real Nix: nothing is computed
+// until something actually demands its value, and the result is then
+// memoized so repeated references don't redo the work.
+//   - Let and rec { } bindings are bound simultaneously as value.Thunks and
+//     only evaluated the first time something forces them, so mutually
+//     recursive bindings resolve regardless of declaration order
+//   - Function arguments are passed as value.Thunks rather than evaluated
+//     up front, so a function that never inspects a parameter never pays
+//     for (or fails on) computing it
+//   - Attribute set values are likewise thunked, both recursive (rec { })
+//     and not, so `{ a = abort "boom"; b = 1; }.b` never forces a
+//   - // and ++ merge attribute sets and lists without forcing the values
+//     or elements they contain
+//   - Short-circuit evaluation for logical operators: && and || only force
+//     their right operand once the left one hasn't already settled the
+//     result
+//   - A Thunk still being forced when something forces it again (e.g.
+//     `let x = x + 1; in x`) reports an "infinite recursion" error instead
+//     of recursing forever
 //
 // Supported Language Features:
 //
@@ -41,13 +74,21 @@
 //
 // Built-in Functions:
 //
-// The evaluator provides 25+ built-in functions including:
+// The evaluator provides 50+ built-in functions including:
 //   - Type checking: isNull, isBool, isInt, isFloat, isString, isList, isAttrs, isFunction
-//   - Conversions: toString
-//   - List operations: length, head, tail, elem
-//   - Attribute operations: attrNames, attrValues, hasAttr, getAttr
-//   - Math: add, sub, mul, div
+//   - Conversions: toString, toJSON, fromJSON
+//   - List operations: length, head, tail, elem, map, filter, foldl', genList, concatLists,
+//     concatMap, sort
+//   - Attribute operations: attrNames, attrValues, hasAttr, getAttr, listToAttrs, mapAttrs,
+//     removeAttrs, intersectAttrs, catAttrs
+//   - Math: add, sub, mul, div, bitAnd, bitOr, bitXor, quot, rem, ceil, floor
+//   - Strings: stringLength, substring, concatStringsSep
+//   - String contexts: unsafeDiscardStringContext, hasContext, getContext, appendContext
+//   - Error handling: abort, throw, tryEval
+//   - File system: readFile, pathExists
+//   - Versioning: compareVersions, parseDrvName
 //   - System: derivation
+//   - Modules and fetching: import, scopedImport, fetchurl, fetchTarball
 //
 // Error Handling:
 //
@@ -0,0 +1,169 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/conneroisu/gix/internal/types"
+)
+
+// ErrorKind classifies an Error, so a caller (an LSP diagnostic pass, a
+// REPL error prefix) can react to a failure mode without string-matching
+// Msg. It's deliberately coarse-grained for now - only the failure modes
+// that currently carry a Pos are distinguished - and is expected to grow
+// alongside eval.Error's adoption across the package.
+type ErrorKind byte
+
+const (
+	// ErrUndefinedVariable means evalIdent couldn't resolve a name
+	// anywhere in the lexical chain or its with-scopes.
+	ErrUndefinedVariable ErrorKind = iota
+	// ErrNotCallable means evalApply tried to apply a value that is
+	// neither a *value.Function nor a *value.Builtin.
+	ErrNotCallable
+	// ErrAttrConflict means setNestedAttr found a non-attrset value
+	// already occupying an intermediate component of an attribute path.
+	ErrAttrConflict
+	// ErrRuntime covers operator type mismatches and other failures
+	// raised computing a value once its operands are already in hand
+	// (evalBinary, evalUnary, and the evalAdd/evalDiv/evalConcat/
+	// evalUpdate family they call into) - see wrapRuntimeError.
+	ErrRuntime
+)
+
+// Frame records one entry of the call stack active when an Error was
+// raised: a human-readable description of the application being
+// evaluated (e.g. "f x") and the position of the ApplyExpr that pushed
+// it. Trace holds these innermost-first, mirroring a Nix traceback.
+type Frame struct {
+	Desc string
+	Pos  types.SourcePos
+}
+
+// Error is a structured, position-carrying evaluation failure. It
+// satisfies the error interface, so existing fmt.Errorf("...: %w", err)
+// wrapping elsewhere in the package still composes with it via
+// errors.As/errors.Unwrap - wrapping doesn't need to change, it just
+// needs to keep using %w rather than %v so the *Error survives.
+//
+// Not every error raised by this package is an *Error yet; evalIdent,
+// evalApply, setNestedAttr, and the operator paths that go through
+// wrapRuntimeError are the call sites converted so far, since those are
+// where a caller most wants to know where in the source the failure
+// happened.
+type Error struct {
+	Pos   types.SourcePos
+	Kind  ErrorKind
+	Msg   string
+	Trace []Frame // innermost call first
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d:%d: %s", e.Pos.Line, e.Pos.Column, e.Msg)
+
+	for _, frame := range e.Trace {
+		fmt.Fprintf(&b, "\n  from %s at %d:%d", frame.Desc, frame.Pos.Line, frame.Pos.Column)
+	}
+
+	return b.String()
+}
+
+// Format renders e as a Rust-style caret diagnostic: the offending
+// line (looked up in src, keyed by the file path the expression was
+// parsed from - pass the empty string for a single in-memory source
+// with no file of its own, which is what every source position in this
+// package currently carries since SourcePos has no File field), a caret
+// under the failing column, and one "called from" entry per Trace
+// frame. A position whose line falls outside the available source is
+// skipped rather than causing Format to fail.
+func (e *Error) Format(src map[string][]byte) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "error: %s\n", e.Msg)
+	writeCaret(&b, src[""], e.Pos)
+
+	for _, frame := range e.Trace {
+		fmt.Fprintf(&b, "called from %s at %d:%d\n", frame.Desc, frame.Pos.Line, frame.Pos.Column)
+		writeCaret(&b, src[""], frame.Pos)
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// writeCaret appends the line of source containing pos, if available,
+// followed by a line with a caret under pos's column.
+func writeCaret(b *strings.Builder, source []byte, pos types.SourcePos) {
+	if source == nil {
+		return
+	}
+
+	lines := strings.Split(string(source), "\n")
+	if pos.Line < 1 || pos.Line > len(lines) {
+		return
+	}
+
+	// pos.Column is 1-based, so the caret needs Column-1 spaces before it.
+	pad := pos.Column - 1
+	if pad < 0 {
+		pad = 0
+	}
+
+	fmt.Fprintf(b, "  %s\n", lines[pos.Line-1])
+	fmt.Fprintf(b, "  %s^\n", strings.Repeat(" ", pad))
+}
+
+// pushFrame records a call-stack entry for the duration of evaluating
+// within it. Callers defer the returned func to pop it back off,
+// keeping e.frames a true stack even when evaluation returns early on
+// error.
+func (e *Evaluator) pushFrame(desc string, pos types.SourcePos) func() {
+	e.frames = append(e.frames, Frame{Desc: desc, Pos: pos})
+	idx := len(e.frames) - 1
+
+	return func() {
+		e.frames = e.frames[:idx]
+	}
+}
+
+// trace snapshots the current call stack for attaching to a newly
+// raised Error, innermost frame first.
+func (e *Evaluator) trace() []Frame {
+	if len(e.frames) == 0 {
+		return nil
+	}
+
+	trace := make([]Frame, len(e.frames))
+	for i, f := range e.frames {
+		trace[len(e.frames)-1-i] = f
+	}
+
+	return trace
+}
+
+// wrapRuntimeError promotes a plain error from an operator helper
+// (evalAdd, evalDiv, evalConcat, evalUpdate, ...) into a position- and
+// call-stack-carrying *Error, so a type mismatch like "cannot add string
+// to int" is reported at the operator's own source position and with
+// the same "called from" trace evalIdent/evalApply already attach,
+// instead of bubbling up as a bare string. err that is already an *Error
+// - raised evaluating one of the operands, rather than by the operator
+// itself - passes through unchanged.
+func (e *Evaluator) wrapRuntimeError(expr types.Expr, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var structured *Error
+	if errors.As(err, &structured) {
+		return err
+	}
+
+	return &Error{
+		Pos:   expr.Position(),
+		Kind:  ErrRuntime,
+		Msg:   err.Error(),
+		Trace: e.trace(),
+	}
+}
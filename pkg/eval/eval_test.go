@@ -1,9 +1,15 @@
 package eval
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/conneroisu/gix/internal/value"
+	"github.com/conneroisu/gix/pkg/eval/optimize"
 	"github.com/conneroisu/gix/pkg/lexer"
 	"github.com/conneroisu/gix/pkg/parser"
 )
@@ -18,7 +24,16 @@ func testEval(input string) value.Value {
 	return result
 }
 
+// testIntegerObject, testBooleanObject, and testNullObject all force obj
+// first since list elements and attrset values are lazy Thunks until
+// something demands them.
 func testIntegerObject(t *testing.T, obj value.Value, expected int64) bool {
+	obj, err := value.Force(obj)
+	if err != nil {
+		t.Errorf("could not force object: %v", err)
+
+		return false
+	}
 	result, ok := obj.(value.Int)
 	if !ok {
 		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
@@ -35,6 +50,12 @@ func testIntegerObject(t *testing.T, obj value.Value, expected int64) bool {
 }
 
 func testBooleanObject(t *testing.T, obj value.Value, expected bool) bool {
+	obj, err := value.Force(obj)
+	if err != nil {
+		t.Errorf("could not force object: %v", err)
+
+		return false
+	}
 	result, ok := obj.(value.Bool)
 	if !ok {
 		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
@@ -51,6 +72,12 @@ func testBooleanObject(t *testing.T, obj value.Value, expected bool) bool {
 }
 
 func testNullObject(t *testing.T, obj value.Value) bool {
+	obj, err := value.Force(obj)
+	if err != nil {
+		t.Errorf("could not force object: %v", err)
+
+		return false
+	}
 	_, ok := obj.(value.Null)
 	if !ok {
 		t.Errorf("object is not Null. got=%T (%+v)", obj, obj)
@@ -245,7 +272,7 @@ func TestBuiltinFunctions(t *testing.T) {
 
 				continue
 			}
-			if string(str) != expected {
+			if str.Raw != expected {
 				t.Errorf("String has wrong value. got=%q", str)
 			}
 		case []int:
@@ -327,7 +354,7 @@ func TestDerivationBuiltin(t *testing.T) {
 		t.Error("derivation missing 'name' attribute")
 	} else {
 		name, ok := nameVal.(value.String)
-		if !ok || string(name) != "hello" {
+		if !ok || name.Raw != "hello" {
 			t.Errorf("derivation name wrong. got=%v", nameVal)
 		}
 	}
@@ -337,7 +364,7 @@ func TestDerivationBuiltin(t *testing.T) {
 		t.Error("derivation missing 'builder' attribute")
 	} else {
 		builder, ok := builderVal.(value.String)
-		if !ok || string(builder) != "/bin/sh" {
+		if !ok || builder.Raw != "/bin/sh" {
 			t.Errorf("derivation builder wrong. got=%v", builderVal)
 		}
 	}
@@ -353,3 +380,1296 @@ func TestDerivationBuiltin(t *testing.T) {
 		}
 	}
 }
+
+func testEvalErr(input string) (value.Value, error) {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program, _ := p.Parse()
+	e := New(".")
+
+	return e.Eval(program)
+}
+
+func TestLetMutualRecursion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		// b is declared after a but a's binding can still see it.
+		{"let a = b + 1; b = 2; in a", 3},
+		{"let isEven = n: if n == 0 then true else isOdd (n - 1);" +
+			" isOdd = n: if n == 0 then false else isEven (n - 1);" +
+			" in if isEven 10 then 1 else 0", 1},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestRecAttrSetMutualRecursion(t *testing.T) {
+	input := "(rec { a = b + 1; b = 2; }).a"
+
+	testIntegerObject(t, testEval(input), 3)
+}
+
+func TestLetSelfReferenceCycleDetected(t *testing.T) {
+	_, err := testEvalErr("let x = x; in x")
+	if err == nil {
+		t.Fatal("expected an error forcing a self-referential binding, got nil")
+	}
+}
+
+func TestLetNonRecursiveStillWorks(t *testing.T) {
+	// A binding that never references a sibling should still work even
+	// though evalLet thunks every binding unconditionally now.
+	testIntegerObject(t, testEval("let a = 5; in a"), 5)
+}
+
+func TestUnusedAttrIsNeverForced(t *testing.T) {
+	tests := []string{
+		`{ a = abort "boom"; b = 1; }.b`,
+		`(rec { a = abort "boom"; b = 1; }).b`,
+		`let a = abort "boom"; b = 1; in b`,
+	}
+
+	for _, input := range tests {
+		testIntegerObject(t, testEval(input), 1)
+	}
+}
+
+func TestUnusedFunctionArgumentIsNeverForced(t *testing.T) {
+	testIntegerObject(t, testEval(`(x: 1) (abort "boom")`), 1)
+}
+
+func TestShortCircuitOperatorsDoNotForceUnusedOperand(t *testing.T) {
+	testBooleanObject(t, testEval(`false && (abort "boom")`), false)
+	testBooleanObject(t, testEval(`true || (abort "boom")`), true)
+	testBooleanObject(t, testEval(`false -> (abort "boom")`), true)
+}
+
+func TestUpdateAndConcatDoNotForceElements(t *testing.T) {
+	// // overrides the left attrset's "a" with the right one's, so the
+	// left "a" (which would abort if forced) is simply discarded.
+	merged := testEval(`({ a = abort "boom"; } // { a = 1; }).a`)
+	testIntegerObject(t, merged, 1)
+
+	list := testEval(`head ([ 1 ] ++ [ (abort "boom") ])`)
+	testIntegerObject(t, list, 1)
+}
+
+func BenchmarkLetLiteralBindings(b *testing.B) {
+	l := lexer.New("let a = 1; b = 2; c = 3; in a + b + c")
+	p := parser.New(l)
+	program, _ := p.Parse()
+	e := New(".")
+
+	b.ResetTimer()
+	for range b.N {
+		if _, err := e.Eval(program); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLetRecursiveBindings(b *testing.B) {
+	l := lexer.New("let isEven = n: if n == 0 then true else isOdd (n - 1);" +
+		" isOdd = n: if n == 0 then false else isEven (n - 1);" +
+		" in isEven 20")
+	p := parser.New(l)
+	program, _ := p.Parse()
+	e := New(".")
+
+	b.ResetTimer()
+	for range b.N {
+		if _, err := e.Eval(program); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestLetInherit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; in let inherit a; in a", 5},
+		{"let pkgs = { x = 1; y = 2; }; in let inherit (pkgs) x y; in x + y", 3},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestRecAttrSetInherit(t *testing.T) {
+	// The inherited name b must be visible to the sibling binding a,
+	// just like an ordinary rec binding would be.
+	input := "(rec { inherit b; a = b + 1; }).a"
+
+	_, err := testEvalErr(input)
+	if err == nil {
+		t.Fatal("expected undefined variable error for bare 'b' in outer scope")
+	}
+
+	input = "let b = 2; in (rec { inherit b; a = b + 1; }).a"
+	testIntegerObject(t, testEval(input), 3)
+}
+
+func TestAttrSetInheritFromExpr(t *testing.T) {
+	input := `let pkgs = { foo = 1; bar = 2; }; in { inherit (pkgs) foo bar; }.foo`
+	testIntegerObject(t, testEval(input), 1)
+}
+
+func TestInheritUndefinedVariable(t *testing.T) {
+	_, err := testEvalErr("{ inherit doesNotExist; }")
+	if err == nil {
+		t.Fatal("expected an error inheriting an undefined variable")
+	}
+}
+
+func TestWithExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"with { a = 1; b = 2; }; a + b", 3},
+		// Re-export pattern: a `with` over the result of a let-bound
+		// attrset, as commonly seen as `with import ./foo.nix; ...`.
+		{"let pkgs = { x = 5; }; in with pkgs; x", 5},
+		// Nested with: the innermost scope's binding wins.
+		{"with { a = 1; }; with { a = 2; }; a", 2},
+		// A name found in the outer with but not the inner one still
+		// resolves, skipping past the inner scope's miss.
+		{"with { a = 1; }; with { b = 2; }; a + b", 3},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestWithScopeLosesToLexicalBinding(t *testing.T) {
+	// A lexical binding - here a let - always wins over a with-scope,
+	// no matter how deeply the with nests relative to it.
+	input := "let a = 5; in with { a = 10; }; a"
+	testIntegerObject(t, testEval(input), 5)
+}
+
+func TestWithScopeLosesToFunctionParameter(t *testing.T) {
+	input := "(a: with { a = 10; }; a) 5"
+	testIntegerObject(t, testEval(input), 5)
+}
+
+func TestWithNonAttrsetScopeErrors(t *testing.T) {
+	_, err := testEvalErr("with 5; a")
+	if err == nil {
+		t.Fatal("expected an error for a with scope that isn't an attribute set")
+	}
+}
+
+func TestFindAlongAttrPath(t *testing.T) {
+	e := New(".")
+	root := testEval(`{ a = { b = [ 10 20 30 ]; }; }`)
+
+	result, err := e.FindAlongAttrPath(root, "a.b.1", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testIntegerObject(t, result, 20)
+}
+
+func TestFindAlongAttrPathAutoCallsFunctions(t *testing.T) {
+	e := New(".")
+	// Mirrors a `pkgs: { hello = ...; }`-shaped expression, reached the
+	// way `nix-build -A hello` reaches into a nixpkgs default.nix.
+	root := testEval(`self: { hello = self; }`)
+	autoArgs := value.NewAttrsFrom(map[string]value.Value{"x": value.Int(42)})
+
+	result, err := e.FindAlongAttrPath(root, "hello.x", autoArgs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testIntegerObject(t, result, 42)
+}
+
+func TestFindAlongAttrPathMissingAttr(t *testing.T) {
+	e := New(".")
+	root := testEval(`{ a = 1; }`)
+
+	_, err := e.FindAlongAttrPath(root, "b", nil)
+	var pathErr *AttrPathError
+	if !errors.As(err, &pathErr) || pathErr.Kind != AttrPathMissingAttr {
+		t.Fatalf("expected AttrPathMissingAttr, got %v", err)
+	}
+}
+
+func TestFindAlongAttrPathIndexOutOfRange(t *testing.T) {
+	e := New(".")
+	root := testEval(`[ 1 2 ]`)
+
+	_, err := e.FindAlongAttrPath(root, "5", nil)
+	var pathErr *AttrPathError
+	if !errors.As(err, &pathErr) || pathErr.Kind != AttrPathIndexOutOfRange {
+		t.Fatalf("expected AttrPathIndexOutOfRange, got %v", err)
+	}
+}
+
+func TestFindAlongAttrPathNotIndexable(t *testing.T) {
+	e := New(".")
+	root := testEval(`{ a = 5; }`)
+
+	_, err := e.FindAlongAttrPath(root, "a.b", nil)
+	var pathErr *AttrPathError
+	if !errors.As(err, &pathErr) || pathErr.Kind != AttrPathNotIndexable {
+		t.Fatalf("expected AttrPathNotIndexable, got %v", err)
+	}
+}
+
+func TestCurriedMultiArgBuiltins(t *testing.T) {
+	// Nix application is nested single-argument application (`f a b` =
+	// `(f a) b`), so a multi-arg built-in like hasAttr/elem/add must be
+	// directly callable that way, not just through the + operator.
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"add 1 2", 3},
+		{"sub 5 2", 3},
+		{"mul 3 4", 12},
+		{"div 10 2", 5},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	testBooleanObject(t, testEval(`hasAttr "a" { a = 1; }`), true)
+	testBooleanObject(t, testEval(`elem 2 [1, 2, 3]`), true)
+}
+
+func TestCurriedBuiltinPartialApplication(t *testing.T) {
+	// `add 1` on its own must be a function value capturing the first
+	// argument, not an arity error - this is what lets it be passed
+	// around, e.g. to a higher-order function like map.
+	input := "let addOne = add 1; in addOne 41"
+	testIntegerObject(t, testEval(input), 42)
+}
+
+func TestBuiltinsAttrsetExposed(t *testing.T) {
+	// Every unqualified built-in is also reachable via builtins.<name>,
+	// alongside the unqualified name itself.
+	testIntegerObject(t, testEval("builtins.length [1, 2, 3]"), 3)
+	testIntegerObject(t, testEval("length [1, 2, 3]"), 3)
+}
+
+func TestWithExtraBuiltin(t *testing.T) {
+	e := New(".", WithExtraBuiltin("double", 1, func(_ *Evaluator, args []value.Value) (value.Value, error) {
+		n, ok := args[0].(value.Int)
+		if !ok {
+			return nil, errors.New("double expects an int")
+		}
+
+		return value.Int(n * 2), nil
+	}))
+
+	l := lexer.New("double 21")
+	p := parser.New(l)
+	program, _ := p.Parse()
+	result, err := e.Eval(program)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testIntegerObject(t, result, 42)
+}
+
+// doubleBuiltin is an interface-form Builtin, exercising WithBuiltins and
+// (*Evaluator).RegisterBuiltin as an alternative to the bare-function
+// form WithExtraBuiltin registers.
+type doubleBuiltin struct{}
+
+func (doubleBuiltin) Name() string  { return "double" }
+func (doubleBuiltin) Arity() int    { return 1 }
+func (doubleBuiltin) Call(_ *Evaluator, args []value.Value) (value.Value, error) {
+	n, ok := args[0].(value.Int)
+	if !ok {
+		return nil, errors.New("double expects an int")
+	}
+
+	return value.Int(n * 2), nil
+}
+
+func TestWithBuiltinsRegistersInterfaceForm(t *testing.T) {
+	e := New(".", WithBuiltins(doubleBuiltin{}))
+
+	l := lexer.New("double 21")
+	p := parser.New(l)
+	program, _ := p.Parse()
+	result, err := e.Eval(program)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testIntegerObject(t, result, 42)
+}
+
+func TestEvaluatorRegisterBuiltinTakesEffectImmediately(t *testing.T) {
+	e := New(".")
+	e.RegisterBuiltin(doubleBuiltin{})
+
+	l := lexer.New("double 10")
+	p := parser.New(l)
+	program, _ := p.Parse()
+	result, err := e.Eval(program)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testIntegerObject(t, result, 20)
+}
+
+func TestWithLanguageLevelGatesVersionedBuiltins(t *testing.T) {
+	registered := func(e *Evaluator) {
+		e.registry.RegisterVersioned(1, "futureBuiltin", 1, func(_ *Evaluator, args []value.Value) (value.Value, error) {
+			return args[0], nil
+		})
+	}
+
+	base := New(".")
+	registered(base)
+	if _, ok := base.NewEnv().Get("futureBuiltin"); ok {
+		t.Fatal("expected futureBuiltin to be absent at the default language level")
+	}
+
+	leveled := New(".", WithLanguageLevel(1))
+	registered(leveled)
+	if _, ok := leveled.NewEnv().Get("futureBuiltin"); !ok {
+		t.Fatal("expected futureBuiltin to be present once the language level is raised")
+	}
+}
+
+func TestUndefinedVariableErrorHasPosition(t *testing.T) {
+	_, err := testEvalErr("1 + nope")
+
+	var evalErr *Error
+	if !errors.As(err, &evalErr) {
+		t.Fatalf("expected *Error, got %T (%v)", err, err)
+	}
+	if evalErr.Kind != ErrUndefinedVariable {
+		t.Fatalf("expected ErrUndefinedVariable, got %v", evalErr.Kind)
+	}
+	// nope starts partway through the line, not at its very first column.
+	if evalErr.Pos.Line != 1 || evalErr.Pos.Column == 0 {
+		t.Fatalf("expected a position on line 1 past column 0, got %d:%d", evalErr.Pos.Line, evalErr.Pos.Column)
+	}
+}
+
+func TestUndefinedVariableErrorHasCallTrace(t *testing.T) {
+	// nope is only undefined once f is actually applied, so the error's
+	// Trace should record that application.
+	_, err := testEvalErr("let f = x: x + nope; in f 1")
+
+	var evalErr *Error
+	if !errors.As(err, &evalErr) {
+		t.Fatalf("expected *Error, got %T (%v)", err, err)
+	}
+	if len(evalErr.Trace) != 1 {
+		t.Fatalf("expected one call frame, got %d (%+v)", len(evalErr.Trace), evalErr.Trace)
+	}
+}
+
+func TestApplyNonFunctionError(t *testing.T) {
+	_, err := testEvalErr("1 2")
+
+	var evalErr *Error
+	if !errors.As(err, &evalErr) {
+		t.Fatalf("expected *Error, got %T (%v)", err, err)
+	}
+	if evalErr.Kind != ErrNotCallable {
+		t.Fatalf("expected ErrNotCallable, got %v", evalErr.Kind)
+	}
+}
+
+func TestBinaryOperatorTypeErrorHasPosition(t *testing.T) {
+	_, err := testEvalErr(`1 + "a"`)
+
+	var evalErr *Error
+	if !errors.As(err, &evalErr) {
+		t.Fatalf("expected *Error, got %T (%v)", err, err)
+	}
+	if evalErr.Kind != ErrRuntime {
+		t.Fatalf("expected ErrRuntime, got %v", evalErr.Kind)
+	}
+	if evalErr.Pos.Line != 1 || evalErr.Pos.Column != 1 {
+		t.Fatalf("expected the position of the + expression at 1:1, got %d:%d", evalErr.Pos.Line, evalErr.Pos.Column)
+	}
+}
+
+func TestUnaryOperatorTypeErrorHasPosition(t *testing.T) {
+	_, err := testEvalErr(`!1`)
+
+	var evalErr *Error
+	if !errors.As(err, &evalErr) {
+		t.Fatalf("expected *Error, got %T (%v)", err, err)
+	}
+	if evalErr.Kind != ErrRuntime {
+		t.Fatalf("expected ErrRuntime, got %v", evalErr.Kind)
+	}
+}
+
+func TestBinaryOperatorErrorHasCallTrace(t *testing.T) {
+	_, err := testEvalErr(`let f = x: x + "a"; in f 1`)
+
+	var evalErr *Error
+	if !errors.As(err, &evalErr) {
+		t.Fatalf("expected *Error, got %T (%v)", err, err)
+	}
+	if len(evalErr.Trace) != 1 {
+		t.Fatalf("expected one call frame, got %d (%+v)", len(evalErr.Trace), evalErr.Trace)
+	}
+}
+
+func TestErrorFormatRendersCaretAndTrace(t *testing.T) {
+	src := "let f = x: x + nope; in f 1"
+	_, err := testEvalErr(src)
+
+	var evalErr *Error
+	if !errors.As(err, &evalErr) {
+		t.Fatalf("expected *Error, got %T (%v)", err, err)
+	}
+
+	formatted := evalErr.Format(map[string][]byte{"": []byte(src)})
+	if !strings.Contains(formatted, "undefined variable: nope") {
+		t.Fatalf("expected formatted output to mention the error, got %q", formatted)
+	}
+	if !strings.Contains(formatted, "called from") {
+		t.Fatalf("expected formatted output to include a call trace, got %q", formatted)
+	}
+	if !strings.Contains(formatted, "^") {
+		t.Fatalf("expected formatted output to include a caret, got %q", formatted)
+	}
+}
+
+func TestAttrSetDottedPathsAlreadyMerge(t *testing.T) {
+	// Sibling dotted paths with no inline literal involved already work
+	// via setNestedAttr's on-the-fly intermediate attrsets.
+	result := testEval(`{ a.b = 1; a.c = 2; }`)
+	attrs, ok := result.(*value.Attrs)
+	if !ok {
+		t.Fatalf("result not *value.Attrs, got %T", result)
+	}
+
+	a, ok := attrs.Get("a")
+	if !ok {
+		t.Fatal("expected attribute 'a'")
+	}
+	aAttrs, err := value.Force(a)
+	if err != nil {
+		t.Fatalf("could not force a: %v", err)
+	}
+	nested, ok := aAttrs.(*value.Attrs)
+	if !ok {
+		t.Fatalf("a is not *value.Attrs, got %T", aAttrs)
+	}
+
+	testIntegerObject(t, selectMust(t, nested, "b"), 1)
+	testIntegerObject(t, selectMust(t, nested, "c"), 2)
+}
+
+func selectMust(t *testing.T, attrs *value.Attrs, key string) value.Value {
+	t.Helper()
+	val, ok := attrs.Get(key)
+	if !ok {
+		t.Fatalf("expected attribute %q", key)
+	}
+
+	return val
+}
+
+func TestAttrSetInlineLiteralMergesWithDottedPath(t *testing.T) {
+	result := testEval(`{ a = { b = 1; }; a.c = 2; }`)
+	attrs, ok := result.(*value.Attrs)
+	if !ok {
+		t.Fatalf("result not *value.Attrs, got %T", result)
+	}
+
+	aVal, ok := attrs.Get("a")
+	if !ok {
+		t.Fatal("expected attribute 'a'")
+	}
+	aForced, err := value.Force(aVal)
+	if err != nil {
+		t.Fatalf("could not force a: %v", err)
+	}
+	nested, ok := aForced.(*value.Attrs)
+	if !ok {
+		t.Fatalf("a is not *value.Attrs, got %T", aForced)
+	}
+
+	testIntegerObject(t, selectMust(t, nested, "b"), 1)
+	testIntegerObject(t, selectMust(t, nested, "c"), 2)
+}
+
+func TestAttrSetDuplicateDottedPathErrors(t *testing.T) {
+	_, err := testEvalErr(`{ a.b = 1; a.b = 2; }`)
+	if err == nil || !strings.Contains(err.Error(), "a.b") {
+		t.Fatalf("expected a duplicate-attribute error naming a.b, got %v", err)
+	}
+}
+
+func TestAttrSetDuplicateFlatKeyErrors(t *testing.T) {
+	_, err := testEvalErr(`{ x = 1; x = 2; }`)
+	if err == nil || !strings.Contains(err.Error(), "x") {
+		t.Fatalf("expected a duplicate-attribute error naming x, got %v", err)
+	}
+}
+
+func TestAttrSetRecursiveLiteralDoesNotMerge(t *testing.T) {
+	// A rec {} literal's bindings can see names a textual rewrite can't
+	// safely relocate, so it's not a merge candidate: this must still be
+	// the pre-existing attribute-path-conflict error.
+	_, err := testEvalErr(`{ a = rec { b = 1; }; a.c = 2; }`)
+
+	var evalErr *Error
+	if !errors.As(err, &evalErr) || evalErr.Kind != ErrAttrConflict {
+		t.Fatalf("expected ErrAttrConflict, got %v", err)
+	}
+}
+
+// TestAttrSetMergeNixpkgsStyle exercises the merge rules against the
+// shape nixpkgs default.nix files actually write them in: a package's
+// meta is given as an inline literal and then extended with one or two
+// dotted-path overrides further down the same attrset.
+func TestAttrSetMergeNixpkgsStyle(t *testing.T) {
+	input := `
+		{
+			pname = "widget";
+			version = "1.0";
+			meta = {
+				description = "A widget";
+				license = "MIT";
+			};
+			meta.homepage = "https://example.com";
+		}
+	`
+
+	result := testEval(input)
+	attrs, ok := result.(*value.Attrs)
+	if !ok {
+		t.Fatalf("result not *value.Attrs, got %T", result)
+	}
+
+	metaVal, ok := attrs.Get("meta")
+	if !ok {
+		t.Fatal("expected attribute 'meta'")
+	}
+	meta, err := value.Force(metaVal)
+	if err != nil {
+		t.Fatalf("could not force meta: %v", err)
+	}
+	metaAttrs, ok := meta.(*value.Attrs)
+	if !ok {
+		t.Fatalf("meta is not *value.Attrs, got %T", meta)
+	}
+
+	if metaAttrs.Len() != 3 {
+		t.Fatalf("expected meta to have 3 merged attributes, got %d: %v", metaAttrs.Len(), metaAttrs.Keys())
+	}
+	for _, key := range []string{"description", "license", "homepage"} {
+		if _, ok := metaAttrs.Get(key); !ok {
+			t.Errorf("expected meta.%s to be present", key)
+		}
+	}
+}
+
+func TestSeqForcesFirstArgReturnsSecond(t *testing.T) {
+	testIntegerObject(t, testEval("seq 1 2"), 2)
+
+	_, err := testEvalErr(`seq (1 + nope) 2`)
+	if err == nil {
+		t.Fatal("expected seq to force and propagate the error in its first argument")
+	}
+}
+
+func TestDeepSeqForcesNestedValues(t *testing.T) {
+	testIntegerObject(t, testEval("deepSeq { a = [ 1 2 ]; } 42"), 42)
+
+	_, err := testEvalErr(`deepSeq { a = [ (1 + nope) ]; } 42`)
+	if err == nil {
+		t.Fatal("expected deepSeq to force nested list elements and propagate the error")
+	}
+}
+
+func TestIntegerMultiplicationOverflowErrors(t *testing.T) {
+	_, err := testEvalErr("mul 9223372036854775807 2")
+	if err == nil {
+		t.Fatal("expected overflowing multiplication to error instead of silently wrapping")
+	}
+}
+
+func TestIntegerAdditionOverflowErrors(t *testing.T) {
+	_, err := testEvalErr("add 9223372036854775807 1")
+	if err == nil {
+		t.Fatal("expected overflowing addition to error")
+	}
+}
+
+func TestIntegerSubtractionUnderflowErrors(t *testing.T) {
+	_, err := testEvalErr("sub (-9223372036854775807 - 1) 1")
+	if err == nil {
+		t.Fatal("expected underflowing subtraction to error")
+	}
+}
+
+func TestWithArbitraryPrecisionPromotesOverflowToBigInt(t *testing.T) {
+	input := "9223372036854775807 + 1"
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program, err := p.Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	e := New(".", WithArbitraryPrecision(true))
+
+	result, err := e.Eval(program)
+	if err != nil {
+		t.Fatalf("eval error: %v", err)
+	}
+
+	n, ok := result.(value.BigInt)
+	if !ok {
+		t.Fatalf("expected a value.BigInt, got %T (%v)", result, result)
+	}
+	if n.String() != "9223372036854775808" {
+		t.Errorf("expected 9223372036854775808, got %s", n.String())
+	}
+}
+
+// TestWithArbitraryPrecisionDivisionStaysExact covers the motivating case
+// for value.Rat: 10 / 3 doesn't divide evenly, so dividing and
+// multiplying back by 3 must land exactly on 10 again rather than
+// accumulating the rounding error a Float division would.
+func TestWithArbitraryPrecisionDivisionStaysExact(t *testing.T) {
+	input := "(10 / 3) * 3"
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program, err := p.Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	e := New(".", WithArbitraryPrecision(true))
+
+	result, err := e.Eval(program)
+	if err != nil {
+		t.Fatalf("eval error: %v", err)
+	}
+	testIntegerObject(t, result, 10)
+}
+
+// TestWithArbitraryPrecisionMixedComparison covers comparing and
+// equating a promoted value.BigInt against a plain value.Int, which
+// value.Int.Equals alone can't do (it only matches another value.Int).
+func TestWithArbitraryPrecisionMixedComparison(t *testing.T) {
+	input := "(9223372036854775807 + 1) > 9223372036854775807 && (9223372036854775807 + 1) != 9223372036854775807"
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program, err := p.Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	e := New(".", WithArbitraryPrecision(true))
+
+	result, err := e.Eval(program)
+	if err != nil {
+		t.Fatalf("eval error: %v", err)
+	}
+	testBooleanObject(t, result, true)
+}
+
+func TestBitwiseBuiltins(t *testing.T) {
+	testIntegerObject(t, testEval("bitAnd 6 3"), 2)
+	testIntegerObject(t, testEval("bitOr 6 3"), 7)
+	testIntegerObject(t, testEval("bitXor 6 3"), 5)
+}
+
+func TestQuotAndRem(t *testing.T) {
+	testIntegerObject(t, testEval("quot 7 2"), 3)
+	testIntegerObject(t, testEval("rem 7 2"), 1)
+
+	_, err := testEvalErr("quot 1 0")
+	if err == nil {
+		t.Fatal("expected quot by zero to error")
+	}
+
+	_, err = testEvalErr("rem 1 0")
+	if err == nil {
+		t.Fatal("expected rem by zero to error")
+	}
+}
+
+func TestCeilAndFloor(t *testing.T) {
+	testIntegerObject(t, testEval("ceil 1.5"), 2)
+	testIntegerObject(t, testEval("floor 1.5"), 1)
+	testIntegerObject(t, testEval("ceil 2"), 2)
+}
+
+func TestMapAppliesFunctionToEachElement(t *testing.T) {
+	result := testEval("map (x: x * 2) [ 1 2 3 ]")
+	list, ok := result.(*value.List)
+	if !ok || list.Len() != 3 {
+		t.Fatalf("expected a 3-element list, got %v", result)
+	}
+	testIntegerObject(t, list.Get(0), 2)
+	testIntegerObject(t, list.Get(1), 4)
+	testIntegerObject(t, list.Get(2), 6)
+}
+
+func TestFilterKeepsMatchingElements(t *testing.T) {
+	result := testEval("filter (x: x > 1) [ 1 2 3 ]")
+	list, ok := result.(*value.List)
+	if !ok || list.Len() != 2 {
+		t.Fatalf("expected a 2-element list, got %v", result)
+	}
+	testIntegerObject(t, list.Get(0), 2)
+	testIntegerObject(t, list.Get(1), 3)
+}
+
+func TestFoldlAccumulatesLeftToRight(t *testing.T) {
+	// The bare name can't be spelled: this lexer's identifier rule
+	// doesn't accept apostrophes (see lexer.isLetter), unlike real Nix's.
+	// builtins."foldl'" still reaches the same registered built-in,
+	// since attribute selection accepts a string-literal path segment.
+	testIntegerObject(t, testEval(`builtins."foldl'" (acc: x: acc - x) 10 [ 1 2 3 ]`), 4)
+}
+
+func TestGenListBuildsFromIndex(t *testing.T) {
+	result := testEval("genList (i: i * i) 4")
+	list, ok := result.(*value.List)
+	if !ok || list.Len() != 4 {
+		t.Fatalf("expected a 4-element list, got %v", result)
+	}
+	testIntegerObject(t, list.Get(0), 0)
+	testIntegerObject(t, list.Get(3), 9)
+}
+
+func TestConcatListsAndConcatMap(t *testing.T) {
+	testIntegerObject(t, testEval("length (concatLists [ [ 1 2 ] [ 3 ] ])"), 3)
+	testIntegerObject(t, testEval("length (concatMap (x: [ x x ]) [ 1 2 ])"), 4)
+}
+
+func TestSortOrdersByComparator(t *testing.T) {
+	result := testEval("sort (a: b: a < b) [ 3 1 2 ]")
+	list, ok := result.(*value.List)
+	if !ok || list.Len() != 3 {
+		t.Fatalf("expected a 3-element list, got %v", result)
+	}
+	testIntegerObject(t, list.Get(0), 1)
+	testIntegerObject(t, list.Get(1), 2)
+	testIntegerObject(t, list.Get(2), 3)
+}
+
+func TestListToAttrsAndMapAttrs(t *testing.T) {
+	result := testEval(`listToAttrs [ { name = "a"; value = 1; } { name = "b"; value = 2; } ]`)
+	attrs, ok := result.(*value.Attrs)
+	if !ok {
+		t.Fatalf("expected an attrset, got %v", result)
+	}
+	av, _ := attrs.Get("a")
+	testIntegerObject(t, av, 1)
+
+	mapped := testEval(`mapAttrs (name: v: v + 1) { a = 1; b = 2; }`)
+	mappedAttrs, ok := mapped.(*value.Attrs)
+	if !ok {
+		t.Fatalf("expected an attrset, got %v", mapped)
+	}
+	av, _ = mappedAttrs.Get("a")
+	testIntegerObject(t, av, 2)
+}
+
+func TestRemoveAttrsAndIntersectAttrs(t *testing.T) {
+	removed := testEval(`removeAttrs { a = 1; b = 2; } [ "b" ]`)
+	removedAttrs, ok := removed.(*value.Attrs)
+	if !ok || removedAttrs.Len() != 1 {
+		t.Fatalf("expected a 1-attribute attrset, got %v", removed)
+	}
+
+	intersected := testEval(`intersectAttrs { a = 1; b = 2; } { b = 3; c = 4; }`)
+	intersectedAttrs, ok := intersected.(*value.Attrs)
+	if !ok || intersectedAttrs.Len() != 1 {
+		t.Fatalf("expected a 1-attribute attrset, got %v", intersected)
+	}
+}
+
+func TestCatAttrsCollectsPresentValues(t *testing.T) {
+	result := testEval(`catAttrs "a" [ { a = 1; } { b = 2; } { a = 3; } ]`)
+	list, ok := result.(*value.List)
+	if !ok || list.Len() != 2 {
+		t.Fatalf("expected a 2-element list, got %v", result)
+	}
+	testIntegerObject(t, list.Get(0), 1)
+	testIntegerObject(t, list.Get(1), 3)
+}
+
+func TestSubstringAndConcatStringsSep(t *testing.T) {
+	result, err := value.Force(testEval(`substring 1 3 "nixpkgs"`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s, ok := result.(value.String); !ok || s.Raw != "ixp" {
+		t.Fatalf("expected %q, got %v", "ixp", result)
+	}
+
+	joined, err := value.Force(testEval(`concatStringsSep ", " [ "a" "b" "c" ]`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s, ok := joined.(value.String); !ok || s.Raw != "a, b, c" {
+		t.Fatalf("expected %q, got %v", "a, b, c", joined)
+	}
+}
+
+func TestToJSONFromJSONRoundTrip(t *testing.T) {
+	encoded, err := value.Force(testEval(`toJSON { a = 1; b = [ 1 2 3 ]; c = "hi"; }`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := encoded.(value.String); !ok {
+		t.Fatalf("expected toJSON to return a string, got %v", encoded)
+	}
+
+	decoded := testEval(`fromJSON (toJSON { a = 1; b = [ 1 2 3 ]; c = "hi"; })`)
+	attrs, ok := decoded.(*value.Attrs)
+	if !ok {
+		t.Fatalf("expected fromJSON to return an attrset, got %v", decoded)
+	}
+	av, _ := attrs.Get("a")
+	testIntegerObject(t, av, 1)
+}
+
+func TestTryEvalCatchesErrorsAndPassesThroughSuccess(t *testing.T) {
+	ok := testEval("(tryEval (1 + nope)).success")
+	testBooleanObject(t, ok, false)
+
+	good := testEval("(tryEval (1 + 1)).value")
+	testIntegerObject(t, good, 2)
+}
+
+func TestAbortAndThrowProduceErrors(t *testing.T) {
+	_, err := testEvalErr(`abort "boom"`)
+	if err == nil {
+		t.Fatal("expected abort to produce an error")
+	}
+
+	_, err = testEvalErr(`throw "boom"`)
+	if err == nil {
+		t.Fatal("expected throw to produce an error")
+	}
+}
+
+func TestCompareVersionsAndParseDrvName(t *testing.T) {
+	testIntegerObject(t, testEval(`compareVersions "1.0" "1.0.1"`), -1)
+	testIntegerObject(t, testEval(`compareVersions "1.2" "1.2"`), 0)
+	testIntegerObject(t, testEval(`compareVersions "2.0" "1.9"`), 1)
+
+	name := testEval(`(parseDrvName "gcc-wrapper-10.3.0").name`)
+	version := testEval(`(parseDrvName "gcc-wrapper-10.3.0").version`)
+	nameStr, ok := name.(value.String)
+	if !ok || nameStr.Raw != "gcc-wrapper" {
+		t.Fatalf("expected name %q, got %v", "gcc-wrapper", name)
+	}
+	versionStr, ok := version.(value.String)
+	if !ok || versionStr.Raw != "10.3.0" {
+		t.Fatalf("expected version %q, got %v", "10.3.0", version)
+	}
+}
+
+// TestToStringUsesDunderToString covers Nix's convention for giving an
+// arbitrary attrset a custom string representation: toString (and
+// string interpolation, which is defined as sugar for it) calls
+// __toString with the attrset itself rather than falling back to
+// outPath when __toString is present.
+func TestToStringUsesDunderToString(t *testing.T) {
+	result := testEval(`toString { __toString = self: "custom:" + self.name; name = "x"; }`)
+	s, ok := result.(value.String)
+	if !ok || s.Raw != "custom:x" {
+		t.Fatalf("expected %q, got %v", "custom:x", result)
+	}
+
+	interpolated := testEval(`"value is ${ { __toString = self: self.msg; msg = "hi"; } }"`)
+	is, ok := interpolated.(value.String)
+	if !ok || is.Raw != "value is hi" {
+		t.Fatalf("expected %q, got %v", "value is hi", interpolated)
+	}
+}
+
+// TestPathLiteralIsAbsolute covers that an absolute path literal evaluates
+// to a value.Path with its Value used as-is, unlike a relative one which
+// resolvePath joins against the evaluator's base directory.
+func TestPathLiteralIsAbsolute(t *testing.T) {
+	result := testEval(`/etc/nixos`)
+	p, ok := result.(value.Path)
+	if !ok || string(p) != "/etc/nixos" {
+		t.Fatalf("expected path %q, got %v", "/etc/nixos", result)
+	}
+}
+
+// TestInterpolatedPathResolvesLikeAPlainOne covers that a path literal
+// with a "${ ... }" interpolation is resolved the same way a plain
+// PathExpr's Value would be - joined against the evaluator's base
+// directory when relative - after its interpolated parts are coerced to
+// strings and spliced in.
+func TestInterpolatedPathResolvesLikeAPlainOne(t *testing.T) {
+	result := testEval(`let name = "foo"; in ./${name}.nix`)
+	p, ok := result.(value.Path)
+	if !ok {
+		t.Fatalf("expected value.Path, got %T (%v)", result, result)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("os.Getwd() failed: %v", err)
+	}
+	want := filepath.Join(wd, "foo.nix")
+	if string(p) != want {
+		t.Fatalf("expected path %q, got %q", want, string(p))
+	}
+}
+
+// TestStringConcatenationUnionsContext covers the rule that a string
+// built by concatenation keeps the context of its operands - so a
+// path's context carries over when a plain string is appended to it,
+// and discarding that context first leaves the result with none.
+func TestStringConcatenationUnionsContext(t *testing.T) {
+	result := testEval(`toString ./some-file + "-suffix"`)
+	s, ok := result.(value.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", result, result)
+	}
+	if !strings.HasSuffix(s.Raw, "/some-file-suffix") {
+		t.Errorf("unexpected concatenation result: %q", s.Raw)
+	}
+	if !s.HasContext() {
+		t.Error("expected the concatenation to carry over the path's context")
+	}
+
+	discarded := testEval(`unsafeDiscardStringContext (toString ./some-file) + "-suffix"`)
+	ds, ok := discarded.(value.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", discarded, discarded)
+	}
+	if ds.HasContext() {
+		t.Error("expected unsafeDiscardStringContext to drop the path's context")
+	}
+}
+
+func TestHasContextAndGetContext(t *testing.T) {
+	testBooleanObject(t, testEval(`hasContext "plain"`), false)
+	testBooleanObject(t, testEval(`hasContext (toString ./some-file)`), true)
+
+	ctx := testEval(`getContext (toString ./some-file)`)
+	attrs, ok := ctx.(*value.Attrs)
+	if !ok {
+		t.Fatalf("object is not Attrs. got=%T (%+v)", ctx, ctx)
+	}
+	if attrs.Len() != 1 {
+		t.Fatalf("expected one context entry, got %d", attrs.Len())
+	}
+}
+
+// TestAppendContextRoundTripsThroughGetContext checks that feeding
+// getContext's output straight back into appendContext reproduces the
+// same dependency, since that round trip is the whole point of the two
+// built-ins existing as a pair.
+func TestAppendContextRoundTripsThroughGetContext(t *testing.T) {
+	result := testEval(`
+		let
+			withCtx = toString ./some-file;
+			ctx = getContext withCtx;
+			plain = unsafeDiscardStringContext withCtx;
+		in hasContext (appendContext plain ctx)
+	`)
+	testBooleanObject(t, result, true)
+}
+
+func evalFileWith(t *testing.T, e *Evaluator, input string) value.Value {
+	t.Helper()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+
+	program, err := p.Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	result, err := e.Eval(program)
+	if err != nil {
+		t.Fatalf("eval error: %v", err)
+	}
+
+	return result
+}
+
+func TestImportEvaluatesFile(t *testing.T) {
+	dir := t.TempDir()
+	libPath := filepath.Join(dir, "lib.nix")
+	if err := os.WriteFile(libPath, []byte(`{ greeting = "hi"; }`), 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	e := New(dir)
+	result := evalFileWith(t, e, fmt.Sprintf(`(import %q).greeting`, libPath))
+
+	s, ok := result.(value.String)
+	if !ok || s.Raw != "hi" {
+		t.Errorf("object is not String \"hi\". got=%T (%+v)", result, result)
+	}
+}
+
+// TestImportCachesByPath covers the cache import is meant to provide:
+// importing the same path twice returns the value computed the first
+// time, even if the file on disk changes in between.
+func TestImportCachesByPath(t *testing.T) {
+	dir := t.TempDir()
+	libPath := filepath.Join(dir, "lib.nix")
+	if err := os.WriteFile(libPath, []byte(`1`), 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	e := New(dir)
+	first := evalFileWith(t, e, fmt.Sprintf(`import %q`, libPath))
+	testIntegerObject(t, first, 1)
+
+	if err := os.WriteFile(libPath, []byte(`2`), 0o644); err != nil {
+		t.Fatalf("failed to rewrite fixture: %v", err)
+	}
+
+	second := evalFileWith(t, e, fmt.Sprintf(`import %q`, libPath))
+	testIntegerObject(t, second, 1)
+}
+
+func TestScopedImportInjectsScope(t *testing.T) {
+	dir := t.TempDir()
+	libPath := filepath.Join(dir, "uses-x.nix")
+	if err := os.WriteFile(libPath, []byte(`x + 1`), 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	e := New(dir)
+	result := evalFileWith(t, e, fmt.Sprintf(`scopedImport { x = 41; } %q`, libPath))
+
+	testIntegerObject(t, result, 42)
+}
+
+// TestSaveLoadCacheRoundTrip builds a deeply nested attrset out of
+// concrete values, saves it, reloads it on a fresh Evaluator, and checks
+// the reloaded value still Equals the original.
+func TestSaveLoadCacheRoundTrip(t *testing.T) {
+	inner := value.NewAttrs()
+	inner.Set("name", value.NewString("gix"))
+	inner.Set("version", value.Int(3))
+
+	list := value.NewList(value.Int(1), value.Int(2), value.Int(3))
+
+	original := value.NewAttrs()
+	original.Set("pkg", inner)
+	original.Set("outputs", list)
+	original.Set("enabled", value.Bool(true))
+	original.Set("nothing", value.Null{})
+
+	path := filepath.Join(t.TempDir(), "round-trip.gixc")
+
+	e := New(".")
+	if err := e.SaveCache(path, original); err != nil {
+		t.Fatalf("SaveCache: %v", err)
+	}
+
+	fresh := New(".")
+	loaded, err := fresh.LoadCache(path)
+	if err != nil {
+		t.Fatalf("LoadCache: %v", err)
+	}
+
+	if !original.Equals(loaded) {
+		t.Errorf("round-tripped value does not Equal the original: got=%s", loaded.String())
+	}
+	if !loaded.Equals(original) {
+		t.Errorf("Equals is not symmetric for the round-tripped value: got=%s", loaded.String())
+	}
+}
+
+// TestSaveLoadCacheStringContext confirms a String's context survives the
+// round trip, since it's reconstructed from interned context-entry
+// strings rather than copied directly.
+func TestSaveLoadCacheStringContext(t *testing.T) {
+	key, elem := value.DrvContextElem("/nix/store/xxx-foo.drv", "out")
+	original := value.NewStringWithContext("/nix/store/xxx-foo", value.StringContext{key: elem})
+
+	path := filepath.Join(t.TempDir(), "string-context.gixc")
+
+	e := New(".")
+	if err := e.SaveCache(path, original); err != nil {
+		t.Fatalf("SaveCache: %v", err)
+	}
+
+	loaded, err := e.LoadCache(path)
+	if err != nil {
+		t.Fatalf("LoadCache: %v", err)
+	}
+
+	s, ok := loaded.(value.String)
+	if !ok {
+		t.Fatalf("loaded value is not value.String, got %T", loaded)
+	}
+	if !s.HasContext() {
+		t.Fatalf("round-tripped string lost its context")
+	}
+	if !original.Equals(s) {
+		t.Errorf("round-tripped string does not Equal the original")
+	}
+}
+
+// TestSaveLoadCacheFunctionBecomesStub confirms a Function cut at the
+// cache boundary reloads as something distinct from a real function
+// rather than silently vanishing or crashing the decoder.
+func TestSaveLoadCacheFunctionBecomesStub(t *testing.T) {
+	fn := testEval(`x: x + 1`)
+
+	path := filepath.Join(t.TempDir(), "function-stub.gixc")
+
+	e := New(".")
+	if err := e.SaveCache(path, fn); err != nil {
+		t.Fatalf("SaveCache: %v", err)
+	}
+
+	loaded, err := e.LoadCache(path)
+	if err != nil {
+		t.Fatalf("LoadCache: %v", err)
+	}
+
+	if loaded.Equals(fn) {
+		t.Errorf("a cached function stub should never compare Equal to anything")
+	}
+	if _, ok := loaded.(*value.Function); ok {
+		t.Errorf("a cached function stub should not decode back into a real *value.Function")
+	}
+}
+
+// TestCacheKeyStableUnderWhitespaceChanges checks normalizeSource's
+// whole point: two sources differing only in line endings or trailing
+// whitespace hash to the same key.
+func TestCacheKeyStableUnderWhitespaceChanges(t *testing.T) {
+	a := "1 + 1\n"
+	b := "1 + 1  \r\n\n\n"
+
+	if CacheKey(a) != CacheKey(b) {
+		t.Errorf("CacheKey should be stable under trailing whitespace/newline differences")
+	}
+
+	if CacheKey(a) == CacheKey("1 + 2\n") {
+		t.Errorf("CacheKey should differ for differing source")
+	}
+}
+
+// TestEvalCachedHitsCacheOnSecondCall proves EvalCached actually uses the
+// cache on a second call: it rewrites the cache file between calls with a
+// value that doesn't match the source, then checks the second call still
+// returns the stale cached value instead of re-evaluating.
+func TestEvalCachedHitsCacheOnSecondCall(t *testing.T) {
+	dir := t.TempDir()
+	e := New(".")
+
+	first, err := e.EvalCached("1 + 1", dir)
+	if err != nil {
+		t.Fatalf("EvalCached: %v", err)
+	}
+	testIntegerObject(t, first, 2)
+
+	cachePath := filepath.Join(dir, CacheKey("1 + 1")+".gixc")
+	if err := e.SaveCache(cachePath, value.Int(99)); err != nil {
+		t.Fatalf("SaveCache: %v", err)
+	}
+
+	second, err := e.EvalCached("1 + 1", dir)
+	if err != nil {
+		t.Fatalf("EvalCached: %v", err)
+	}
+	testIntegerObject(t, second, 99)
+}
+
+func TestWithOptimizeFoldsConstantsBeforeEval(t *testing.T) {
+	input := `let x = 1 + 2; in x * 10`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program, err := p.Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	e := New(".", WithOptimize(true))
+
+	result, err := e.Eval(program)
+	if err != nil {
+		t.Fatalf("eval error: %v", err)
+	}
+	testIntegerObject(t, result, 30)
+
+	stats := e.LastOptimizeStats()
+	if stats.ConstantsFolded == 0 {
+		t.Errorf("expected ConstantsFolded > 0, got %+v", stats)
+	}
+	if stats.LetBindingsInlined == 0 {
+		t.Errorf("expected LetBindingsInlined > 0, got %+v", stats)
+	}
+}
+
+func TestWithoutOptimizeLeavesStatsZero(t *testing.T) {
+	input := `1 + 2`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program, err := p.Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	e := New(".")
+
+	if _, err := e.Eval(program); err != nil {
+		t.Fatalf("eval error: %v", err)
+	}
+
+	if stats := e.LastOptimizeStats(); stats != (optimize.Stats{}) {
+		t.Errorf("expected zero Stats when WithOptimize is unset, got %+v", stats)
+	}
+}
+
+func TestDocBuiltinOnUserFunction(t *testing.T) {
+	input := `let f = /** Adds one. */ x: x + 1; in (doc f).content`
+	result := testEval(input)
+
+	str, ok := result.(value.String)
+	if !ok {
+		t.Fatalf("result not value.String. got=%T (%+v)", result, result)
+	}
+	if str.Raw != "Adds one." {
+		t.Fatalf("unexpected doc content. got=%q", str)
+	}
+}
+
+func TestDocBuiltinOnUndocumentedFunction(t *testing.T) {
+	input := `(doc (x: x)).content`
+	result := testEval(input)
+
+	str, ok := result.(value.String)
+	if !ok {
+		t.Fatalf("result not value.String. got=%T (%+v)", result, result)
+	}
+	if str.Raw != "" {
+		t.Fatalf("expected empty doc content, got=%q", str)
+	}
+}
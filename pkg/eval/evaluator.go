@@ -4,9 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	"github.com/conneroisu/gix/internal/types"
 	"github.com/conneroisu/gix/internal/value"
+	"github.com/conneroisu/gix/pkg/eval/optimize"
 )
 
 // Evaluator implements the semantic evaluation engine for Nix expressions.
@@ -14,21 +16,112 @@ import (
 // implementing the complete Nix evaluation semantics including scoping,
 // function application, and built-in operations.
 type Evaluator struct {
-	baseDir  string                 // Base directory for resolving relative paths
-	builtins map[string]value.Value // Built-in functions and constants
+	baseDir       string           // Base directory for resolving relative paths
+	registry      *BuiltinRegistry // Built-in functions available to this evaluator
+	languageLevel int              // Gates RegisterVersioned built-ins; see WithLanguageLevel
+	frames        []Frame          // Active call stack, innermost last; see pushFrame/trace
+	imports       *ImportCache     // Memoized import results; see ImportCache/WithImportCache
+
+	optimize          bool           // Whether Eval runs expr through optimize.Optimize first; see WithOptimize
+	lastOptimizeStats optimize.Stats // Stats from the most recent optimized Eval call; see LastOptimizeStats
+
+	arbitraryPrecision bool // Whether int64 overflow promotes to value.BigInt/value.Rat instead of erroring; see WithArbitraryPrecision
+}
+
+// Option configures an Evaluator at construction time, for the parts of
+// its behavior embedders need to customize without forking the package -
+// adding domain-specific built-ins or opting into newer language-level
+// gated features.
+type Option func(*Evaluator)
+
+// WithLanguageLevel sets the evaluator's language level, which gates any
+// built-in registered via (*BuiltinRegistry).RegisterVersioned with a
+// minLevel above 0. This mirrors how Nix has grown new builtins across
+// releases: an embedder pinned to an older level won't see built-ins
+// introduced after it.
+func WithLanguageLevel(level int) Option {
+	return func(e *Evaluator) { e.languageLevel = level }
+}
+
+// WithExtraBuiltin registers an additional built-in on top of the
+// standard library, letting third-party embedders add domain-specific
+// primops (e.g. a custom fetcher) without forking this package:
+//
+//	eval.New(dir, eval.WithExtraBuiltin("myFetch", 1, impl))
+//
+// arity follows the same curried-application rules as
+// (*BuiltinRegistry).Register.
+func WithExtraBuiltin(name string, arity int, impl BuiltinImpl) Option {
+	return func(e *Evaluator) { e.registry.Register(name, arity, impl) }
+}
+
+// WithBuiltins registers one or more interface-form Builtins alongside
+// the standard library, for embedders who'd rather define a type
+// (Name/Arity/Call) than pass WithExtraBuiltin's loose arguments:
+//
+//	eval.New(dir, eval.WithBuiltins(fetchGitBuiltin{}, readSecretBuiltin{}))
+func WithBuiltins(builtins ...Builtin) Option {
+	return func(e *Evaluator) {
+		for _, b := range builtins {
+			e.registry.RegisterBuiltin(b)
+		}
+	}
+}
+
+// WithOptimize enables the pkg/eval/optimize pass: Eval runs the parsed
+// expression through optimize.Optimize (constant folding, dead-branch
+// elimination, let-inlining, and rec-attrset invariant hoisting) before
+// evaluating it. Off by default, since the pass rebuilds the tree and
+// isn't worth the cost for an expression only ever evaluated once.
+func WithOptimize(enabled bool) Option {
+	return func(e *Evaluator) { e.optimize = enabled }
+}
+
+// WithArbitraryPrecision controls how +, -, *, and / behave once a
+// computation no longer fits in a native int64. Off by default, which
+// keeps the errors addInt64Checked/subInt64Checked/mulInt64Checked
+// already raise on overflow and evalDiv's integer division truncating
+// toward zero. Enabled, an overflowing +/-/* promotes to a
+// value.BigInt instead of erroring, and dividing two integers that
+// don't divide evenly produces an exact value.Rat instead of a
+// truncated Int - see the bigintOrRat* helpers in operators.go. A
+// result that is a whole number fitting in an int64 comes straight
+// back as a plain Int (see demoteRat); a Rat collapses to a Float only
+// at a display boundary (String, toString) or when a builtin that
+// isn't rational-aware forces the conversion.
+func WithArbitraryPrecision(enabled bool) Option {
+	return func(e *Evaluator) { e.arbitraryPrecision = enabled }
 }
 
 // New creates a new evaluator instance with the specified base directory.
 // The base directory is used for resolving relative path literals in expressions.
 // The evaluator is initialized with all standard built-in functions and constants
-// that form the Nix standard library.
-func New(baseDir string) *Evaluator {
+// that form the Nix standard library, then opts is applied in order, so a
+// later option (e.g. WithExtraBuiltin overriding a standard name) wins.
+func New(baseDir string, opts ...Option) *Evaluator {
+	// resolvePath only joins relative path literals onto baseDir, so a
+	// relative baseDir (e.g. ".") would leave resolved paths relative too.
+	// Canonicalize up front so every path resolvePath returns is absolute,
+	// as its doc comment promises; fall back to the given value if the
+	// working directory can't be determined.
+	if abs, err := filepath.Abs(baseDir); err == nil {
+		baseDir = abs
+	}
+
 	e := &Evaluator{
-		baseDir:  baseDir,                      // Store base directory for path resolution
-		builtins: make(map[string]value.Value), // Initialize built-ins registry
+		baseDir:  baseDir,              // Store base directory for path resolution
+		registry: NewBuiltinRegistry(), // Initialize the built-in registry
+		imports:  NewImportCache(),
 	}
 	// Populate the built-ins registry with standard functions
 	e.registerBuiltins()
+	e.registerStdlibBuiltins()
+	e.registerImportBuiltins()
+	e.registerFetchBuiltins()
+
+	for _, opt := range opts {
+		opt(e)
+	}
 
 	return e
 }
@@ -38,17 +131,62 @@ func New(baseDir string) *Evaluator {
 // environment populated with built-in functions and constants.
 // Used for evaluating top-level expressions and standalone evaluations.
 func (e *Evaluator) Eval(expr types.Expr) (value.Value, error) {
-	// Create a fresh environment for this evaluation
+	if e.optimize {
+		expr, e.lastOptimizeStats = optimize.Optimize(expr, optimize.DefaultMaxIterations)
+	}
+
+	// Delegate to the main evaluation dispatcher
+	val, err := e.evalExpr(expr, e.NewEnv())
+	if err != nil {
+		return nil, err
+	}
+
+	// The result may be an unforced Thunk (e.g. a bare reference to a
+	// lazy let binding) - external callers expect a concrete value back.
+	return value.Force(val)
+}
+
+// LastOptimizeStats returns the Stats from the most recent Eval call made
+// with WithOptimize(true), so a test or caller can confirm which passes
+// actually fired rather than just checking the result happens to be
+// correct. Zero-valued if WithOptimize was never enabled or Eval hasn't
+// run yet.
+func (e *Evaluator) LastOptimizeStats() optimize.Stats {
+	return e.lastOptimizeStats
+}
+
+// RegisterBuiltin adds b to e's registry, immediately available to any
+// Eval call that follows - the method form of WithBuiltins, for
+// embedders that already have an *Evaluator in hand (a long-lived REPL,
+// say) rather than constructing one fresh with New.
+func (e *Evaluator) RegisterBuiltin(b Builtin) {
+	e.registry.RegisterBuiltin(b)
+}
+
+// NewEnv creates a fresh environment populated with this evaluator's
+// built-in functions and constants, with no other bindings. Callers that
+// need to evaluate several expressions against a shared, evolving scope
+// (such as a REPL) can hold on to the returned environment and pass it to
+// EvalWithEnv repeatedly instead of going through Eval each time.
+//
+// Every built-in is installed twice: once under its own unqualified name
+// (as Nix has traditionally exposed length, map, etc. directly) and once
+// more inside a `builtins` attrset (as builtins.length, builtins.map,
+// ...), matching how real Nix exposes both forms side by side.
+func (e *Evaluator) NewEnv() *value.Env {
 	env := value.NewEnv()
+	env.Set("true", value.Bool(true))
+	env.Set("false", value.Bool(false))
+	env.Set("null", value.Null{})
 
-	// Populate environment with all built-in functions and constants
-	// This makes functions like 'length', 'map', etc. available to expressions
-	for name, builtin := range e.builtins {
+	builtinsAttrs := value.NewAttrs()
+	for name, builtin := range e.registry.Builtins(e, e.languageLevel) {
 		env.Set(name, builtin)
+		builtinsAttrs.Set(name, builtin)
 	}
+	env.Set("builtins", builtinsAttrs)
 
-	// Delegate to the main evaluation dispatcher
-	return e.evalExpr(expr, env)
+	return env
 }
 
 // EvalWithEnv evaluates an expression in an existing environment.
@@ -57,7 +195,12 @@ func (e *Evaluator) Eval(expr types.Expr) (value.Value, error) {
 // expressions within let bindings where variables are already bound.
 func (e *Evaluator) EvalWithEnv(expr types.Expr, env value.Environment) (value.Value, error) {
 	// Use the provided environment directly without modification
-	return e.evalExpr(expr, env)
+	val, err := e.evalExpr(expr, env)
+	if err != nil {
+		return nil, err
+	}
+
+	return value.Force(val)
 }
 
 // evalExpr is the central evaluation dispatcher that implements the Nix evaluation semantics.
@@ -76,8 +219,12 @@ func (e *Evaluator) evalExpr(expr types.Expr, env value.Environment) (value.Valu
 		return value.Float(expr.Value), nil
 
 	case *types.StringExpr:
-		// String literals: "hello", "world"
-		return value.String(expr.Value), nil
+		// String literals: "hello", "world" - no context of their own.
+		return value.NewString(expr.Value), nil
+
+	case *types.InterpStringExpr:
+		// String literals with "${ ... }" interpolation: "hello ${name}".
+		return e.evalInterpString(expr, env)
 
 	case *types.BoolExpr:
 		// Boolean literals: true, false
@@ -94,9 +241,13 @@ func (e *Evaluator) evalExpr(expr types.Expr, env value.Environment) (value.Valu
 
 		return value.Path(path), nil
 
+	case *types.InterpPathExpr:
+		// Path literals with "${ ... }" interpolation: ./foo/${name}.nix
+		return e.evalInterpPath(expr, env)
+
 	case *types.IdentExpr:
 		// Variable references: look up in current environment
-		return e.evalIdent(expr.Name, env)
+		return e.evalIdent(expr, env)
 
 	// Compound data structure expressions
 	case *types.ListExpr:
@@ -136,7 +287,10 @@ func (e *Evaluator) evalExpr(expr types.Expr, env value.Environment) (value.Valu
 	// Function expressions - creation and application
 	case *types.FunctionExpr:
 		// Function definitions: x: x + 1 (create closure capturing environment)
-		return value.NewFunction(expr.Param, expr.Body, env), nil
+		fn := value.NewFunction(expr.Param, expr.Body, env)
+		fn.SetDoc(expr.Doc)
+
+		return fn, nil
 
 	case *types.ApplyExpr:
 		// Function application: f x (apply function to argument)
@@ -157,39 +311,120 @@ func (e *Evaluator) evalExpr(expr types.Expr, env value.Environment) (value.Valu
 	}
 }
 
+// evalInterpString evaluates an interpolated string literal by
+// concatenating its literal parts as-is and coercing each "${ ... }"
+// part's value to a string (the same coercion toString performs),
+// unioning every part's context along the way - mirroring how evalAdd
+// handles string concatenation, since "${x}" is defined as sugar for
+// string addition.
+func (e *Evaluator) evalInterpString(expr *types.InterpStringExpr, env value.Environment) (value.Value, error) {
+	var sb strings.Builder
+
+	ctx := value.StringContext{}
+
+	for _, part := range expr.Parts {
+		if part.Expr == nil {
+			sb.WriteString(part.Literal)
+
+			continue
+		}
+
+		val, err := e.evalExpr(part.Expr, env)
+		if err != nil {
+			return nil, err
+		}
+
+		val, err = value.Force(val)
+		if err != nil {
+			return nil, err
+		}
+
+		str, err := e.coerceToString(val)
+		if err != nil {
+			return nil, err
+		}
+
+		sb.WriteString(str.Raw)
+		ctx = value.UnionContext(ctx, str.Context)
+	}
+
+	return value.NewStringWithContext(sb.String(), ctx), nil
+}
+
+// evalInterpPath evaluates a path literal with "${ ... }" interpolation
+// by concatenating its literal parts as-is and coercing each "${ ... }"
+// part's value to a string, the same way evalInterpString assembles an
+// interpolated string - then resolving the result exactly as a plain
+// PathExpr's Value would be, via resolvePath.
+func (e *Evaluator) evalInterpPath(expr *types.InterpPathExpr, env value.Environment) (value.Value, error) {
+	var sb strings.Builder
+
+	for _, part := range expr.Parts {
+		if part.Expr == nil {
+			sb.WriteString(part.Literal)
+
+			continue
+		}
+
+		val, err := e.evalExpr(part.Expr, env)
+		if err != nil {
+			return nil, err
+		}
+
+		val, err = value.Force(val)
+		if err != nil {
+			return nil, err
+		}
+
+		str, err := e.coerceToString(val)
+		if err != nil {
+			return nil, err
+		}
+
+		sb.WriteString(str.Raw)
+	}
+
+	return value.Path(e.resolvePath(sb.String())), nil
+}
+
 // evalIdent resolves variable references by looking up identifiers in the environment.
 // This implements lexical scoping - variables are resolved in the environment where
 // they are referenced, following the scope chain established by let bindings,
 // function parameters, and with expressions.
-func (e *Evaluator) evalIdent(name string, env value.Environment) (value.Value, error) {
+func (e *Evaluator) evalIdent(expr *types.IdentExpr, env value.Environment) (value.Value, error) {
 	// Attempt to resolve the variable in the current environment
-	if val, ok := env.Get(name); ok {
+	if val, ok := env.Get(expr.Name); ok {
 		// Variable found - return its value
 		return val, nil
 	}
 
-	// Variable not found in any accessible scope
-	return nil, fmt.Errorf("undefined variable: %s", name)
+	// Variable not found in any accessible scope - report where the
+	// reference is and what was being called at the time, so a REPL or
+	// LSP can point straight at it instead of just printing the message.
+	return nil, &Error{
+		Pos:   expr.Position(),
+		Kind:  ErrUndefinedVariable,
+		Msg:   fmt.Sprintf("undefined variable: %s", expr.Name),
+		Trace: e.trace(),
+	}
 }
 
-// evalList evaluates list literals by recursively evaluating each element.
-// Lists in Nix are heterogeneous sequences that can contain any combination
-// of value types. Evaluation is eager - all elements are evaluated immediately
-// when the list expression is encountered.
+// evalList evaluates list literals. Elements are lazy: each one is
+// wrapped in a Thunk closing over its own expression and env rather than
+// evaluated up front, so a list can hold elements that error, diverge,
+// or reference a not-yet-bound recursive name as long as nothing ever
+// forces them - the same principle evalLet and evalAttrSet already apply
+// to bindings. Consumers (builtinHead, builtinElem, etc.) force only the
+// elements they actually inspect.
 func (e *Evaluator) evalList(expr *types.ListExpr, env value.Environment) (value.Value, error) {
-	// Pre-allocate result slice with known size for efficiency
 	elements := make([]value.Value, len(expr.Elements))
-	// Evaluate each element expression in the current environment
 	for i, elem := range expr.Elements {
-		val, err := e.evalExpr(elem, env)
-		if err != nil {
-			// Propagate evaluation error with element context
-			return nil, err
-		}
-		elements[i] = val
+		elem := elem
+		elements[i] = value.NewThunk(func() (value.Value, error) {
+			return e.evalExpr(elem, env)
+		})
 	}
 
-	// Create and return the completed list value
 	return value.NewList(elements...), nil
 }
 
@@ -198,13 +433,29 @@ func (e *Evaluator) evalList(expr *types.ListExpr, env value.Environment) (value
 // - Regular sets: { x = 1; y = 2; } - bindings cannot reference each other
 // - Recursive sets: rec { x = 1; y = x + 1; } - bindings can reference each other
 //
-// For recursive sets, we implement a two-pass evaluation to handle dependencies:
-// 1. First pass: evaluate simple expressions that don't reference other bindings
-// 2. Second pass: evaluate complex expressions that may reference first-pass results.
+// For recursive sets, every binding is installed as a Thunk in a shared
+// environment before any of them are evaluated (see evalLet, which does
+// the same thing for the same reason), so bindings can reference each
+// other regardless of declaration order.
+//
+// Before any of that, mergeAttrBindings folds dotted-path bindings into
+// whatever inline attrset literal they extend (so `a = { b = 1; };
+// a.c = 2;` behaves like a single `a = { b = 1; c = 2; };`) and rejects
+// exact-path duplicates.
 func (e *Evaluator) evalAttrSet(
 	expr *types.AttrSetExpr,
 	env value.Environment,
 ) (value.Value, error) {
+	// Merge dotted-path writes into whatever inline attrset literal they
+	// extend, and catch exact-path duplicates, before doing anything
+	// else - both recursive and non-recursive branches below need to see
+	// the merged, conflict-checked binding list rather than expr's raw
+	// one. See mergeAttrBindings.
+	bindings, err := mergeAttrBindings(expr.Bindings)
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize the result attribute set
 	attrs := value.NewAttrs()
 
@@ -212,56 +463,93 @@ func (e *Evaluator) evalAttrSet(
 	evalEnv := env
 	if expr.Recursive {
 		// Recursive attribute set: create extended environment for self-references
-		recEnv := env.Extend()
+		recEnv := env.Extend().(*value.Env)
 		evalEnv = recEnv
 
-		// Two-pass evaluation for recursive sets to handle dependencies
-		// First pass: evaluate simple expressions (literals, non-referencing expressions)
-		// This establishes basic bindings that other expressions can reference
-		for _, binding := range expr.Bindings {
-			if len(binding.Path) == 1 && isSimpleExpr(binding.Value) {
-				// Simple expression - safe to evaluate first
-				val, err := e.evalExpr(binding.Value, recEnv)
-				if err != nil {
-					return nil, err
-				}
-				// Add to both the result set and the environment
-				attrs.Set(binding.Path[0], val)
-				recEnv.Set(binding.Path[0], val)
+		// rec { } is a simultaneous fixed-point binding, same as let:
+		// install every flat binding as a Thunk before evaluating any of
+		// them, so members can reference each other (and themselves)
+		// regardless of declaration order.
+		for _, binding := range bindings {
+			if len(binding.Path) != 1 {
+				continue // nested paths are resolved below
 			}
+
+			name, valueExpr := binding.Path[0], binding.Value
+			thunk := value.NewThunk(func() (value.Value, error) {
+				return e.evalExpr(valueExpr, recEnv)
+			})
+			attrs.Set(name, thunk)
+			recEnv.SetLazy(name, thunk)
 		}
 
-		// Second pass: evaluate complex expressions that may reference first-pass results
-		for _, binding := range expr.Bindings {
-			if len(binding.Path) == 1 && !isSimpleExpr(binding.Value) {
-				// Complex expression - evaluate with access to first-pass bindings
-				val, err := e.evalExpr(binding.Value, recEnv)
-				if err != nil {
-					return nil, err
-				}
-				attrs.Set(binding.Path[0], val)
-			} else if len(binding.Path) > 1 {
-				// Nested attribute path: a.b.c = value
+		// Nested attribute paths (a.b.c = value) are evaluated directly
+		// into the result set; they can still reach the thunked flat
+		// bindings above through recEnv.
+		for _, binding := range bindings {
+			if len(binding.Path) > 1 {
 				if err := e.setNestedAttr(attrs, binding.Path, binding.Value, recEnv); err != nil {
 					return nil, err
 				}
 			}
 		}
+
+		// inherit clauses are part of the same fixed point: the names
+		// they introduce must be visible to sibling bindings, and vice
+		// versa, so they go through the same recEnv/attrs pair.
+		e.installInherits(expr.Inherits, env, recEnv, attrs)
 	} else {
-		// Non-recursive attribute set: straightforward evaluation
-		// Bindings cannot reference each other, so order doesn't matter
-		for _, binding := range expr.Bindings {
+		// Non-recursive attribute set: bindings cannot reference each
+		// other, so order doesn't matter, but values are still lazy -
+		// `{ a = abort "boom"; b = 1; }.b` must not evaluate `a`.
+		for _, binding := range bindings {
 			if len(binding.Path) == 1 {
-				// Simple attribute: name = value
-				val, err := e.evalExpr(binding.Value, evalEnv)
-				if err != nil {
-					return nil, err
+				name, valueExpr := binding.Path[0], binding.Value
+				attrs.Set(name, value.NewThunk(func() (value.Value, error) {
+					return e.evalExpr(valueExpr, evalEnv)
+				}))
+			} else if err := e.setNestedAttr(attrs, binding.Path, binding.Value, evalEnv); err != nil {
+				return nil, err
+			}
+		}
+
+		// Non-recursive inherit: looked up eagerly in the surrounding
+		// scope, and only ever written into the result set - evalEnv is
+		// the caller's own environment here, so it must not be mutated.
+		for _, inherit := range expr.Inherits {
+			if inherit.From == nil {
+				for _, name := range inherit.Attrs {
+					val, ok := evalEnv.Get(name)
+					if !ok {
+						return nil, fmt.Errorf("inherit: undefined variable: %s", name)
+					}
+					attrs.Set(name, val)
 				}
-				attrs.Set(binding.Path[0], val)
-			} else {
-				if err := e.setNestedAttr(attrs, binding.Path, binding.Value, evalEnv); err != nil {
-					return nil, err
+
+				continue
+			}
+
+			srcVal, err := e.evalExpr(inherit.From, evalEnv)
+			if err != nil {
+				return nil, err
+			}
+
+			srcVal, err = value.Force(srcVal)
+			if err != nil {
+				return nil, err
+			}
+
+			srcAttrs, ok := srcVal.(*value.Attrs)
+			if !ok {
+				return nil, fmt.Errorf("inherit (...) requires an attribute set, got %v", srcVal.Type())
+			}
+
+			for _, name := range inherit.Attrs {
+				val, ok := srcAttrs.Get(name)
+				if !ok {
+					return nil, fmt.Errorf("attribute '%s' not found", name)
 				}
+				attrs.Set(name, val)
 			}
 		}
 	}
@@ -269,6 +557,83 @@ func (e *Evaluator) evalAttrSet(
 	return attrs, nil
 }
 
+// installInherits binds the names introduced by a list of inherit clauses
+// into target, alongside (and visible to) whatever ordinary bindings
+// share that scope - this is used by both evalLet and the recursive
+// branch of evalAttrSet, which is why target is a *value.Env rather than
+// the result attribute set directly. When attrs is non-nil (rec { }),
+// the same values are also recorded there so they show up in the final
+// result; evalLet passes nil since a let has no attribute set of its own.
+//
+// The plain form (`inherit x;`) resolves x in outerEnv, the lexically
+// enclosing scope - not target, since a bare inherit refers to the
+// binding it is named after, not to itself. The scoped form
+// (`inherit (expr) x;`) evaluates expr once, shared across every name it
+// inherits, and selects each name out of the result.
+func (e *Evaluator) installInherits(
+	inherits []types.InheritClause,
+	outerEnv value.Environment,
+	target *value.Env,
+	attrs *value.Attrs,
+) {
+	install := func(name string, thunk *value.Thunk) {
+		target.SetLazy(name, thunk)
+		if attrs != nil {
+			attrs.Set(name, thunk)
+		}
+	}
+
+	for _, inherit := range inherits {
+		if inherit.From == nil {
+			for _, name := range inherit.Attrs {
+				name := name
+				install(name, value.NewThunk(func() (value.Value, error) {
+					val, ok := outerEnv.Get(name)
+					if !ok {
+						return nil, fmt.Errorf("inherit: undefined variable: %s", name)
+					}
+
+					return value.Force(val)
+				}))
+			}
+
+			continue
+		}
+
+		fromExpr := inherit.From
+		source := value.NewThunk(func() (value.Value, error) {
+			val, err := e.evalExpr(fromExpr, target)
+			if err != nil {
+				return nil, err
+			}
+
+			return value.Force(val)
+		})
+
+		for _, name := range inherit.Attrs {
+			name := name
+			install(name, value.NewThunk(func() (value.Value, error) {
+				srcVal, err := source.Force()
+				if err != nil {
+					return nil, err
+				}
+
+				srcAttrs, ok := srcVal.(*value.Attrs)
+				if !ok {
+					return nil, fmt.Errorf("inherit (...) requires an attribute set, got %v", srcVal.Type())
+				}
+
+				val, ok := srcAttrs.Get(name)
+				if !ok {
+					return nil, fmt.Errorf("attribute '%s' not found", name)
+				}
+
+				return value.Force(val)
+			}))
+		}
+	}
+}
+
 // setNestedAttr handles nested attribute assignments like a.b.c = value.
 // This method navigates through the attribute path, creating intermediate
 // attribute sets as needed, and sets the final value at the end of the path.
@@ -284,58 +649,44 @@ func (e *Evaluator) setNestedAttr(
 		return errors.New("empty attribute path")
 	}
 
-	// Evaluate the value expression that will be assigned
-	val, err := e.evalExpr(expr, env)
-	if err != nil {
-		return err
-	}
-
-	// Navigate through the path, creating intermediate attribute sets as needed
+	// Navigate through the path, creating intermediate attribute sets as
+	// needed. This structure is built eagerly - attribute names are
+	// always static in Nix - only the leaf value at the end is lazy.
 	current := attrs
-	for i := range len(path) - 1 {
+	for i := 0; i < len(path)-1; i++ {
 		key := path[i]
 		if existing, ok := current.Get(key); ok {
 			// Path component already exists - must be an attribute set
-			if nested, ok := existing.(*value.Attrs); ok {
-				// Continue navigation through existing attribute set
-				current = nested
-			} else {
+			nested, ok := existing.(*value.Attrs)
+			if !ok {
 				// Conflict: trying to treat non-set value as attribute set
-				return fmt.Errorf("attribute path conflict at %s", key)
+				return &Error{
+					Pos:   expr.Position(),
+					Kind:  ErrAttrConflict,
+					Msg:   fmt.Sprintf("attribute path conflict at %s", key),
+					Trace: e.trace(),
+				}
 			}
-		} else {
-			// Path component doesn't exist - create new attribute set
-			nested := value.NewAttrs()
-			current.Set(key, nested)
 			current = nested
+
+			continue
 		}
+
+		// Path component doesn't exist - create new attribute set
+		nested := value.NewAttrs()
+		current.Set(key, nested)
+		current = nested
 	}
 
-	// Set the final value at the end of the path
-	current.Set(path[len(path)-1], val)
+	// The leaf value is lazy, same as a flat binding.
+	name, valueExpr := path[len(path)-1], expr
+	current.Set(name, value.NewThunk(func() (value.Value, error) {
+		return e.evalExpr(valueExpr, env)
+	}))
 
 	return nil
 }
 
-// isSimpleExpr determines if an expression is "simple" for recursive set evaluation.
-// Simple expressions are those that don't reference other bindings within the same
-// attribute set. These can be safely evaluated in the first pass of recursive set
-// evaluation, providing values that more complex expressions can then reference.
-//
-// Simple expressions include all literal values that evaluate to themselves.
-func isSimpleExpr(expr types.Expr) bool {
-	switch expr.(type) {
-	// All literal expressions are simple (no variable references)
-	case *types.IntExpr, *types.FloatExpr, *types.StringExpr,
-		*types.BoolExpr, *types.NullExpr, *types.PathExpr:
-		return true
-	default:
-		// All other expressions may contain variable references
-		// This includes: identifiers, functions, operations, control flow
-		return false
-	}
-}
-
 // resolvePath resolves path literals against the evaluator's base directory.
 // This ensures that relative paths in Nix expressions are interpreted relative
 // to a consistent base directory, typically where the Nix file is located.
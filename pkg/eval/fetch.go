@@ -0,0 +1,284 @@
+package eval
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/conneroisu/gix/internal/value"
+)
+
+// registerFetchBuiltins adds fetchurl and fetchTarball to the registry.
+// They're split out from registerBuiltins because, unlike the rest of
+// the standard library, they talk to the network and the local
+// filesystem cache rather than just computing over values already in
+// hand.
+func (e *Evaluator) registerFetchBuiltins() {
+	r := e.registry
+
+	// fetchurl url-or-{url,sha256} → path
+	// Nix example: fetchurl { url = "https://example.com/x.tar.gz"; sha256 = "..."; }
+	r.Register("fetchurl", 1, builtinFetchurl)
+
+	// fetchTarball url-or-{url,sha256} → path
+	// Nix example: fetchTarball { url = "https://example.com/x.tar.gz"; sha256 = "..."; }
+	r.Register("fetchTarball", 1, builtinFetchTarball)
+}
+
+// fetchSpec is the subset of fetchurl/fetchTarball's argument shapes
+// this implementation accepts: a bare URL string, or an attrset with a
+// required url and an optional sha256 to verify the download against.
+type fetchSpec struct {
+	url    string
+	sha256 string
+}
+
+func parseFetchSpec(fn string, v value.Value) (fetchSpec, error) {
+	switch arg := v.(type) {
+	case value.String:
+		return fetchSpec{url: arg.Raw}, nil
+
+	case *value.Attrs:
+		urlVal, ok := arg.Get("url")
+		if !ok {
+			return fetchSpec{}, fmt.Errorf("%s: attribute set argument must have a 'url' attribute", fn)
+		}
+		urlVal, err := value.Force(urlVal)
+		if err != nil {
+			return fetchSpec{}, err
+		}
+		urlStr, ok := urlVal.(value.String)
+		if !ok {
+			return fetchSpec{}, fmt.Errorf("%s: 'url' must be a string", fn)
+		}
+
+		spec := fetchSpec{url: urlStr.Raw}
+
+		if sha256Val, ok := arg.Get("sha256"); ok {
+			sha256Val, err := value.Force(sha256Val)
+			if err != nil {
+				return fetchSpec{}, err
+			}
+			if sha256Str, ok := sha256Val.(value.String); ok {
+				spec.sha256 = sha256Str.Raw
+			}
+		}
+
+		return spec, nil
+
+	default:
+		return fetchSpec{}, fmt.Errorf("%s expects a URL string or attribute set, got %v", fn, v.Type())
+	}
+}
+
+func builtinFetchurl(_ *Evaluator, args []value.Value) (value.Value, error) {
+	spec, err := parseFetchSpec("fetchurl", args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := fetchToCache(spec, false)
+	if err != nil {
+		return nil, fmt.Errorf("fetchurl: %w", err)
+	}
+
+	return pathResultWithContext(path), nil
+}
+
+func builtinFetchTarball(_ *Evaluator, args []value.Value) (value.Value, error) {
+	spec, err := parseFetchSpec("fetchTarball", args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := fetchToCache(spec, true)
+	if err != nil {
+		return nil, fmt.Errorf("fetchTarball: %w", err)
+	}
+
+	return pathResultWithContext(path), nil
+}
+
+// pathResultWithContext wraps a fetched local path as a string carrying
+// a context entry for itself, the same way builtinToString treats a
+// value.Path, so a derivation built from a fetcher's result records it
+// as an input source.
+func pathResultWithContext(path string) value.String {
+	key, elem := value.PathContextElem(path)
+
+	return value.NewStringWithContext(path, value.StringContext{key: elem})
+}
+
+// fetchCacheDir returns the directory gix caches fetcher downloads
+// under, honoring $XDG_CACHE_HOME the way the rest of the freedesktop
+// tooling Nix integrates with does.
+func fetchCacheDir() (string, error) {
+	base := os.Getenv("XDG_CACHE_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".cache")
+	}
+
+	return filepath.Join(base, "gix", "fetches"), nil
+}
+
+// fetchToCache downloads spec.url into the fetch cache, keyed by its
+// declared sha256 (or, lacking one, a hash of the URL itself so repeat
+// fetches of the same unverified URL still hit the cache), verifies the
+// download against sha256 when given, and returns the local path - the
+// file itself for fetchurl, or the directory it was extracted to for
+// fetchTarball.
+func fetchToCache(spec fetchSpec, extract bool) (string, error) {
+	cacheDir, err := fetchCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	key := spec.sha256
+	if key == "" {
+		sum := sha256.Sum256([]byte(spec.url))
+		key = hex.EncodeToString(sum[:])
+	}
+
+	dest := filepath.Join(cacheDir, key)
+	if extract {
+		dest += ".d"
+	}
+
+	if _, err := os.Stat(dest); err == nil {
+		return dest, nil
+	}
+
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return "", err
+	}
+
+	resp, err := http.Get(spec.url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status fetching %s: %s", spec.url, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if spec.sha256 != "" {
+		sum := sha256.Sum256(data)
+		if got := hex.EncodeToString(sum[:]); got != spec.sha256 {
+			return "", fmt.Errorf("hash mismatch for %s: expected sha256:%s, got sha256:%s", spec.url, spec.sha256, got)
+		}
+	}
+
+	if extract {
+		if err := extractTarball(data, dest); err != nil {
+			return "", err
+		}
+
+		return dest, nil
+	}
+
+	if err := os.WriteFile(dest, data, 0o644); err != nil {
+		return "", err
+	}
+
+	return dest, nil
+}
+
+// extractTarball unpacks data (optionally gzip-compressed) into dest.
+// Matching real Nix's fetchTarball, if the archive contains exactly one
+// top-level directory, dest ends up being that directory's contents
+// directly rather than a wrapper around it.
+func extractTarball(data []byte, dest string) error {
+	var tarReader io.Reader = bytes.NewReader(data)
+	if gz, err := gzip.NewReader(bytes.NewReader(data)); err == nil {
+		defer gz.Close()
+		tarReader = gz
+	}
+
+	tmp := dest + ".tmp"
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(tmp, 0o755); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(tarReader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(tmp, hdr.Name)
+		if !strings.HasPrefix(target, filepath.Clean(tmp)+string(os.PathSeparator)) {
+			return fmt.Errorf("tarball entry %q escapes destination directory", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode))
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+
+				return err
+			}
+			f.Close()
+		}
+	}
+
+	return collapseSingleTopDir(tmp, dest)
+}
+
+// collapseSingleTopDir renames tmp to dest, first unwrapping it if its
+// only entry is a single directory.
+func collapseSingleTopDir(tmp, dest string) error {
+	entries, err := os.ReadDir(tmp)
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 1 && entries[0].IsDir() {
+		inner := filepath.Join(tmp, entries[0].Name())
+
+		if err := os.Rename(inner, dest); err != nil {
+			return err
+		}
+
+		return os.RemoveAll(tmp)
+	}
+
+	return os.Rename(tmp, dest)
+}
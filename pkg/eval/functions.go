@@ -8,7 +8,10 @@ import (
 	"github.com/conneroisu/gix/internal/value"
 )
 
-// evalApply evaluates function application.
+// evalApply evaluates function application. The argument is passed as a
+// Thunk rather than evaluated up front: Nix functions are call-by-need,
+// so `(x: 1) (abort "boom")` must succeed - the function never forces
+// its unused parameter.
 func (e *Evaluator) evalApply(expr *types.ApplyExpr, env value.Environment) (value.Value, error) {
 	// Evaluate the function
 	fnVal, err := e.evalExpr(expr.Func, env)
@@ -16,18 +19,22 @@ func (e *Evaluator) evalApply(expr *types.ApplyExpr, env value.Environment) (val
 		return nil, err
 	}
 
-	// Evaluate the argument
-	argVal, err := e.evalExpr(expr.Arg, env)
+	fnVal, err = value.Force(fnVal)
 	if err != nil {
 		return nil, err
 	}
 
+	argExpr := expr.Arg
+	argThunk := value.NewThunk(func() (value.Value, error) {
+		return e.evalExpr(argExpr, env)
+	})
+
 	// Apply based on function type
 	switch fn := fnVal.(type) {
 	case *value.Function:
 		// Create new environment for function body
-		fnEnv := fn.Env().Extend()
-		fnEnv.Set(fn.Param(), argVal)
+		fnEnv := fn.Env().Extend().(*value.Env)
+		fnEnv.SetLazy(fn.Param(), argThunk)
 
 		// Evaluate function body
 		body, ok := fn.Body().(types.Expr)
@@ -35,14 +42,57 @@ func (e *Evaluator) evalApply(expr *types.ApplyExpr, env value.Environment) (val
 			return nil, errors.New("invalid function body")
 		}
 
+		// Record this call on the stack for the duration of evaluating
+		// the body, so an error raised deep inside it (an undefined
+		// variable, another bad application) can report "called from"
+		// here too.
+		pop := e.pushFrame(expr.String(), expr.Position())
+		defer pop()
+
 		return e.evalExpr(body, fnEnv)
 
 	case *value.Builtin:
 		// Builtin functions expect a list of arguments
-		return fn.Apply([]value.Value{argVal})
+		return fn.Apply([]value.Value{argThunk})
 
 	default:
-		return nil, fmt.Errorf("cannot apply non-function value of type %v", fnVal.Type())
+		return nil, &Error{
+			Pos:   expr.Position(),
+			Kind:  ErrNotCallable,
+			Msg:   fmt.Sprintf("cannot apply non-function value of type %v", fnVal.Type()),
+			Trace: e.trace(),
+		}
+	}
+}
+
+// applyFunction applies fn to arg and returns the (unforced) result,
+// mirroring evalApply's handling of the two callable value kinds for
+// built-ins that already have both sides as values rather than
+// expressions - map, filter, foldl', sort and friends all go through
+// this instead of duplicating evalApply's switch.
+func (e *Evaluator) applyFunction(fn, arg value.Value) (value.Value, error) {
+	fn, err := value.Force(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	switch f := fn.(type) {
+	case *value.Function:
+		body, ok := f.Body().(types.Expr)
+		if !ok {
+			return nil, errors.New("invalid function body")
+		}
+
+		fnEnv := f.Env().Extend().(*value.Env)
+		fnEnv.Set(f.Param(), arg)
+
+		return e.evalExpr(body, fnEnv)
+
+	case *value.Builtin:
+		return f.Apply([]value.Value{arg})
+
+	default:
+		return nil, fmt.Errorf("attempt to call a %v value", fn.Type())
 	}
 }
 
@@ -55,7 +105,10 @@ func (e *Evaluator) evalSelect(expr *types.SelectExpr, env value.Environment) (v
 	}
 
 	// Navigate through the attribute path
-	current := val
+	current, err := value.Force(val)
+	if err != nil {
+		return nil, err
+	}
 	for i, key := range expr.AttrPath {
 		attrs, ok := current.(*value.Attrs)
 		if !ok {
@@ -77,12 +130,16 @@ func (e *Evaluator) evalSelect(expr *types.SelectExpr, env value.Environment) (v
 			return nil, fmt.Errorf("attribute '%s' not found", key)
 		}
 
-		// For the last key, return the value
+		// For the last key, return the value (left unforced - the caller
+		// forces it if and when it's actually inspected)
 		if i == len(expr.AttrPath)-1 {
 			return next, nil
 		}
 
-		current = next
+		current, err = value.Force(next)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// This shouldn't happen
@@ -101,7 +158,10 @@ func (e *Evaluator) evalHasAttr(
 	}
 
 	// Navigate through the attribute path
-	current := val
+	current, err := value.Force(val)
+	if err != nil {
+		return nil, err
+	}
 	for i, key := range expr.AttrPath {
 		attrs, ok := current.(*value.Attrs)
 		if !ok {
@@ -118,7 +178,10 @@ func (e *Evaluator) evalHasAttr(
 			return value.Bool(true), nil
 		}
 
-		current = next
+		current, err = value.Force(next)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return value.Bool(true), nil
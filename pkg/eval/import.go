@@ -0,0 +1,182 @@
+package eval
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/conneroisu/gix/internal/value"
+	"github.com/conneroisu/gix/pkg/lexer"
+	"github.com/conneroisu/gix/pkg/parser"
+)
+
+// ImportCache memoizes the result of import, keyed by canonicalized
+// path, so importing the same file more than once - the common case
+// for a shared library like lib/default.nix - only parses and
+// evaluates it once. It's safe for concurrent use and safe to share
+// across Evaluators via WithImportCache, so a caller that creates
+// several Evaluators over a related set of files (a REPL loading one
+// file after another, or a tool that evaluates many entry points) can
+// warm it once and have every Evaluator benefit.
+type ImportCache struct {
+	mu      sync.Mutex
+	results map[string]value.Value
+}
+
+// NewImportCache creates an empty import cache.
+func NewImportCache() *ImportCache {
+	return &ImportCache{results: make(map[string]value.Value)}
+}
+
+func (c *ImportCache) get(path string) (value.Value, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	v, ok := c.results[path]
+
+	return v, ok
+}
+
+func (c *ImportCache) set(path string, v value.Value) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.results[path] = v
+}
+
+// WithImportCache sets the evaluator's import cache, replacing the one
+// created by New. Passing the same *ImportCache to several Evaluators
+// shares memoized imports between them.
+func WithImportCache(cache *ImportCache) Option {
+	return func(e *Evaluator) { e.imports = cache }
+}
+
+// ImportCache returns e's import cache, so a caller can warm it ahead
+// of evaluation or hand it to WithImportCache to share it with another
+// Evaluator.
+func (e *Evaluator) ImportCache() *ImportCache {
+	return e.imports
+}
+
+// registerImportBuiltins adds import and scopedImport to the registry.
+// They're split out from registerBuiltins because, unlike every other
+// built-in, they need access to the lexer/parser packages and to the
+// evaluator's import cache.
+func (e *Evaluator) registerImportBuiltins() {
+	r := e.registry
+
+	// import path → value
+	// Nix example: import ./lib.nix
+	r.Register("import", 1, builtinImport)
+
+	// scopedImport scope path → value
+	// Nix example: scopedImport { x = 1; } ./uses-x.nix
+	r.RegisterPrimOpApp("scopedImport", 2, builtinScopedImport)
+}
+
+func builtinImport(e *Evaluator, args []value.Value) (value.Value, error) {
+	path, err := pathArg("import", args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return e.importPath(path, nil)
+}
+
+func builtinScopedImport(e *Evaluator, args []value.Value) (value.Value, error) {
+	scope, ok := args[0].(*value.Attrs)
+	if !ok {
+		return nil, fmt.Errorf("scopedImport expects an attribute set as its first argument, got %v", args[0].Type())
+	}
+
+	path, err := pathArg("scopedImport", args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return e.importPath(path, scope)
+}
+
+// importPath parses and evaluates the .nix file at path in a fresh
+// environment, as import and scopedImport require. scope, if non-nil,
+// is merged into that fresh environment on top of the usual builtins -
+// scopedImport's extra bindings, which shadow same-named builtins just
+// as a let binding would.
+//
+// Results are only cached for plain import (scope == nil): scopedImport
+// is deliberately keyed on the scope argument as well as the path, and
+// caching that too would need the cache key to account for scope's
+// identity, which isn't worth the complexity for a built-in real Nix
+// itself documents as rarely used.
+func (e *Evaluator) importPath(path string, scope *value.Attrs) (value.Value, error) {
+	canonical, err := e.canonicalImportPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("import: %w", err)
+	}
+
+	if scope == nil {
+		if cached, ok := e.ImportCache().get(canonical); ok {
+			return cached, nil
+		}
+	}
+
+	contents, err := os.ReadFile(canonical)
+	if err != nil {
+		return nil, fmt.Errorf("import: %w", err)
+	}
+
+	l := lexer.New(string(contents))
+	p := parser.NewWithFilename(l, canonical)
+
+	ast, err := p.Parse()
+	if err != nil {
+		return nil, fmt.Errorf("import: parse error in %s: %w", canonical, err)
+	}
+
+	// The imported file resolves its own relative paths (nested
+	// imports, readFile, ...) against its own directory, not the
+	// importing file's - so evaluation happens through a child
+	// Evaluator pointed at that directory. It shares this Evaluator's
+	// registry (so custom built-ins added via WithExtraBuiltin carry
+	// through) and import cache (so a diamond of imports still only
+	// evaluates each file once).
+	child := &Evaluator{
+		baseDir:       filepath.Dir(canonical),
+		registry:      e.registry,
+		languageLevel: e.languageLevel,
+		imports:       e.ImportCache(),
+	}
+
+	env := child.NewEnv()
+	if scope != nil {
+		for _, name := range scope.Keys() {
+			v, _ := scope.Get(name)
+			env.Set(name, v)
+		}
+	}
+
+	result, err := child.EvalWithEnv(ast, env)
+	if err != nil {
+		return nil, fmt.Errorf("import: error evaluating %s: %w", canonical, err)
+	}
+
+	if scope == nil {
+		e.ImportCache().set(canonical, result)
+	}
+
+	return result, nil
+}
+
+// canonicalImportPath resolves path (relative paths against e's base
+// directory, same as any other path built-in) to the absolute, cleaned
+// form used as the import cache key, so the same file reached through
+// two different relative paths still hits the cache.
+func (e *Evaluator) canonicalImportPath(path string) (string, error) {
+	abs, err := filepath.Abs(e.resolvePath(path))
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Clean(abs), nil
+}
@@ -3,6 +3,8 @@ package eval
 import (
 	"errors"
 	"fmt"
+	"math"
+	"math/big"
 
 	"github.com/conneroisu/gix/internal/types"
 	"github.com/conneroisu/gix/internal/value"
@@ -25,22 +27,44 @@ func (e *Evaluator) evalBinary(expr *types.BinaryExpr, env value.Environment) (v
 	if err != nil {
 		return nil, err
 	}
+	left, err = value.Force(left)
+	if err != nil {
+		return nil, err
+	}
 
 	right, err := e.evalExpr(expr.Right, env)
 	if err != nil {
 		return nil, err
 	}
+	right, err = value.Force(right)
+	if err != nil {
+		return nil, err
+	}
 
-	switch expr.Op {
+	result, err := e.evalBinaryOp(expr.Op, left, right)
+	if err != nil {
+		return nil, e.wrapRuntimeError(expr, err)
+	}
+
+	return result, nil
+}
+
+// evalBinaryOp computes a non-short-circuit binary operator over already
+// forced operands. Split out of evalBinary so every error it returns -
+// from evalAdd, evalDiv, evalConcat, evalUpdate, or an unknown operator -
+// passes through a single wrapRuntimeError call instead of needing its
+// own at every return site.
+func (e *Evaluator) evalBinaryOp(op types.BinaryOp, left, right value.Value) (value.Value, error) {
+	switch op {
 	// Arithmetic
 	case types.OpAdd:
-		return evalAdd(left, right)
+		return e.evalAdd(left, right)
 	case types.OpSub:
-		return evalSub(left, right)
+		return e.evalSub(left, right)
 	case types.OpMul:
-		return evalMul(left, right)
+		return e.evalMul(left, right)
 	case types.OpDiv:
-		return evalDiv(left, right)
+		return e.evalDiv(left, right)
 
 	// String/List operations
 	case types.OpConcat:
@@ -48,9 +72,9 @@ func (e *Evaluator) evalBinary(expr *types.BinaryExpr, env value.Environment) (v
 
 	// Comparison
 	case types.OpEq:
-		return value.Bool(left.Equals(right)), nil
+		return value.Bool(valuesEqual(left, right)), nil
 	case types.OpNEq:
-		return value.Bool(!left.Equals(right)), nil
+		return value.Bool(!valuesEqual(left, right)), nil
 	case types.OpLT:
 		return evalLess(left, right)
 	case types.OpGT:
@@ -65,7 +89,7 @@ func (e *Evaluator) evalBinary(expr *types.BinaryExpr, env value.Environment) (v
 		return evalUpdate(left, right)
 
 	default:
-		return nil, fmt.Errorf("unknown binary operator: %v", expr.Op)
+		return nil, fmt.Errorf("unknown binary operator: %v", op)
 	}
 }
 
@@ -75,8 +99,21 @@ func (e *Evaluator) evalUnary(expr *types.UnaryExpr, env value.Environment) (val
 	if err != nil {
 		return nil, err
 	}
+	operand, err = value.Force(operand)
+	if err != nil {
+		return nil, err
+	}
 
-	switch expr.Op {
+	result, err := evalUnaryOp(expr.Op, operand)
+	if err != nil {
+		return nil, e.wrapRuntimeError(expr, err)
+	}
+
+	return result, nil
+}
+
+func evalUnaryOp(op types.UnaryOp, operand value.Value) (value.Value, error) {
+	switch op {
 	case types.OpNot:
 		b, ok := operand.(value.Bool)
 		if !ok {
@@ -96,7 +133,7 @@ func (e *Evaluator) evalUnary(expr *types.UnaryExpr, env value.Environment) (val
 		}
 
 	default:
-		return nil, fmt.Errorf("unknown unary operator: %v", expr.Op)
+		return nil, fmt.Errorf("unknown unary operator: %v", op)
 	}
 }
 
@@ -106,6 +143,10 @@ func (e *Evaluator) evalAnd(expr *types.BinaryExpr, env value.Environment) (valu
 	if err != nil {
 		return nil, err
 	}
+	left, err = value.Force(left)
+	if err != nil {
+		return nil, err
+	}
 
 	leftBool, ok := left.(value.Bool)
 	if !ok {
@@ -120,6 +161,10 @@ func (e *Evaluator) evalAnd(expr *types.BinaryExpr, env value.Environment) (valu
 	if err != nil {
 		return nil, err
 	}
+	right, err = value.Force(right)
+	if err != nil {
+		return nil, err
+	}
 
 	rightBool, ok := right.(value.Bool)
 	if !ok {
@@ -134,6 +179,10 @@ func (e *Evaluator) evalOr(expr *types.BinaryExpr, env value.Environment) (value
 	if err != nil {
 		return nil, err
 	}
+	left, err = value.Force(left)
+	if err != nil {
+		return nil, err
+	}
 
 	leftBool, ok := left.(value.Bool)
 	if !ok {
@@ -148,6 +197,10 @@ func (e *Evaluator) evalOr(expr *types.BinaryExpr, env value.Environment) (value
 	if err != nil {
 		return nil, err
 	}
+	right, err = value.Force(right)
+	if err != nil {
+		return nil, err
+	}
 
 	rightBool, ok := right.(value.Bool)
 	if !ok {
@@ -162,6 +215,10 @@ func (e *Evaluator) evalImpl(expr *types.BinaryExpr, env value.Environment) (val
 	if err != nil {
 		return nil, err
 	}
+	left, err = value.Force(left)
+	if err != nil {
+		return nil, err
+	}
 
 	leftBool, ok := left.(value.Bool)
 	if !ok {
@@ -176,6 +233,10 @@ func (e *Evaluator) evalImpl(expr *types.BinaryExpr, env value.Environment) (val
 	if err != nil {
 		return nil, err
 	}
+	right, err = value.Force(right)
+	if err != nil {
+		return nil, err
+	}
 
 	rightBool, ok := right.(value.Bool)
 	if !ok {
@@ -186,12 +247,69 @@ func (e *Evaluator) evalImpl(expr *types.BinaryExpr, env value.Environment) (val
 }
 
 // Arithmetic operations.
-func evalAdd(left, right value.Value) (value.Value, error) {
+
+// addInt64Checked, subInt64Checked and mulInt64Checked perform int64
+// arithmetic with overflow detection. Nix's own integers are 64-bit and
+// raise an evaluation error on overflow rather than silently wrapping or
+// promoting to an arbitrary-precision type, so that's the behavior
+// mirrored here instead of Go's native wraparound semantics.
+func addInt64Checked(l, r int64) (int64, error) {
+	sum := l + r
+	if (r > 0 && l > math.MaxInt64-r) || (r < 0 && l < math.MinInt64-r) {
+		return 0, fmt.Errorf("integer overflow in addition: %d + %d", l, r)
+	}
+
+	return sum, nil
+}
+
+func subInt64Checked(l, r int64) (int64, error) {
+	diff := l - r
+	if (r < 0 && l > math.MaxInt64+r) || (r > 0 && l < math.MinInt64+r) {
+		return 0, fmt.Errorf("integer overflow in subtraction: %d - %d", l, r)
+	}
+
+	return diff, nil
+}
+
+func mulInt64Checked(l, r int64) (int64, error) {
+	if l == 0 || r == 0 {
+		return 0, nil
+	}
+
+	// MinInt64 * -1 is the one case product/r != l can't catch: in
+	// two's-complement division MinInt64 / -1 itself wraps back around
+	// to MinInt64, which equals l and would hide the overflow.
+	if (l == math.MinInt64 && r == -1) || (r == math.MinInt64 && l == -1) {
+		return 0, fmt.Errorf("integer overflow in multiplication: %d * %d", l, r)
+	}
+
+	product := l * r
+	if product/r != l {
+		return 0, fmt.Errorf("integer overflow in multiplication: %d * %d", l, r)
+	}
+
+	return product, nil
+}
+
+func (e *Evaluator) evalAdd(left, right value.Value) (value.Value, error) {
+	if isArbitraryPrecision(left) || isArbitraryPrecision(right) {
+		return bigintOrRatAdd(left, right)
+	}
+
 	switch l := left.(type) {
 	case value.Int:
 		switch r := right.(type) {
 		case value.Int:
-			return value.Int(l + r), nil
+			sum, err := addInt64Checked(int64(l), int64(r))
+			if err != nil {
+				if e.arbitraryPrecision {
+					return bigintOrRatAdd(left, right)
+				}
+
+				return nil, err
+			}
+
+			return value.Int(sum), nil
 		case value.Float:
 			return value.Float(float64(l) + float64(r)), nil
 		default:
@@ -210,7 +328,7 @@ func evalAdd(left, right value.Value) (value.Value, error) {
 
 	case value.String:
 		if r, ok := right.(value.String); ok {
-			return value.String(string(l) + string(r)), nil
+			return value.NewStringWithContext(l.Raw+r.Raw, value.UnionContext(l.Context, r.Context)), nil
 		}
 
 		return nil, fmt.Errorf("cannot add %v to string", right.Type())
@@ -220,12 +338,25 @@ func evalAdd(left, right value.Value) (value.Value, error) {
 	}
 }
 
-func evalSub(left, right value.Value) (value.Value, error) {
+func (e *Evaluator) evalSub(left, right value.Value) (value.Value, error) {
+	if isArbitraryPrecision(left) || isArbitraryPrecision(right) {
+		return bigintOrRatSub(left, right)
+	}
+
 	switch l := left.(type) {
 	case value.Int:
 		switch r := right.(type) {
 		case value.Int:
-			return value.Int(int64(l) - int64(r)), nil
+			diff, err := subInt64Checked(int64(l), int64(r))
+			if err != nil {
+				if e.arbitraryPrecision {
+					return bigintOrRatSub(left, right)
+				}
+
+				return nil, err
+			}
+
+			return value.Int(diff), nil
 		case value.Float:
 			return value.Float(float64(l) - float64(r)), nil
 		default:
@@ -247,12 +378,25 @@ func evalSub(left, right value.Value) (value.Value, error) {
 	}
 }
 
-func evalMul(left, right value.Value) (value.Value, error) {
+func (e *Evaluator) evalMul(left, right value.Value) (value.Value, error) {
+	if isArbitraryPrecision(left) || isArbitraryPrecision(right) {
+		return bigintOrRatMul(left, right)
+	}
+
 	switch l := left.(type) {
 	case value.Int:
 		switch r := right.(type) {
 		case value.Int:
-			return value.Int(int64(l) * int64(r)), nil
+			product, err := mulInt64Checked(int64(l), int64(r))
+			if err != nil {
+				if e.arbitraryPrecision {
+					return bigintOrRatMul(left, right)
+				}
+
+				return nil, err
+			}
+
+			return value.Int(product), nil
 		case value.Float:
 			return value.Float(float64(l) * float64(r)), nil
 		default:
@@ -274,7 +418,11 @@ func evalMul(left, right value.Value) (value.Value, error) {
 	}
 }
 
-func evalDiv(left, right value.Value) (value.Value, error) {
+func (e *Evaluator) evalDiv(left, right value.Value) (value.Value, error) {
+	if isArbitraryPrecision(left) || isArbitraryPrecision(right) {
+		return bigintOrRatDiv(left, right)
+	}
+
 	// Check for division by zero
 	switch r := right.(type) {
 	case value.Int:
@@ -291,8 +439,11 @@ func evalDiv(left, right value.Value) (value.Value, error) {
 	case value.Int:
 		switch r := right.(type) {
 		case value.Int:
-			// Integer division in Nix returns float
-			return value.Float(float64(l) / float64(r)), nil
+			if e.arbitraryPrecision {
+				return bigintOrRatDiv(left, right)
+			}
+			// Int / Int truncates toward zero, like Nix and Go's native /.
+			return value.Int(l / r), nil
 		case value.Float:
 			return value.Float(float64(l) / float64(r)), nil
 		default:
@@ -314,6 +465,135 @@ func evalDiv(left, right value.Value) (value.Value, error) {
 	}
 }
 
+// isArbitraryPrecision reports whether v is one of the types that only
+// ever appear once WithArbitraryPrecision is enabled: a value.BigInt
+// produced by an overflowing +/-/*, or a value.Rat produced by a
+// division that didn't come out even.
+func isArbitraryPrecision(v value.Value) bool {
+	switch v.(type) {
+	case value.BigInt, value.Rat:
+		return true
+	default:
+		return false
+	}
+}
+
+// bigintOrRatAdd, bigintOrRatSub, bigintOrRatMul, and bigintOrRatDiv
+// implement +, -, *, and / once either operand is already a
+// value.BigInt or value.Rat - either because int64 arithmetic
+// overflowed (see evalAdd/evalSub/evalMul above) or because an earlier
+// division in the same expression produced one. A Float operand
+// collapses the other side down to float64 and falls back to ordinary
+// floating-point arithmetic: mixing in a Float already gives up
+// exactness, so there's no reason to keep carrying a Rat through it.
+func bigintOrRatAdd(left, right value.Value) (value.Value, error) {
+	if lf, rf, ok := floatOperands(left, right); ok {
+		return value.Float(lf + rf), nil
+	}
+
+	l, lok := value.AsRat(left)
+	r, rok := value.AsRat(right)
+	if !lok || !rok {
+		return nil, fmt.Errorf("cannot add %v to %v", right.Type(), left.Type())
+	}
+
+	return demoteRat(new(big.Rat).Add(l, r)), nil
+}
+
+func bigintOrRatSub(left, right value.Value) (value.Value, error) {
+	if lf, rf, ok := floatOperands(left, right); ok {
+		return value.Float(lf - rf), nil
+	}
+
+	l, lok := value.AsRat(left)
+	r, rok := value.AsRat(right)
+	if !lok || !rok {
+		return nil, fmt.Errorf("cannot subtract %v from %v", right.Type(), left.Type())
+	}
+
+	return demoteRat(new(big.Rat).Sub(l, r)), nil
+}
+
+func bigintOrRatMul(left, right value.Value) (value.Value, error) {
+	if lf, rf, ok := floatOperands(left, right); ok {
+		return value.Float(lf * rf), nil
+	}
+
+	l, lok := value.AsRat(left)
+	r, rok := value.AsRat(right)
+	if !lok || !rok {
+		return nil, fmt.Errorf("cannot multiply %v by %v", left.Type(), right.Type())
+	}
+
+	return demoteRat(new(big.Rat).Mul(l, r)), nil
+}
+
+func bigintOrRatDiv(left, right value.Value) (value.Value, error) {
+	if lf, rf, ok := floatOperands(left, right); ok {
+		if rf == 0 {
+			return nil, errors.New("division by zero")
+		}
+
+		return value.Float(lf / rf), nil
+	}
+
+	l, lok := value.AsRat(left)
+	r, rok := value.AsRat(right)
+	if !lok || !rok {
+		return nil, fmt.Errorf("cannot divide %v by %v", left.Type(), right.Type())
+	}
+	if r.Sign() == 0 {
+		return nil, errors.New("division by zero")
+	}
+
+	return demoteRat(new(big.Rat).Quo(l, r)), nil
+}
+
+// floatOperands reports whether either left or right is a plain Float,
+// and if so converts both to float64 (exactly, via value.AsRat) so the
+// caller can fall back to ordinary floating-point arithmetic instead of
+// carrying an exact BigInt/Rat through an operand that already isn't
+// exact.
+func floatOperands(left, right value.Value) (l, r float64, ok bool) {
+	_, lFloat := left.(value.Float)
+	_, rFloat := right.(value.Float)
+	if !lFloat && !rFloat {
+		return 0, 0, false
+	}
+
+	lr, lok := value.AsRat(left)
+	rr, rok := value.AsRat(right)
+	if !lok || !rok {
+		return 0, 0, false
+	}
+
+	lf, _ := lr.Float64()
+	rf, _ := rr.Float64()
+
+	return lf, rf, true
+}
+
+// demoteRat collapses a big.Rat result back down to the simplest Value
+// that represents it exactly: an Int if it's a whole number that fits
+// in an int64, a BigInt if it's a whole number that doesn't, or a Rat if
+// it's still a genuine fraction. Keeping every +, -, *, and / result
+// this tight - rather than only demoting at a display boundary - is what
+// keeps ordinary arithmetic that happens to pass through a BigInt/Rat
+// (e.g. subtracting back down from an overflowed sum) just as cheap as
+// the native int64 path once the value fits again.
+func demoteRat(r *big.Rat) value.Value {
+	if !r.IsInt() {
+		return value.NewRat(r)
+	}
+
+	n := r.Num()
+	if n.IsInt64() {
+		return value.Int(n.Int64())
+	}
+
+	return value.NewBigInt(new(big.Int).Set(n))
+}
+
 // List concatenation.
 func evalConcat(left, right value.Value) (value.Value, error) {
 	lList, lOk := left.(*value.List)
@@ -332,8 +612,33 @@ func evalConcat(left, right value.Value) (value.Value, error) {
 	return value.NewList(elements...), nil
 }
 
+// valuesEqual implements ==/!=. value.Value.Equals isn't symmetric once a
+// value.BigInt or value.Rat is involved - a plain value.Float's Equals
+// only ever matches another value.Float, so under WithArbitraryPrecision
+// `0.5 == 1 / 2` (a Float against an exact Rat) would wrongly come out
+// false - so this falls back to exact rational comparison instead
+// whenever either side is arbitrary-precision.
+func valuesEqual(left, right value.Value) bool {
+	if isArbitraryPrecision(left) || isArbitraryPrecision(right) {
+		cmp, ok := value.CompareNumeric(left, right)
+
+		return ok && cmp == 0
+	}
+
+	return left.Equals(right)
+}
+
 // Comparison operations.
 func evalLess(left, right value.Value) (value.Value, error) {
+	if isArbitraryPrecision(left) || isArbitraryPrecision(right) {
+		cmp, ok := value.CompareNumeric(left, right)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare %v with %v", left.Type(), right.Type())
+		}
+
+		return value.Bool(cmp < 0), nil
+	}
+
 	switch l := left.(type) {
 	case value.Int:
 		switch r := right.(type) {
@@ -357,7 +662,7 @@ func evalLess(left, right value.Value) (value.Value, error) {
 
 	case value.String:
 		if r, ok := right.(value.String); ok {
-			return value.Bool(l < r), nil
+			return value.Bool(l.Raw < r.Raw), nil
 		}
 
 		return nil, fmt.Errorf("cannot compare string with %v", right.Type())
@@ -381,7 +686,7 @@ func evalLessEq(left, right value.Value) (value.Value, error) {
 		return value.Bool(true), nil
 	}
 
-	return value.Bool(left.Equals(right)), nil
+	return value.Bool(valuesEqual(left, right)), nil
 }
 
 func evalGreaterEq(left, right value.Value) (value.Value, error) {
@@ -393,7 +698,7 @@ func evalGreaterEq(left, right value.Value) (value.Value, error) {
 		return value.Bool(true), nil
 	}
 
-	return value.Bool(left.Equals(right)), nil
+	return value.Bool(valuesEqual(left, right)), nil
 }
 
 // Attribute set update.
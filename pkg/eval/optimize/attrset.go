@@ -0,0 +1,462 @@
+package optimize
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/conneroisu/gix/internal/types"
+)
+
+// rewriteAttrSet rewrites an attribute set's bindings and inherits
+// bottom-up, then - for a rec { ... } set - looks for subexpressions
+// repeated across more than one binding that don't depend on any of
+// the set's own bindings (so they'd compute the same thing wherever
+// they're evaluated from) and hoists each one into a let wrapped
+// around the set, replacing every occurrence with a reference to the
+// hoisted name. Each rec binding is already its own memoized thunk, so
+// the point isn't avoiding re-evaluation within a binding - it's
+// merging what would otherwise be N separate thunks, each doing the
+// same work, into one.
+func rewriteAttrSet(e *types.AttrSetExpr, stats *Stats) types.Expr {
+	bindings := make([]types.AttrBinding, len(e.Bindings))
+	for i, b := range e.Bindings {
+		bindings[i] = types.AttrBinding{Path: b.Path, Value: rewrite(b.Value, stats), Span: b.Span}
+	}
+
+	inherits := make([]types.InheritClause, len(e.Inherits))
+	for i, inh := range e.Inherits {
+		inherits[i] = types.InheritClause{From: rewrite(inh.From, stats), Attrs: inh.Attrs, Span: inh.Span}
+	}
+
+	node := copySpan(&types.AttrSetExpr{Recursive: e.Recursive, Bindings: bindings, Inherits: inherits}, e).(*types.AttrSetExpr)
+
+	if !node.Recursive {
+		return node
+	}
+
+	return hoistInvariants(node, stats)
+}
+
+// candidate is one hoistable subexpression found while scanning a rec
+// attrset's bindings, keyed by its String() form so identical
+// subexpressions occurring in different bindings are recognized as the
+// same candidate.
+type candidate struct {
+	key  string
+	expr types.Expr
+}
+
+func hoistInvariants(node *types.AttrSetExpr, stats *Stats) types.Expr {
+	boundNames := make(map[string]bool, len(node.Bindings)+len(node.Inherits))
+	for _, b := range node.Bindings {
+		if len(b.Path) > 0 {
+			boundNames[b.Path[0]] = true
+		}
+	}
+	for _, inh := range node.Inherits {
+		for _, a := range inh.Attrs {
+			boundNames[a] = true
+		}
+	}
+
+	counts := make(map[string]int)
+	first := make(map[string]candidate)
+
+	for _, b := range node.Bindings {
+		var found []candidate
+		collectCandidates(b.Value, map[string]bool{}, boundNames, &found)
+		seenInThisBinding := make(map[string]bool, len(found))
+		for _, c := range found {
+			if seenInThisBinding[c.key] {
+				continue
+			}
+			seenInThisBinding[c.key] = true
+			counts[c.key]++
+			if _, ok := first[c.key]; !ok {
+				first[c.key] = c
+			}
+		}
+	}
+
+	var keys []string
+	for key, n := range counts {
+		if n >= 2 {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return node
+	}
+	// Deterministic ordering: by first occurrence's source position,
+	// falling back to the key text for anything that ties.
+	sort.Slice(keys, func(i, j int) bool {
+		pi, pj := first[keys[i]].expr.Position(), first[keys[j]].expr.Position()
+		if pi.Offset != pj.Offset {
+			return pi.Offset < pj.Offset
+		}
+
+		return keys[i] < keys[j]
+	})
+
+	targets := make(map[string]string, len(keys))
+	letBindings := make([]types.Binding, 0, len(keys))
+
+	for i, key := range keys {
+		name := fmt.Sprintf("__hoisted%d", i)
+		for boundNames[name] {
+			name += "_"
+		}
+		targets[key] = name
+		letBindings = append(letBindings, types.Binding{Name: name, Value: first[key].expr, Span: first[key].expr.Span()})
+	}
+
+	bindings := make([]types.AttrBinding, len(node.Bindings))
+	for i, b := range node.Bindings {
+		bindings[i] = types.AttrBinding{
+			Path:  b.Path,
+			Value: replaceCandidates(b.Value, map[string]bool{}, boundNames, targets),
+			Span:  b.Span,
+		}
+	}
+
+	attrSet := copySpan(&types.AttrSetExpr{Recursive: true, Bindings: bindings, Inherits: node.Inherits}, node)
+
+	stats.InvariantsHoisted += len(keys)
+
+	return copySpan(&types.LetExpr{Bindings: letBindings, Body: attrSet}, node)
+}
+
+// isHoistable reports whether expr is safe to evaluate from a
+// different point in the tree than where it originally appeared -
+// deterministic, and free of anything (a builtin call, in particular)
+// that could have an observable effect whose count of occurrences
+// matters, such as builtins.trace.
+func isHoistable(expr types.Expr) bool {
+	switch e := expr.(type) {
+	case *types.IntExpr, *types.FloatExpr, *types.StringExpr,
+		*types.BoolExpr, *types.NullExpr, *types.PathExpr, *types.IdentExpr:
+		return true
+	case *types.BinaryExpr:
+		return isHoistable(e.Left) && isHoistable(e.Right)
+	case *types.UnaryExpr:
+		return isHoistable(e.Expr)
+	case *types.ListExpr:
+		for _, el := range e.Elements {
+			if !isHoistable(el) {
+				return false
+			}
+		}
+
+		return true
+	case *types.SelectExpr:
+		if e.Default != nil && !isHoistable(e.Default) {
+			return false
+		}
+
+		return isHoistable(e.Expr)
+	case *types.HasAttrExpr:
+		return isHoistable(e.Expr)
+	default:
+		return false
+	}
+}
+
+func isInvariantCandidate(expr types.Expr, shadow, boundNames map[string]bool) bool {
+	for name := range freeIdents(expr) {
+		if boundNames[name] || shadow[name] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// collectCandidates walks expr with the same shadow-tracking shape as
+// walkFreeIdents, appending every hoistable, rec-independent
+// subexpression it finds to out - including ones nested inside a
+// larger candidate, since a smaller subexpression can recur in a
+// context the larger one doesn't share.
+func collectCandidates(expr types.Expr, shadow, boundNames map[string]bool, out *[]candidate) {
+	if expr == nil {
+		return
+	}
+
+	switch expr.(type) {
+	case *types.IntExpr, *types.FloatExpr, *types.StringExpr,
+		*types.BoolExpr, *types.NullExpr, *types.PathExpr, *types.IdentExpr:
+		return
+	}
+
+	// A node being individually hoistable doesn't stop the walk: a
+	// smaller subexpression nested inside it may recur elsewhere in a
+	// context this outer node doesn't, so every candidate at every
+	// level is recorded. replaceCandidates' own top-down, stop-on-match
+	// traversal is what keeps a later hoist from fighting over the same
+	// text - once an outer match is rewritten, its nested occurrence
+	// goes with it.
+	if isHoistable(expr) && isInvariantCandidate(expr, shadow, boundNames) {
+		*out = append(*out, candidate{key: expr.String(), expr: expr})
+	}
+
+	switch e := expr.(type) {
+	case *types.ListExpr:
+		for _, el := range e.Elements {
+			collectCandidates(el, shadow, boundNames, out)
+		}
+
+	case *types.AttrSetExpr:
+		inner := shadow
+		if e.Recursive {
+			inner = cloneBound(shadow)
+			for _, b := range e.Bindings {
+				if len(b.Path) > 0 {
+					inner[b.Path[0]] = true
+				}
+			}
+			for _, inh := range e.Inherits {
+				for _, a := range inh.Attrs {
+					inner[a] = true
+				}
+			}
+		}
+		for _, b := range e.Bindings {
+			collectCandidates(b.Value, inner, boundNames, out)
+		}
+		for _, inh := range e.Inherits {
+			collectCandidates(inh.From, shadow, boundNames, out)
+		}
+
+	case *types.BinaryExpr:
+		collectCandidates(e.Left, shadow, boundNames, out)
+		collectCandidates(e.Right, shadow, boundNames, out)
+
+	case *types.UnaryExpr:
+		collectCandidates(e.Expr, shadow, boundNames, out)
+
+	case *types.IfExpr:
+		collectCandidates(e.Cond, shadow, boundNames, out)
+		collectCandidates(e.Then, shadow, boundNames, out)
+		collectCandidates(e.Else, shadow, boundNames, out)
+
+	case *types.LetExpr:
+		inner := cloneBound(shadow)
+		for _, b := range e.Bindings {
+			inner[b.Name] = true
+		}
+		for _, inh := range e.Inherits {
+			for _, a := range inh.Attrs {
+				inner[a] = true
+			}
+		}
+		for _, b := range e.Bindings {
+			collectCandidates(b.Value, inner, boundNames, out)
+		}
+		for _, inh := range e.Inherits {
+			collectCandidates(inh.From, shadow, boundNames, out)
+		}
+		collectCandidates(e.Body, inner, boundNames, out)
+
+	case *types.WithExpr:
+		collectCandidates(e.Expr, shadow, boundNames, out)
+		collectCandidates(e.Body, shadow, boundNames, out)
+
+	case *types.AssertExpr:
+		collectCandidates(e.Cond, shadow, boundNames, out)
+		collectCandidates(e.Body, shadow, boundNames, out)
+
+	case *types.FunctionExpr:
+		inner := cloneBound(shadow)
+		if e.Pattern != nil {
+			if e.Pattern.Name != "" {
+				inner[e.Pattern.Name] = true
+			}
+			for _, a := range e.Pattern.Attrs {
+				inner[a] = true
+			}
+		} else {
+			inner[e.Param] = true
+		}
+		collectCandidates(e.Body, inner, boundNames, out)
+
+	case *types.ApplyExpr:
+		collectCandidates(e.Func, shadow, boundNames, out)
+		collectCandidates(e.Arg, shadow, boundNames, out)
+
+	case *types.SelectExpr:
+		collectCandidates(e.Expr, shadow, boundNames, out)
+		collectCandidates(e.Default, shadow, boundNames, out)
+
+	case *types.HasAttrExpr:
+		collectCandidates(e.Expr, shadow, boundNames, out)
+
+	case *types.InheritExpr:
+		collectCandidates(e.From, shadow, boundNames, out)
+	}
+}
+
+// replaceCandidates mirrors collectCandidates' traversal, but rebuilds
+// the tree, replacing every subexpression whose key is in targets with
+// a reference to the hoisted name instead of collecting it.
+func replaceCandidates(expr types.Expr, shadow, boundNames map[string]bool, targets map[string]string) types.Expr {
+	if expr == nil {
+		return nil
+	}
+
+	switch expr.(type) {
+	case *types.IntExpr, *types.FloatExpr, *types.StringExpr,
+		*types.BoolExpr, *types.NullExpr, *types.PathExpr, *types.IdentExpr:
+		return expr
+	}
+
+	// A node not itself in targets still needs its children visited -
+	// collectCandidates recorded every hoistable subexpression at every
+	// level, not just the outermost one, so a smaller repeated
+	// subexpression nested inside a hoistable-but-unmatched node (one
+	// that was itself only seen once) must still be found and replaced
+	// here. Only a node that IS in targets short-circuits the walk,
+	// since everything beneath it is going with it.
+	if isHoistable(expr) && isInvariantCandidate(expr, shadow, boundNames) {
+		if name, ok := targets[expr.String()]; ok {
+			return copySpan(&types.IdentExpr{Name: name}, expr)
+		}
+	}
+
+	switch e := expr.(type) {
+	case *types.ListExpr:
+		elems := make([]types.Expr, len(e.Elements))
+		for i, el := range e.Elements {
+			elems[i] = replaceCandidates(el, shadow, boundNames, targets)
+		}
+
+		return copySpan(&types.ListExpr{Elements: elems}, e)
+
+	case *types.AttrSetExpr:
+		inner := shadow
+		if e.Recursive {
+			inner = cloneBound(shadow)
+			for _, b := range e.Bindings {
+				if len(b.Path) > 0 {
+					inner[b.Path[0]] = true
+				}
+			}
+			for _, inh := range e.Inherits {
+				for _, a := range inh.Attrs {
+					inner[a] = true
+				}
+			}
+		}
+
+		bindings := make([]types.AttrBinding, len(e.Bindings))
+		for i, b := range e.Bindings {
+			bindings[i] = types.AttrBinding{Path: b.Path, Value: replaceCandidates(b.Value, inner, boundNames, targets), Span: b.Span}
+		}
+
+		return copySpan(&types.AttrSetExpr{Recursive: e.Recursive, Bindings: bindings, Inherits: e.Inherits}, e)
+
+	case *types.BinaryExpr:
+		return copySpan(&types.BinaryExpr{
+			Left:  replaceCandidates(e.Left, shadow, boundNames, targets),
+			Op:    e.Op,
+			Right: replaceCandidates(e.Right, shadow, boundNames, targets),
+		}, e)
+
+	case *types.UnaryExpr:
+		return copySpan(&types.UnaryExpr{Op: e.Op, Expr: replaceCandidates(e.Expr, shadow, boundNames, targets)}, e)
+
+	case *types.IfExpr:
+		return copySpan(&types.IfExpr{
+			Cond: replaceCandidates(e.Cond, shadow, boundNames, targets),
+			Then: replaceCandidates(e.Then, shadow, boundNames, targets),
+			Else: replaceCandidates(e.Else, shadow, boundNames, targets),
+		}, e)
+
+	case *types.LetExpr:
+		inner := cloneBound(shadow)
+		for _, b := range e.Bindings {
+			inner[b.Name] = true
+		}
+		for _, inh := range e.Inherits {
+			for _, a := range inh.Attrs {
+				inner[a] = true
+			}
+		}
+
+		bindings := make([]types.Binding, len(e.Bindings))
+		for i, b := range e.Bindings {
+			bindings[i] = types.Binding{Name: b.Name, Value: replaceCandidates(b.Value, inner, boundNames, targets), Span: b.Span}
+		}
+
+		return copySpan(&types.LetExpr{
+			Bindings: bindings,
+			Inherits: e.Inherits,
+			Body:     replaceCandidates(e.Body, inner, boundNames, targets),
+		}, e)
+
+	case *types.WithExpr:
+		return copySpan(&types.WithExpr{
+			Expr: replaceCandidates(e.Expr, shadow, boundNames, targets),
+			Body: replaceCandidates(e.Body, shadow, boundNames, targets),
+		}, e)
+
+	case *types.AssertExpr:
+		return copySpan(&types.AssertExpr{
+			Cond: replaceCandidates(e.Cond, shadow, boundNames, targets),
+			Body: replaceCandidates(e.Body, shadow, boundNames, targets),
+		}, e)
+
+	case *types.FunctionExpr:
+		inner := cloneBound(shadow)
+		if e.Pattern != nil {
+			if e.Pattern.Name != "" {
+				inner[e.Pattern.Name] = true
+			}
+			for _, a := range e.Pattern.Attrs {
+				inner[a] = true
+			}
+		} else {
+			inner[e.Param] = true
+		}
+
+		return copySpan(&types.FunctionExpr{
+			Param:   e.Param,
+			Pattern: e.Pattern,
+			Body:    replaceCandidates(e.Body, inner, boundNames, targets),
+			Doc:     e.Doc,
+		}, e)
+
+	case *types.ApplyExpr:
+		return copySpan(&types.ApplyExpr{
+			Func: replaceCandidates(e.Func, shadow, boundNames, targets),
+			Arg:  replaceCandidates(e.Arg, shadow, boundNames, targets),
+		}, e)
+
+	case *types.SelectExpr:
+		var def types.Expr
+		if e.Default != nil {
+			def = replaceCandidates(e.Default, shadow, boundNames, targets)
+		}
+
+		return copySpan(&types.SelectExpr{
+			Expr:     replaceCandidates(e.Expr, shadow, boundNames, targets),
+			AttrPath: e.AttrPath,
+			Default:  def,
+		}, e)
+
+	case *types.HasAttrExpr:
+		return copySpan(&types.HasAttrExpr{
+			Expr:     replaceCandidates(e.Expr, shadow, boundNames, targets),
+			AttrPath: e.AttrPath,
+		}, e)
+
+	case *types.InheritExpr:
+		var from types.Expr
+		if e.From != nil {
+			from = replaceCandidates(e.From, shadow, boundNames, targets)
+		}
+
+		return copySpan(&types.InheritExpr{From: from, Attrs: e.Attrs}, e)
+
+	default:
+		return expr
+	}
+}
@@ -0,0 +1,303 @@
+package optimize
+
+import (
+	"math"
+
+	"github.com/conneroisu/gix/internal/types"
+)
+
+// newInt/newFloat/newBool/newString build a fresh literal node carrying
+// from's span, the same way copySpan does for compound nodes - the
+// folded constant still points at the source that produced it.
+func newInt(v int64, from types.Node) types.Expr {
+	return copySpan(&types.IntExpr{Value: v}, from)
+}
+
+func newFloat(v float64, from types.Node) types.Expr {
+	return copySpan(&types.FloatExpr{Value: v}, from)
+}
+
+func newBool(v bool, from types.Node) types.Expr {
+	return copySpan(&types.BoolExpr{Value: v}, from)
+}
+
+func newString(v string, from types.Node) types.Expr {
+	return copySpan(&types.StringExpr{Value: v}, from)
+}
+
+// number reads e as a literal number, reporting whether it was a float
+// (as opposed to an int) alongside its value - mirroring how evalAdd et
+// al. branch on value.Int vs value.Float.
+func number(e types.Expr) (v float64, isFloat, ok bool) {
+	switch n := e.(type) {
+	case *types.IntExpr:
+		return float64(n.Value), false, true
+	case *types.FloatExpr:
+		return n.Value, true, true
+	default:
+		return 0, false, false
+	}
+}
+
+// addInt64Checked, subInt64Checked and mulInt64Checked mirror the
+// overflow-checked helpers in pkg/eval/operators.go: Nix's integers are
+// 64-bit and error on overflow instead of wrapping, so folding must
+// refuse to fold (leaving the original expression for the evaluator to
+// raise that error at run time) rather than silently produce a wrapped
+// constant.
+func addInt64Checked(l, r int64) (int64, bool) {
+	sum := l + r
+	if (r > 0 && l > math.MaxInt64-r) || (r < 0 && l < math.MinInt64-r) {
+		return 0, false
+	}
+
+	return sum, true
+}
+
+func subInt64Checked(l, r int64) (int64, bool) {
+	diff := l - r
+	if (r < 0 && l > math.MaxInt64+r) || (r > 0 && l < math.MinInt64+r) {
+		return 0, false
+	}
+
+	return diff, true
+}
+
+func mulInt64Checked(l, r int64) (int64, bool) {
+	if l == 0 || r == 0 {
+		return 0, true
+	}
+	if (l == math.MinInt64 && r == -1) || (r == math.MinInt64 && l == -1) {
+		return 0, false
+	}
+
+	product := l * r
+	if product/r != l {
+		return 0, false
+	}
+
+	return product, true
+}
+
+// foldBinary reduces node to a literal if both operands are literals
+// the operator knows how to fold and folding wouldn't change the
+// evaluator's observable behavior (e.g. it refuses to fold an overflow
+// or a division by zero, leaving that error for evaluation itself to
+// raise). Otherwise it returns node unchanged.
+func foldBinary(node *types.BinaryExpr, stats *Stats) types.Expr {
+	switch node.Op {
+	case types.OpAdd:
+		if s, ok := foldStringConcat(node); ok {
+			stats.ConstantsFolded++
+
+			return s
+		}
+
+		return foldArith(node, stats, func(l, r int64) (int64, bool) { return addInt64Checked(l, r) },
+			func(l, r float64) float64 { return l + r })
+
+	case types.OpSub:
+		return foldArith(node, stats, subInt64Checked, func(l, r float64) float64 { return l - r })
+
+	case types.OpMul:
+		return foldArith(node, stats, mulInt64Checked, func(l, r float64) float64 { return l * r })
+
+	case types.OpDiv:
+		return foldDiv(node, stats)
+
+	case types.OpEq, types.OpNEq, types.OpLT, types.OpGT, types.OpLTE, types.OpGTE:
+		return foldCompare(node, stats)
+
+	case types.OpAnd, types.OpOr, types.OpImpl:
+		return foldLogical(node, stats)
+
+	default:
+		// OpConcat (list concatenation) and OpUpdate (attrset //) depend
+		// on structural merging this optimizer doesn't attempt at the
+		// AST level; leave them for the evaluator.
+		return node
+	}
+}
+
+func foldStringConcat(node *types.BinaryExpr) (types.Expr, bool) {
+	left, ok := node.Left.(*types.StringExpr)
+	if !ok || left.IsIndented {
+		return nil, false
+	}
+
+	right, ok := node.Right.(*types.StringExpr)
+	if !ok || right.IsIndented {
+		return nil, false
+	}
+
+	return newString(left.Value+right.Value, node), true
+}
+
+// foldArith handles +, -, * for literal int/float operands: an
+// all-int pair folds (via intFn) to an int literal unless that would
+// overflow, and any other int/float combination folds (via floatFn,
+// after widening) to a float literal, matching evalAdd/evalSub/evalMul's
+// int-stays-int-but-mixes-to-float behavior.
+func foldArith(
+	node *types.BinaryExpr,
+	stats *Stats,
+	intFn func(l, r int64) (int64, bool),
+	floatFn func(l, r float64) float64,
+) types.Expr {
+	li, lIsInt := node.Left.(*types.IntExpr)
+	ri, rIsInt := node.Right.(*types.IntExpr)
+
+	if lIsInt && rIsInt {
+		if sum, ok := intFn(li.Value, ri.Value); ok {
+			stats.ConstantsFolded++
+
+			return newInt(sum, node)
+		}
+
+		return node
+	}
+
+	lv, _, lok := number(node.Left)
+	rv, _, rok := number(node.Right)
+	if !lok || !rok {
+		return node
+	}
+
+	stats.ConstantsFolded++
+
+	return newFloat(floatFn(lv, rv), node)
+}
+
+func foldDiv(node *types.BinaryExpr, stats *Stats) types.Expr {
+	lv, lFloat, lok := number(node.Left)
+	rv, rFloat, rok := number(node.Right)
+	if !lok || !rok || rv == 0 {
+		// Division by a literal zero is left for the evaluator to raise
+		// its "division by zero" error at the usual place and time.
+		return node
+	}
+
+	if !lFloat && !rFloat {
+		// evalDiv truncates Int / Int (or yields an exact Rat under
+		// eval.WithArbitraryPrecision), so only a quotient that divides
+		// evenly, and can't overflow, is folded - to an int literal.
+		l, r := node.Left.(*types.IntExpr).Value, node.Right.(*types.IntExpr).Value
+		if l%r != 0 || (l == math.MinInt64 && r == -1) {
+			return node
+		}
+		stats.ConstantsFolded++
+
+		return newInt(l/r, node)
+	}
+
+	stats.ConstantsFolded++
+
+	// A float operand makes the result a float, as in evalDiv.
+	return newFloat(lv/rv, node)
+}
+
+func foldCompare(node *types.BinaryExpr, stats *Stats) types.Expr {
+	lv, lFloat, lok := number(node.Left)
+	rv, rFloat, rok := number(node.Right)
+	if !lok || !rok {
+		return node
+	}
+
+	// valuesEqual never treats a value.Int as equal to a value.Float, so
+	// a mixed int/float ==/!= is left for the evaluator to decide.
+	if lFloat != rFloat && (node.Op == types.OpEq || node.Op == types.OpNEq) {
+		return node
+	}
+
+	var result bool
+
+	switch node.Op {
+	case types.OpEq:
+		result = lv == rv
+	case types.OpNEq:
+		result = lv != rv
+	case types.OpLT:
+		result = lv < rv
+	case types.OpGT:
+		result = lv > rv
+	case types.OpLTE:
+		result = lv <= rv
+	case types.OpGTE:
+		result = lv >= rv
+	}
+
+	stats.ConstantsFolded++
+
+	return newBool(result, node)
+}
+
+func foldLogical(node *types.BinaryExpr, stats *Stats) types.Expr {
+	left, ok := node.Left.(*types.BoolExpr)
+	if !ok {
+		return node
+	}
+
+	switch node.Op {
+	case types.OpAnd:
+		if !left.Value {
+			stats.ConstantsFolded++
+
+			return newBool(false, node)
+		}
+	case types.OpOr:
+		if left.Value {
+			stats.ConstantsFolded++
+
+			return newBool(true, node)
+		}
+	case types.OpImpl:
+		if !left.Value {
+			stats.ConstantsFolded++
+
+			return newBool(true, node)
+		}
+	}
+
+	right, ok := node.Right.(*types.BoolExpr)
+	if !ok {
+		return node
+	}
+
+	stats.ConstantsFolded++
+
+	switch node.Op {
+	case types.OpAnd:
+		return newBool(left.Value && right.Value, node)
+	case types.OpOr:
+		return newBool(left.Value || right.Value, node)
+	default: // types.OpImpl, and left.Value is true here
+		return newBool(right.Value, node)
+	}
+}
+
+// foldUnary reduces node to a literal when its operand is a literal
+// bool (for !) or int/float (for unary -).
+func foldUnary(node *types.UnaryExpr, stats *Stats) types.Expr {
+	switch node.Op {
+	case types.OpNot:
+		if b, ok := node.Expr.(*types.BoolExpr); ok {
+			stats.ConstantsFolded++
+
+			return newBool(!b.Value, node)
+		}
+
+	case types.OpNeg:
+		switch v := node.Expr.(type) {
+		case *types.IntExpr:
+			stats.ConstantsFolded++
+
+			return newInt(-v.Value, node)
+		case *types.FloatExpr:
+			stats.ConstantsFolded++
+
+			return newFloat(-v.Value, node)
+		}
+	}
+
+	return node
+}
@@ -0,0 +1,386 @@
+package optimize
+
+import "github.com/conneroisu/gix/internal/types"
+
+// This file holds the shadow-aware identifier analysis shared by the
+// let-inlining and invariant-hoisting passes: both need to know, for a
+// given name, which references to it inside a subexpression actually
+// resolve to the binding in question rather than to some inner
+// shadowing let/function/rec that happens to reuse the same name.
+//
+// cloneBound copies a shadow set so a nested scope can extend it
+// without mutating the enclosing one.
+func cloneBound(bound map[string]bool) map[string]bool {
+	next := make(map[string]bool, len(bound)+2)
+	for k := range bound {
+		next[k] = true
+	}
+
+	return next
+}
+
+// walkFreeIdents calls visit once for every IdentExpr in expr that
+// isn't shadowed by an enclosing let, function parameter, or rec
+// attrset binding introduced *within* expr itself - i.e. every
+// identifier reference that resolves outside expr, given that names in
+// bound are already known to be shadowed before expr is even reached.
+// with doesn't shadow anything here, matching Nix's own scoping rule
+// (see value.Env.Get) that a with-scope is always the lowest-priority
+// lookup source.
+func walkFreeIdents(expr types.Expr, bound map[string]bool, visit func(name string)) {
+	switch e := expr.(type) {
+	case nil:
+		return
+
+	case *types.IdentExpr:
+		if !bound[e.Name] {
+			visit(e.Name)
+		}
+
+	case *types.IntExpr, *types.FloatExpr, *types.StringExpr,
+		*types.BoolExpr, *types.NullExpr, *types.PathExpr:
+		return
+
+	case *types.ListExpr:
+		for _, el := range e.Elements {
+			walkFreeIdents(el, bound, visit)
+		}
+
+	case *types.AttrSetExpr:
+		inner := bound
+		if e.Recursive {
+			inner = cloneBound(bound)
+			for _, b := range e.Bindings {
+				if len(b.Path) > 0 {
+					inner[b.Path[0]] = true
+				}
+			}
+			for _, inh := range e.Inherits {
+				for _, a := range inh.Attrs {
+					inner[a] = true
+				}
+			}
+		}
+		for _, b := range e.Bindings {
+			walkFreeIdents(b.Value, inner, visit)
+		}
+		for _, inh := range e.Inherits {
+			walkFreeIdents(inh.From, bound, visit)
+		}
+
+	case *types.BinaryExpr:
+		walkFreeIdents(e.Left, bound, visit)
+		walkFreeIdents(e.Right, bound, visit)
+
+	case *types.UnaryExpr:
+		walkFreeIdents(e.Expr, bound, visit)
+
+	case *types.IfExpr:
+		walkFreeIdents(e.Cond, bound, visit)
+		walkFreeIdents(e.Then, bound, visit)
+		walkFreeIdents(e.Else, bound, visit)
+
+	case *types.LetExpr:
+		inner := cloneBound(bound)
+		for _, b := range e.Bindings {
+			inner[b.Name] = true
+		}
+		for _, inh := range e.Inherits {
+			for _, a := range inh.Attrs {
+				inner[a] = true
+			}
+		}
+		for _, b := range e.Bindings {
+			walkFreeIdents(b.Value, inner, visit)
+		}
+		for _, inh := range e.Inherits {
+			walkFreeIdents(inh.From, bound, visit)
+		}
+		walkFreeIdents(e.Body, inner, visit)
+
+	case *types.WithExpr:
+		walkFreeIdents(e.Expr, bound, visit)
+		walkFreeIdents(e.Body, bound, visit)
+
+	case *types.AssertExpr:
+		walkFreeIdents(e.Cond, bound, visit)
+		walkFreeIdents(e.Body, bound, visit)
+
+	case *types.FunctionExpr:
+		inner := cloneBound(bound)
+		if e.Pattern != nil {
+			if e.Pattern.Name != "" {
+				inner[e.Pattern.Name] = true
+			}
+			for _, a := range e.Pattern.Attrs {
+				inner[a] = true
+			}
+		} else {
+			inner[e.Param] = true
+		}
+		walkFreeIdents(e.Body, inner, visit)
+
+	case *types.ApplyExpr:
+		walkFreeIdents(e.Func, bound, visit)
+		walkFreeIdents(e.Arg, bound, visit)
+
+	case *types.SelectExpr:
+		walkFreeIdents(e.Expr, bound, visit)
+		walkFreeIdents(e.Default, bound, visit)
+
+	case *types.HasAttrExpr:
+		walkFreeIdents(e.Expr, bound, visit)
+
+	case *types.InheritExpr:
+		walkFreeIdents(e.From, bound, visit)
+
+	default:
+		return
+	}
+}
+
+// freeIdents returns the set of names walkFreeIdents visits in expr -
+// used by the invariant-hoisting pass to check that a candidate
+// subexpression doesn't reference any of a rec attrset's own bindings.
+func freeIdents(expr types.Expr) map[string]bool {
+	out := make(map[string]bool)
+	walkFreeIdents(expr, map[string]bool{}, func(name string) { out[name] = true })
+
+	return out
+}
+
+// countFreeIdent counts how many unshadowed references to name appear
+// in expr - used by the let-inlining pass to find bindings used
+// exactly once in their body.
+func countFreeIdent(expr types.Expr, name string) int {
+	count := 0
+	walkFreeIdents(expr, map[string]bool{}, func(n string) {
+		if n == name {
+			count++
+		}
+	})
+
+	return count
+}
+
+// substituteFreeIdent returns a copy of expr with every unshadowed
+// reference to name replaced by replacement. It's only ever called
+// after countFreeIdent has confirmed there is exactly one such
+// reference, so sharing the replacement node (rather than deep-copying
+// it per occurrence) is safe.
+func substituteFreeIdent(expr types.Expr, bound map[string]bool, name string, replacement types.Expr) types.Expr {
+	switch e := expr.(type) {
+	case nil:
+		return nil
+
+	case *types.IdentExpr:
+		if !bound[e.Name] && e.Name == name {
+			return replacement
+		}
+
+		return expr
+
+	case *types.IntExpr, *types.FloatExpr, *types.StringExpr,
+		*types.BoolExpr, *types.NullExpr, *types.PathExpr:
+		return expr
+
+	case *types.ListExpr:
+		elems := make([]types.Expr, len(e.Elements))
+		for i, el := range e.Elements {
+			elems[i] = substituteFreeIdent(el, bound, name, replacement)
+		}
+
+		return copySpan(&types.ListExpr{Elements: elems}, e)
+
+	case *types.BinaryExpr:
+		return copySpan(&types.BinaryExpr{
+			Left:  substituteFreeIdent(e.Left, bound, name, replacement),
+			Op:    e.Op,
+			Right: substituteFreeIdent(e.Right, bound, name, replacement),
+		}, e)
+
+	case *types.UnaryExpr:
+		return copySpan(&types.UnaryExpr{
+			Op:   e.Op,
+			Expr: substituteFreeIdent(e.Expr, bound, name, replacement),
+		}, e)
+
+	case *types.IfExpr:
+		return copySpan(&types.IfExpr{
+			Cond: substituteFreeIdent(e.Cond, bound, name, replacement),
+			Then: substituteFreeIdent(e.Then, bound, name, replacement),
+			Else: substituteFreeIdent(e.Else, bound, name, replacement),
+		}, e)
+
+	case *types.AssertExpr:
+		return copySpan(&types.AssertExpr{
+			Cond: substituteFreeIdent(e.Cond, bound, name, replacement),
+			Body: substituteFreeIdent(e.Body, bound, name, replacement),
+		}, e)
+
+	case *types.WithExpr:
+		return copySpan(&types.WithExpr{
+			Expr: substituteFreeIdent(e.Expr, bound, name, replacement),
+			Body: substituteFreeIdent(e.Body, bound, name, replacement),
+		}, e)
+
+	case *types.ApplyExpr:
+		return copySpan(&types.ApplyExpr{
+			Func: substituteFreeIdent(e.Func, bound, name, replacement),
+			Arg:  substituteFreeIdent(e.Arg, bound, name, replacement),
+		}, e)
+
+	case *types.SelectExpr:
+		var def types.Expr
+		if e.Default != nil {
+			def = substituteFreeIdent(e.Default, bound, name, replacement)
+		}
+
+		return copySpan(&types.SelectExpr{
+			Expr:     substituteFreeIdent(e.Expr, bound, name, replacement),
+			AttrPath: e.AttrPath,
+			Default:  def,
+		}, e)
+
+	case *types.HasAttrExpr:
+		return copySpan(&types.HasAttrExpr{
+			Expr:     substituteFreeIdent(e.Expr, bound, name, replacement),
+			AttrPath: e.AttrPath,
+		}, e)
+
+	case *types.InheritExpr:
+		var from types.Expr
+		if e.From != nil {
+			from = substituteFreeIdent(e.From, bound, name, replacement)
+		}
+
+		return copySpan(&types.InheritExpr{From: from, Attrs: e.Attrs}, e)
+
+	case *types.FunctionExpr:
+		inner := cloneBound(bound)
+		if e.Pattern != nil {
+			if e.Pattern.Name != "" {
+				inner[e.Pattern.Name] = true
+			}
+			for _, a := range e.Pattern.Attrs {
+				inner[a] = true
+			}
+		} else {
+			inner[e.Param] = true
+		}
+
+		return copySpan(&types.FunctionExpr{
+			Param:   e.Param,
+			Pattern: e.Pattern,
+			Body:    substituteFreeIdent(e.Body, inner, name, replacement),
+			Doc:     e.Doc,
+		}, e)
+
+	case *types.LetExpr:
+		if letShadows(e, name) {
+			return expr
+		}
+
+		inner := cloneBound(bound)
+		bindings := make([]types.Binding, len(e.Bindings))
+		for i, b := range e.Bindings {
+			bindings[i] = types.Binding{Name: b.Name, Value: substituteFreeIdent(b.Value, inner, name, replacement), Span: b.Span}
+		}
+		inherits := make([]types.InheritClause, len(e.Inherits))
+		for i, inh := range e.Inherits {
+			inherits[i] = types.InheritClause{From: substituteFreeIdent(inh.From, bound, name, replacement), Attrs: inh.Attrs, Span: inh.Span}
+		}
+
+		return copySpan(&types.LetExpr{
+			Bindings: bindings,
+			Inherits: inherits,
+			Body:     substituteFreeIdent(e.Body, inner, name, replacement),
+		}, e)
+
+	case *types.AttrSetExpr:
+		if e.Recursive && attrSetShadows(e, name) {
+			return expr
+		}
+
+		bindings := make([]types.AttrBinding, len(e.Bindings))
+		for i, b := range e.Bindings {
+			bindings[i] = types.AttrBinding{Path: b.Path, Value: substituteFreeIdent(b.Value, bound, name, replacement), Span: b.Span}
+		}
+		inherits := make([]types.InheritClause, len(e.Inherits))
+		for i, inh := range e.Inherits {
+			inherits[i] = types.InheritClause{From: substituteFreeIdent(inh.From, bound, name, replacement), Attrs: inh.Attrs, Span: inh.Span}
+		}
+
+		return copySpan(&types.AttrSetExpr{
+			Recursive: e.Recursive,
+			Bindings:  bindings,
+			Inherits:  inherits,
+		}, e)
+
+	default:
+		return expr
+	}
+}
+
+func letShadows(e *types.LetExpr, name string) bool {
+	for _, b := range e.Bindings {
+		if b.Name == name {
+			return true
+		}
+	}
+	for _, inh := range e.Inherits {
+		for _, a := range inh.Attrs {
+			if a == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func attrSetShadows(e *types.AttrSetExpr, name string) bool {
+	for _, b := range e.Bindings {
+		if len(b.Path) > 0 && b.Path[0] == name {
+			return true
+		}
+	}
+	for _, inh := range e.Inherits {
+		for _, a := range inh.Attrs {
+			if a == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isPure reports whether expr is safe to duplicate into a different
+// evaluation context - no observable side effect (a thrown error,
+// builtins.trace, forcing something strictness wouldn't otherwise
+// force) depends on how many times, or in what context, it's
+// evaluated. Only the syntactic forms the other passes actually need
+// to move around are allowed; anything else is conservatively treated
+// as impure.
+func isPure(expr types.Expr) bool {
+	switch e := expr.(type) {
+	case *types.IntExpr, *types.FloatExpr, *types.StringExpr,
+		*types.BoolExpr, *types.NullExpr, *types.PathExpr, *types.IdentExpr:
+		return true
+	case *types.BinaryExpr:
+		return isPure(e.Left) && isPure(e.Right)
+	case *types.UnaryExpr:
+		return isPure(e.Expr)
+	case *types.ListExpr:
+		for _, el := range e.Elements {
+			if !isPure(el) {
+				return false
+			}
+		}
+
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,70 @@
+package optimize
+
+import "github.com/conneroisu/gix/internal/types"
+
+// rewriteLet rewrites a let expression's bindings and body bottom-up,
+// then inlines any binding that is: referenced from the body exactly
+// once, referenced nowhere else (no sibling binding or inherit
+// expression depends on it - inlining those safely would mean
+// threading the substitution through them too, which isn't worth the
+// complexity for what's meant to be a narrow pass), and pure (see
+// isPure) so duplicating it into the body's evaluation doesn't change
+// how many times, or in what order, any effect it might have would
+// run. A let left with no bindings or inherits at all collapses to
+// its body.
+func rewriteLet(e *types.LetExpr, stats *Stats) types.Expr {
+	bindings := make([]types.Binding, len(e.Bindings))
+	for i, b := range e.Bindings {
+		bindings[i] = types.Binding{Name: b.Name, Value: rewrite(b.Value, stats), Span: b.Span}
+	}
+
+	inherits := make([]types.InheritClause, len(e.Inherits))
+	for i, inh := range e.Inherits {
+		inherits[i] = types.InheritClause{From: rewrite(inh.From, stats), Attrs: inh.Attrs, Span: inh.Span}
+	}
+
+	body := rewrite(e.Body, stats)
+
+	kept := make([]types.Binding, 0, len(bindings))
+
+	for i, b := range bindings {
+		if isPure(b.Value) &&
+			countFreeIdent(body, b.Name) == 1 &&
+			countFreeIdent(b.Value, b.Name) == 0 &&
+			!usedElsewhere(bindings, inherits, i, b.Name) {
+			body = substituteFreeIdent(body, map[string]bool{}, b.Name, b.Value)
+			stats.LetBindingsInlined++
+
+			continue
+		}
+
+		kept = append(kept, b)
+	}
+
+	if len(kept) == 0 && len(inherits) == 0 {
+		return body
+	}
+
+	return copySpan(&types.LetExpr{Bindings: kept, Inherits: inherits, Body: body}, e)
+}
+
+// usedElsewhere reports whether name is referenced by any binding
+// other than bindings[skip] or by any inherit's source expression -
+// the cases rewriteLet's single-site substitution doesn't cover.
+func usedElsewhere(bindings []types.Binding, inherits []types.InheritClause, skip int, name string) bool {
+	for i, b := range bindings {
+		if i == skip {
+			continue
+		}
+		if countFreeIdent(b.Value, name) > 0 {
+			return true
+		}
+	}
+	for _, inh := range inherits {
+		if countFreeIdent(inh.From, name) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,212 @@
+// Package optimize implements a small AST-to-AST optimizer for the Nix
+// expression trees produced by pkg/parser, run as an optional pass
+// before evaluation (see eval.WithOptimize). It borrows the spirit of a
+// classic SSA block optimizer - constant folding, dead-branch
+// elimination, and invariant hoisting - adapted to a lazy, tree-shaped
+// AST instead of a basic-block CFG.
+//
+// It lives in its own package, rather than inside pkg/eval alongside
+// the arithmetic it mirrors (evalAdd, evalSub, ...), because eval needs
+// to call into it from Eval: folding at eval's own evalAdd/evalSub
+// would create an import cycle. The fold* helpers below are therefore
+// independent reimplementations working directly on literal AST nodes
+// instead of on evaluated value.Value, kept deliberately narrow (the
+// same operators, the same overflow checks) so the two stay in sync by
+// inspection.
+package optimize
+
+import (
+	"github.com/conneroisu/gix/internal/types"
+)
+
+// Stats counts how many times each pass actually rewrote something,
+// so a test can assert the optimizer fired on a handcrafted input
+// instead of just checking the output happens to be correct.
+type Stats struct {
+	ConstantsFolded    int // arithmetic/string/logical subexpressions reduced to a literal
+	BranchesEliminated int // if expressions with a literal condition reduced to one branch
+	LetBindingsInlined int // single-use, pure let bindings substituted into their body
+	InvariantsHoisted  int // subexpressions shared across rec bindings lifted into a let
+	Iterations         int // passes actually run before reaching a fixed point or the cap
+}
+
+// DefaultMaxIterations caps how many times Optimize will re-run the
+// pass pipeline looking for further progress. Each pass already
+// rewrites bottom-up in one traversal, so in practice a fixed point is
+// reached in two or three rounds (one rewrite can expose another, e.g.
+// eliminating an if branch can turn a let binding that was used twice
+// into one used once); the cap just bounds pathological inputs.
+const DefaultMaxIterations = 20
+
+// Optimize rewrites expr by running the fold/dead-branch/inline/hoist
+// passes to a fixed point (or until maxIterations is reached,
+// whichever comes first) and returns the rewritten tree along with
+// statistics on what fired. maxIterations <= 0 is treated as
+// DefaultMaxIterations.
+func Optimize(expr types.Expr, maxIterations int) (types.Expr, Stats) {
+	if maxIterations <= 0 {
+		maxIterations = DefaultMaxIterations
+	}
+
+	var total Stats
+
+	for total.Iterations = 1; total.Iterations <= maxIterations; total.Iterations++ {
+		var round Stats
+
+		expr = rewrite(expr, &round)
+
+		total.ConstantsFolded += round.ConstantsFolded
+		total.BranchesEliminated += round.BranchesEliminated
+		total.LetBindingsInlined += round.LetBindingsInlined
+		total.InvariantsHoisted += round.InvariantsHoisted
+
+		if (round == Stats{}) {
+			break
+		}
+	}
+
+	// Reaching the cap without a fixed point runs the loop's post
+	// statement once more; report only the passes actually run.
+	if total.Iterations > maxIterations {
+		total.Iterations = maxIterations
+	}
+
+	return expr, total
+}
+
+// spanSetter is satisfied by every concrete *types.XxxExpr node (each
+// promotes it from the embedded, unexported baseNode - see SetSpan's
+// doc comment in internal/types/ast.go). Rebuilding a node with a
+// plain composite literal loses its original span, since baseNode
+// itself isn't reachable outside the types package; copySpan restores
+// it so error messages for an optimized tree still point at the
+// original source.
+type spanSetter interface {
+	SetSpan(types.Span)
+}
+
+func copySpan(node types.Expr, from types.Node) types.Expr {
+	if ss, ok := node.(spanSetter); ok {
+		ss.SetSpan(from.Span())
+	}
+
+	return node
+}
+
+// rewrite applies one bottom-up pass over expr: every child is
+// rewritten first, the node is rebuilt (preserving its original span)
+// if any child changed, and then the node-local rewrites (constant
+// folding, dead-branch elimination, let-inlining, invariant hoisting)
+// are attempted on the rebuilt node.
+func rewrite(expr types.Expr, stats *Stats) types.Expr {
+	if expr == nil {
+		return nil
+	}
+
+	switch e := expr.(type) {
+	case *types.IntExpr, *types.FloatExpr, *types.StringExpr,
+		*types.BoolExpr, *types.NullExpr, *types.PathExpr, *types.IdentExpr:
+		return expr
+
+	case *types.ListExpr:
+		elems := make([]types.Expr, len(e.Elements))
+		for i, el := range e.Elements {
+			elems[i] = rewrite(el, stats)
+		}
+
+		return copySpan(&types.ListExpr{Elements: elems}, e)
+
+	case *types.AttrSetExpr:
+		return rewriteAttrSet(e, stats)
+
+	case *types.BinaryExpr:
+		left := rewrite(e.Left, stats)
+		right := rewrite(e.Right, stats)
+		node := &types.BinaryExpr{Left: left, Op: e.Op, Right: right}
+
+		return foldBinary(copySpan(node, e).(*types.BinaryExpr), stats)
+
+	case *types.UnaryExpr:
+		operand := rewrite(e.Expr, stats)
+		node := &types.UnaryExpr{Op: e.Op, Expr: operand}
+
+		return foldUnary(copySpan(node, e).(*types.UnaryExpr), stats)
+
+	case *types.IfExpr:
+		cond := rewrite(e.Cond, stats)
+		then := rewrite(e.Then, stats)
+		els := rewrite(e.Else, stats)
+
+		if b, ok := cond.(*types.BoolExpr); ok {
+			stats.BranchesEliminated++
+			if b.Value {
+				return then
+			}
+
+			return els
+		}
+
+		return copySpan(&types.IfExpr{Cond: cond, Then: then, Else: els}, e)
+
+	case *types.LetExpr:
+		return rewriteLet(e, stats)
+
+	case *types.WithExpr:
+		return copySpan(&types.WithExpr{
+			Expr: rewrite(e.Expr, stats),
+			Body: rewrite(e.Body, stats),
+		}, e)
+
+	case *types.AssertExpr:
+		return copySpan(&types.AssertExpr{
+			Cond: rewrite(e.Cond, stats),
+			Body: rewrite(e.Body, stats),
+		}, e)
+
+	case *types.FunctionExpr:
+		return copySpan(&types.FunctionExpr{
+			Param:   e.Param,
+			Pattern: e.Pattern,
+			Body:    rewrite(e.Body, stats),
+			Doc:     e.Doc,
+		}, e)
+
+	case *types.ApplyExpr:
+		return copySpan(&types.ApplyExpr{
+			Func: rewrite(e.Func, stats),
+			Arg:  rewrite(e.Arg, stats),
+		}, e)
+
+	case *types.SelectExpr:
+		var def types.Expr
+		if e.Default != nil {
+			def = rewrite(e.Default, stats)
+		}
+
+		return copySpan(&types.SelectExpr{
+			Expr:     rewrite(e.Expr, stats),
+			AttrPath: e.AttrPath,
+			Default:  def,
+		}, e)
+
+	case *types.HasAttrExpr:
+		return copySpan(&types.HasAttrExpr{
+			Expr:     rewrite(e.Expr, stats),
+			AttrPath: e.AttrPath,
+		}, e)
+
+	case *types.InheritExpr:
+		var from types.Expr
+		if e.From != nil {
+			from = rewrite(e.From, stats)
+		}
+
+		return copySpan(&types.InheritExpr{From: from, Attrs: e.Attrs}, e)
+
+	default:
+		// ErrorExpr and anything else this optimizer doesn't know about
+		// pass through unchanged rather than risk mishandling a node
+		// shape it wasn't written against.
+		return expr
+	}
+}
@@ -0,0 +1,219 @@
+package optimize
+
+import (
+	"testing"
+
+	"github.com/conneroisu/gix/internal/types"
+	"github.com/conneroisu/gix/pkg/lexer"
+	"github.com/conneroisu/gix/pkg/parser"
+)
+
+func parseExpr(t *testing.T, input string) types.Expr {
+	t.Helper()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+
+	program, err := p.Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	return program
+}
+
+func TestOptimizeFoldsConstantArithmetic(t *testing.T) {
+	expr, stats := Optimize(parseExpr(t, "1 + 2 * 3"), 0)
+
+	if stats.ConstantsFolded == 0 {
+		t.Errorf("expected ConstantsFolded > 0, got %+v", stats)
+	}
+
+	i, ok := expr.(*types.IntExpr)
+	if !ok {
+		t.Fatalf("expected *types.IntExpr, got %T (%s)", expr, expr)
+	}
+	if i.Value != 7 {
+		t.Errorf("got %d, want 7", i.Value)
+	}
+}
+
+func TestOptimizeFoldsStringConcat(t *testing.T) {
+	expr, stats := Optimize(parseExpr(t, `"foo" + "bar"`), 0)
+
+	if stats.ConstantsFolded == 0 {
+		t.Errorf("expected ConstantsFolded > 0, got %+v", stats)
+	}
+
+	s, ok := expr.(*types.StringExpr)
+	if !ok {
+		t.Fatalf("expected *types.StringExpr, got %T (%s)", expr, expr)
+	}
+	if s.Value != "foobar" {
+		t.Errorf("got %q, want %q", s.Value, "foobar")
+	}
+}
+
+func TestOptimizeRefusesToFoldOverflow(t *testing.T) {
+	expr, stats := Optimize(parseExpr(t, "9223372036854775807 + 1"), 0)
+
+	if stats.ConstantsFolded != 0 {
+		t.Errorf("expected no folding on overflow, got %+v", stats)
+	}
+	if _, ok := expr.(*types.BinaryExpr); !ok {
+		t.Fatalf("expected the BinaryExpr to survive unfolded, got %T", expr)
+	}
+}
+
+func TestOptimizeRefusesToFoldDivisionByZero(t *testing.T) {
+	expr, stats := Optimize(parseExpr(t, "1 / 0"), 0)
+
+	if stats.ConstantsFolded != 0 {
+		t.Errorf("expected no folding on division by zero, got %+v", stats)
+	}
+	if _, ok := expr.(*types.BinaryExpr); !ok {
+		t.Fatalf("expected the BinaryExpr to survive unfolded, got %T", expr)
+	}
+}
+
+func TestOptimizeEliminatesDeadIfBranch(t *testing.T) {
+	expr, stats := Optimize(parseExpr(t, "if true then 1 else 2"), 0)
+
+	if stats.BranchesEliminated == 0 {
+		t.Errorf("expected BranchesEliminated > 0, got %+v", stats)
+	}
+
+	i, ok := expr.(*types.IntExpr)
+	if !ok {
+		t.Fatalf("expected *types.IntExpr, got %T (%s)", expr, expr)
+	}
+	if i.Value != 1 {
+		t.Errorf("got %d, want 1", i.Value)
+	}
+}
+
+func TestOptimizeEliminatesDeadIfBranchDoesNotEvaluateOtherBranch(t *testing.T) {
+	// The then branch divides by zero; if it were picked the whole
+	// expression would collapse to an unfoldable BinaryExpr instead of
+	// surviving as the untouched else-branch.
+	expr, stats := Optimize(parseExpr(t, "if false then (1 / 0) else 42"), 0)
+
+	if stats.BranchesEliminated == 0 {
+		t.Errorf("expected BranchesEliminated > 0, got %+v", stats)
+	}
+
+	i, ok := expr.(*types.IntExpr)
+	if !ok {
+		t.Fatalf("expected *types.IntExpr, got %T (%s)", expr, expr)
+	}
+	if i.Value != 42 {
+		t.Errorf("got %d, want 42", i.Value)
+	}
+}
+
+func TestOptimizeInlinesSingleUsePureLetBinding(t *testing.T) {
+	expr, stats := Optimize(parseExpr(t, "let x = 1 + 1; in x * 10"), 0)
+
+	if stats.LetBindingsInlined == 0 {
+		t.Errorf("expected LetBindingsInlined > 0, got %+v", stats)
+	}
+
+	// x = 1+1 folds to 2, then inlines into x*10 = 2*10, which itself
+	// folds to the final literal 20.
+	i, ok := expr.(*types.IntExpr)
+	if !ok {
+		t.Fatalf("expected *types.IntExpr, got %T (%s)", expr, expr)
+	}
+	if i.Value != 20 {
+		t.Errorf("got %d, want 20", i.Value)
+	}
+}
+
+func TestOptimizeDoesNotInlineBindingUsedTwice(t *testing.T) {
+	expr, stats := Optimize(parseExpr(t, "let x = foo; in x + x"), 0)
+
+	if stats.LetBindingsInlined != 0 {
+		t.Errorf("expected no inlining of a binding used twice, got %+v", stats)
+	}
+	if _, ok := expr.(*types.LetExpr); !ok {
+		t.Fatalf("expected the let to survive, got %T", expr)
+	}
+}
+
+func TestOptimizeDoesNotInlineAcrossShadowing(t *testing.T) {
+	// The inner x shadows the outer one and is used exactly once, so it
+	// inlines to 5; the outer x is now referenced nowhere (rewriteLet
+	// only inlines single-use bindings, so an unused one like this is
+	// left in place rather than dropped) and must keep its own value
+	// rather than having the inner one's substituted into it.
+	expr, stats := Optimize(parseExpr(t, "let x = foo; in let x = 5; in x"), 0)
+
+	if stats.LetBindingsInlined == 0 {
+		t.Errorf("expected the inner let's binding to be inlined, got %+v", stats)
+	}
+
+	outer, ok := expr.(*types.LetExpr)
+	if !ok {
+		t.Fatalf("expected the outer let to survive (its x is unused, not single-use), got %T (%s)", expr, expr)
+	}
+	if len(outer.Bindings) != 1 || outer.Bindings[0].Name != "x" {
+		t.Fatalf("expected the outer binding x to survive unchanged, got %+v", outer.Bindings)
+	}
+	if _, ok := outer.Bindings[0].Value.(*types.IdentExpr); !ok {
+		t.Fatalf("expected outer x's value to remain the identifier foo, got %T", outer.Bindings[0].Value)
+	}
+
+	i, ok := outer.Body.(*types.IntExpr)
+	if !ok {
+		t.Fatalf("expected the outer body to be the inlined inner x, got %T (%s)", outer.Body, outer.Body)
+	}
+	if i.Value != 5 {
+		t.Errorf("got %d, want 5 (must not have substituted the outer x's value)", i.Value)
+	}
+}
+
+func TestOptimizeHoistsInvariantAcrossRecBindings(t *testing.T) {
+	expr, stats := Optimize(parseExpr(t, "rec { a = (foo + bar) * 2; b = (foo + bar) - 1; }"), 0)
+
+	if stats.InvariantsHoisted == 0 {
+		t.Errorf("expected InvariantsHoisted > 0, got %+v", stats)
+	}
+
+	let, ok := expr.(*types.LetExpr)
+	if !ok {
+		t.Fatalf("expected the rec set to be wrapped in a *types.LetExpr, got %T (%s)", expr, expr)
+	}
+	if len(let.Bindings) != 1 {
+		t.Fatalf("expected exactly one hoisted binding, got %d (%s)", len(let.Bindings), expr)
+	}
+
+	attrs, ok := let.Body.(*types.AttrSetExpr)
+	if !ok {
+		t.Fatalf("expected the let body to still be the rec set, got %T", let.Body)
+	}
+	if len(attrs.Bindings) != 2 {
+		t.Fatalf("expected both original bindings to survive, got %d", len(attrs.Bindings))
+	}
+}
+
+func TestOptimizeDoesNotHoistExpressionReferencingARecBinding(t *testing.T) {
+	expr, stats := Optimize(parseExpr(t, "rec { a = 1; b = a + 1; c = a + 1; }"), 0)
+
+	if stats.InvariantsHoisted != 0 {
+		t.Errorf("expected no hoisting of an expression referencing a's own rec binding, got %+v", stats)
+	}
+	if _, ok := expr.(*types.AttrSetExpr); !ok {
+		t.Fatalf("expected a plain rec set with nothing hoisted, got %T", expr)
+	}
+}
+
+func TestOptimizeDoesNotHoistFromNonRecursiveAttrSet(t *testing.T) {
+	expr, stats := Optimize(parseExpr(t, "{ a = expensive foo; b = expensive foo; }"), 0)
+
+	if stats.InvariantsHoisted != 0 {
+		t.Errorf("expected no hoisting in a non-recursive attrset, got %+v", stats)
+	}
+	if _, ok := expr.(*types.AttrSetExpr); !ok {
+		t.Fatalf("expected a plain attrset, got %T", expr)
+	}
+}
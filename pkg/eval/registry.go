@@ -0,0 +1,198 @@
+package eval
+
+import (
+	"fmt"
+
+	"github.com/conneroisu/gix/internal/value"
+)
+
+// BuiltinsVersion identifies the shape of registerBuiltins/
+// registerStdlibBuiltins/registerImportBuiltins/registerFetchBuiltins -
+// which names exist, what they do, what arity they take. It has nothing
+// to do with WithLanguageLevel (which gates individual built-ins at
+// runtime); it exists purely so the on-disk eval cache (see
+// (*Evaluator).SaveCache/LoadCache and CacheKey in cache.go) can tell
+// whether a cache file came from a compatible build of gix. Bump it
+// whenever a built-in is added, removed, renamed, or has its behavior
+// changed in a way that would make a previously cached result wrong.
+const BuiltinsVersion = 1
+
+// Builtin is the interface form of a built-in function: an alternative to
+// registering a bare BuiltinImpl closure for embedders who'd rather
+// define a type than pass loose name/arity/function arguments - the way
+// Starlark's Universe or HIL's function table let a host app extend the
+// language with its own type. Call receives the evaluator it's
+// registered on, the same as BuiltinImpl, since most built-ins need it
+// to call back into evalExpr/evalApply (map, filter, import) far more
+// often than they need anything from the calling lexical environment.
+type Builtin interface {
+	Name() string
+	Arity() int
+	Call(e *Evaluator, args []value.Value) (value.Value, error)
+}
+
+// RegisterBuiltin adapts b onto Register, so it goes through the exact
+// same curried-application and registry machinery as every other
+// built-in in this package.
+func (r *BuiltinRegistry) RegisterBuiltin(b Builtin) {
+	r.Register(b.Name(), b.Arity(), b.Call)
+}
+
+// BuiltinImpl is the implementation of a built-in function. It receives
+// the evaluator it was registered on (so higher-order built-ins can call
+// back into evalExpr/evalApply) and the full list of arguments, already
+// forced to concrete values.
+type BuiltinImpl func(e *Evaluator, args []value.Value) (value.Value, error)
+
+// builtinEntry is a registered built-in's definition, independent of any
+// particular Evaluator - BuiltinRegistry.Builtins binds entries to a
+// specific Evaluator when it builds the Value each one resolves to.
+type builtinEntry struct {
+	arity    int
+	impl     BuiltinImpl
+	minLevel int
+	lazy     bool // if true, impl receives its arguments unforced; see RegisterLazy
+}
+
+// BuiltinRegistry holds the set of built-in functions available to an
+// Evaluator. Splitting this out of Evaluator itself is what lets
+// embedders add domain-specific primops (via WithExtraBuiltin) without
+// forking the package, and lets built-ins be gated by language level
+// (see WithLanguageLevel) the way Nix itself has grown new builtins and
+// renamed old ones across releases.
+type BuiltinRegistry struct {
+	entries map[string]builtinEntry
+}
+
+// NewBuiltinRegistry creates an empty registry.
+func NewBuiltinRegistry() *BuiltinRegistry {
+	return &BuiltinRegistry{entries: make(map[string]builtinEntry)}
+}
+
+// Register adds a built-in available from language level 0 onward (i.e.
+// always available). arity is the number of arguments impl expects;
+// Nix's `f a b` is nested single-argument application, so a built-in of
+// arity > 1 is automatically curried - applying it to fewer than arity
+// arguments yields a new built-in capturing what's been supplied so far,
+// exactly like RegisterPrimOpApp. Register and RegisterPrimOpApp are the
+// same mechanism under two names: use Register for the common arity-1
+// case and RegisterPrimOpApp when the arity > 1 partial-application
+// behavior is the point of the registration.
+func (r *BuiltinRegistry) Register(name string, arity int, impl BuiltinImpl) {
+	r.RegisterVersioned(0, name, arity, impl)
+}
+
+// RegisterPrimOpApp adds a built-in of arity > 1 that supports partial
+// application, mirroring Nix's PrimOpApp: calling it with fewer than
+// arity arguments returns a new built-in value remembering the
+// arguments already given, so expressions like `map (add 1) xs` can
+// pass `add 1` around as an ordinary function value.
+func (r *BuiltinRegistry) RegisterPrimOpApp(name string, arity int, impl BuiltinImpl) {
+	r.RegisterVersioned(0, name, arity, impl)
+}
+
+// RegisterVersioned adds a built-in gated behind minLevel: it's only
+// included in the environment built by Builtins when the Evaluator's
+// language level is >= minLevel. Register and RegisterPrimOpApp are thin
+// wrappers around this that always use level 0.
+func (r *BuiltinRegistry) RegisterVersioned(
+	minLevel int,
+	name string,
+	arity int,
+	impl BuiltinImpl,
+) {
+	r.entries[name] = builtinEntry{arity: arity, impl: impl, minLevel: minLevel}
+}
+
+// RegisterLazy adds a built-in whose arguments are passed to impl
+// unforced - the one exception to Register/RegisterPrimOpApp's rule that
+// every argument arrives already evaluated to WHNF. tryEval is the
+// motivating case: it needs to catch an error that forcing its argument
+// would raise, which is impossible if forcing already happened before
+// impl is even called. Most built-ins should use Register or
+// RegisterPrimOpApp instead; only reach for this when the whole point of
+// the built-in is to control forcing itself.
+func (r *BuiltinRegistry) RegisterLazy(name string, arity int, impl BuiltinImpl) {
+	r.entries[name] = builtinEntry{arity: arity, impl: impl, lazy: true}
+}
+
+// Builtins returns the Value each registered built-in resolves to for e,
+// restricted to entries whose minLevel is at or below level.
+func (r *BuiltinRegistry) Builtins(e *Evaluator, level int) map[string]value.Value {
+	out := make(map[string]value.Value, len(r.entries))
+	for name, entry := range r.entries {
+		entry := entry // capture per iteration; closure below outlives the loop
+		if entry.minLevel > level {
+			continue
+		}
+		fn := func(args []value.Value) (value.Value, error) {
+			return entry.impl(e, args)
+		}
+		if entry.lazy {
+			out[name] = lazyCurriedBuiltin(name, entry.arity, nil, fn)
+		} else {
+			out[name] = curriedBuiltin(name, entry.arity, nil, fn)
+		}
+	}
+
+	return out
+}
+
+// curriedBuiltin builds a *value.Builtin of the given total arity that
+// accumulates one argument per Apply call - matching evalApply's calling
+// convention of always invoking a built-in with exactly one argument at
+// a time - and only calls fn, with every collected argument forced, once
+// arity arguments have been gathered. Applying it early returns a new
+// curriedBuiltin closing over what's been collected so far.
+func curriedBuiltin(
+	name string,
+	arity int,
+	collected []value.Value,
+	fn func([]value.Value) (value.Value, error),
+) *value.Builtin {
+	return value.NewBuiltin(name, func(args []value.Value) (value.Value, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s: internal error: expected one argument per application, got %d", name, len(args))
+		}
+
+		next := append(append([]value.Value(nil), collected...), args[0])
+		if len(next) < arity {
+			return curriedBuiltin(name, arity, next, fn), nil
+		}
+
+		forced := make([]value.Value, len(next))
+		for i, arg := range next {
+			val, err := value.Force(arg)
+			if err != nil {
+				return nil, err
+			}
+			forced[i] = val
+		}
+
+		return fn(forced)
+	})
+}
+
+// lazyCurriedBuiltin is curriedBuiltin without the forcing step: it
+// accumulates arguments the same way, but once arity of them have been
+// gathered it invokes fn with them exactly as received, leaving it up to
+// fn to force (or deliberately not force) each one itself.
+func lazyCurriedBuiltin(
+	name string,
+	arity int,
+	collected []value.Value,
+	fn func([]value.Value) (value.Value, error),
+) *value.Builtin {
+	return value.NewBuiltin(name, func(args []value.Value) (value.Value, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s: internal error: expected one argument per application, got %d", name, len(args))
+		}
+
+		next := append(append([]value.Value(nil), collected...), args[0])
+		if len(next) < arity {
+			return lazyCurriedBuiltin(name, arity, next, fn), nil
+		}
+
+		return fn(next)
+	})
+}
@@ -0,0 +1,32 @@
+// Package format implements a canonical pretty-printer for gix ASTs.
+//
+// Unlike types.Expr's String() method, which produces a compact,
+// single-line representation suitable for error messages, this package
+// walks the AST and produces indentation-aware, multi-line Nix source:
+// an attribute set or list that fits within maxInlineWidth stays on one
+// line, and one that doesn't breaks to one binding or element per line -
+// attribute set bindings additionally have their "=" signs aligned in a
+// block. Binary operators are parenthesized only where precedence would
+// otherwise change how the result reparses. It backs the `gix fmt`
+// command, golden-file parser tests that need a stable round-trip
+// representation, and the REPL's :p command.
+//
+// Comments are not preserved: gix's lexer can surface them as trivia
+// (see lexer.ScanComments), but the parser doesn't attach that trivia
+// to any AST node, so by the time an Expr reaches this package its
+// comments are already gone. Reformatting a file strips them. Attaching
+// trivia to every node so it could round-trip through reformatting
+// would be a parser-wide change (every production would need to thread
+// Leading/Trailing through to the node it builds); until that lands,
+// this package stays honest about the gap rather than pretending to
+// support it.
+//
+// Usage Example:
+//
+//	ast, err := parser.New(lexer.New(src)).Parse()
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//
+//	fmt.Println(format.Format(ast))
+package format
@@ -0,0 +1,310 @@
+package format
+
+import (
+	"strings"
+
+	"github.com/conneroisu/gix/internal/types"
+)
+
+// indentUnit is the whitespace added per nesting level.
+const indentUnit = "  "
+
+// Format renders expr as canonical, indentation-aware Nix source:
+// attribute sets print one binding per line, let bindings are aligned
+// under their "let", and operator spacing is normalized.
+func Format(expr types.Expr) string {
+	p := &printer{}
+	p.printExpr(expr)
+
+	return p.buf.String()
+}
+
+// printer walks an AST and writes canonical Nix source to buf, tracking
+// the current nesting level for indentation.
+type printer struct {
+	buf   strings.Builder
+	level int
+}
+
+func (p *printer) indent() string {
+	return strings.Repeat(indentUnit, p.level)
+}
+
+func (p *printer) printExpr(expr types.Expr) {
+	switch e := expr.(type) {
+	case *types.ListExpr:
+		p.printList(e)
+	case *types.AttrSetExpr:
+		p.printAttrSet(e)
+	case *types.BinaryExpr:
+		p.printBinary(e)
+	case *types.UnaryExpr:
+		p.buf.WriteString(e.Op.String())
+		p.printExpr(e.Expr)
+	case *types.IfExpr:
+		p.printIf(e)
+	case *types.LetExpr:
+		p.printLet(e)
+	case *types.WithExpr:
+		p.buf.WriteString("with ")
+		p.printExpr(e.Expr)
+		p.buf.WriteString("; ")
+		p.printExpr(e.Body)
+	case *types.AssertExpr:
+		p.buf.WriteString("assert ")
+		p.printExpr(e.Cond)
+		p.buf.WriteString("; ")
+		p.printExpr(e.Body)
+	case *types.FunctionExpr:
+		p.printFunction(e)
+	case *types.ApplyExpr:
+		p.printExpr(e.Func)
+		p.buf.WriteString(" ")
+		p.printExpr(e.Arg)
+	case *types.SelectExpr:
+		p.printSelect(e)
+	case *types.HasAttrExpr:
+		p.printExpr(e.Expr)
+		p.buf.WriteString(" ? ")
+		p.buf.WriteString(strings.Join(e.AttrPath, "."))
+	default:
+		// Literals (IntExpr, FloatExpr, StringExpr, BoolExpr, NullExpr,
+		// PathExpr, IdentExpr) have no structure to indent - their String()
+		// form is already canonical.
+		p.buf.WriteString(expr.String())
+	}
+}
+
+// maxInlineWidth is the line-length budget printList uses to decide
+// whether a list fits on one line or needs one element per line.
+const maxInlineWidth = 80
+
+func (p *printer) printList(e *types.ListExpr) {
+	if len(e.Elements) == 0 {
+		p.buf.WriteString("[ ]")
+
+		return
+	}
+
+	if len(p.indent())+len(e.String()) <= maxInlineWidth {
+		p.buf.WriteString("[ ")
+		for i, elem := range e.Elements {
+			if i > 0 {
+				p.buf.WriteString(" ")
+			}
+			p.printExpr(elem)
+		}
+		p.buf.WriteString(" ]")
+
+		return
+	}
+
+	p.buf.WriteString("[\n")
+
+	p.level++
+	for _, elem := range e.Elements {
+		p.buf.WriteString(p.indent())
+		p.printExpr(elem)
+		p.buf.WriteString("\n")
+	}
+	p.level--
+
+	p.buf.WriteString(p.indent())
+	p.buf.WriteString("]")
+}
+
+func (p *printer) printAttrSet(e *types.AttrSetExpr) {
+	if len(e.Bindings) == 0 && len(e.Inherits) == 0 {
+		if e.Recursive {
+			p.buf.WriteString("rec { }")
+		} else {
+			p.buf.WriteString("{ }")
+		}
+
+		return
+	}
+
+	if e.Recursive {
+		p.buf.WriteString("rec ")
+	}
+
+	if len(p.indent())+len(e.String()) <= maxInlineWidth {
+		p.buf.WriteString("{ ")
+		for _, inherit := range e.Inherits {
+			p.buf.WriteString(inherit.String())
+			p.buf.WriteString(" ")
+		}
+		for _, binding := range e.Bindings {
+			p.buf.WriteString(strings.Join(binding.Path, "."))
+			p.buf.WriteString(" = ")
+			p.printExpr(binding.Value)
+			p.buf.WriteString("; ")
+		}
+		p.buf.WriteString("}")
+
+		return
+	}
+
+	p.buf.WriteString("{\n")
+
+	p.level++
+
+	// Bindings' "=" signs line up in a block, the way nixpkgs-fmt and
+	// similar tools align them, so a reader's eye can scan down the
+	// names and values in two straight columns instead of a ragged one.
+	nameWidth := 0
+	for _, binding := range e.Bindings {
+		if n := len(strings.Join(binding.Path, ".")); n > nameWidth {
+			nameWidth = n
+		}
+	}
+
+	for _, inherit := range e.Inherits {
+		p.buf.WriteString(p.indent())
+		p.buf.WriteString(inherit.String())
+		p.buf.WriteString("\n")
+	}
+	for _, binding := range e.Bindings {
+		name := strings.Join(binding.Path, ".")
+		p.buf.WriteString(p.indent())
+		p.buf.WriteString(name)
+		p.buf.WriteString(strings.Repeat(" ", nameWidth-len(name)))
+		p.buf.WriteString(" = ")
+		p.printExpr(binding.Value)
+		p.buf.WriteString(";\n")
+	}
+	p.level--
+
+	p.buf.WriteString(p.indent())
+	p.buf.WriteString("}")
+}
+
+func (p *printer) printBinary(e *types.BinaryExpr) {
+	p.buf.WriteString("(")
+	p.printBinaryBody(e)
+	p.buf.WriteString(")")
+}
+
+// printBinaryBody prints e's two operands and operator without e's own
+// wrapping parens - the caller (printBinary, or printBinaryOperand
+// re-using a nested BinaryExpr's body to avoid double parens) supplies
+// those where needed.
+func (p *printer) printBinaryBody(e *types.BinaryExpr) {
+	prec := binaryPrecedence(e.Op)
+
+	p.printBinaryOperand(e.Left, prec, false)
+	p.buf.WriteString(" ")
+	p.buf.WriteString(e.Op.String())
+	p.buf.WriteString(" ")
+	p.printBinaryOperand(e.Right, prec, true)
+}
+
+// printBinaryOperand prints operand as one side of a BinaryExpr whose
+// operator binds at parentPrec, parenthesizing a nested BinaryExpr only
+// when dropping the parens would change how the result reparses - a
+// looser-binding child always needs them, and (since parseBinary
+// builds left-associative trees) a same-precedence child needs them
+// only on the right: "a - (b - c)" isn't "a - b - c". Anything
+// else is printed as-is.
+func (p *printer) printBinaryOperand(operand types.Expr, parentPrec int, isRight bool) {
+	be, ok := operand.(*types.BinaryExpr)
+	if !ok {
+		p.printExpr(operand)
+
+		return
+	}
+
+	childPrec := binaryPrecedence(be.Op)
+	if childPrec < parentPrec || (isRight && childPrec == parentPrec) {
+		p.buf.WriteString("(")
+		p.printBinaryBody(be)
+		p.buf.WriteString(")")
+
+		return
+	}
+
+	p.printBinaryBody(be)
+}
+
+// binaryPrecedence mirrors pkg/parser's precedence table (the
+// precedence* levels in pkg/parser/precedence.go, which the parser
+// records per token in its precedenceMap) so printBinaryOperand only
+// parenthesizes a child BinaryExpr when dropping the parens would
+// change how the result reparses.
+func binaryPrecedence(op types.BinaryOp) int {
+	switch op {
+	case types.OpImpl:
+		return 1
+	case types.OpOr:
+		return 2
+	case types.OpAnd:
+		return 3
+	case types.OpEq, types.OpNEq:
+		return 4
+	case types.OpLT, types.OpGT, types.OpLTE, types.OpGTE:
+		return 5
+	case types.OpUpdate:
+		return 6
+	case types.OpConcat:
+		return 7
+	case types.OpAdd, types.OpSub:
+		return 8
+	case types.OpMul, types.OpDiv:
+		return 9
+	default:
+		return 0
+	}
+}
+
+func (p *printer) printIf(e *types.IfExpr) {
+	p.buf.WriteString("if ")
+	p.printExpr(e.Cond)
+	p.buf.WriteString(" then ")
+	p.printExpr(e.Then)
+	p.buf.WriteString(" else ")
+	p.printExpr(e.Else)
+}
+
+func (p *printer) printLet(e *types.LetExpr) {
+	p.buf.WriteString("let\n")
+
+	p.level++
+	for _, inherit := range e.Inherits {
+		p.buf.WriteString(p.indent())
+		p.buf.WriteString(inherit.String())
+		p.buf.WriteString("\n")
+	}
+	for _, b := range e.Bindings {
+		p.buf.WriteString(p.indent())
+		p.buf.WriteString(b.Name)
+		p.buf.WriteString(" = ")
+		p.printExpr(b.Value)
+		p.buf.WriteString(";\n")
+	}
+	p.level--
+
+	p.buf.WriteString(p.indent())
+	p.buf.WriteString("in\n")
+	p.buf.WriteString(p.indent())
+	p.printExpr(e.Body)
+}
+
+func (p *printer) printFunction(e *types.FunctionExpr) {
+	if e.Pattern != nil {
+		p.buf.WriteString(e.Pattern.String())
+	} else {
+		p.buf.WriteString(e.Param)
+	}
+	p.buf.WriteString(": ")
+	p.printExpr(e.Body)
+}
+
+func (p *printer) printSelect(e *types.SelectExpr) {
+	p.printExpr(e.Expr)
+	p.buf.WriteString(".")
+	p.buf.WriteString(strings.Join(e.AttrPath, "."))
+	if e.Default != nil {
+		p.buf.WriteString(" or ")
+		p.printExpr(e.Default)
+	}
+}
@@ -0,0 +1,96 @@
+package format
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/conneroisu/gix/pkg/lexer"
+	"github.com/conneroisu/gix/pkg/parser"
+)
+
+func mustParse(t *testing.T, src string) *parser.Parser {
+	t.Helper()
+
+	return parser.New(lexer.New(src))
+}
+
+// reparses formats src, reparses the result, and fails if that second
+// parse errors - a formatted expression should always still be valid
+// Nix source.
+func reparses(t *testing.T, src string) string {
+	t.Helper()
+
+	ast, err := mustParse(t, src).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	out := Format(ast)
+
+	if _, err := mustParse(t, out).Parse(); err != nil {
+		t.Fatalf("formatted output %q failed to reparse: %v", out, err)
+	}
+
+	return out
+}
+
+func TestFormatOmitsRedundantParensForSamePrecedence(t *testing.T) {
+	out := reparses(t, "1 + 2 + 3")
+	if strings.Contains(out, "((") {
+		t.Errorf("expected no nested redundant parens, got %q", out)
+	}
+}
+
+func TestFormatKeepsParensThatChangeGrouping(t *testing.T) {
+	out := reparses(t, "1 + (2 + 3)")
+	if !strings.Contains(out, "(2 + 3)") {
+		t.Errorf("expected the right operand to stay parenthesized, got %q", out)
+	}
+}
+
+func TestFormatParenthesizesTighterOperatorCorrectly(t *testing.T) {
+	out := reparses(t, "(1 + 2) * 3")
+	if !strings.Contains(out, "(1 + 2)") {
+		t.Errorf("expected the looser-binding left operand to stay parenthesized, got %q", out)
+	}
+}
+
+func TestFormatBreaksLongListsOntoMultipleLines(t *testing.T) {
+	out := reparses(t, `[ "aaaaaaaaaa" "bbbbbbbbbb" "cccccccccc" "dddddddddd" "eeeeeeeeee" "ffffffffff" "gggggggggg" ]`)
+	if !strings.Contains(out, "\n") {
+		t.Errorf("expected a long list to break across lines, got %q", out)
+	}
+}
+
+func TestFormatKeepsShortListsInline(t *testing.T) {
+	out := reparses(t, "[ 1 2 3 ]")
+	if strings.Contains(out, "\n") {
+		t.Errorf("expected a short list to stay on one line, got %q", out)
+	}
+}
+
+func TestFormatKeepsShortAttrSetsInline(t *testing.T) {
+	out := reparses(t, "{ x = 1; y = 2; }")
+	if strings.Contains(out, "\n") {
+		t.Errorf("expected a short attrset to stay on one line, got %q", out)
+	}
+}
+
+func TestFormatAlignsEqualsSignsInBrokenAttrSet(t *testing.T) {
+	out := reparses(t, `{ aVeryLongAttributeName = 1; x = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"; }`)
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	var eqCols []int
+	for _, line := range lines {
+		if i := strings.Index(line, "= "); i != -1 {
+			eqCols = append(eqCols, i)
+		}
+	}
+
+	if len(eqCols) != 2 {
+		t.Fatalf("expected 2 bindings with an aligned '=', got %v in %q", eqCols, out)
+	}
+	if eqCols[0] != eqCols[1] {
+		t.Errorf("expected '=' signs aligned at the same column, got %v in %q", eqCols, out)
+	}
+}
@@ -8,23 +8,47 @@
 // Token Recognition:
 //   - Keywords: if, then, else, let, in, with, assert, or, and, not, rec, inherit
 //   - Identifiers: variable names following Nix naming rules
-//   - Literals: integers, floats, strings (with escape sequences), paths
+//   - Literals: integers, floats (including scientific notation like
+//     1.5e-3), strings (with escape sequences), paths, lookup paths
+//     (<nixpkgs>), and unquoted URIs (https://example.com/foo.tar.gz)
 //   - Operators: +, -, *, /, ==, !=, <, >, <=, >=, &&, ||, ->, ++, ?, .
 //   - Delimiters: (, ), {, }, [, ], ;, :, ,, =
 //
 // Comment Handling:
 //   - Single-line comments starting with '#'
 //   - Multi-line comments enclosed in /* */
-//   - Comments are skipped during tokenization
+//   - Comments are skipped during tokenization by default
+//   - NewWithMode(input, ScanComments|ScanWhitespace) instead surfaces
+//     them, and whitespace, as trivia attached to the surrounding
+//     significant token's Leading/Trailing - see Mode - so a formatter or
+//     an LSP can recover the exact source a plain New lexer discards
 //
 // Position Tracking:
-//   - Accurate line and column information for each token
-//   - Essential for meaningful error reporting
+//   - Each token carries a full source span: start and end line, column,
+//     and byte offset, not just a start point
+//   - Essential for meaningful error reporting and accurate source extraction
 //   - Handles both Unix (\n) and Windows (\r\n) line endings
+//   - A lexer created with NewFile also stamps every token with a
+//     token.Pos - a compact handle a token.FileSet can resolve back to
+//     (filename, line, column) - so diagnostics stay attributable once
+//     more than one file (an import chain, say) is involved
 //
 // String Processing:
-//   - Double-quoted strings with escape sequences
-//   - Proper handling of escaped quotes, newlines, etc.
+//   - Double-quoted "..." and indented ''...'' strings, both capable of
+//     "${ ... }" interpolation
+//   - Neither is a single token: the lexer pushes a mode onto an
+//     internal stack and streams an opening delimiter, alternating
+//     TOKEN_STR_PART (literal text, with escapes already decoded) and
+//     TOKEN_INTERP_START/TOKEN_INTERP_END around an interpolation's own
+//     tokens, and a closing delimiter - see Lexer.nextStringToken
+//   - Indented strings' common leading whitespace is stripped by the
+//     parser once it has assembled a literal's fragments, not here,
+//     since that requires seeing every line at once
+//   - A path literal (./foo/${name}.nix) can interpolate the same way:
+//     one with no "${ ... }" in it at all still lexes as a single
+//     TOKEN_PATH, but one that does streams TOKEN_PATH_PART in place of
+//     TOKEN_STR_PART, with no opening or closing delimiter of its own -
+//     a path's literal text just ends wherever its character class does
 //   - Unicode support through Go's UTF-8 handling
 //
 // Performance Optimizations:
@@ -35,13 +59,24 @@
 //
 // Error Handling:
 //   - Graceful handling of unexpected characters
-//   - ILLEGAL tokens for invalid input
-//   - Position information preserved for error reporting
+//   - ILLEGAL tokens for invalid input, so a caller that only reads the
+//     token stream never gets stuck
+//   - NewWithErrorHandler additionally reports every illegal character,
+//     unterminated string, unterminated block comment, and stray '&'/'|'
+//     via a go/scanner-style ErrorHandler; Lexer.Err returns the same
+//     problems afterward as a sorted, deduplicated ErrorList, for callers
+//     that want every scanning problem in one pass instead of stopping at
+//     the first one
 //
 // The lexer follows the maximal munch principle, consuming the longest possible
 // sequence of characters for each token. This ensures correct tokenization of
 // multi-character operators like '++', '->', '&&', etc.
 //
+// IsComplete reports whether a source string forms a syntactically
+// complete expression (balanced brackets, matching let/in, a terminated
+// string), without building a full token slice - useful for callers like
+// the REPL deciding whether to read another line before parsing.
+//
 // Usage Example:
 //
 //	lexer := lexer.New("let x = 42; in x + 1")
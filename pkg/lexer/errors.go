@@ -0,0 +1,99 @@
+package lexer
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Position identifies a location in a lexer's input for diagnostic
+// purposes. It's deliberately separate from Token's own Line/Column/Offset
+// fields: those describe a token's span, while Position is just the single
+// point an ErrorHandler or ErrorList entry refers to.
+type Position struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// ErrorHandler is called, if set, for every illegal-input condition the
+// Lexer encounters while scanning - an unrecognized character, an
+// unterminated string or block comment, a stray '&' or '|' - in addition
+// to (not instead of) the TOKEN_ILLEGAL it still produces, so existing
+// callers that only look at the token stream see no change in behavior.
+// Modeled after go/scanner.ErrorHandler.
+type ErrorHandler func(pos Position, msg string)
+
+// Error is one problem recorded in an ErrorList.
+type Error struct {
+	Pos Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// ErrorList accumulates the Errors a Lexer reports over its lifetime, in
+// the order they were found. Modeled after go/scanner.ErrorList.
+type ErrorList []*Error
+
+// Add appends an error to the list.
+func (l *ErrorList) Add(pos Position, msg string) {
+	*l = append(*l, &Error{Pos: pos, Msg: msg})
+}
+
+func (l ErrorList) Len() int      { return len(l) }
+func (l ErrorList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l ErrorList) Less(i, j int) bool {
+	pi, pj := l[i].Pos, l[j].Pos
+	if pi.Line != pj.Line {
+		return pi.Line < pj.Line
+	}
+	if pi.Column != pj.Column {
+		return pi.Column < pj.Column
+	}
+
+	return l[i].Msg < l[j].Msg
+}
+
+// Sort orders the list by position, breaking ties by message, and drops
+// exact duplicates - the same problem reported twice at the same spot
+// (which can happen if a caller re-lexes overlapping input) collapses to
+// one entry.
+func (l *ErrorList) Sort() {
+	sort.Sort(*l)
+
+	out := (*l)[:0:0]
+
+	for i, e := range *l {
+		if i > 0 && *e == *(*l)[i-1] {
+			continue
+		}
+
+		out = append(out, e)
+	}
+
+	*l = out
+}
+
+// Error satisfies the error interface, summarizing every entry in the
+// list.
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+
+	msg := l[0].Error()
+	for _, e := range l[1:] {
+		msg += "\n" + e.Error()
+	}
+
+	return fmt.Sprintf("%d errors:\n%s", len(l), msg)
+}
@@ -1,7 +1,12 @@
 package lexer
 
 import (
+	"fmt"
+	"io"
+	"strings"
 	"unicode"
+
+	"github.com/conneroisu/gix/internal/token"
 )
 
 // Lexer represents a lexical analyzer for Nix expressions.
@@ -13,7 +18,64 @@ type Lexer struct {
 	readPosition int    // Current reading position in input (after current char)
 	ch           byte   // Current char under examination (0 for EOF)
 	line         int    // Current line number (1-based for user display)
-	column       int    // Current column number (0-based within line)
+	column       int    // Current column number (1-based for user display)
+
+	// file is non-nil when this lexer was created via NewFile, letting
+	// finish stamp every token with a token.Pos in addition to its
+	// Line/Column - see Token.Pos.
+	file *token.File
+
+	// modeStack tracks nested string literals and the interpolated
+	// expressions inside them. While the top frame is modeDQuote or
+	// modeIndent, NextToken scans literal string text instead of running
+	// its ordinary character switch; pushing a modeInterp frame for a
+	// "${" temporarily restores ordinary tokenization for the
+	// interpolation's own expression. See nextStringToken.
+	modeStack []stringMode
+
+	// errorHandler, if set via NewWithErrorHandler, is invoked alongside
+	// errors for every illegal-input condition NextToken encounters. errors
+	// accumulates the same reports regardless, so Err() works even when no
+	// handler was installed.
+	errorHandler ErrorHandler
+	errors       ErrorList
+
+	// mode, if non-zero, makes NextToken surface whitespace and/or
+	// comments as trivia attached to Token.Leading/Trailing instead of
+	// silently discarding them - see Mode and NewWithMode.
+	mode Mode
+	// doc mirrors the doc var scanSignificant tracks locally, except it
+	// has to survive across several NextToken calls when mode is set:
+	// trivia now comes back one piece at a time, so the doc comment seen
+	// two calls ago has to be remembered until the significant token it
+	// precedes is finally produced.
+	doc string
+	// pushedBack holds a token nextWithTrivia read one call too many -
+	// looking for same-line trailing trivia and finding the next
+	// significant token instead - so the following NextToken call returns
+	// it rather than re-scanning.
+	pushedBack *Token
+}
+
+// modeKind distinguishes the four contexts a lexer mode-stack frame can
+// represent.
+type modeKind byte
+
+const (
+	modeDQuote modeKind = iota // inside "..."
+	modeIndent                 // inside ''...''
+	modePath                   // inside a path literal that contains ${ ... }
+	modeInterp                 // inside a ${ ... } that one of the above opened
+)
+
+// stringMode is one frame of the lexer's mode stack. braceDepth is only
+// meaningful for a modeInterp frame: it counts ordinary "{"/"}" pairs
+// (an attrset literal, say) opened inside the interpolation, so the "}"
+// that actually closes the interpolation - the one seen while braceDepth
+// is back at zero - can be told apart from one that closes a nested set.
+type stringMode struct {
+	kind       modeKind
+	braceDepth int
 }
 
 // New creates a new lexer instance for the given input string.
@@ -31,6 +93,88 @@ func New(input string) *Lexer {
 	return l
 }
 
+// NewWithErrorHandler creates a new lexer like New, but additionally
+// invokes h, if non-nil, for every illegal-input condition encountered
+// while scanning - see ErrorHandler. Regardless of whether h is given,
+// those reports also accumulate in the ErrorList returned by Err.
+func NewWithErrorHandler(input string, h ErrorHandler) *Lexer {
+	l := New(input)
+	l.errorHandler = h
+
+	return l
+}
+
+// NewFile creates a lexer for the content of r, registering it with fset
+// under filename so every token it produces carries a token.Pos (see
+// Token.Pos) resolvable back to (filename, line, column) even once other
+// files - other imports - have been registered with the same fset.
+//
+// Despite reading from an io.Reader, NewFile buffers r's content in full
+// rather than truly streaming it a chunk at a time: several of the
+// lexer's scans (a "${" interpolation, a lookup path, a URI literal) look
+// an unbounded distance ahead of the current character, which needs
+// random access into the whole remaining input. Supporting incremental
+// chunk-by-chunk buffering under that requirement would need a larger
+// rewrite of those scans around a bounded lookahead window; until a real
+// need for lexing inputs too large to fit in memory arises, reading r
+// fully up front is the simpler and correct choice.
+func NewFile(filename string, fset *token.FileSet, r io.Reader) (*Lexer, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("lexer: reading %s: %w", filename, err)
+	}
+
+	input := string(data)
+
+	file := fset.AddFile(filename, len(input))
+	for i, ch := range input {
+		if ch == '\n' {
+			file.AddLine(i + 1)
+		}
+	}
+
+	l := New(input)
+	l.file = file
+
+	return l, nil
+}
+
+// NewWithMode creates a lexer like New, but with mode controlling which
+// trivia NextToken surfaces on Token.Leading/Trailing instead of
+// discarding - see Mode.
+func NewWithMode(input string, mode Mode) *Lexer {
+	l := New(input)
+	l.mode = mode
+
+	return l
+}
+
+// error records a scanning problem at pos, both in the lexer's own
+// ErrorList (see Err) and, if one was installed via NewWithErrorHandler,
+// by calling the error handler.
+func (l *Lexer) error(pos Position, msg string) {
+	l.errors.Add(pos, msg)
+
+	if l.errorHandler != nil {
+		l.errorHandler(pos, msg)
+	}
+}
+
+// Err returns a sorted, deduplicated ErrorList of every illegal-input
+// condition this lexer has reported so far, or nil if there have been
+// none. Callers that want every scanning problem in one pass - a parser
+// recovering from errors, an LSP - can call this once they're done
+// pulling tokens instead of stopping at the first TOKEN_ILLEGAL.
+func (l *Lexer) Err() error {
+	if len(l.errors) == 0 {
+		return nil
+	}
+
+	l.errors.Sort()
+
+	return l.errors
+}
+
 // readChar reads the next character and advances the lexer position.
 // This method implements the core character consumption mechanism:
 // 1. Sets ch to the character at readPosition (or 0 for EOF)
@@ -75,6 +219,18 @@ func (l *Lexer) peekChar() byte {
 	return l.input[l.readPosition]
 }
 
+// peekChar2 returns the character one past peekChar - two characters
+// ahead of l.ch - without consuming anything. The only lookahead in this
+// lexer that needs it is recognizing a "../" relative path literal,
+// where the "/" that confirms it comes two characters past the first ".".
+func (l *Lexer) peekChar2() byte {
+	if l.readPosition+1 >= len(l.input) {
+		return 0
+	}
+
+	return l.input[l.readPosition+1]
+}
+
 // skipWhitespace consumes and skips over all whitespace characters.
 // This includes spaces, tabs, newlines, and carriage returns.
 // Essential for clean tokenization by eliminating meaningless whitespace.
@@ -85,37 +241,68 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-// skipComment consumes and skips over comment text.
-// Handles both Nix comment styles:
+// skipComment consumes and skips over comment text, returning the text
+// of the comment's body when it was a doc comment (`/** ... */`, as
+// opposed to a plain `/* ... */`) so NextToken can attach it to the
+// token that follows. Handles both Nix comment styles:
 // 1. Single-line comments starting with '#' (until newline or EOF)
 // 2. Multi-line comments enclosed in /* ... */ (with proper nesting)
 //
-// Comments are completely ignored during tokenization, allowing clean
+// Comments are otherwise ignored during tokenization, allowing clean
 // separation of documentation from executable code.
-func (l *Lexer) skipComment() {
+func (l *Lexer) skipComment() (doc string, isDoc bool) {
 	if l.ch == '#' {
 		// Single-line comment: consume everything until newline or EOF
 		for l.ch != '\n' && l.ch != 0 {
 			l.readChar()
 		}
-	} else if l.ch == '/' && l.peekChar() == '*' {
+
+		return "", false
+	}
+
+	if l.ch == '/' && l.peekChar() == '*' {
+		startLine, startColumn, startOffset := l.line, l.column, l.position
+
 		// Multi-line comment: consume /* ... */ block
 		l.readChar() // Skip opening '/'
 		l.readChar() // Skip opening '*'
 
+		// A third leading '*' (and not a lone "/**/") marks a doc
+		// comment, following the same convention as Javadoc/JSDoc.
+		isDoc = l.ch == '*' && l.peekChar() != '/'
+		if isDoc {
+			l.readChar() // Skip the doc-marking '*'
+		}
+
+		start := l.position
+
 		// Consume all characters until we find the closing */
 		for l.ch != 0 {
 			// Check for the closing */ sequence
 			if l.ch == '*' && l.peekChar() == '/' {
+				if isDoc {
+					doc = strings.TrimSpace(l.input[start:l.position])
+				}
+
 				l.readChar() // Skip closing '*'
 				l.readChar() // Skip closing '/'
 
-				break
+				return doc, isDoc
 			}
 			// Continue consuming characters within the comment
 			l.readChar()
 		}
+
+		l.error(Position{Line: startLine, Column: startColumn, Offset: startOffset}, "unterminated block comment")
+
+		if isDoc {
+			doc = strings.TrimSpace(l.input[start:l.position])
+		}
+
+		return doc, isDoc
 	}
+
+	return "", false
 }
 
 // readIdentifier reads a complete identifier or keyword from the input.
@@ -169,40 +356,283 @@ func (l *Lexer) readNumber() (string, TokenType) {
 		}
 	}
 
+	// Check for a scientific-notation exponent (1e10, 1.5e-3, 2E+4).
+	// Requires at least one digit after the 'e'/'E' and an optional
+	// sign, so "1e" alone or "1ex" leaves the exponent untouched and
+	// "e"/"ex" get tokenized separately as their own identifier.
+	if l.ch == 'e' || l.ch == 'E' {
+		digitsFrom := l.readPosition
+		if digitsFrom < len(l.input) && (l.input[digitsFrom] == '+' || l.input[digitsFrom] == '-') {
+			digitsFrom++
+		}
+
+		if digitsFrom < len(l.input) && isDigit(l.input[digitsFrom]) {
+			tokenType = TOKEN_FLOAT
+			l.readChar() // Consume 'e'/'E'
+
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar() // Consume the sign
+			}
+
+			for isDigit(l.ch) {
+				l.readChar()
+			}
+		}
+	}
+
 	// Return the complete number string and its determined type
 	return l.input[position:l.position], TokenType(tokenType)
 }
 
-// readString reads a complete string literal from the input.
-// Handles double-quoted strings with escape sequences:
-// - \" for literal quotes
-// - \n for newlines
-// - \\ for literal backslashes
-// - \t for tabs
-//
-// The function properly handles escaped characters and returns the
-// string content without the surrounding quotes.
-func (l *Lexer) readString() string {
-	// Skip the opening quote and mark the start of string content
-	position := l.position + 1
+// nextStringToken produces the next token while the lexer is inside a
+// "..." (kind == modeDQuote) or ''...'' (kind == modeIndent) literal: the
+// closing delimiter, a "${" that opens an interpolation, or a run of
+// literal text in between. It bypasses NextToken's ordinary whitespace
+// and comment skipping entirely, since both are significant inside a
+// string.
+func (l *Lexer) nextStringToken(kind modeKind) Token {
+	var tok Token
+	tok.Line = l.line
+	tok.Column = l.column
+	startOffset := l.position
 
-	for {
+	switch {
+	case l.ch == 0 && kind == modePath:
+		// Unlike a quoted string, a path literal has no closing
+		// delimiter to wait for - it simply ends wherever the path
+		// characters do, and running out of input counts as ending
+		// there too. Pop the mode and hand back a plain EOF for
+		// NextToken's ordinary EOF handling to pick up next.
+		l.modeStack = l.modeStack[:len(l.modeStack)-1]
+		tok.Type = TOKEN_EOF
+
+		return l.finish(tok, startOffset)
+
+	case l.ch == 0:
+		// Ran off the end of input before the closing delimiter. Leave
+		// the mode on the stack (rather than popping it) so IsComplete
+		// can tell this string never closed, and report plain EOF so
+		// the parser surfaces its own "unterminated string" error.
+		msg := "unterminated string"
+		if kind == modeIndent {
+			msg = "unterminated indented string"
+		}
+
+		l.error(Position{Line: tok.Line, Column: tok.Column, Offset: startOffset}, msg)
+
+		tok.Type = TOKEN_EOF
+
+		return l.finish(tok, startOffset)
+
+	case l.ch == '$' && l.peekChar() == '{':
+		l.readChar() // consume '$'
+		l.readChar() // consume '{'
+		l.modeStack = append(l.modeStack, stringMode{kind: modeInterp})
+		tok.Type = TOKEN_INTERP_START
+		tok.Literal = "${"
+
+		return l.finish(tok, startOffset)
+
+	case kind == modeDQuote && l.ch == '"':
+		l.modeStack = l.modeStack[:len(l.modeStack)-1]
 		l.readChar()
+		tok.Type = TOKEN_DQUOTE
+		tok.Literal = `"`
+
+		return l.finish(tok, startOffset)
 
-		// End of string: closing quote or unexpected EOF
+	case kind == modeIndent && l.ch == '\'' && l.peekChar() == '\'' && !l.isIndentEscape():
+		l.readChar() // consume first "'"
+		l.readChar() // consume second "'"
+		l.modeStack = l.modeStack[:len(l.modeStack)-1]
+		tok.Type = TOKEN_ISTRING_QUOTE
+		tok.Literal = "''"
+
+		return l.finish(tok, startOffset)
+	}
+
+	if kind == modePath {
+		lit, done := l.scanPathPart()
+		tok.Type = TOKEN_PATH_PART
+		tok.Literal = lit
+
+		if done {
+			l.modeStack = l.modeStack[:len(l.modeStack)-1]
+		}
+
+		return l.finish(tok, startOffset)
+	}
+
+	tok.Type = TOKEN_STR_PART
+	if kind == modeDQuote {
+		tok.Literal = l.scanDQuotePart()
+	} else {
+		tok.Literal = l.scanIndentPart()
+	}
+
+	return l.finish(tok, startOffset)
+}
+
+// scanDQuotePart consumes a run of literal text inside a "..." string,
+// decoding escape sequences as it goes, and stops (without consuming)
+// at the closing '"', a "${" interpolation, or EOF.
+func (l *Lexer) scanDQuotePart() string {
+	var sb strings.Builder
+
+	for {
 		if l.ch == '"' || l.ch == 0 {
-			break
+			return sb.String()
+		}
+
+		if l.ch == '$' && l.peekChar() == '{' {
+			return sb.String()
 		}
 
-		// Handle escape sequences: consume the escape character and the next character
 		if l.ch == '\\' {
-			l.readChar() // Skip the backslash
-			// The next character (if any) is consumed in the next iteration
+			l.readChar() // consume the backslash
+
+			switch l.ch {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case '\\':
+				sb.WriteByte('\\')
+			case '"':
+				sb.WriteByte('"')
+			case '$':
+				// "\${" escapes the whole antiquotation marker so it's
+				// kept literal instead of opening an interpolation;
+				// a lone "\$" not followed by '{' just means '$'.
+				if l.peekChar() == '{' {
+					sb.WriteString("${")
+					l.readChar()
+				} else {
+					sb.WriteByte('$')
+				}
+			case 0:
+				return sb.String()
+			default:
+				// Not a recognized escape - Nix passes the character
+				// through unchanged and drops the backslash.
+				sb.WriteByte(l.ch)
+			}
+
+			l.readChar()
+
+			continue
 		}
+
+		sb.WriteByte(l.ch)
+		l.readChar()
 	}
+}
 
-	// Return the string content (excluding surrounding quotes)
-	return l.input[position:l.position]
+// isIndentEscape reports whether the "''" at the lexer's current
+// position is one of the indented string's three escapes (''$, ''\,
+// or ''') rather than the string's closing delimiter, by looking one
+// character past the pair.
+func (l *Lexer) isIndentEscape() bool {
+	if l.readPosition+1 >= len(l.input) {
+		return false
+	}
+
+	switch l.input[l.readPosition+1] {
+	case '$', '\'', '\\':
+		return true
+	default:
+		return false
+	}
+}
+
+// scanIndentPart consumes a run of literal text inside a ''...''
+// string, decoding its three escapes - ''$ for a literal '$', ''' for a
+// literal "''", and ''\X for the same escapes scanDQuotePart decodes
+// after a bare backslash - and stops (without consuming) at the closing
+// "''", a "${" interpolation, or EOF. Unlike scanDQuotePart, it does not
+// strip the common leading whitespace indented strings are supposed to
+// have removed: that requires seeing every line of the literal at once,
+// so it's done once as a whole by the parser after it has assembled all
+// of a literal's TOKEN_STR_PART fragments (see dedentStringParts).
+func (l *Lexer) scanIndentPart() string {
+	var sb strings.Builder
+
+	for {
+		if l.ch == 0 {
+			return sb.String()
+		}
+
+		if l.ch == '$' && l.peekChar() == '{' {
+			return sb.String()
+		}
+
+		if l.ch == '\'' && l.peekChar() == '\'' {
+			if !l.isIndentEscape() {
+				return sb.String()
+			}
+
+			switch l.input[l.readPosition+1] {
+			case '$':
+				sb.WriteByte('$')
+				l.readChar()
+				l.readChar()
+				l.readChar()
+			case '\'':
+				sb.WriteString("''")
+				l.readChar()
+				l.readChar()
+				l.readChar()
+			case '\\':
+				l.readChar() // consume first "'"
+				l.readChar() // consume second "'"
+				l.readChar() // consume the backslash; l.ch is now the escaped char
+
+				switch l.ch {
+				case 'n':
+					sb.WriteByte('\n')
+				case 't':
+					sb.WriteByte('\t')
+				case 'r':
+					sb.WriteByte('\r')
+				case 0:
+					return sb.String()
+				default:
+					sb.WriteByte(l.ch)
+				}
+
+				l.readChar()
+			}
+
+			continue
+		}
+
+		sb.WriteByte(l.ch)
+		l.readChar()
+	}
+}
+
+// scanPath produces the token (or first of a stream of tokens) for a
+// path literal starting at l.ch, called from scanSignificant once it has
+// confirmed l.ch begins one (a leading "/", "./", or "../"). A path with
+// no "${ ... }" interpolation in it is read whole as a single TOKEN_PATH,
+// same as before this function existed; one that does have an
+// interpolation is handed off to nextStringToken the same way a "${"
+// inside a string is, via a modePath mode-stack frame, so it comes back
+// as a stream of TOKEN_PATH_PART/TOKEN_INTERP_START/TOKEN_INTERP_END
+// tokens instead.
+func (l *Lexer) scanPath(tok Token, startOffset int) Token {
+	if !l.pathHasInterp() {
+		tok.Type = TOKEN_PATH
+		tok.Literal = l.readPath()
+
+		return l.finish(tok, startOffset)
+	}
+
+	l.modeStack = append(l.modeStack, stringMode{kind: modePath})
+
+	return l.nextStringToken(modePath)
 }
 
 // readPath reads a complete path literal from the input.
@@ -222,9 +652,162 @@ func (l *Lexer) readPath() string {
 	return l.input[position:l.position]
 }
 
-// NextToken returns the next token from the input stream.
-// This is the main entry point for tokenization, implementing a single-pass
-// scanner that recognizes all Nix language tokens.
+// pathHasInterp reports whether the path literal starting at l.ch
+// contains a "${ ... }" interpolation before it runs out of path
+// characters, without consuming any input. Callers use this to decide
+// between the fast single-token path (readPath, TOKEN_PATH) and the
+// streaming modePath mode that nextStringToken drives a token at a time.
+func (l *Lexer) pathHasInterp() bool {
+	i := l.position
+	for i < len(l.input) {
+		if l.input[i] == '$' && i+1 < len(l.input) && l.input[i+1] == '{' {
+			return true
+		}
+		if !isPathChar(l.input[i]) {
+			return false
+		}
+		i++
+	}
+
+	return false
+}
+
+// scanPathPart consumes a run of literal path text, stopping (without
+// consuming) at a "${" interpolation or the first character that isn't
+// a valid path character - including EOF. done reports whether that
+// stop was the end of the path (true) rather than a "${" to hand off to
+// an interpolated expression (false). Unlike scanDQuotePart, there are
+// no escape sequences to decode: path literals don't support them.
+func (l *Lexer) scanPathPart() (lit string, done bool) {
+	position := l.position
+
+	for {
+		if l.ch == '$' && l.peekChar() == '{' {
+			return l.input[position:l.position], false
+		}
+
+		if !isPathChar(l.ch) {
+			return l.input[position:l.position], true
+		}
+
+		l.readChar()
+	}
+}
+
+// scanLookupPath reports whether a lookup-path literal ("<nixpkgs>",
+// "<nixpkgs/lib>") starts at l.ch == '<', consuming and returning it if
+// so. It only recognizes the shape as a lookup path when a run of valid
+// path characters starting with a letter is immediately closed by a
+// '>' - anything else (bare "<", "<=", "<3" as a malformed comparison,
+// an unclosed "<foo") is left untouched for the ordinary "<"/"<=" cases
+// to handle instead.
+func (l *Lexer) scanLookupPath() (string, bool) {
+	i := l.readPosition
+	if i >= len(l.input) || !isLetter(l.input[i]) {
+		return "", false
+	}
+
+	for i < len(l.input) && isPathChar(l.input[i]) {
+		i++
+	}
+
+	if i >= len(l.input) || l.input[i] != '>' {
+		return "", false
+	}
+
+	literal := l.input[l.position : i+1]
+
+	for l.position <= i {
+		l.readChar()
+	}
+
+	return literal, true
+}
+
+// isSchemeChar reports whether ch can appear in a URI literal's scheme,
+// the part before its ':' - a letter, digit, '+', '-', or '.'. The
+// scheme's first character must additionally be a letter, which callers
+// check separately before scanning the rest.
+func isSchemeChar(ch byte) bool {
+	return isLetter(ch) || isDigit(ch) || ch == '+' || ch == '-' || ch == '.'
+}
+
+// isURIBodyChar reports whether ch can appear after a URI literal's
+// "scheme://", per RFC 3986's unreserved and sub-delim character sets
+// (except the sub-delims "(", ")" and ";", which end the literal) plus
+// the handful of delimiters ("/", "?", ":", "@") URIs commonly use
+// unescaped.
+func isURIBodyChar(ch byte) bool {
+	switch ch {
+	case '%', '/', '?', ':', '@', '&', '=', '+', '$', ',', '_', '.', '!', '~', '*', '\'', '-':
+		return true
+	}
+
+	return isLetter(ch) || isDigit(ch)
+}
+
+// scanURI reports whether an unquoted URI literal (e.g.
+// "https://example.com/foo.tar.gz") starts at l.ch, consuming and
+// returning it if so. l.ch must already be known to be a letter - the
+// only valid first character of a scheme - before calling this. Requiring
+// the scheme to be followed by "://" (rather than just ":") is what lets
+// this tell a URI apart from a function pattern like "attr: expr": the
+// latter's colon is never immediately followed by two slashes.
+func (l *Lexer) scanURI() (string, bool) {
+	i := l.readPosition
+	for i < len(l.input) && isSchemeChar(l.input[i]) {
+		i++
+	}
+
+	if i+2 >= len(l.input) || l.input[i] != ':' || l.input[i+1] != '/' || l.input[i+2] != '/' {
+		return "", false
+	}
+
+	j := i + 3
+	for j < len(l.input) && isURIBodyChar(l.input[j]) {
+		j++
+	}
+
+	literal := l.input[l.position:j]
+
+	for l.position < j {
+		l.readChar()
+	}
+
+	return literal, true
+}
+
+// NextToken returns the next token from the input stream. This is the
+// main entry point for tokenization.
+//
+// A lexer created with the default Mode (New, NewWithErrorHandler,
+// NewFile) calls straight through to scanSignificant, unchanged from
+// before Mode existed. One created with NewWithMode instead goes through
+// nextWithTrivia, which surfaces whitespace and/or comments as trivia
+// attached to Token.Leading/Trailing around the same significant tokens
+// scanSignificant would otherwise return on its own - see Mode.
+func (l *Lexer) NextToken() Token {
+	if l.mode != 0 {
+		return l.nextWithTrivia()
+	}
+
+	// Inside a string literal's text, delegate entirely to
+	// nextStringToken: whitespace and comments aren't meaningful there,
+	// so none of the usual skipping or the character switch below
+	// applies. A modeInterp frame on top, by contrast, means we're back
+	// inside an interpolated expression's own tokens, so ordinary
+	// tokenization resumes.
+	if n := len(l.modeStack); n > 0 {
+		if top := l.modeStack[n-1]; top.kind != modeInterp {
+			return l.nextStringToken(top.kind)
+		}
+	}
+
+	return l.scanSignificant()
+}
+
+// scanSignificant recognizes and returns the next significant (non-trivia)
+// token, assuming the caller has already handled any active string mode.
 //
 // The tokenization process:
 // 1. Skip whitespace and comments
@@ -232,24 +815,36 @@ func (l *Lexer) readPath() string {
 // 3. Recognize token type based on current character
 // 4. Apply maximal munch for multi-character tokens
 // 5. Return complete token with type, literal, and position.
-func (l *Lexer) NextToken() Token {
+func (l *Lexer) scanSignificant() Token {
 	var tok Token
 
-	// Skip all non-significant characters (whitespace and comments)
+	// Skip all non-significant characters (whitespace and comments),
+	// remembering the most recent doc comment seen - it's only
+	// attached to the token if nothing but whitespace and further
+	// comments separate them, and a non-doc comment in between clears
+	// it, matching Javadoc-style "immediately preceding" semantics.
+	var doc string
 	for {
 		l.skipWhitespace()
 		// Check for comment start patterns
 		if l.ch == '#' || (l.ch == '/' && l.peekChar() == '*') {
-			l.skipComment()
+			text, isDoc := l.skipComment()
+			if isDoc {
+				doc = text
+			} else {
+				doc = ""
+			}
 		} else {
 			// No more whitespace or comments, ready to tokenize
 			break
 		}
 	}
+	tok.Doc = doc
 
 	// Capture current position for this token (essential for error reporting)
 	tok.Line = l.line
 	tok.Column = l.column
+	startOffset := l.position
 
 	// Token recognition switch: each case handles a specific character or character sequence
 	// Multi-character operators use lookahead to distinguish similar patterns
@@ -292,8 +887,19 @@ func (l *Lexer) NextToken() Token {
 		// "*" multiplication operator
 		tok = Token{Type: TOKEN_MULTIPLY, Literal: "*", Line: tok.Line, Column: tok.Column}
 	case '/':
-		// "/" division operator (also used for paths, handled in default case)
-		tok = Token{Type: TOKEN_DIVIDE, Literal: "/", Line: tok.Line, Column: tok.Column}
+		if unicode.IsLetter(rune(l.peekChar())) {
+			// Absolute path literal: "/" followed by a letter, as
+			// opposed to a division operator.
+			return l.scanPath(tok, startOffset)
+		}
+		if l.peekChar() == '/' {
+			// "//" attribute set update/merge operator
+			l.readChar()
+			tok = Token{Type: TOKEN_UPDATE, Literal: "//", Line: tok.Line, Column: tok.Column}
+		} else {
+			// "/" division operator
+			tok = Token{Type: TOKEN_DIVIDE, Literal: "/", Line: tok.Line, Column: tok.Column}
+		}
 
 	// Logical NOT and inequality operators
 	case '!':
@@ -308,7 +914,14 @@ func (l *Lexer) NextToken() Token {
 
 	// Less-than comparison operators
 	case '<':
-		if l.peekChar() == '=' {
+		if literal, ok := l.scanLookupPath(); ok {
+			// "<nixpkgs>" / "<nixpkgs/lib>" lookup-path literal. Only the
+			// lexer knows this token: pkg/parser has no parse function for
+			// TOKEN_SPATH, and nothing resolves it against NIX_PATH.
+			tok = Token{Type: TOKEN_SPATH, Literal: literal, Line: tok.Line, Column: tok.Column, Doc: doc}
+
+			return l.finish(tok, startOffset)
+		} else if l.peekChar() == '=' {
 			// "<=" less-than-or-equal comparison operator
 			l.readChar()
 			tok = Token{Type: TOKEN_LTE, Literal: "<=", Line: tok.Line, Column: tok.Column}
@@ -336,6 +949,7 @@ func (l *Lexer) NextToken() Token {
 			tok = Token{Type: TOKEN_AND_OP, Literal: "&&", Line: tok.Line, Column: tok.Column}
 		} else {
 			// Single "&" is not valid in Nix
+			l.error(Position{Line: tok.Line, Column: tok.Column, Offset: startOffset}, "unexpected '&', did you mean '&&'?")
 			tok = Token{Type: TOKEN_ILLEGAL, Literal: "&", Line: tok.Line, Column: tok.Column}
 		}
 
@@ -347,6 +961,7 @@ func (l *Lexer) NextToken() Token {
 			tok = Token{Type: TOKEN_OR_OP, Literal: "||", Line: tok.Line, Column: tok.Column}
 		} else {
 			// Single "|" is not valid in Nix
+			l.error(Position{Line: tok.Line, Column: tok.Column, Offset: startOffset}, "unexpected '|', did you mean '||'?")
 			tok = Token{Type: TOKEN_ILLEGAL, Literal: "|", Line: tok.Line, Column: tok.Column}
 		}
 
@@ -354,7 +969,16 @@ func (l *Lexer) NextToken() Token {
 	case '?':
 		// "?" attribute existence test operator
 		tok = Token{Type: TOKEN_QUESTION, Literal: "?", Line: tok.Line, Column: tok.Column}
+	case '@':
+		// "@" binds the whole argument alongside an attrset pattern,
+		// e.g. "{ a, b }@args: ..." or "args@{ a, b }: ..."
+		tok = Token{Type: TOKEN_AT, Literal: "@", Line: tok.Line, Column: tok.Column}
 	case '.':
+		if l.peekChar() == '/' || (l.peekChar() == '.' && l.peekChar2() == '/') {
+			// Relative path literal: "./" or "../", as opposed to the
+			// attribute selection operator.
+			return l.scanPath(tok, startOffset)
+		}
 		// "." attribute selection operator
 		tok = Token{Type: TOKEN_DOT, Literal: ".", Line: tok.Line, Column: tok.Column}
 
@@ -377,11 +1001,28 @@ func (l *Lexer) NextToken() Token {
 		// ")" right parenthesis
 		tok = Token{Type: TOKEN_RPAREN, Literal: ")", Line: tok.Line, Column: tok.Column}
 	case '{':
-		// "{" left brace for attribute sets
+		// "{" left brace for attribute sets. Inside an interpolation,
+		// also track it as nesting so the matching "}" is recognized as
+		// closing this set rather than the interpolation itself.
+		if n := len(l.modeStack); n > 0 && l.modeStack[n-1].kind == modeInterp {
+			l.modeStack[n-1].braceDepth++
+		}
 		tok = Token{Type: TOKEN_LBRACE, Literal: "{", Line: tok.Line, Column: tok.Column}
 	case '}':
-		// "}" right brace
-		tok = Token{Type: TOKEN_RBRACE, Literal: "}", Line: tok.Line, Column: tok.Column}
+		// "}" right brace, unless it's closing a "${" interpolation:
+		// the mode stack disambiguates a nested set's own "}" (braceDepth
+		// still above zero) from the one that ends the interpolation.
+		if n := len(l.modeStack); n > 0 && l.modeStack[n-1].kind == modeInterp {
+			if l.modeStack[n-1].braceDepth > 0 {
+				l.modeStack[n-1].braceDepth--
+				tok = Token{Type: TOKEN_RBRACE, Literal: "}", Line: tok.Line, Column: tok.Column}
+			} else {
+				l.modeStack = l.modeStack[:n-1]
+				tok = Token{Type: TOKEN_INTERP_END, Literal: "}", Line: tok.Line, Column: tok.Column}
+			}
+		} else {
+			tok = Token{Type: TOKEN_RBRACE, Literal: "}", Line: tok.Line, Column: tok.Column}
+		}
 	case '[':
 		// "[" left bracket for lists
 		tok = Token{Type: TOKEN_LBRACKET, Literal: "[", Line: tok.Line, Column: tok.Column}
@@ -389,11 +1030,22 @@ func (l *Lexer) NextToken() Token {
 		// "]" right bracket
 		tok = Token{Type: TOKEN_RBRACKET, Literal: "]", Line: tok.Line, Column: tok.Column}
 
-	// String literals
+	// String literals: both kinds are pushed onto the mode stack and
+	// scanned a token at a time by nextStringToken (see NextToken's entry
+	// check above), not read in full here.
 	case '"':
-		// String literal: delegate to readString() for proper escape handling
-		tok.Type = TOKEN_STRING
-		tok.Literal = l.readString()
+		l.modeStack = append(l.modeStack, stringMode{kind: modeDQuote})
+		tok = Token{Type: TOKEN_DQUOTE, Literal: `"`, Line: tok.Line, Column: tok.Column}
+	case '\'':
+		if l.peekChar() == '\'' {
+			l.readChar() // consume second "'"
+			l.modeStack = append(l.modeStack, stringMode{kind: modeIndent})
+			tok = Token{Type: TOKEN_ISTRING_QUOTE, Literal: "''", Line: tok.Line, Column: tok.Column}
+		} else {
+			// A lone "'" isn't valid Nix syntax outside of "''".
+			l.error(Position{Line: tok.Line, Column: tok.Column, Offset: startOffset}, "unexpected \"'\", did you mean \"''\"?")
+			tok = Token{Type: TOKEN_ILLEGAL, Literal: "'", Line: tok.Line, Column: tok.Column}
+		}
 
 	// End of file
 	case 0:
@@ -404,29 +1056,55 @@ func (l *Lexer) NextToken() Token {
 	// Complex token recognition
 	default:
 		if isLetter(l.ch) {
+			if uri, ok := l.scanURI(); ok {
+				// "scheme://..." URI literal, tried before treating this
+				// as an ordinary identifier since both start the same way.
+				tok.Type = TOKEN_URI
+				tok.Literal = uri
+
+				return l.finish(tok, startOffset)
+			}
 			// Identifier or keyword: delegate to readIdentifier() and keyword lookup
 			tok.Literal = l.readIdentifier()
 			tok.Type = LookupIdent(tok.Literal)
 			// Early return: readIdentifier() already advanced the position
-			return tok
+			return l.finish(tok, startOffset)
 		} else if isDigit(l.ch) {
 			// Numeric literal: delegate to readNumber() for int/float detection
 			tok.Literal, tok.Type = l.readNumber()
 			// Early return: readNumber() already advanced the position
-			return tok
-		} else if l.ch == '/' && unicode.IsLetter(rune(l.peekChar())) {
-			// Path literal: "/" followed by letter indicates path, not division
-			tok.Type = TOKEN_PATH
-			tok.Literal = l.readPath()
-			// Early return: readPath() already advanced the position
-			return tok
+			return l.finish(tok, startOffset)
 		} else {
 			// Unrecognized character: mark as illegal for error reporting
+			l.error(Position{Line: tok.Line, Column: tok.Column, Offset: startOffset},
+				fmt.Sprintf("illegal character %q", l.ch))
 			tok = Token{Type: TOKEN_ILLEGAL, Literal: string(l.ch), Line: tok.Line, Column: tok.Column}
 		}
 	}
 
 	l.readChar()
 
+	// The switch cases above rebuild tok from scratch, dropping the Doc
+	// set before it.
+	tok.Doc = doc
+
+	return l.finish(tok, startOffset)
+}
+
+// finish stamps tok with its start offset and its end position (the line,
+// column, and byte offset immediately after the token's last character,
+// i.e. the lexer's current position), giving every token a full source
+// span rather than just a start point.
+func (l *Lexer) finish(tok Token, startOffset int) Token {
+	tok.Offset = startOffset
+	tok.EndLine = l.line
+	tok.EndColumn = l.column
+	tok.EndOffset = l.position
+
+	if l.file != nil {
+		tok.Pos = l.file.Pos(startOffset)
+		tok.EndPos = l.file.Pos(l.position)
+	}
+
 	return tok
 }
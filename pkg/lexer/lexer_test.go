@@ -1,7 +1,10 @@
 package lexer
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/conneroisu/gix/internal/token"
 )
 
 func TestNextToken(t *testing.T) {
@@ -33,9 +36,13 @@ else
 		{TOKEN_GT, ">"},
 		{TOKEN_IDENT, "y"},
 		{TOKEN_THEN, "then"},
-		{TOKEN_STRING, "x is greater"},
+		{TOKEN_DQUOTE, `"`},
+		{TOKEN_STR_PART, "x is greater"},
+		{TOKEN_DQUOTE, `"`},
 		{TOKEN_ELSE, "else"},
-		{TOKEN_STRING, "y is greater"},
+		{TOKEN_DQUOTE, `"`},
+		{TOKEN_STR_PART, "y is greater"},
+		{TOKEN_DQUOTE, `"`},
 		{TOKEN_EOF, ""},
 	}
 
@@ -128,15 +135,238 @@ func TestNumbers(t *testing.T) {
 	}
 }
 
+func TestNumberExponents(t *testing.T) {
+	input := "1e10 1.5e-3 2E+4 1e 1ex"
+
+	tests := []struct {
+		expectedType    TokenType
+		expectedLiteral string
+	}{
+		{TOKEN_FLOAT, "1e10"},
+		{TOKEN_FLOAT, "1.5e-3"},
+		{TOKEN_FLOAT, "2E+4"},
+		// "1e" has no digit after the 'e', so it's the integer "1"
+		// followed by the identifier "e".
+		{TOKEN_INT, "1"},
+		{TOKEN_IDENT, "e"},
+		// Likewise "1ex" is "1" followed by the identifier "ex".
+		{TOKEN_INT, "1"},
+		{TOKEN_IDENT, "ex"},
+		{TOKEN_EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestLookupPaths(t *testing.T) {
+	input := "<nixpkgs> <nixpkgs/lib> 1 < 2"
+
+	tests := []struct {
+		expectedType    TokenType
+		expectedLiteral string
+	}{
+		{TOKEN_SPATH, "<nixpkgs>"},
+		{TOKEN_SPATH, "<nixpkgs/lib>"},
+		{TOKEN_INT, "1"},
+		{TOKEN_LT, "<"},
+		{TOKEN_INT, "2"},
+		{TOKEN_EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestPathLiterals(t *testing.T) {
+	// "1 / 2" keeps its spaces: a bare "/" next to a digit is always
+	// division, since a path's first character must be a letter, ".",
+	// or another "/" - never a digit - so there's no ambiguity to guard
+	// against here the way there is for, say, an identifier immediately
+	// followed by "/".
+	input := "/etc/nixos ./file.nix ../lib 1 / 2 1.0"
+
+	tests := []struct {
+		expectedType    TokenType
+		expectedLiteral string
+	}{
+		{TOKEN_PATH, "/etc/nixos"},
+		{TOKEN_PATH, "./file.nix"},
+		{TOKEN_PATH, "../lib"},
+		{TOKEN_INT, "1"},
+		{TOKEN_DIVIDE, "/"},
+		{TOKEN_INT, "2"},
+		{TOKEN_FLOAT, "1.0"},
+		{TOKEN_EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestPathInterpolation(t *testing.T) {
+	input := "./foo/${name}.nix"
+
+	tests := []struct {
+		expectedType    TokenType
+		expectedLiteral string
+	}{
+		{TOKEN_PATH_PART, "./foo/"},
+		{TOKEN_INTERP_START, "${"},
+		{TOKEN_IDENT, "name"},
+		{TOKEN_INTERP_END, "}"},
+		{TOKEN_PATH_PART, ".nix"},
+		{TOKEN_EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestURIs(t *testing.T) {
+	input := "https://example.com/foo.tar.gz a: a+1"
+
+	tests := []struct {
+		expectedType    TokenType
+		expectedLiteral string
+	}{
+		{TOKEN_URI, "https://example.com/foo.tar.gz"},
+		{TOKEN_IDENT, "a"},
+		{TOKEN_COLON, ":"},
+		{TOKEN_IDENT, "a"},
+		{TOKEN_PLUS, "+"},
+		{TOKEN_INT, "1"},
+		{TOKEN_EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
 func TestStrings(t *testing.T) {
-	input := `"hello world" "escaped \"quote\""`
+	input := `"hello world" "escaped \"quote\"" "a\nb" "drop \q"`
+
+	tests := []struct {
+		expectedType    TokenType
+		expectedLiteral string
+	}{
+		{TOKEN_DQUOTE, `"`},
+		{TOKEN_STR_PART, "hello world"},
+		{TOKEN_DQUOTE, `"`},
+
+		{TOKEN_DQUOTE, `"`},
+		{TOKEN_STR_PART, `escaped "quote"`},
+		{TOKEN_DQUOTE, `"`},
+
+		{TOKEN_DQUOTE, `"`},
+		{TOKEN_STR_PART, "a\nb"},
+		{TOKEN_DQUOTE, `"`},
+
+		{TOKEN_DQUOTE, `"`},
+		// An unrecognized escape passes the character through and drops
+		// the backslash.
+		{TOKEN_STR_PART, "drop q"},
+		{TOKEN_DQUOTE, `"`},
+
+		{TOKEN_EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	input := `"hello ${name}!"`
 
 	tests := []struct {
 		expectedType    TokenType
 		expectedLiteral string
 	}{
-		{TOKEN_STRING, "hello world"},
-		{TOKEN_STRING, "escaped \\\"quote\\\""},
+		{TOKEN_DQUOTE, `"`},
+		{TOKEN_STR_PART, "hello "},
+		{TOKEN_INTERP_START, "${"},
+		{TOKEN_IDENT, "name"},
+		{TOKEN_INTERP_END, "}"},
+		{TOKEN_STR_PART, "!"},
+		{TOKEN_DQUOTE, `"`},
 		{TOKEN_EOF, ""},
 	}
 
@@ -157,6 +387,105 @@ func TestStrings(t *testing.T) {
 	}
 }
 
+func TestStringInterpolationWithNestedSet(t *testing.T) {
+	// The "}" closing the nested attrset must stay TOKEN_RBRACE - only
+	// the final "}", seen while the interpolation's own brace depth is
+	// back at zero, should close the interpolation.
+	input := `"${ { a = 1; }.a }"`
+
+	tests := []struct {
+		expectedType    TokenType
+		expectedLiteral string
+	}{
+		{TOKEN_DQUOTE, `"`},
+		{TOKEN_INTERP_START, "${"},
+		{TOKEN_LBRACE, "{"},
+		{TOKEN_IDENT, "a"},
+		{TOKEN_ASSIGN, "="},
+		{TOKEN_INT, "1"},
+		{TOKEN_SEMICOLON, ";"},
+		{TOKEN_RBRACE, "}"},
+		{TOKEN_DOT, "."},
+		{TOKEN_IDENT, "a"},
+		{TOKEN_INTERP_END, "}"},
+		{TOKEN_DQUOTE, `"`},
+		{TOKEN_EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestIndentedStrings(t *testing.T) {
+	input := "''foo\n  bar'' ''a'''b'' ''$${dollar}''"
+
+	tests := []struct {
+		expectedType    TokenType
+		expectedLiteral string
+	}{
+		{TOKEN_ISTRING_QUOTE, "''"},
+		{TOKEN_STR_PART, "foo\n  bar"},
+		{TOKEN_ISTRING_QUOTE, "''"},
+
+		// "'''" in the middle is the escape for a literal "''".
+		{TOKEN_ISTRING_QUOTE, "''"},
+		{TOKEN_STR_PART, "a''b"},
+		{TOKEN_ISTRING_QUOTE, "''"},
+
+		{TOKEN_ISTRING_QUOTE, "''"},
+		{TOKEN_STR_PART, "$"},
+		{TOKEN_INTERP_START, "${"},
+		{TOKEN_IDENT, "dollar"},
+		{TOKEN_INTERP_END, "}"},
+		{TOKEN_ISTRING_QUOTE, "''"},
+
+		{TOKEN_EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestUnterminatedInterpolationIsIncomplete(t *testing.T) {
+	if IsComplete(`"hello ${name`) {
+		t.Fatalf("expected an unterminated interpolation to be incomplete")
+	}
+
+	if !IsComplete(`"hello ${name}"`) {
+		t.Fatalf("expected a terminated interpolation to be complete")
+	}
+
+	if IsComplete("''unterminated indented string") {
+		t.Fatalf("expected an unterminated indented string to be incomplete")
+	}
+}
+
 func TestKeywords(t *testing.T) {
 	input := "if then else let in with assert or and not rec inherit true false null"
 
@@ -239,3 +568,272 @@ let y = 10;`
 		}
 	}
 }
+
+func TestDocComments(t *testing.T) {
+	input := `/** Adds one to x. */
+x: x + 1`
+
+	l := New(input)
+	tok := l.NextToken()
+
+	if tok.Type != TOKEN_IDENT || tok.Literal != "x" {
+		t.Fatalf("expected ident 'x', got type=%q literal=%q", tok.Type, tok.Literal)
+	}
+
+	if tok.Doc != "Adds one to x." {
+		t.Fatalf("expected doc %q, got %q", "Adds one to x.", tok.Doc)
+	}
+}
+
+func TestPlainCommentIsNotDoc(t *testing.T) {
+	input := `/* not a doc comment */
+x: x + 1`
+
+	l := New(input)
+	tok := l.NextToken()
+
+	if tok.Doc != "" {
+		t.Fatalf("expected no doc comment, got %q", tok.Doc)
+	}
+}
+
+func TestIsComplete(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{"simple expression", "1 + 2", true},
+		{"balanced parens", "(1 + 2)", true},
+		{"unclosed paren", "(1 + 2", false},
+		{"unclosed brace", "{ a = 1;", false},
+		{"balanced brace", "{ a = 1; }", true},
+		{"unclosed bracket", "[ 1 2", false},
+		{"let without in", "let x = 1;", false},
+		{"let with in", "let x = 1; in x", true},
+		{"nested let", "let x = let y = 1; in y; in x", true},
+		{"terminated string", `"hello"`, true},
+		{"unterminated string", `"hello`, false},
+		{"unterminated string with escape", `"hello\"`, false},
+		{"string containing brackets", `"{ ["`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsComplete(tt.input); got != tt.want {
+				t.Errorf("IsComplete(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorHandlerReportsIllegalInput(t *testing.T) {
+	var got []Position
+
+	l := NewWithErrorHandler(`1 & 2`, func(pos Position, _ string) {
+		got = append(got, pos)
+	})
+
+	for {
+		tok := l.NextToken()
+		if tok.Type == TOKEN_EOF {
+			break
+		}
+	}
+
+	if len(got) != 1 || got[0].Line != 1 || got[0].Column != 3 {
+		t.Fatalf("expected one error at 1:3, got %v", got)
+	}
+
+	err := l.Err()
+	if err == nil {
+		t.Fatal("expected Err() to report the illegal '&'")
+	}
+}
+
+func TestErrWithoutHandlerStillAccumulates(t *testing.T) {
+	l := New("1 | 2")
+
+	for {
+		tok := l.NextToken()
+		if tok.Type == TOKEN_EOF {
+			break
+		}
+	}
+
+	if err := l.Err(); err == nil {
+		t.Fatal("expected Err() to report the illegal '|' even with no handler installed")
+	}
+}
+
+func TestErrNilWhenNoProblems(t *testing.T) {
+	l := New("1 + 2")
+
+	for {
+		tok := l.NextToken()
+		if tok.Type == TOKEN_EOF {
+			break
+		}
+	}
+
+	if err := l.Err(); err != nil {
+		t.Fatalf("expected Err() to be nil, got %v", err)
+	}
+}
+
+func TestErrReportsUnterminatedStringAndComment(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		input string
+	}{
+		{"unterminated double-quoted string", `"hello`},
+		{"unterminated indented string", `''hello`},
+		{"unterminated block comment", `/* hello`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(tt.input)
+
+			for {
+				tok := l.NextToken()
+				if tok.Type == TOKEN_EOF {
+					break
+				}
+			}
+
+			if err := l.Err(); err == nil {
+				t.Fatalf("expected Err() to report a problem for %q", tt.input)
+			}
+		})
+	}
+}
+
+func TestNewFileTracksPosAcrossFiles(t *testing.T) {
+	fset := token.NewFileSet()
+
+	a, err := NewFile("a.nix", fset, strings.NewReader("1 +\n2"))
+	if err != nil {
+		t.Fatalf("NewFile(a.nix): %v", err)
+	}
+
+	b, err := NewFile("b.nix", fset, strings.NewReader("3"))
+	if err != nil {
+		t.Fatalf("NewFile(b.nix): %v", err)
+	}
+
+	a.NextToken() // "1"
+	a.NextToken() // "+"
+	two := a.NextToken()
+
+	if two.Literal != "2" {
+		t.Fatalf("expected literal \"2\", got %q", two.Literal)
+	}
+
+	pos := fset.Position(two.Pos)
+	if pos.Filename != "a.nix" || pos.Line != 2 || pos.Column != 0 {
+		t.Fatalf("expected a.nix:2:0, got %s", pos)
+	}
+
+	three := b.NextToken()
+
+	bPos := fset.Position(three.Pos)
+	if bPos.Filename != "b.nix" || bPos.Line != 1 || bPos.Column != 0 {
+		t.Fatalf("expected b.nix:1:0, got %s", bPos)
+	}
+
+	// A Pos from one file resolved against the other file's own File
+	// would silently misreport rather than erroring (that's why
+	// FileSet.Position searches every registered file), so also check the
+	// FileSet itself tells the two tokens' positions apart.
+	if pos.Filename == bPos.Filename {
+		t.Fatalf("expected distinct filenames, got %q for both", pos.Filename)
+	}
+}
+
+func TestNewFileWithPlainNewLeavesPosZero(t *testing.T) {
+	l := New("1 + 2")
+
+	tok := l.NextToken()
+	if tok.Pos != token.NoPos {
+		t.Fatalf("expected NoPos from a plain New lexer, got %v", tok.Pos)
+	}
+}
+
+func TestDefaultModeLeavesLeadingTrailingNil(t *testing.T) {
+	l := New("  1 + 2 # sum\n")
+
+	tok := l.NextToken()
+	if tok.Leading != nil || tok.Trailing != nil {
+		t.Fatalf("expected nil Leading/Trailing from a plain New lexer, got %v / %v", tok.Leading, tok.Trailing)
+	}
+}
+
+// reconstruct concatenates every token's Leading, its own Literal, and its
+// Trailing, in order, which should reproduce the original source exactly
+// when both ScanComments and ScanWhitespace are requested and no string
+// literal uses an escape.
+func reconstruct(l *Lexer) string {
+	var sb strings.Builder
+
+	for {
+		tok := l.NextToken()
+
+		for _, t := range tok.Leading {
+			sb.WriteString(t.Literal)
+		}
+
+		sb.WriteString(tok.Literal)
+
+		for _, t := range tok.Trailing {
+			sb.WriteString(t.Literal)
+		}
+
+		if tok.Type == TOKEN_EOF {
+			return sb.String()
+		}
+	}
+}
+
+func TestNewWithModeReconstructsSourceByteForByte(t *testing.T) {
+	inputs := []string{
+		"  1 + 2 # sum\n",
+		"let x = 1; in\n  x",
+		"/* not a doc */\nx",
+		"",
+		"x # trailing comment, no trailing newline",
+	}
+
+	for _, input := range inputs {
+		l := NewWithMode(input, ScanComments|ScanWhitespace)
+
+		if got := reconstruct(l); got != input {
+			t.Fatalf("reconstruct(%q) = %q", input, got)
+		}
+	}
+}
+
+func TestNewWithModeOneFlagOnlyCoversThatTrivia(t *testing.T) {
+	l := NewWithMode("1 #c\n2", ScanWhitespace)
+
+	one := l.NextToken()
+	for _, tok := range append(append([]Token{}, one.Leading...), one.Trailing...) {
+		if tok.Type == TOKEN_LINE_COMMENT {
+			t.Fatalf("expected comments to stay unscanned when only ScanWhitespace is set")
+		}
+	}
+}
+
+func TestNewWithModeDocCommentStillAttached(t *testing.T) {
+	input := `/** Adds one to x. */
+x: x + 1`
+
+	l := NewWithMode(input, ScanComments|ScanWhitespace)
+	tok := l.NextToken()
+
+	if tok.Type != TOKEN_IDENT || tok.Literal != "x" {
+		t.Fatalf("expected ident 'x', got type=%q literal=%q", tok.Type, tok.Literal)
+	}
+
+	if tok.Doc != "Adds one to x." {
+		t.Fatalf("expected doc %q, got %q", "Adds one to x.", tok.Doc)
+	}
+}
@@ -0,0 +1,37 @@
+package lexer
+
+// IsComplete reports whether src forms a syntactically complete Nix
+// expression: every (), [], {} is balanced, every `let` has a matching
+// `in`, and src doesn't end in the middle of a string literal or
+// interpolation. Callers that accumulate input line by line (an
+// interactive REPL, say) can use this to decide whether to evaluate
+// what's been typed so far or prompt for another line.
+func IsComplete(src string) bool {
+	l := New(src)
+
+	depth := 0
+	letDepth := 0
+
+	for {
+		tok := l.NextToken()
+		if tok.Type == TOKEN_EOF {
+			break
+		}
+
+		switch tok.Type {
+		case TOKEN_LPAREN, TOKEN_LBRACE, TOKEN_LBRACKET:
+			depth++
+		case TOKEN_RPAREN, TOKEN_RBRACE, TOKEN_RBRACKET:
+			depth--
+		case TOKEN_LET:
+			letDepth++
+		case TOKEN_IN:
+			letDepth--
+		}
+	}
+
+	// A "..." or ''...'' (or an interpolation inside one) that never
+	// closed leaves its mode frame on the stack, since nextStringToken
+	// deliberately doesn't pop on EOF - see its l.ch == 0 case.
+	return depth <= 0 && letDepth <= 0 && len(l.modeStack) == 0
+}
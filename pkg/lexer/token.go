@@ -2,6 +2,8 @@ package lexer
 
 import (
 	"fmt"
+
+	"github.com/conneroisu/gix/internal/token"
 )
 
 // TokenType represents the classification of lexical tokens in the Nix language.
@@ -18,11 +20,33 @@ const (
 	TOKEN_ILLEGAL        // Invalid/unrecognized character sequences
 
 	// Literal value tokens - represent data directly in source code.
-	TOKEN_INT    // Integer literals (42, -10, 0)
-	TOKEN_FLOAT  // Floating-point literals (3.14, -0.5, 1.0)
-	TOKEN_STRING // String literals ("hello", "world")
-	TOKEN_PATH   // Path literals (./file, /absolute/path)
-	TOKEN_IDENT  // Identifiers and variable names
+	TOKEN_INT   // Integer literals (42, 0); a leading '-' lexes as TOKEN_MINUS
+	TOKEN_FLOAT // Floating-point literals (3.14, 0.5, 1.0, 1e10, 1.5e-3)
+	TOKEN_PATH  // Path literals (./file, /absolute/path)
+	TOKEN_SPATH // Lookup-path literals (<nixpkgs>, <nixpkgs/lib>)
+	TOKEN_URI   // Unquoted URI literals (https://example.com/foo.tar.gz)
+	TOKEN_IDENT // Identifiers and variable names
+
+	// String literal tokens. A double-quoted "..." or indented ''...''
+	// string is not a single token: the lexer streams it as an opening
+	// delimiter, alternating TOKEN_STR_PART (literal text, escapes
+	// already decoded) and TOKEN_INTERP_START/TOKEN_INTERP_END pairs
+	// wrapping an interpolated expression's own tokens, and a matching
+	// closing delimiter. See Lexer's mode stack in lexer.go.
+	TOKEN_DQUOTE        // '"' opening or closing a double-quoted string
+	TOKEN_ISTRING_QUOTE // "''" opening or closing an indented string
+	TOKEN_STR_PART      // a run of literal text inside a string
+	TOKEN_INTERP_START  // "${" begins an interpolated expression
+	TOKEN_INTERP_END    // the '}' that closes an interpolated expression
+
+	// TOKEN_PATH_PART is TOKEN_STR_PART's equivalent for a path literal
+	// that contains a "${ ... }" interpolation (./foo/${name}.nix):
+	// since a path has no opening/closing delimiter to bracket it the
+	// way a string does, the first TOKEN_PATH_PART simply starts where
+	// TOKEN_PATH otherwise would, and the literal ends - with no
+	// explicit closing token - at the first character that isn't a
+	// valid path character. See Lexer's mode stack in lexer.go.
+	TOKEN_PATH_PART
 
 	// Reserved keywords - language control structures and built-in concepts.
 	TOKEN_IF      // "if" conditional expression start
@@ -63,8 +87,10 @@ const (
 
 	// Specialized operators.
 	TOKEN_CONCAT   // "++" list/string concatenation
+	TOKEN_UPDATE   // "//" attribute set merge
 	TOKEN_QUESTION // "?" attribute existence test
 	TOKEN_DOT      // "." attribute access
+	TOKEN_AT       // "@" function pattern argument binding
 
 	// Delimiters - structural punctuation for grouping and separation.
 	TOKEN_SEMICOLON // ";" statement separator
@@ -78,67 +104,120 @@ const (
 	TOKEN_RBRACE   // "}" right brace
 	TOKEN_LBRACKET // "[" left bracket (lists)
 	TOKEN_RBRACKET // "]" right bracket
+
+	// Trivia tokens. NextToken only ever produces these for a lexer
+	// created with NewWithMode and a Mode requesting them (see Mode);
+	// every other lexer skips the input they'd cover without a trace.
+	TOKEN_LINE_COMMENT  // a "#" comment, through the end of its line
+	TOKEN_BLOCK_COMMENT // a "/* ... */" comment, delimiters included
+	TOKEN_WHITESPACE    // a run of spaces, tabs, newlines, or carriage returns
 )
 
 // Token represents a complete lexical unit from the Nix source code.
 // Each token contains its classification, the actual text from the source,
-// and position information for accurate error reporting and debugging.
+// and a full source span (not just a start point) for accurate error
+// reporting, diagnostics, and source extraction.
 type Token struct {
 	Type    TokenType // The classification of this token (what kind it is)
 	Literal string    // The actual text from source ("42", "hello", "+", etc.)
-	Line    int       // Line number in source (1-based for human readability)
-	Column  int       // Column position in line (0-based within line)
+
+	Line   int // Start line number in source (1-based for human readability)
+	Column int // Start column number in line (1-based for human readability)
+
+	EndLine   int // Line number immediately after the token's last character
+	EndColumn int // Column number immediately after the token's last character
+
+	Offset    int // Start byte offset into the input
+	EndOffset int // Byte offset immediately after the token's last character
+
+	// Pos and EndPos mirror Offset/EndOffset as token.Pos values resolvable
+	// back to (filename, line, column) through the token.FileSet passed to
+	// NewFile - which is also the only way to get a non-zero value here.
+	// A lexer created with New or NewWithErrorHandler leaves both NoPos,
+	// since there's no FileSet to resolve them against.
+	Pos    token.Pos
+	EndPos token.Pos
+
+	// Doc holds the text of a /** ... */ doc comment immediately
+	// preceding this token (only whitespace and other comments may
+	// intervene), or "" if there wasn't one. Populated by the lexer so
+	// the parser can attach documentation to the AST node it's about to
+	// build without having to re-scan source text.
+	Doc string
+
+	// Leading and Trailing hold the trivia - TOKEN_WHITESPACE,
+	// TOKEN_LINE_COMMENT, TOKEN_BLOCK_COMMENT - immediately before and
+	// after this token: Leading back to the previous significant token
+	// (or start of input), Trailing up through the first newline after
+	// this one, so a same-line "# explains this" comment ends up here
+	// rather than as the next token's Leading. Only a lexer created with
+	// NewWithMode and a non-zero Mode populates these; every other lexer
+	// leaves both nil.
+	Leading  []Token
+	Trailing []Token
 }
 
 // tokenNames provides human-readable string representations for each token type.
 // Used primarily for debugging, error messages, and development tools.
 // Each token type maps to a descriptive name that clearly identifies its purpose.
 var tokenNames = map[TokenType]string{
-	TOKEN_EOF:       "EOF",
-	TOKEN_ILLEGAL:   "ILLEGAL",
-	TOKEN_INT:       "INT",
-	TOKEN_FLOAT:     "FLOAT",
-	TOKEN_STRING:    "STRING",
-	TOKEN_PATH:      "PATH",
-	TOKEN_IDENT:     "IDENT",
-	TOKEN_IF:        "IF",
-	TOKEN_THEN:      "THEN",
-	TOKEN_ELSE:      "ELSE",
-	TOKEN_LET:       "LET",
-	TOKEN_IN:        "IN",
-	TOKEN_WITH:      "WITH",
-	TOKEN_ASSERT:    "ASSERT",
-	TOKEN_OR:        "OR",
-	TOKEN_AND:       "AND",
-	TOKEN_NOT:       "NOT",
-	TOKEN_REC:       "REC",
-	TOKEN_INHERIT:   "INHERIT",
-	TOKEN_ASSIGN:    "ASSIGN",
-	TOKEN_PLUS:      "PLUS",
-	TOKEN_MINUS:     "MINUS",
-	TOKEN_MULTIPLY:  "MULTIPLY",
-	TOKEN_DIVIDE:    "DIVIDE",
-	TOKEN_EQ:        "EQ",
-	TOKEN_NEQ:       "NEQ",
-	TOKEN_LT:        "LT",
-	TOKEN_GT:        "GT",
-	TOKEN_LTE:       "LTE",
-	TOKEN_GTE:       "GTE",
-	TOKEN_AND_OP:    "AND_OP",
-	TOKEN_OR_OP:     "OR_OP",
-	TOKEN_IMPL:      "IMPL",
-	TOKEN_CONCAT:    "CONCAT",
-	TOKEN_QUESTION:  "QUESTION",
-	TOKEN_DOT:       "DOT",
-	TOKEN_SEMICOLON: "SEMICOLON",
-	TOKEN_COLON:     "COLON",
-	TOKEN_COMMA:     "COMMA",
-	TOKEN_LPAREN:    "LPAREN",
-	TOKEN_RPAREN:    "RPAREN",
-	TOKEN_LBRACE:    "LBRACE",
-	TOKEN_RBRACE:    "RBRACE",
-	TOKEN_LBRACKET:  "LBRACKET",
-	TOKEN_RBRACKET:  "RBRACKET",
+	TOKEN_EOF:           "EOF",
+	TOKEN_ILLEGAL:       "ILLEGAL",
+	TOKEN_INT:           "INT",
+	TOKEN_FLOAT:         "FLOAT",
+	TOKEN_PATH:          "PATH",
+	TOKEN_SPATH:         "SPATH",
+	TOKEN_URI:           "URI",
+	TOKEN_IDENT:         "IDENT",
+	TOKEN_DQUOTE:        "DQUOTE",
+	TOKEN_ISTRING_QUOTE: "ISTRING_QUOTE",
+	TOKEN_STR_PART:      "STR_PART",
+	TOKEN_INTERP_START:  "INTERP_START",
+	TOKEN_INTERP_END:    "INTERP_END",
+	TOKEN_PATH_PART:     "PATH_PART",
+	TOKEN_IF:            "IF",
+	TOKEN_THEN:          "THEN",
+	TOKEN_ELSE:          "ELSE",
+	TOKEN_LET:           "LET",
+	TOKEN_IN:            "IN",
+	TOKEN_WITH:          "WITH",
+	TOKEN_ASSERT:        "ASSERT",
+	TOKEN_OR:            "OR",
+	TOKEN_AND:           "AND",
+	TOKEN_NOT:           "NOT",
+	TOKEN_REC:           "REC",
+	TOKEN_INHERIT:       "INHERIT",
+	TOKEN_ASSIGN:        "ASSIGN",
+	TOKEN_PLUS:          "PLUS",
+	TOKEN_MINUS:         "MINUS",
+	TOKEN_MULTIPLY:      "MULTIPLY",
+	TOKEN_DIVIDE:        "DIVIDE",
+	TOKEN_EQ:            "EQ",
+	TOKEN_NEQ:           "NEQ",
+	TOKEN_LT:            "LT",
+	TOKEN_GT:            "GT",
+	TOKEN_LTE:           "LTE",
+	TOKEN_GTE:           "GTE",
+	TOKEN_AND_OP:        "AND_OP",
+	TOKEN_OR_OP:         "OR_OP",
+	TOKEN_IMPL:          "IMPL",
+	TOKEN_CONCAT:        "CONCAT",
+	TOKEN_UPDATE:        "UPDATE",
+	TOKEN_QUESTION:      "QUESTION",
+	TOKEN_DOT:           "DOT",
+	TOKEN_AT:            "AT",
+	TOKEN_SEMICOLON:     "SEMICOLON",
+	TOKEN_COLON:         "COLON",
+	TOKEN_COMMA:         "COMMA",
+	TOKEN_LPAREN:        "LPAREN",
+	TOKEN_RPAREN:        "RPAREN",
+	TOKEN_LBRACE:        "LBRACE",
+	TOKEN_RBRACE:        "RBRACE",
+	TOKEN_LBRACKET:      "LBRACKET",
+	TOKEN_RBRACKET:      "RBRACKET",
+	TOKEN_LINE_COMMENT:  "LINE_COMMENT",
+	TOKEN_BLOCK_COMMENT: "BLOCK_COMMENT",
+	TOKEN_WHITESPACE:    "WHITESPACE",
 }
 
 // String returns a human-readable string representation of the token type.
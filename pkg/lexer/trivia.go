@@ -0,0 +1,236 @@
+package lexer
+
+import "strings"
+
+// Mode is a bitmask controlling which non-significant input NextToken
+// surfaces as trivia - attached to the Leading/Trailing of the
+// significant token it's adjacent to - rather than silently discarding.
+// The zero Mode (what New, NewWithErrorHandler, and NewFile all produce)
+// preserves the original behavior: whitespace and comments vanish with
+// no trace anywhere in the token stream.
+//
+// A mode-enabled lexer is for tools that need the source back byte for
+// byte - a nixfmt-style formatter re-emitting a tree unchanged, or an LSP
+// hover that wants the doc comment sitting right above a token - without
+// making the parser, which only ever wants one significant token per
+// NextToken call, aware that trivia exists at all.
+type Mode int
+
+const (
+	// ScanComments attaches TOKEN_LINE_COMMENT and TOKEN_BLOCK_COMMENT
+	// tokens - exact source text, delimiters included - to the nearby
+	// significant token's Leading or Trailing.
+	ScanComments Mode = 1 << iota
+	// ScanWhitespace does the same for TOKEN_WHITESPACE tokens covering
+	// runs of spaces, tabs, newlines, and carriage returns. Combined with
+	// ScanComments, concatenating a token stream's Leading literals, its
+	// own Literal, and its Trailing literals in order reconstructs the
+	// original source exactly, except inside string literals that use
+	// escapes: a TOKEN_STR_PART's Literal holds the decoded text ("\n"
+	// as a newline, ''$ as "$"), not the escape as written.
+	ScanWhitespace
+)
+
+// isTriviaType reports whether t is one of the token types a Mode can ask
+// NextToken to surface instead of discarding.
+func isTriviaType(t TokenType) bool {
+	return t == TOKEN_WHITESPACE || t == TOKEN_LINE_COMMENT || t == TOKEN_BLOCK_COMMENT
+}
+
+// nextWithTrivia is NextToken's entry point once l.mode != 0. It pulls
+// raw units - ordinary tokens, or trivia surfaced per Mode - from
+// readRaw, accumulating trivia into Leading until a significant token
+// appears, then eagerly collects that token's same-line Trailing trivia
+// before returning it.
+func (l *Lexer) nextWithTrivia() Token {
+	var leading []Token
+
+	for {
+		tok := l.readRaw()
+		if isTriviaType(tok.Type) {
+			leading = append(leading, tok)
+
+			continue
+		}
+
+		tok.Leading = leading
+		tok.Trailing = l.collectTrailing()
+
+		return tok
+	}
+}
+
+// collectTrailing eagerly pulls trivia immediately following the token
+// nextWithTrivia is about to return, stopping after (and including) the
+// first TOKEN_WHITESPACE run that contains a newline - a "# explains
+// this line" comment ends up here, but a blank line and everything past
+// it becomes the next token's Leading instead. The first non-trivia
+// token it reads is stashed in l.pushedBack rather than discarded, since
+// that's the next call's significant token.
+func (l *Lexer) collectTrailing() []Token {
+	var trailing []Token
+
+	for {
+		tok := l.readRaw()
+		if !isTriviaType(tok.Type) {
+			l.pushedBack = &tok
+
+			return trailing
+		}
+
+		trailing = append(trailing, tok)
+
+		if tok.Type == TOKEN_WHITESPACE && strings.ContainsRune(tok.Literal, '\n') {
+			return trailing
+		}
+	}
+}
+
+// readRaw returns a token nextWithTrivia previously pushed back, if any,
+// otherwise scans the next one - which, with l.mode set, may itself be a
+// single piece of trivia rather than a significant token.
+func (l *Lexer) readRaw() Token {
+	if l.pushedBack != nil {
+		tok := *l.pushedBack
+		l.pushedBack = nil
+
+		return tok
+	}
+
+	return l.scanRaw()
+}
+
+// scanRaw returns one trivia token if l.mode asks for the kind sitting at
+// l.ch, silently skipping it first if the active Mode doesn't cover it,
+// and otherwise falls through to scanSignificant for an ordinary token.
+// Like NextToken, it re-checks the string mode stack on every call: an
+// interpolation's own expression can itself contain a nested string
+// literal, which needs to route back through nextStringToken rather than
+// the trivia/significant-token machinery below.
+func (l *Lexer) scanRaw() Token {
+	if n := len(l.modeStack); n > 0 {
+		if top := l.modeStack[n-1]; top.kind != modeInterp {
+			return l.nextStringToken(top.kind)
+		}
+	}
+
+	for {
+		tok, shouldEmit, hadTrivia := l.scanOneTrivia()
+		if !hadTrivia {
+			break
+		}
+
+		if shouldEmit {
+			return tok
+		}
+	}
+
+	tok := l.scanSignificant()
+	tok.Doc = l.doc
+	l.doc = ""
+
+	return tok
+}
+
+// scanOneTrivia consumes a single piece of trivia - one whitespace run,
+// or one whole comment - starting at l.ch, if there is one, reporting
+// whether the active Mode wants it surfaced as a token (shouldEmit) and
+// whether it found anything to consume at all (hadTrivia). It always
+// consumes what it finds regardless of shouldEmit, so scanRaw's caller
+// never reprocesses the same bytes.
+func (l *Lexer) scanOneTrivia() (tok Token, shouldEmit bool, hadTrivia bool) {
+	switch {
+	case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+		return l.scanWhitespaceRun(), l.mode&ScanWhitespace != 0, true
+
+	case l.ch == '#' || (l.ch == '/' && l.peekChar() == '*'):
+		return l.scanComment(), l.mode&ScanComments != 0, true
+
+	default:
+		return Token{}, false, false
+	}
+}
+
+// scanWhitespaceRun consumes the run of whitespace starting at l.ch
+// (which must already be whitespace) and returns it as a TOKEN_WHITESPACE
+// token.
+func (l *Lexer) scanWhitespaceRun() Token {
+	var tok Token
+	tok.Line, tok.Column = l.line, l.column
+	start := l.position
+
+	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+		l.readChar()
+	}
+
+	tok.Type = TOKEN_WHITESPACE
+	tok.Literal = l.input[start:l.position]
+
+	return l.finish(tok, start)
+}
+
+// scanComment consumes a single "#" or "/* ... */" comment starting at
+// l.ch and returns it as a TOKEN_LINE_COMMENT or TOKEN_BLOCK_COMMENT
+// token with its exact source text, delimiters included. It updates
+// l.doc the same way skipComment does for the non-trivia path, so the
+// significant token scanRaw eventually returns still gets the right Doc.
+func (l *Lexer) scanComment() Token {
+	var tok Token
+	tok.Line, tok.Column = l.line, l.column
+	start := l.position
+
+	if l.ch == '#' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+
+		tok.Type = TOKEN_LINE_COMMENT
+		tok.Literal = l.input[start:l.position]
+		l.doc = ""
+
+		return l.finish(tok, start)
+	}
+
+	l.readChar() // skip opening '/'
+	l.readChar() // skip opening '*'
+
+	isDoc := l.ch == '*' && l.peekChar() != '/'
+	if isDoc {
+		l.readChar() // skip the doc-marking '*'
+	}
+
+	bodyStart := l.position
+
+	for l.ch != 0 {
+		if l.ch == '*' && l.peekChar() == '/' {
+			if isDoc {
+				l.doc = strings.TrimSpace(l.input[bodyStart:l.position])
+			} else {
+				l.doc = ""
+			}
+
+			l.readChar() // skip closing '*'
+			l.readChar() // skip closing '/'
+
+			tok.Type = TOKEN_BLOCK_COMMENT
+			tok.Literal = l.input[start:l.position]
+
+			return l.finish(tok, start)
+		}
+
+		l.readChar()
+	}
+
+	l.error(Position{Line: tok.Line, Column: tok.Column, Offset: start}, "unterminated block comment")
+
+	if isDoc {
+		l.doc = strings.TrimSpace(l.input[bodyStart:l.position])
+	} else {
+		l.doc = ""
+	}
+
+	tok.Type = TOKEN_BLOCK_COMMENT
+	tok.Literal = l.input[start:l.position]
+
+	return l.finish(tok, start)
+}
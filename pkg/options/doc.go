@@ -0,0 +1,19 @@
+// Package options collects the command-line settings that shape a single
+// gix evaluation run - how much of the result gets forced, which format
+// it's rendered in, and any arguments bound into a top-level `{...}:
+// expr` function - so main doesn't have to thread a dozen loose flag
+// variables through evalExpression/evalFile by hand.
+//
+// Options itself is a plain struct; RegisterFlags wires its single-token
+// fields onto a *flag.FlagSet the same way any other gix subcommand
+// builds one (see buildCommand/docCommand in main.go). --arg and
+// --argstr take two tokens each, which the standard flag package can't
+// express, so ExtractArgs pulls them out of the argument list before the
+// FlagSet ever sees it; see its doc comment for the ordering this
+// requires.
+//
+// Once an expression has been evaluated, AutoApply/FindAlongAttrPath (on
+// *eval.Evaluator) consume the --arg/--argstr bindings and --attr path,
+// value.DeepForce implements --strict, and RenderJSON/RenderXML in this
+// package implement --json and --xml.
+package options
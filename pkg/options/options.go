@@ -0,0 +1,132 @@
+package options
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/conneroisu/gix/internal/value"
+	"github.com/conneroisu/gix/pkg/eval"
+	"github.com/conneroisu/gix/pkg/lexer"
+	"github.com/conneroisu/gix/pkg/parser"
+)
+
+// Options bundles the flags that steer a single gix evaluation: how much
+// of the value tree gets forced, which format it's printed in, and any
+// arguments bound into a top-level function.
+type Options struct {
+	Verbose   bool // Print extra diagnostic information
+	Strict    bool // Deep-force the result before printing (see DeepForce)
+	JSON      bool // Render the result as JSON instead of Nix syntax
+	XML       bool // Render the result as XML instead of Nix syntax
+	ParseOnly bool // Pretty-print the parsed AST and exit, without evaluating
+	Reduce    bool // Run the optimizer (constant folding etc.) before evaluating; see eval.WithOptimize
+	Attr      string // Dotted attribute path to select from the result before printing
+	ReadFrom  string // Read the expression from this file instead of -e or a positional argument
+
+	// IncludePaths accumulates -I entries. pkg/lexer tokenizes <name> as
+	// TOKEN_SPATH (see its scanLookupPath), but gix's parser has no
+	// search-path syntax, so these are recorded but have no effect.
+	IncludePaths []string
+
+	// Args holds --arg name expr pairs: expr is parsed and evaluated as
+	// a Nix expression, mirroring nix-instantiate --arg.
+	Args map[string]string
+	// ArgStrs holds --argstr name value pairs: value is bound as a
+	// literal string with no further parsing, mirroring nix-instantiate
+	// --argstr.
+	ArgStrs map[string]string
+}
+
+// New returns an Options with its argument maps initialized, ready for
+// ExtractArgs to populate.
+func New() *Options {
+	return &Options{Args: make(map[string]string), ArgStrs: make(map[string]string)}
+}
+
+// ExtractArgs scans args for --arg name value and --argstr name value
+// pairs, recording each on o and returning the remaining arguments for a
+// *flag.FlagSet to parse. It must run before RegisterFlags' FlagSet sees
+// args, since flag.Parse would otherwise abort the first time it hit an
+// --arg/--argstr it doesn't know how to parse as a single-token flag.
+func (o *Options) ExtractArgs(args []string) ([]string, error) {
+	rest := make([]string, 0, len(args))
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--arg":
+			if i+2 >= len(args) {
+				return nil, fmt.Errorf("--arg requires a name and a value")
+			}
+			o.Args[args[i+1]] = args[i+2]
+			i += 2
+		case "--argstr":
+			if i+2 >= len(args) {
+				return nil, fmt.Errorf("--argstr requires a name and a value")
+			}
+			o.ArgStrs[args[i+1]] = args[i+2]
+			i += 2
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+
+	return rest, nil
+}
+
+// RegisterFlags wires o's single-token fields onto fs, following the
+// same flag.NewFlagSet convention buildCommand/docCommand already use.
+func (o *Options) RegisterFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&o.Verbose, "verbose", false, "Print extra diagnostic information")
+	fs.BoolVar(&o.Strict, "strict", false, "Force deep evaluation of the result before printing")
+	fs.BoolVar(&o.JSON, "json", false, "Print the result as JSON")
+	fs.BoolVar(&o.XML, "xml", false, "Print the result as XML")
+	fs.BoolVar(&o.ParseOnly, "parse", false, "Parse and pretty-print the AST, without evaluating")
+	fs.BoolVar(&o.Reduce, "reduce", false, "Run the constant-folding/dead-branch optimizer before evaluating")
+	fs.StringVar(&o.Attr, "attr", "", "Select this dotted attribute path from the result before printing")
+	fs.StringVar(&o.ReadFrom, "read-from", "", "Read the expression to evaluate from this file")
+	fs.Var((*stringList)(&o.IncludePaths), "I", "Add a path to the list of search paths for <...> imports (accumulates)")
+}
+
+// stringList implements flag.Value over a []string, accumulating every
+// occurrence of a repeatable flag instead of keeping only the last one.
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ":") }
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+
+	return nil
+}
+
+// AutoArgs evaluates o's --arg expressions and --argstr literals with e,
+// producing the attribute set that eval.Evaluator.AutoApply/
+// FindAlongAttrPath auto-apply a top-level `{...}: expr` function to, the
+// way nix-instantiate's --arg/--argstr call a default.nix-shaped file
+// from the command line.
+func (o *Options) AutoArgs(e *eval.Evaluator) (*value.Attrs, error) {
+	attrs := value.NewAttrs()
+
+	for name, expr := range o.Args {
+		p := parser.New(lexer.New(expr))
+
+		ast, err := p.Parse()
+		if err != nil {
+			return nil, fmt.Errorf("--arg %s: %w", name, err)
+		}
+
+		val, err := e.Eval(ast)
+		if err != nil {
+			return nil, fmt.Errorf("--arg %s: %w", name, err)
+		}
+
+		attrs.Set(name, val)
+	}
+
+	for name, s := range o.ArgStrs {
+		attrs.Set(name, value.NewString(s))
+	}
+
+	return attrs, nil
+}
@@ -0,0 +1,86 @@
+package options
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/conneroisu/gix/internal/value"
+)
+
+func TestExtractArgsSeparatesTwoTokenFlags(t *testing.T) {
+	o := New()
+
+	rest, err := o.ExtractArgs([]string{
+		"-strict", "--arg", "x", "1 + 1", "file.nix", "--argstr", "name", "hello",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(rest, []string{"-strict", "file.nix"}) {
+		t.Fatalf("unexpected remaining args: %#v", rest)
+	}
+
+	if o.Args["x"] != "1 + 1" {
+		t.Fatalf("expected --arg x to be recorded, got %#v", o.Args)
+	}
+
+	if o.ArgStrs["name"] != "hello" {
+		t.Fatalf("expected --argstr name to be recorded, got %#v", o.ArgStrs)
+	}
+}
+
+func TestExtractArgsMissingValueErrors(t *testing.T) {
+	o := New()
+
+	if _, err := o.ExtractArgs([]string{"--arg", "x"}); err == nil {
+		t.Fatal("expected an error for a dangling --arg")
+	}
+
+	if _, err := o.ExtractArgs([]string{"--argstr", "x"}); err == nil {
+		t.Fatal("expected an error for a dangling --argstr")
+	}
+}
+
+func TestRenderJSON(t *testing.T) {
+	attrs := value.NewAttrsFrom(map[string]value.Value{
+		"a": value.Int(1),
+		"b": value.NewList(value.Bool(true), value.Null{}),
+	})
+
+	out, err := RenderJSON(attrs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	const want = `{
+  "a": 1,
+  "b": [
+    true,
+    null
+  ]
+}`
+	if out != want {
+		t.Fatalf("RenderJSON = %q, want %q", out, want)
+	}
+}
+
+func TestRenderJSONRejectsFunctions(t *testing.T) {
+	fn := value.NewFunction("x", nil, nil)
+
+	if _, err := RenderJSON(fn); err == nil {
+		t.Fatal("expected an error converting a function to JSON")
+	}
+}
+
+func TestRenderXML(t *testing.T) {
+	out, err := RenderXML(value.Int(42))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	const want = "<?xml version='1.0' encoding='utf-8'?>\n<expr>\n  <int value=\"42\" />\n</expr>\n"
+	if out != want {
+		t.Fatalf("RenderXML = %q, want %q", out, want)
+	}
+}
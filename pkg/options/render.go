@@ -0,0 +1,150 @@
+package options
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/conneroisu/gix/internal/value"
+)
+
+// toJSON converts v into the plain interface{} tree encoding/json knows
+// how to marshal: attrsets become maps, lists become slices, and
+// functions/builtins are rejected, since JSON has no way to represent
+// them - the same restriction nix-instantiate --json enforces.
+func toJSON(v value.Value) (interface{}, error) {
+	forced, err := value.Force(v)
+	if err != nil {
+		return nil, err
+	}
+
+	switch val := forced.(type) {
+	case value.Null:
+		return nil, nil
+	case value.Bool:
+		return bool(val), nil
+	case value.Int:
+		return int64(val), nil
+	case value.Float:
+		return float64(val), nil
+	case value.String:
+		return val.Raw, nil
+	case value.Path:
+		return string(val), nil
+
+	case *value.List:
+		elems := val.Elements()
+		out := make([]interface{}, len(elems))
+
+		for i, e := range elems {
+			out[i], err = toJSON(e)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return out, nil
+
+	case *value.Attrs:
+		out := make(map[string]interface{}, val.Len())
+		for _, k := range val.Keys() {
+			child, _ := val.Get(k)
+
+			out[k], err = toJSON(child)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return out, nil
+
+	default:
+		return nil, fmt.Errorf("cannot convert %s to JSON", forced.Type())
+	}
+}
+
+// RenderJSON converts v to its Nix-compatible JSON rendering: attrsets
+// become objects, lists become arrays, and functions are rejected.
+func RenderJSON(v value.Value) (string, error) {
+	tree, err := toJSON(v)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := json.MarshalIndent(tree, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+// writeXML appends v's XML rendering to buf at the given indent,
+// following the element shapes nix-instantiate --xml uses: <attrs>/
+// <attr name="...">, <list>, and one self-closing element per scalar
+// kind. Functions/builtins are rejected the same way toJSON rejects
+// them.
+func writeXML(buf *strings.Builder, v value.Value, indent string) error {
+	forced, err := value.Force(v)
+	if err != nil {
+		return err
+	}
+
+	switch val := forced.(type) {
+	case value.Null:
+		fmt.Fprintf(buf, "%s<null />\n", indent)
+	case value.Bool:
+		fmt.Fprintf(buf, "%s<bool value=%q />\n", indent, fmt.Sprintf("%t", val))
+	case value.Int:
+		fmt.Fprintf(buf, "%s<int value=%q />\n", indent, fmt.Sprintf("%d", val))
+	case value.Float:
+		fmt.Fprintf(buf, "%s<float value=%q />\n", indent, fmt.Sprintf("%g", val))
+	case value.String:
+		fmt.Fprintf(buf, "%s<string value=%q />\n", indent, val.Raw)
+	case value.Path:
+		fmt.Fprintf(buf, "%s<path value=%q />\n", indent, string(val))
+
+	case *value.List:
+		fmt.Fprintf(buf, "%s<list>\n", indent)
+		for _, e := range val.Elements() {
+			if err := writeXML(buf, e, indent+"  "); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(buf, "%s</list>\n", indent)
+
+	case *value.Attrs:
+		fmt.Fprintf(buf, "%s<attrs>\n", indent)
+		for _, k := range val.Keys() {
+			child, _ := val.Get(k)
+
+			fmt.Fprintf(buf, "%s  <attr name=%q>\n", indent, k)
+			if err := writeXML(buf, child, indent+"    "); err != nil {
+				return err
+			}
+			fmt.Fprintf(buf, "%s  </attr>\n", indent)
+		}
+		fmt.Fprintf(buf, "%s</attrs>\n", indent)
+
+	default:
+		return fmt.Errorf("cannot convert %s to XML", forced.Type())
+	}
+
+	return nil
+}
+
+// RenderXML converts v to its Nix-compatible XML rendering, the same
+// shape nix-instantiate --xml produces.
+func RenderXML(v value.Value) (string, error) {
+	var buf strings.Builder
+
+	buf.WriteString("<?xml version='1.0' encoding='utf-8'?>\n<expr>\n")
+
+	if err := writeXML(&buf, v, "  "); err != nil {
+		return "", err
+	}
+
+	buf.WriteString("</expr>\n")
+
+	return buf.String(), nil
+}
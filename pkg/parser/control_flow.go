@@ -1,17 +1,29 @@
 package parser
 
 import (
+	"fmt"
+
 	"github.com/conneroisu/gix/internal/types"
 	"github.com/conneroisu/gix/pkg/lexer"
 )
 
 // parseIf parses if-then-else expressions.
 func (p *Parser) parseIf() types.Expr {
+	defer p.untrace(p.trace("parseIf"))
+
 	p.advance() // skip 'if'
 
 	cond := p.parseExpression(precedenceLowest)
 
 	if !p.expectPeek(lexer.TOKEN_THEN) {
+		// expectPeek already recorded why; in recovery mode synchronize
+		// (to ';', '}', ']', 'then', 'else', ...) and hand back an
+		// ErrorExpr instead of nil, so a caller building an IfExpr
+		// around this never ends up with a nil field.
+		if p.recoverMode {
+			return p.recover(p.peek.Line, p.peek.Column, "if missing 'then'")
+		}
+
 		return nil
 	}
 
@@ -19,6 +31,10 @@ func (p *Parser) parseIf() types.Expr {
 	then := p.parseExpression(precedenceLowest)
 
 	if !p.expectPeek(lexer.TOKEN_ELSE) {
+		if p.recoverMode {
+			return p.recover(p.peek.Line, p.peek.Column, "if missing 'else'")
+		}
+
 		return nil
 	}
 
@@ -34,6 +50,8 @@ func (p *Parser) parseIf() types.Expr {
 
 // parseLet parses let expressions.
 func (p *Parser) parseLet() types.Expr {
+	defer p.untrace(p.trace("parseLet"))
+
 	p.advance() // skip 'let'
 
 	let := &types.LetExpr{
@@ -42,37 +60,97 @@ func (p *Parser) parseLet() types.Expr {
 
 	// Parse bindings
 	for !p.curIs(lexer.TOKEN_IN) && !p.curIs(lexer.TOKEN_EOF) {
+		if p.curIs(lexer.TOKEN_INHERIT) {
+			if clause := p.parseInherit(); clause != nil {
+				let.Inherits = append(let.Inherits, *clause)
+			}
+
+			continue
+		}
+
 		if !p.curIs(lexer.TOKEN_IDENT) {
-			p.errors.Addf(p.cur.Line, p.cur.Column,
-				"expected identifier in let binding, got %v", p.cur.Type)
+			msg := fmt.Sprintf("expected identifier in let binding, got %v", p.cur.Type)
+			if p.recoverMode {
+				p.synchronize(nil)
+
+				continue
+			}
+			p.errors.Addf(p.cur.Line, p.cur.Column, "%s", msg)
 
 			return nil
 		}
 
+		bindingStart := p.cur
 		name := p.cur.Literal
+		doc := p.cur.Doc
+
+		if !p.peekIs(lexer.TOKEN_ASSIGN) {
+			msg := fmt.Sprintf("expected next token to be %v, got %v", lexer.TOKEN_ASSIGN, p.peek.Type)
+			if p.recoverMode {
+				// No value was ever parsed, so there's nothing salvageable
+				// for this binding - fall back to an ErrorExpr value.
+				p.advance()
+				let.Bindings = append(let.Bindings, types.Binding{
+					Name:  name,
+					Value: p.recover(p.cur.Line, p.cur.Column, msg),
+					Span:  tokenSpan(bindingStart, p.cur),
+				})
+
+				if p.curIs(lexer.TOKEN_SEMICOLON) {
+					p.advance()
+				}
+
+				continue
+			}
+			p.errors.Addf(p.peek.Line, p.peek.Column, "%s", msg)
 
-		if !p.expectPeek(lexer.TOKEN_ASSIGN) {
 			return nil
 		}
+		p.advance()
 
 		p.advance()
 		value := p.parseExpression(precedenceLowest)
+		attachDoc(value, doc)
+
+		if !p.peekIs(lexer.TOKEN_SEMICOLON) {
+			msg := fmt.Sprintf("expected next token to be %v, got %v", lexer.TOKEN_SEMICOLON, p.peek.Type)
+			if p.recoverMode {
+				// value already parsed fine - keep it and just skip past
+				// the missing ';'.
+				p.advance()
+				p.recover(p.cur.Line, p.cur.Column, msg)
+				let.Bindings = append(let.Bindings, types.Binding{
+					Name:  name,
+					Value: value,
+					Span:  tokenSpan(bindingStart, p.cur),
+				})
+
+				continue
+			}
+			p.errors.Addf(p.peek.Line, p.peek.Column, "%s", msg)
+
+			return nil
+		}
+		semiTok := p.peek
+		p.advance()
 
 		let.Bindings = append(let.Bindings, types.Binding{
 			Name:  name,
 			Value: value,
+			Span:  tokenSpan(bindingStart, semiTok),
 		})
 
-		if !p.expectPeek(lexer.TOKEN_SEMICOLON) {
-			return nil
-		}
-
 		p.advance() // position on next token
 	}
 
 	if !p.curIs(lexer.TOKEN_IN) {
-		p.errors.Addf(p.cur.Line, p.cur.Column,
-			"expected 'in' after let bindings, got %v", p.cur.Type)
+		msg := fmt.Sprintf("expected 'in' after let bindings, got %v", p.cur.Type)
+		if p.recoverMode {
+			let.Body = p.recover(p.cur.Line, p.cur.Column, msg)
+
+			return let
+		}
+		p.errors.Addf(p.cur.Line, p.cur.Column, "%s", msg)
 
 		return nil
 	}
@@ -85,6 +163,8 @@ func (p *Parser) parseLet() types.Expr {
 
 // parseWith parses with expressions.
 func (p *Parser) parseWith() types.Expr {
+	defer p.untrace(p.trace("parseWith"))
+
 	p.advance() // skip 'with'
 
 	expr := p.parseExpression(precedenceLowest)
@@ -104,6 +184,8 @@ func (p *Parser) parseWith() types.Expr {
 
 // parseAssert parses assert expressions.
 func (p *Parser) parseAssert() types.Expr {
+	defer p.untrace(p.trace("parseAssert"))
+
 	p.advance() // skip 'assert'
 
 	cond := p.parseExpression(precedenceLowest)
@@ -66,6 +66,22 @@
 //   - Multiple error collection for better user experience
 //   - Structured error types for programmatic handling
 //
+// By default a syntax error aborts the parse. Calling EnableErrorRecovery
+// switches to a best-effort mode instead: malformed productions
+// synchronize to the next statement boundary and leave a types.ErrorExpr
+// placeholder (or a partial node, where one was already parsed) so a
+// partial AST is still produced - useful for editor tooling that needs
+// something to show even from half-typed source. ParseIncremental builds
+// on this to re-parse just the edited portion of a file after a small
+// text edit, reusing unaffected subtrees of the previous tree where it
+// can. Events() exposes EnterNode/LeaveNode/Error notifications as
+// parsing progresses, so LSP-style tooling can build semantic tokens
+// without a second walk of the finished tree. EnableTrace writes an
+// indented log of every parseX call - entry, exit, and the current/
+// lookahead tokens and precedence that drove the decision - useful for
+// diagnosing precedence bugs and function-application ambiguity
+// (couldBeArgument) by hand instead of sprinkling in print statements.
+//
 // Performance Features:
 //   - Single-pass parsing with minimal backtracking
 //   - Efficient operator precedence resolution
@@ -0,0 +1,1147 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/conneroisu/gix/internal/types"
+)
+
+// Dump writes file as a flattened preorder text format: every line is
+// either a bare ";" (a nil child) or a node's kind tag followed by its
+// own fields - each individually quoted with strconv.Quote, even numeric
+// ones, so Load's field reader never has to guess where one ends and
+// the next begins - with any child nodes immediately following in
+// preorder. A kind that has a variable number of children (ListExpr's
+// Elements, an attribute set's bindings) writes the count as one of its
+// own fields first.
+//
+// The result is meant as a stable textual IR: something a standalone
+// lexer/parser binary can pipe to an evaluator, something test fixtures
+// can assert against instead of a reflect-based %+v dump, something that
+// diffs cleanly and can be cached on disk. In service of that, Dump
+// deliberately omits every node's Span/SourcePos: two otherwise-identical
+// trees parsed from differently-formatted source would otherwise never
+// compare equal, which defeats the point of a canonical form. Load
+// reconstructs every node with a zero Span; re-parse the original source
+// if real positions are ever needed again.
+func Dump(w io.Writer, file *types.File) error {
+	bw := bufio.NewWriter(w)
+
+	if err := writeNode(bw, "FILE", file.Source); err != nil {
+		return fmt.Errorf("parser: dump: %w", err)
+	}
+
+	if err := dumpExpr(bw, file.Root); err != nil {
+		return fmt.Errorf("parser: dump: %w", err)
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("parser: dump: %w", err)
+	}
+
+	return nil
+}
+
+// Load reads a *types.File previously written by Dump.
+func Load(r io.Reader) (*types.File, error) {
+	ld := newLoader(r)
+
+	line, ok := ld.next()
+	if !ok {
+		return nil, fmt.Errorf("parser: load: empty dump")
+	}
+
+	fr := newFieldReader(line)
+
+	if tag := fr.word(); tag != "FILE" {
+		return nil, ld.errorf("expected FILE header, got %q", tag)
+	}
+
+	source, err := fr.quoted()
+	if err != nil {
+		return nil, ld.errorf("FILE header: %w", err)
+	}
+
+	root, err := ld.loadExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.File{Source: source, Root: root}, nil
+}
+
+// writeNode writes one line: kind, then each of fields individually
+// quoted. A kind's own dump function is responsible for then writing
+// whatever child nodes follow it.
+func writeNode(bw *bufio.Writer, kind string, fields ...string) error {
+	if _, err := bw.WriteString(kind); err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		if err := bw.WriteByte(' '); err != nil {
+			return err
+		}
+
+		if _, err := bw.WriteString(strconv.Quote(f)); err != nil {
+			return err
+		}
+	}
+
+	return bw.WriteByte('\n')
+}
+
+// writeNil writes the line Load's loadExpr/loadPattern recognize as a
+// nil child: a bare ";", not a kind tag, so a type switch never has to
+// special-case it.
+func writeNil(bw *bufio.Writer) error {
+	_, err := bw.WriteString(";\n")
+
+	return err
+}
+
+func boolField(b bool) string {
+	if b {
+		return "1"
+	}
+
+	return "0"
+}
+
+func parseBoolField(s string) bool {
+	return s == "1"
+}
+
+// fieldReader pulls the fields off one line written by writeNode: word()
+// for the bare kind tag, quoted() for everything after it.
+type fieldReader struct {
+	s string
+}
+
+func newFieldReader(line string) *fieldReader {
+	return &fieldReader{s: line}
+}
+
+func (r *fieldReader) word() string {
+	r.s = strings.TrimLeft(r.s, " ")
+
+	i := strings.IndexByte(r.s, ' ')
+	if i < 0 {
+		w := r.s
+		r.s = ""
+
+		return w
+	}
+
+	w := r.s[:i]
+	r.s = r.s[i:]
+
+	return w
+}
+
+func (r *fieldReader) quoted() (string, error) {
+	r.s = strings.TrimLeft(r.s, " ")
+
+	prefix := r.quotedPrefix()
+	if prefix == "" {
+		return "", fmt.Errorf("expected a quoted field, got %q", r.s)
+	}
+
+	v, err := strconv.Unquote(prefix)
+	if err != nil {
+		return "", fmt.Errorf("malformed quoted field %q: %w", prefix, err)
+	}
+
+	r.s = r.s[len(prefix):]
+
+	return v, nil
+}
+
+// quotedPrefix returns the leading quoted Go string literal in r.s, or ""
+// if r.s doesn't start with one - the same job strconv.QuotedPrefix does,
+// reimplemented here so this doesn't depend on a Go version newer than
+// the rest of the module assumes.
+func (r *fieldReader) quotedPrefix() string {
+	if len(r.s) == 0 || r.s[0] != '"' {
+		return ""
+	}
+
+	escaped := false
+
+	for i := 1; i < len(r.s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case r.s[i] == '\\':
+			escaped = true
+		case r.s[i] == '"':
+			return r.s[:i+1]
+		}
+	}
+
+	return ""
+}
+
+func (r *fieldReader) int() (int, error) {
+	s, err := r.quoted()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("expected an integer field: %w", err)
+	}
+
+	return n, nil
+}
+
+func (r *fieldReader) bool() (bool, error) {
+	s, err := r.quoted()
+	if err != nil {
+		return false, err
+	}
+
+	return parseBoolField(s), nil
+}
+
+// loader wraps a bufio.Scanner with the line counter Load's errors quote.
+type loader struct {
+	sc   *bufio.Scanner
+	line int
+}
+
+func newLoader(r io.Reader) *loader {
+	sc := bufio.NewScanner(r)
+	// A string literal can be long; the default 64KiB scan buffer is
+	// easy to outgrow.
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	return &loader{sc: sc}
+}
+
+func (ld *loader) next() (string, bool) {
+	if !ld.sc.Scan() {
+		return "", false
+	}
+
+	ld.line++
+
+	return ld.sc.Text(), true
+}
+
+// errorf builds the prefix directly into format and hands both, plus
+// args, to a single fmt.Errorf call - formatting through an intermediate
+// fmt.Sprintf would stringify any %w verb a caller passed (there are
+// many: every malformed-field case below wraps the underlying parse
+// error this way), breaking errors.Is/errors.As on the result.
+func (ld *loader) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("parser: load: line %d: "+format, append([]interface{}{ld.line}, args...)...)
+}
+
+// dumpExpr writes e in preorder - a bare ";" if e is nil, otherwise its
+// kind tag and fields followed immediately by whatever children it has.
+func dumpExpr(bw *bufio.Writer, e types.Expr) error {
+	if e == nil {
+		return writeNil(bw)
+	}
+
+	switch e := e.(type) {
+	case *types.IntExpr:
+		return writeNode(bw, "INT", strconv.FormatInt(e.Value, 10))
+
+	case *types.FloatExpr:
+		return writeNode(bw, "FLOAT", strconv.FormatFloat(e.Value, 'g', -1, 64))
+
+	case *types.StringExpr:
+		return writeNode(bw, "STR", e.Value, boolField(e.IsIndented))
+
+	case *types.InterpStringExpr:
+		if err := writeNode(bw, "ISTR", boolField(e.IsIndented), strconv.Itoa(len(e.Parts))); err != nil {
+			return err
+		}
+
+		for _, part := range e.Parts {
+			if part.Expr == nil {
+				if err := writeNode(bw, "PARTLIT", part.Literal); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if err := writeNode(bw, "PARTEXPR"); err != nil {
+				return err
+			}
+
+			if err := dumpExpr(bw, part.Expr); err != nil {
+				return err
+			}
+		}
+
+		return nil
+
+	case *types.BoolExpr:
+		return writeNode(bw, "BOOL", boolField(e.Value))
+
+	case *types.NullExpr:
+		return writeNode(bw, "NULL")
+
+	case *types.PathExpr:
+		return writeNode(bw, "PATH", e.Value, boolField(e.IsAbsolute))
+
+	case *types.InterpPathExpr:
+		if err := writeNode(bw, "IPATH", boolField(e.IsAbsolute), strconv.Itoa(len(e.Parts))); err != nil {
+			return err
+		}
+
+		for _, part := range e.Parts {
+			if part.Expr == nil {
+				if err := writeNode(bw, "PARTLIT", part.Literal); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if err := writeNode(bw, "PARTEXPR"); err != nil {
+				return err
+			}
+
+			if err := dumpExpr(bw, part.Expr); err != nil {
+				return err
+			}
+		}
+
+		return nil
+
+	case *types.IdentExpr:
+		return writeNode(bw, "IDENT", e.Name)
+
+	case *types.ListExpr:
+		if err := writeNode(bw, "LIST", strconv.Itoa(len(e.Elements))); err != nil {
+			return err
+		}
+
+		for _, elem := range e.Elements {
+			if err := dumpExpr(bw, elem); err != nil {
+				return err
+			}
+		}
+
+		return nil
+
+	case *types.AttrSetExpr:
+		return dumpAttrSet(bw, e)
+
+	case *types.BinaryExpr:
+		if err := writeNode(bw, "BINARY", strconv.Itoa(int(e.Op))); err != nil {
+			return err
+		}
+
+		if err := dumpExpr(bw, e.Left); err != nil {
+			return err
+		}
+
+		return dumpExpr(bw, e.Right)
+
+	case *types.UnaryExpr:
+		if err := writeNode(bw, "UNARY", strconv.Itoa(int(e.Op))); err != nil {
+			return err
+		}
+
+		return dumpExpr(bw, e.Expr)
+
+	case *types.IfExpr:
+		if err := writeNode(bw, "IF"); err != nil {
+			return err
+		}
+
+		if err := dumpExpr(bw, e.Cond); err != nil {
+			return err
+		}
+
+		if err := dumpExpr(bw, e.Then); err != nil {
+			return err
+		}
+
+		return dumpExpr(bw, e.Else)
+
+	case *types.LetExpr:
+		return dumpLet(bw, e)
+
+	case *types.WithExpr:
+		if err := writeNode(bw, "WITH"); err != nil {
+			return err
+		}
+
+		if err := dumpExpr(bw, e.Expr); err != nil {
+			return err
+		}
+
+		return dumpExpr(bw, e.Body)
+
+	case *types.AssertExpr:
+		if err := writeNode(bw, "ASSERT"); err != nil {
+			return err
+		}
+
+		if err := dumpExpr(bw, e.Cond); err != nil {
+			return err
+		}
+
+		return dumpExpr(bw, e.Body)
+
+	case *types.FunctionExpr:
+		if err := writeNode(bw, "FUNCTION", e.Param, e.Doc); err != nil {
+			return err
+		}
+
+		if err := dumpPattern(bw, e.Pattern); err != nil {
+			return err
+		}
+
+		return dumpExpr(bw, e.Body)
+
+	case *types.ApplyExpr:
+		if err := writeNode(bw, "APPLY"); err != nil {
+			return err
+		}
+
+		if err := dumpExpr(bw, e.Func); err != nil {
+			return err
+		}
+
+		return dumpExpr(bw, e.Arg)
+
+	case *types.SelectExpr:
+		if err := writeNode(bw, "SELECT", joinPath(e.AttrPath)...); err != nil {
+			return err
+		}
+
+		if err := dumpExpr(bw, e.Expr); err != nil {
+			return err
+		}
+
+		return dumpExpr(bw, e.Default)
+
+	case *types.HasAttrExpr:
+		if err := writeNode(bw, "HASATTR", joinPath(e.AttrPath)...); err != nil {
+			return err
+		}
+
+		return dumpExpr(bw, e.Expr)
+
+	case *types.InheritExpr:
+		if err := writeNode(bw, "INHERITEXPR", joinPath(e.Attrs)...); err != nil {
+			return err
+		}
+
+		return dumpExpr(bw, e.From)
+
+	case *types.ErrorExpr:
+		fields := append([]string{e.Message}, joinPath(e.Tokens)...)
+
+		return writeNode(bw, "ERROR", fields...)
+
+	default:
+		return fmt.Errorf("parser: dump: unsupported node type %T", e)
+	}
+}
+
+// joinPath returns attrs prefixed with its own length, so Load knows how
+// many of the following quoted fields belong to it without needing a
+// sentinel.
+func joinPath(attrs []string) []string {
+	fields := make([]string, 0, len(attrs)+1)
+	fields = append(fields, strconv.Itoa(len(attrs)))
+	fields = append(fields, attrs...)
+
+	return fields
+}
+
+func dumpAttrSet(bw *bufio.Writer, e *types.AttrSetExpr) error {
+	if err := writeNode(bw, "ATTRSET",
+		boolField(e.Recursive), strconv.Itoa(len(e.Bindings)), strconv.Itoa(len(e.Inherits))); err != nil {
+		return err
+	}
+
+	for _, b := range e.Bindings {
+		if err := writeNode(bw, "ABIND", joinPath(b.Path)...); err != nil {
+			return err
+		}
+
+		if err := dumpExpr(bw, b.Value); err != nil {
+			return err
+		}
+	}
+
+	for _, inh := range e.Inherits {
+		if err := dumpInherit(bw, inh); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func dumpInherit(bw *bufio.Writer, inh types.InheritClause) error {
+	if err := writeNode(bw, "INHERIT", joinPath(inh.Attrs)...); err != nil {
+		return err
+	}
+
+	return dumpExpr(bw, inh.From)
+}
+
+func dumpLet(bw *bufio.Writer, e *types.LetExpr) error {
+	if err := writeNode(bw, "LET", strconv.Itoa(len(e.Bindings)), strconv.Itoa(len(e.Inherits))); err != nil {
+		return err
+	}
+
+	for _, b := range e.Bindings {
+		if err := writeNode(bw, "LBIND", b.Name); err != nil {
+			return err
+		}
+
+		if err := dumpExpr(bw, b.Value); err != nil {
+			return err
+		}
+	}
+
+	for _, inh := range e.Inherits {
+		if err := dumpInherit(bw, inh); err != nil {
+			return err
+		}
+	}
+
+	return dumpExpr(bw, e.Body)
+}
+
+func dumpPattern(bw *bufio.Writer, p *types.Pattern) error {
+	if p == nil {
+		return writeNil(bw)
+	}
+
+	fields := append([]string{strconv.Itoa(int(p.Type)), p.Name}, joinPath(p.Attrs)...)
+	fields = append(fields, boolField(p.Ellipsis))
+
+	return writeNode(bw, "PATTERN", fields...)
+}
+
+// loadExpr reads back one node dumpExpr wrote, recursing into its
+// children the same way dumpExpr descended into them.
+func (ld *loader) loadExpr() (types.Expr, error) {
+	line, ok := ld.next()
+	if !ok {
+		return nil, ld.errorf("unexpected end of dump")
+	}
+
+	if line == ";" {
+		return nil, nil
+	}
+
+	fr := newFieldReader(line)
+	kind := fr.word()
+
+	switch kind {
+	case "INT":
+		s, err := fr.quoted()
+		if err != nil {
+			return nil, ld.errorf("INT: %w", err)
+		}
+
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, ld.errorf("INT: %w", err)
+		}
+
+		return &types.IntExpr{Value: v}, nil
+
+	case "FLOAT":
+		s, err := fr.quoted()
+		if err != nil {
+			return nil, ld.errorf("FLOAT: %w", err)
+		}
+
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, ld.errorf("FLOAT: %w", err)
+		}
+
+		return &types.FloatExpr{Value: v}, nil
+
+	case "STR":
+		value, err := fr.quoted()
+		if err != nil {
+			return nil, ld.errorf("STR: %w", err)
+		}
+
+		indented, err := fr.bool()
+		if err != nil {
+			return nil, ld.errorf("STR: %w", err)
+		}
+
+		return &types.StringExpr{Value: value, IsIndented: indented}, nil
+
+	case "ISTR":
+		return ld.loadInterpString(fr)
+
+	case "BOOL":
+		v, err := fr.bool()
+		if err != nil {
+			return nil, ld.errorf("BOOL: %w", err)
+		}
+
+		return &types.BoolExpr{Value: v}, nil
+
+	case "NULL":
+		return &types.NullExpr{}, nil
+
+	case "PATH":
+		value, err := fr.quoted()
+		if err != nil {
+			return nil, ld.errorf("PATH: %w", err)
+		}
+
+		absolute, err := fr.bool()
+		if err != nil {
+			return nil, ld.errorf("PATH: %w", err)
+		}
+
+		return &types.PathExpr{Value: value, IsAbsolute: absolute}, nil
+
+	case "IPATH":
+		return ld.loadInterpPath(fr)
+
+	case "IDENT":
+		name, err := fr.quoted()
+		if err != nil {
+			return nil, ld.errorf("IDENT: %w", err)
+		}
+
+		return &types.IdentExpr{Name: name}, nil
+
+	case "LIST":
+		return ld.loadList(fr)
+
+	case "ATTRSET":
+		return ld.loadAttrSet(fr)
+
+	case "BINARY":
+		return ld.loadBinary(fr)
+
+	case "UNARY":
+		return ld.loadUnary(fr)
+
+	case "IF":
+		return ld.loadIf()
+
+	case "LET":
+		return ld.loadLet(fr)
+
+	case "WITH":
+		return ld.loadWith()
+
+	case "ASSERT":
+		return ld.loadAssert()
+
+	case "FUNCTION":
+		return ld.loadFunction(fr)
+
+	case "APPLY":
+		return ld.loadApply()
+
+	case "SELECT":
+		return ld.loadSelect(fr)
+
+	case "HASATTR":
+		return ld.loadHasAttr(fr)
+
+	case "INHERITEXPR":
+		return ld.loadInheritExpr(fr)
+
+	case "ERROR":
+		return ld.loadError(fr)
+
+	default:
+		return nil, ld.errorf("unknown node kind %q", kind)
+	}
+}
+
+func (ld *loader) loadStrings(fr *fieldReader) ([]string, error) {
+	n, err := fr.int()
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := make([]string, n)
+	for i := range attrs {
+		attrs[i], err = fr.quoted()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return attrs, nil
+}
+
+func (ld *loader) loadInterpString(fr *fieldReader) (types.Expr, error) {
+	indented, err := fr.bool()
+	if err != nil {
+		return nil, ld.errorf("ISTR: %w", err)
+	}
+
+	n, err := fr.int()
+	if err != nil {
+		return nil, ld.errorf("ISTR: %w", err)
+	}
+
+	parts := make([]types.StringPart, n)
+
+	for i := range parts {
+		line, ok := ld.next()
+		if !ok {
+			return nil, ld.errorf("ISTR: unexpected end of dump reading part %d", i)
+		}
+
+		pfr := newFieldReader(line)
+
+		switch tag := pfr.word(); tag {
+		case "PARTLIT":
+			lit, err := pfr.quoted()
+			if err != nil {
+				return nil, ld.errorf("PARTLIT: %w", err)
+			}
+
+			parts[i] = types.StringPart{Literal: lit}
+
+		case "PARTEXPR":
+			expr, err := ld.loadExpr()
+			if err != nil {
+				return nil, err
+			}
+
+			parts[i] = types.StringPart{Expr: expr}
+
+		default:
+			return nil, ld.errorf("expected PARTLIT or PARTEXPR, got %q", tag)
+		}
+	}
+
+	return &types.InterpStringExpr{Parts: parts, IsIndented: indented}, nil
+}
+
+func (ld *loader) loadInterpPath(fr *fieldReader) (types.Expr, error) {
+	absolute, err := fr.bool()
+	if err != nil {
+		return nil, ld.errorf("IPATH: %w", err)
+	}
+
+	n, err := fr.int()
+	if err != nil {
+		return nil, ld.errorf("IPATH: %w", err)
+	}
+
+	parts := make([]types.StringPart, n)
+
+	for i := range parts {
+		line, ok := ld.next()
+		if !ok {
+			return nil, ld.errorf("IPATH: unexpected end of dump reading part %d", i)
+		}
+
+		pfr := newFieldReader(line)
+
+		switch tag := pfr.word(); tag {
+		case "PARTLIT":
+			lit, err := pfr.quoted()
+			if err != nil {
+				return nil, ld.errorf("PARTLIT: %w", err)
+			}
+
+			parts[i] = types.StringPart{Literal: lit}
+
+		case "PARTEXPR":
+			expr, err := ld.loadExpr()
+			if err != nil {
+				return nil, err
+			}
+
+			parts[i] = types.StringPart{Expr: expr}
+
+		default:
+			return nil, ld.errorf("expected PARTLIT or PARTEXPR, got %q", tag)
+		}
+	}
+
+	return &types.InterpPathExpr{Parts: parts, IsAbsolute: absolute}, nil
+}
+
+func (ld *loader) loadList(fr *fieldReader) (types.Expr, error) {
+	n, err := fr.int()
+	if err != nil {
+		return nil, ld.errorf("LIST: %w", err)
+	}
+
+	elems := make([]types.Expr, n)
+
+	for i := range elems {
+		elems[i], err = ld.loadExpr()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &types.ListExpr{Elements: elems}, nil
+}
+
+func (ld *loader) loadInherits(n int) ([]types.InheritClause, error) {
+	inherits := make([]types.InheritClause, n)
+
+	for i := range inherits {
+		line, ok := ld.next()
+		if !ok {
+			return nil, ld.errorf("unexpected end of dump reading inherit %d", i)
+		}
+
+		fr := newFieldReader(line)
+		if tag := fr.word(); tag != "INHERIT" {
+			return nil, ld.errorf("expected INHERIT, got %q", tag)
+		}
+
+		attrs, err := ld.loadStrings(fr)
+		if err != nil {
+			return nil, ld.errorf("INHERIT: %w", err)
+		}
+
+		from, err := ld.loadExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		inherits[i] = types.InheritClause{From: from, Attrs: attrs}
+	}
+
+	return inherits, nil
+}
+
+func (ld *loader) loadAttrSet(fr *fieldReader) (types.Expr, error) {
+	recursive, err := fr.bool()
+	if err != nil {
+		return nil, ld.errorf("ATTRSET: %w", err)
+	}
+
+	numBindings, err := fr.int()
+	if err != nil {
+		return nil, ld.errorf("ATTRSET: %w", err)
+	}
+
+	numInherits, err := fr.int()
+	if err != nil {
+		return nil, ld.errorf("ATTRSET: %w", err)
+	}
+
+	bindings := make([]types.AttrBinding, numBindings)
+
+	for i := range bindings {
+		line, ok := ld.next()
+		if !ok {
+			return nil, ld.errorf("unexpected end of dump reading binding %d", i)
+		}
+
+		bfr := newFieldReader(line)
+		if tag := bfr.word(); tag != "ABIND" {
+			return nil, ld.errorf("expected ABIND, got %q", tag)
+		}
+
+		path, err := ld.loadStrings(bfr)
+		if err != nil {
+			return nil, ld.errorf("ABIND: %w", err)
+		}
+
+		value, err := ld.loadExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		bindings[i] = types.AttrBinding{Path: path, Value: value}
+	}
+
+	inherits, err := ld.loadInherits(numInherits)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.AttrSetExpr{Recursive: recursive, Bindings: bindings, Inherits: inherits}, nil
+}
+
+func (ld *loader) loadBinary(fr *fieldReader) (types.Expr, error) {
+	op, err := fr.int()
+	if err != nil {
+		return nil, ld.errorf("BINARY: %w", err)
+	}
+
+	left, err := ld.loadExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := ld.loadExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.BinaryExpr{Left: left, Op: types.BinaryOp(op), Right: right}, nil
+}
+
+func (ld *loader) loadUnary(fr *fieldReader) (types.Expr, error) {
+	op, err := fr.int()
+	if err != nil {
+		return nil, ld.errorf("UNARY: %w", err)
+	}
+
+	expr, err := ld.loadExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.UnaryExpr{Op: types.UnaryOp(op), Expr: expr}, nil
+}
+
+func (ld *loader) loadIf() (types.Expr, error) {
+	cond, err := ld.loadExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	then, err := ld.loadExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	els, err := ld.loadExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.IfExpr{Cond: cond, Then: then, Else: els}, nil
+}
+
+func (ld *loader) loadLet(fr *fieldReader) (types.Expr, error) {
+	numBindings, err := fr.int()
+	if err != nil {
+		return nil, ld.errorf("LET: %w", err)
+	}
+
+	numInherits, err := fr.int()
+	if err != nil {
+		return nil, ld.errorf("LET: %w", err)
+	}
+
+	bindings := make([]types.Binding, numBindings)
+
+	for i := range bindings {
+		line, ok := ld.next()
+		if !ok {
+			return nil, ld.errorf("unexpected end of dump reading binding %d", i)
+		}
+
+		bfr := newFieldReader(line)
+		if tag := bfr.word(); tag != "LBIND" {
+			return nil, ld.errorf("expected LBIND, got %q", tag)
+		}
+
+		name, err := bfr.quoted()
+		if err != nil {
+			return nil, ld.errorf("LBIND: %w", err)
+		}
+
+		value, err := ld.loadExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		bindings[i] = types.Binding{Name: name, Value: value}
+	}
+
+	inherits, err := ld.loadInherits(numInherits)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ld.loadExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.LetExpr{Bindings: bindings, Inherits: inherits, Body: body}, nil
+}
+
+func (ld *loader) loadWith() (types.Expr, error) {
+	scope, err := ld.loadExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ld.loadExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.WithExpr{Expr: scope, Body: body}, nil
+}
+
+func (ld *loader) loadAssert() (types.Expr, error) {
+	cond, err := ld.loadExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ld.loadExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.AssertExpr{Cond: cond, Body: body}, nil
+}
+
+func (ld *loader) loadPattern() (*types.Pattern, error) {
+	line, ok := ld.next()
+	if !ok {
+		return nil, ld.errorf("unexpected end of dump reading a pattern")
+	}
+
+	if line == ";" {
+		return nil, nil
+	}
+
+	fr := newFieldReader(line)
+	if tag := fr.word(); tag != "PATTERN" {
+		return nil, ld.errorf("expected PATTERN, got %q", tag)
+	}
+
+	typ, err := fr.int()
+	if err != nil {
+		return nil, ld.errorf("PATTERN: %w", err)
+	}
+
+	name, err := fr.quoted()
+	if err != nil {
+		return nil, ld.errorf("PATTERN: %w", err)
+	}
+
+	attrs, err := ld.loadStrings(fr)
+	if err != nil {
+		return nil, ld.errorf("PATTERN: %w", err)
+	}
+
+	ellipsis, err := fr.bool()
+	if err != nil {
+		return nil, ld.errorf("PATTERN: %w", err)
+	}
+
+	return &types.Pattern{Type: types.PatternType(typ), Name: name, Attrs: attrs, Ellipsis: ellipsis}, nil
+}
+
+func (ld *loader) loadFunction(fr *fieldReader) (types.Expr, error) {
+	param, err := fr.quoted()
+	if err != nil {
+		return nil, ld.errorf("FUNCTION: %w", err)
+	}
+
+	doc, err := fr.quoted()
+	if err != nil {
+		return nil, ld.errorf("FUNCTION: %w", err)
+	}
+
+	pattern, err := ld.loadPattern()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ld.loadExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.FunctionExpr{Param: param, Pattern: pattern, Body: body, Doc: doc}, nil
+}
+
+func (ld *loader) loadApply() (types.Expr, error) {
+	fn, err := ld.loadExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	arg, err := ld.loadExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.ApplyExpr{Func: fn, Arg: arg}, nil
+}
+
+func (ld *loader) loadSelect(fr *fieldReader) (types.Expr, error) {
+	path, err := ld.loadStrings(fr)
+	if err != nil {
+		return nil, ld.errorf("SELECT: %w", err)
+	}
+
+	expr, err := ld.loadExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	def, err := ld.loadExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.SelectExpr{Expr: expr, AttrPath: path, Default: def}, nil
+}
+
+func (ld *loader) loadHasAttr(fr *fieldReader) (types.Expr, error) {
+	path, err := ld.loadStrings(fr)
+	if err != nil {
+		return nil, ld.errorf("HASATTR: %w", err)
+	}
+
+	expr, err := ld.loadExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.HasAttrExpr{Expr: expr, AttrPath: path}, nil
+}
+
+func (ld *loader) loadInheritExpr(fr *fieldReader) (types.Expr, error) {
+	attrs, err := ld.loadStrings(fr)
+	if err != nil {
+		return nil, ld.errorf("INHERITEXPR: %w", err)
+	}
+
+	from, err := ld.loadExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.InheritExpr{From: from, Attrs: attrs}, nil
+}
+
+func (ld *loader) loadError(fr *fieldReader) (types.Expr, error) {
+	message, err := fr.quoted()
+	if err != nil {
+		return nil, ld.errorf("ERROR: %w", err)
+	}
+
+	tokens, err := ld.loadStrings(fr)
+	if err != nil {
+		return nil, ld.errorf("ERROR: %w", err)
+	}
+
+	return &types.ErrorExpr{Message: message, Tokens: tokens}, nil
+}
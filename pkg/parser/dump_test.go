@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/conneroisu/gix/internal/types"
+	"github.com/conneroisu/gix/pkg/lexer"
+)
+
+// dumpAndLoad parses source, dumps the result, loads it back, and returns
+// the reloaded file alongside the original for comparison.
+func dumpAndLoad(t *testing.T, source string) (*types.File, *types.File) {
+	t.Helper()
+
+	p := New(lexer.New(source))
+
+	root, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse(%q) returned error: %v", source, err)
+	}
+
+	original := &types.File{Source: source, Root: root}
+
+	var buf bytes.Buffer
+	if err := Dump(&buf, original); err != nil {
+		t.Fatalf("Dump(%q) returned error: %v", source, err)
+	}
+
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatalf("Load after Dump(%q) returned error: %v", source, err)
+	}
+
+	return original, loaded
+}
+
+func TestDumpLoadRoundTripsSource(t *testing.T) {
+	inputs := []string{
+		`1`,
+		`1.5`,
+		`"hello"`,
+		`''indented''`,
+		`"hello ${name}"`,
+		`true`,
+		`false`,
+		`null`,
+		`./relative.nix`,
+		`/absolute/path`,
+		`./foo/${name}.nix`,
+		`x`,
+		`[ 1 2 3 ]`,
+		`{ a = 1; b = 2; }`,
+		`rec { a = 1; b = a + 1; }`,
+		`{ inherit a b; inherit (c) d e; }`,
+		`1 + 2 * 3`,
+		`!true`,
+		`-1`,
+		`if true then 1 else 2`,
+		`let a = 1; b = 2; in a + b`,
+		`let inherit (x) a; in a`,
+		`with x; y`,
+		`assert true; 1`,
+		`x: x + 1`,
+		`{ a, b ? 1, ... }@args: a`,
+		`f x`,
+		`x.a.b`,
+		`x.a or 1`,
+		`x ? a`,
+	}
+
+	for _, input := range inputs {
+		original, loaded := dumpAndLoad(t, input)
+
+		if loaded.Source != original.Source {
+			t.Errorf("Source mismatch for %q: got %q", input, loaded.Source)
+		}
+
+		if loaded.String() != original.String() {
+			t.Errorf("round trip changed %q: got %q", original.String(), loaded.String())
+		}
+	}
+}
+
+func TestDumpOmitsSpans(t *testing.T) {
+	original, loaded := dumpAndLoad(t, "1 + 2")
+
+	if original.Root.Span() == loaded.Root.Span() && original.Root.Span() != (types.Span{}) {
+		t.Fatalf("expected Load to produce a zero-value Span, since Dump intentionally omits source positions")
+	}
+
+	if loaded.Root.Span() != (types.Span{}) {
+		t.Fatalf("expected a zero-value Span from Load, got %+v", loaded.Root.Span())
+	}
+}
@@ -10,23 +10,71 @@ type ParseError struct {
 	Message string
 	Line    int
 	Column  int
+
+	// Filename is the file this error was found in, or "" for a parse of
+	// anonymous input (a REPL line, a string passed to New directly).
+	// Set once, for every error a Parser records, via NewWithFilename.
+	Filename string
 }
 
 func (e ParseError) Error() string {
-	return fmt.Sprintf("parse error at line %d, column %d: %s", e.Line, e.Column, e.Message)
+	if e.Filename == "" {
+		return fmt.Sprintf("parse error at line %d, column %d: %s", e.Line, e.Column, e.Message)
+	}
+
+	return fmt.Sprintf("parse error at %s:%d:%d: %s", e.Filename, e.Line, e.Column, e.Message)
+}
+
+// SnippetWithCaret renders the offending line from source - the full text
+// the error's Parser parsed - followed by a line with a caret under the
+// error's column, e.g.:
+//
+//	let x = ; in x
+//	        ^
+//
+// It returns "" if Line falls outside source's line count.
+func (e ParseError) SnippetWithCaret(source string) string {
+	lines := strings.Split(source, "\n")
+	if e.Line < 1 || e.Line > len(lines) {
+		return ""
+	}
+
+	line := lines[e.Line-1]
+	column := e.Column - 1 // Column is 1-based, like the lexer's token columns
+	if column < 0 {
+		column = 0
+	}
+
+	return line + "\n" + strings.Repeat(" ", column) + "^"
 }
 
 // ParseErrors is a collection of parse errors.
 type ParseErrors struct {
 	errors []ParseError
+
+	// Filename is stamped onto every ParseError this collection records -
+	// see NewWithFilename.
+	Filename string
 }
 
-// Add adds a new parse error.
+// Add adds a new parse error, unless one was already recorded at the
+// same line/column. A single malformed token often trips more than one
+// production on the way back up the call stack - recovery mode
+// especially, re-deriving the same position from nested
+// parseExpression calls - so without this a single mistake can surface
+// as a wall of identical-looking diagnostics instead of one.
 func (p *ParseErrors) Add(msg string, line, column int) {
+	for _, e := range p.errors {
+		if e.Line == line && e.Column == column {
+			return
+		}
+	}
+
 	p.errors = append(p.errors, ParseError{
-		Message: msg,
-		Line:    line,
-		Column:  column,
+		Message:  msg,
+		Line:     line,
+		Column:   column,
+		Filename: p.Filename,
 	})
 }
 
@@ -1,12 +1,16 @@
 package parser
 
 import (
+	"fmt"
+
 	"github.com/conneroisu/gix/internal/types"
 	"github.com/conneroisu/gix/pkg/lexer"
 )
 
 // parseUnary parses unary expressions.
 func (p *Parser) parseUnary(op types.UnaryOp) types.Expr {
+	defer p.untrace(p.trace("parseUnary"))
+
 	p.advance()
 	expr := p.parseExpression(precedenceCall)
 
@@ -18,6 +22,8 @@ func (p *Parser) parseUnary(op types.UnaryOp) types.Expr {
 
 // parseBinary parses binary expressions.
 func (p *Parser) parseBinary(left types.Expr, op types.BinaryOp) types.Expr {
+	defer p.untrace(p.trace("parseBinary"))
+
 	precedence := p.curPrecedence()
 	p.advance()
 	right := p.parseExpression(precedence)
@@ -31,20 +37,37 @@ func (p *Parser) parseBinary(left types.Expr, op types.BinaryOp) types.Expr {
 
 // parseGrouped parses parenthesized expressions.
 func (p *Parser) parseGrouped() types.Expr {
+	defer p.untrace(p.trace("parseGrouped"))
+
 	p.advance() // skip '('
 
 	expr := p.parseExpression(precedenceLowest)
 
-	if !p.expectPeek(lexer.TOKEN_RPAREN) {
+	if !p.peekIs(lexer.TOKEN_RPAREN) {
+		msg := fmt.Sprintf("expected next token to be %v, got %v", lexer.TOKEN_RPAREN, p.peek.Type)
+		if p.recoverMode {
+			// The inner expression already parsed fine - keep it and
+			// just skip past the missing ')'.
+			p.advance()
+			p.recover(p.cur.Line, p.cur.Column, msg)
+
+			return expr
+		}
+		p.errors.Addf(p.peek.Line, p.peek.Column, "%s", msg)
+
 		return nil
 	}
+	p.advance()
 
 	return expr
 }
 
 // parseFunction parses function definitions.
 func (p *Parser) parseFunction() types.Expr {
+	defer p.untrace(p.trace("parseFunction"))
+
 	param := p.cur.Literal
+	doc := p.cur.Doc
 
 	if !p.expectPeek(lexer.TOKEN_COLON) {
 		return nil
@@ -56,11 +79,14 @@ func (p *Parser) parseFunction() types.Expr {
 	return &types.FunctionExpr{
 		Param: param,
 		Body:  body,
+		Doc:   doc,
 	}
 }
 
 // parseFunctionApplication parses function applications.
 func (p *Parser) parseFunctionApplication(fn types.Expr) types.Expr {
+	defer p.untrace(p.trace("parseFunctionApplication"))
+
 	arg := p.parseExpression(precedenceCall)
 
 	return &types.ApplyExpr{
@@ -71,6 +97,8 @@ func (p *Parser) parseFunctionApplication(fn types.Expr) types.Expr {
 
 // parseList parses list literals.
 func (p *Parser) parseList() types.Expr {
+	defer p.untrace(p.trace("parseList"))
+
 	p.advance() // skip '['
 
 	list := &types.ListExpr{
@@ -107,20 +135,201 @@ func (p *Parser) parseList() types.Expr {
 	return list
 }
 
+// parseRecAttrSet parses a recursive attribute set literal (rec { ... }),
+// where every binding can see every other binding in the same set, not
+// just ones already parsed - e.g. rec { a = 1; b = a + 1; }. It skips
+// 'rec' and hands off to parseAttrSet for the braces and bindings
+// themselves, then marks the result Recursive.
+func (p *Parser) parseRecAttrSet() types.Expr {
+	defer p.untrace(p.trace("parseRecAttrSet"))
+
+	p.advance() // skip 'rec'
+
+	if !p.curIs(lexer.TOKEN_LBRACE) {
+		msg := fmt.Sprintf("expected '{' after 'rec', got %v", p.cur.Type)
+		if p.recoverMode {
+			return p.recover(p.cur.Line, p.cur.Column, msg)
+		}
+
+		p.errors.Addf(p.cur.Line, p.cur.Column, "%s", msg)
+
+		return nil
+	}
+
+	expr := p.parseAttrSet()
+	if attrs, ok := expr.(*types.AttrSetExpr); ok {
+		attrs.Recursive = true
+	}
+
+	return expr
+}
+
+// parseAttrSetOrPattern parses a "{" that could open either an
+// attribute-set literal ("{ a = 1; }") or a function's attrset
+// parameter pattern ("{ a, b ? 1, ... }: body"). The two share a "{"
+// prefix, so looksLikePattern peeks far enough in to tell them apart
+// before committing to either parse path.
+func (p *Parser) parseAttrSetOrPattern() types.Expr {
+	defer p.untrace(p.trace("parseAttrSetOrPattern"))
+
+	if p.looksLikePattern() {
+		doc := p.cur.Doc
+
+		return p.parsePatternFunction("", doc)
+	}
+
+	return p.parseAttrSet()
+}
+
+// looksLikePattern reports whether the "{" at cur opens a function
+// parameter pattern rather than an attrset literal. cur is left
+// unmodified either way. A binding always needs "=" and a nested path
+// always needs ".", neither of which a pattern entry ever has, so a
+// bare name followed by ",", "?", or the closing "}" can only be a
+// pattern; "..." as the first real token can likewise only be a
+// pattern's ellipsis. An empty "{}" is a pattern only when ":" or "@"
+// follows it. Everything else parses as an attrset.
+func (p *Parser) looksLikePattern() bool {
+	switch p.peek.Type {
+	case lexer.TOKEN_IDENT:
+		switch p.peekAhead().Type {
+		case lexer.TOKEN_COMMA, lexer.TOKEN_QUESTION, lexer.TOKEN_RBRACE:
+			return true
+		default:
+			return false
+		}
+	case lexer.TOKEN_DOT:
+		return true
+	case lexer.TOKEN_RBRACE:
+		switch p.peekAhead().Type {
+		case lexer.TOKEN_COLON, lexer.TOKEN_AT:
+			return true
+		default:
+			return false
+		}
+	default:
+		return false
+	}
+}
+
+// parsePatternFunction parses a function whose parameter is an attrset
+// pattern: cur is the pattern's opening "{". name and doc come from an
+// already-consumed "name@" prefix (see parseIdentifierOrFunction's
+// TOKEN_AT case); pass "" for name when there's no such prefix, in
+// which case a trailing "@name" after the closing "}" is still picked
+// up here.
+func (p *Parser) parsePatternFunction(name, doc string) types.Expr {
+	defer p.untrace(p.trace("parsePatternFunction"))
+
+	pattern := p.parseAttrSetPattern()
+	if pattern == nil {
+		return nil
+	}
+	pattern.Name = name
+
+	if p.peekIs(lexer.TOKEN_AT) {
+		p.advance() // move to '@'
+
+		if !p.expectPeek(lexer.TOKEN_IDENT) {
+			return nil
+		}
+		pattern.Name = p.cur.Literal
+	}
+
+	if !p.expectPeek(lexer.TOKEN_COLON) {
+		return nil
+	}
+	p.advance()
+
+	body := p.parseExpression(precedenceLowest)
+
+	return &types.FunctionExpr{Pattern: pattern, Body: body, Doc: doc}
+}
+
+// parseAttrSetPattern parses the "{ a, b ? 1, ... }" part of an
+// attrset function pattern. cur is the opening "{" on entry and the
+// closing "}" on return. A "? default" expression is parsed, so it
+// can't desynchronize the token stream, but isn't retained: Pattern
+// only has room for the attribute's name, matching the dump/load
+// golden format's PATTERN record, which has no field for default
+// expressions either.
+func (p *Parser) parseAttrSetPattern() *types.Pattern {
+	defer p.untrace(p.trace("parseAttrSetPattern"))
+
+	p.advance() // skip '{'
+
+	pattern := &types.Pattern{Type: types.AttrSetPattern}
+
+	if p.curIs(lexer.TOKEN_RBRACE) {
+		return pattern
+	}
+
+	for {
+		if p.curIs(lexer.TOKEN_DOT) {
+			if !p.parseEllipsis() {
+				return nil
+			}
+			pattern.Ellipsis = true
+
+			break
+		}
+
+		if !p.curIs(lexer.TOKEN_IDENT) {
+			p.errors.Addf(p.cur.Line, p.cur.Column,
+				"expected identifier or '...' in pattern, got %v", p.cur.Type)
+
+			return nil
+		}
+		pattern.Attrs = append(pattern.Attrs, p.cur.Literal)
+
+		if p.peekIs(lexer.TOKEN_QUESTION) {
+			p.advance() // move to '?'
+			p.advance() // move to default expression
+
+			if p.parseExpression(precedenceLowest) == nil {
+				return nil
+			}
+		}
+
+		if !p.peekIs(lexer.TOKEN_COMMA) {
+			break
+		}
+		p.advance() // move to ','
+		p.advance() // move to next attr, '...', or a trailing '}'
+	}
+
+	if !p.expectPeek(lexer.TOKEN_RBRACE) {
+		return nil
+	}
+
+	return pattern
+}
+
+// parseEllipsis consumes the three-dot "..." marker that, if present,
+// must be the last entry in an attrset pattern. The lexer has no
+// dedicated ellipsis token - "..." scans as three consecutive
+// TOKEN_DOT, the same as a lone "." would between two non-path tokens
+// - so this just expects two more after the one already at cur.
+func (p *Parser) parseEllipsis() bool {
+	for range 2 {
+		if !p.expectPeek(lexer.TOKEN_DOT) {
+			return false
+		}
+	}
+
+	return true
+}
+
 // parseAttrSet parses attribute set literals.
 func (p *Parser) parseAttrSet() types.Expr {
+	defer p.untrace(p.trace("parseAttrSet"))
+
 	p.advance() // skip '{'
 
 	attrs := &types.AttrSetExpr{
 		Bindings: []types.AttrBinding{},
 	}
 
-	// Check for recursive attribute set
-	if p.curIs(lexer.TOKEN_REC) {
-		attrs.Recursive = true
-		p.advance()
-	}
-
 	// Empty attribute set
 	if p.curIs(lexer.TOKEN_RBRACE) {
 		return attrs
@@ -129,12 +338,34 @@ func (p *Parser) parseAttrSet() types.Expr {
 	// Parse bindings
 	for !p.curIs(lexer.TOKEN_RBRACE) && !p.curIs(lexer.TOKEN_EOF) {
 		if p.curIs(lexer.TOKEN_INHERIT) {
-			p.parseInherit(attrs)
+			clause := p.parseInherit()
+			if clause == nil {
+				// parseInherit already recorded the error. Outside
+				// recovery mode it never advances past the token that
+				// broke it, so looping back here would spin forever -
+				// bail out with whatever's been parsed so far.
+				if !p.recoverMode {
+					break
+				}
+
+				p.advance()
+
+				continue
+			}
+			attrs.Inherits = append(attrs.Inherits, *clause)
 		} else {
 			binding := p.parseBinding()
-			if binding != nil {
-				attrs.Bindings = append(attrs.Bindings, *binding)
+			if binding == nil {
+				// Same reasoning as the parseInherit case above.
+				if !p.recoverMode {
+					break
+				}
+
+				p.advance()
+
+				continue
 			}
+			attrs.Bindings = append(attrs.Bindings, *binding)
 		}
 
 		if p.curIs(lexer.TOKEN_RBRACE) {
@@ -143,8 +374,15 @@ func (p *Parser) parseAttrSet() types.Expr {
 	}
 
 	if !p.curIs(lexer.TOKEN_RBRACE) {
-		p.errors.Addf(p.cur.Line, p.cur.Column,
-			"expected '}', got %v", p.cur.Type)
+		msg := fmt.Sprintf("expected '}', got %v", p.cur.Type)
+		if p.recoverMode {
+			// Keep whatever bindings/inherits were already parsed -
+			// they're still a valid partial attrset.
+			p.recover(p.cur.Line, p.cur.Column, msg)
+
+			return attrs
+		}
+		p.errors.Addf(p.cur.Line, p.cur.Column, "%s", msg)
 
 		return nil
 	}
@@ -154,78 +392,213 @@ func (p *Parser) parseAttrSet() types.Expr {
 
 // parseBinding parses a single attribute binding.
 func (p *Parser) parseBinding() *types.AttrBinding {
+	startTok := p.cur
+	doc := p.cur.Doc
+
 	// Parse attribute path
 	path := p.parseAttrPath()
 	if path == nil {
 		return nil
 	}
 
-	if !p.expectPeek(lexer.TOKEN_ASSIGN) {
+	if !p.peekIs(lexer.TOKEN_ASSIGN) {
+		msg := fmt.Sprintf("expected next token to be %v, got %v", lexer.TOKEN_ASSIGN, p.peek.Type)
+		if p.recoverMode {
+			// No value was ever parsed, so there's nothing salvageable
+			// for this binding - fall back to an ErrorExpr value.
+			p.advance()
+
+			return &types.AttrBinding{Path: path, Value: p.recover(p.cur.Line, p.cur.Column, msg), Span: tokenSpan(startTok, p.cur)}
+		}
+		p.errors.Addf(p.peek.Line, p.peek.Column, "%s", msg)
+
 		return nil
 	}
+	p.advance()
 
 	p.advance()
 	value := p.parseExpression(precedenceLowest)
+	attachDoc(value, doc)
+
+	if !p.peekIs(lexer.TOKEN_SEMICOLON) {
+		msg := fmt.Sprintf("expected next token to be %v, got %v", lexer.TOKEN_SEMICOLON, p.peek.Type)
+		if p.recoverMode {
+			// value already parsed fine - keep it and just skip past
+			// the missing ';'.
+			p.advance()
+			p.recover(p.cur.Line, p.cur.Column, msg)
+
+			return &types.AttrBinding{Path: path, Value: value, Span: tokenSpan(startTok, p.cur)}
+		}
+		p.errors.Addf(p.peek.Line, p.peek.Column, "%s", msg)
 
-	if !p.expectPeek(lexer.TOKEN_SEMICOLON) {
 		return nil
 	}
+	semiTok := p.peek
+	p.advance()
 
 	p.advance() // position on next token
 
 	return &types.AttrBinding{
 		Path:  path,
 		Value: value,
+		Span:  tokenSpan(startTok, semiTok),
 	}
 }
 
-// parseAttrPath parses an attribute path.
+// attachDoc records doc as value's doc comment when value is a function
+// that doesn't already have one of its own (e.g. `x: x+1` written
+// directly as a binding's value takes its doc from the binding's name,
+// not the parameter, so fn.Doc is normally empty here).
+func attachDoc(value types.Expr, doc string) {
+	if doc == "" {
+		return
+	}
+
+	if fn, ok := value.(*types.FunctionExpr); ok && fn.Doc == "" {
+		fn.Doc = doc
+	}
+}
+
+// parseAttrPath parses an attribute path. In recovery mode, a malformed
+// path doesn't abort the parse: it synchronizes to the next statement
+// boundary and returns a placeholder "<error>" segment instead of nil,
+// so callers (parseBinding, parseSelect, parseHasAttr) can keep
+// building a partial AST around it. A path is just a []string, with no
+// natural place to hang a types.ErrorExpr, which is why this reports
+// the error via Errors() rather than an ErrorExpr node, unlike the
+// Expr-returning recovery paths elsewhere in this file.
 func (p *Parser) parseAttrPath() []string {
 	var path []string
 
-	if !p.curIs(lexer.TOKEN_IDENT) && !p.curIs(lexer.TOKEN_STRING) {
-		p.errors.Addf(p.cur.Line, p.cur.Column,
-			"expected identifier or string, got %v", p.cur.Type)
+	name, ok := p.parseAttrName("")
+	if !ok {
+		if p.recoverMode {
+			p.synchronize(nil)
+
+			return []string{"<error>"}
+		}
 
 		return nil
 	}
 
-	path = append(path, p.cur.Literal)
+	path = append(path, name)
 
 	for p.peekIs(lexer.TOKEN_DOT) {
 		p.advance() // consume dot
 		p.advance() // get next part
 
-		if !p.curIs(lexer.TOKEN_IDENT) && !p.curIs(lexer.TOKEN_STRING) {
-			p.errors.Addf(p.cur.Line, p.cur.Column,
-				"expected identifier or string after dot, got %v", p.cur.Type)
+		name, ok := p.parseAttrName("after dot")
+		if !ok {
+			if p.recoverMode {
+				p.synchronize(nil)
+
+				return append(path, "<error>")
+			}
 
 			return nil
 		}
 
-		path = append(path, p.cur.Literal)
+		path = append(path, name)
 	}
 
 	return path
 }
 
-// parseInherit parses inherit statements.
-func (p *Parser) parseInherit(attrs *types.AttrSetExpr) {
+// parseAttrName parses a single attribute path segment at the current
+// token: a bare identifier, or a quoted string name. A quoted name must
+// not contain a "${...}" interpolation - a dynamically computed
+// attribute name isn't supported in any attribute path, including the
+// path that names a binding in an attribute-set literal. context is
+// folded into the "expected identifier or string" error message (e.g.
+// "after dot") to match the mis-parse site; pass "" for the first
+// segment of a path, which needs no qualifier.
+func (p *Parser) parseAttrName(context string) (string, bool) {
+	switch {
+	case p.curIs(lexer.TOKEN_IDENT):
+		return p.cur.Literal, true
+
+	case p.curIs(lexer.TOKEN_DQUOTE) || p.curIs(lexer.TOKEN_ISTRING_QUOTE):
+		expr := p.parseStringLiteral()
+		if expr == nil {
+			// parseStringLiteral already recorded the error (e.g. an
+			// unterminated literal).
+			return "", false
+		}
+
+		str, ok := expr.(*types.StringExpr)
+		if !ok {
+			p.errors.Addf(p.cur.Line, p.cur.Column,
+				"attribute name cannot contain an interpolated expression")
+
+			return "", false
+		}
+
+		return str.Value, true
+
+	default:
+		if context != "" {
+			p.errors.Addf(p.cur.Line, p.cur.Column,
+				"expected identifier or string %s, got %v", context, p.cur.Type)
+		} else {
+			p.errors.Addf(p.cur.Line, p.cur.Column,
+				"expected identifier or string, got %v", p.cur.Type)
+		}
+
+		return "", false
+	}
+}
+
+// parseInherit parses inherit statements, both the plain form
+// (`inherit x y z;`, which copies bindings from the enclosing scope)
+// and the scoped form (`inherit (expr) x y z;`, which copies the named
+// attributes out of expr). Used by both parseAttrSet and parseLet,
+// since `let` also allows inherit clauses alongside its bindings.
+// Returns nil on a parse error, having already recorded it.
+func (p *Parser) parseInherit() *types.InheritClause {
+	defer p.untrace(p.trace("parseInherit"))
+
+	startTok := p.cur
 	p.advance() // skip 'inherit'
 
-	// TODO: Implement full inherit parsing
-	// For now, skip to semicolon
-	for !p.curIs(lexer.TOKEN_SEMICOLON) && !p.curIs(lexer.TOKEN_EOF) {
-		p.advance()
+	clause := &types.InheritClause{}
+
+	if p.curIs(lexer.TOKEN_LPAREN) {
+		p.advance() // skip '('
+		clause.From = p.parseExpression(precedenceLowest)
+
+		if !p.expectPeek(lexer.TOKEN_RPAREN) {
+			return nil
+		}
+		p.advance() // position on first inherited name
 	}
 
-	if p.curIs(lexer.TOKEN_SEMICOLON) {
+	for p.curIs(lexer.TOKEN_IDENT) || p.curIs(lexer.TOKEN_DQUOTE) || p.curIs(lexer.TOKEN_ISTRING_QUOTE) {
+		name, ok := p.parseAttrName("in inherit")
+		if !ok {
+			return nil
+		}
+
+		clause.Attrs = append(clause.Attrs, name)
 		p.advance()
 	}
+
+	if !p.curIs(lexer.TOKEN_SEMICOLON) {
+		p.errors.Addf(p.cur.Line, p.cur.Column,
+			"expected ';' after inherit, got %v", p.cur.Type)
+
+		return nil
+	}
+	clause.Span = tokenSpan(startTok, p.cur)
+	p.advance() // position on next token
+
+	return clause
 }
 
 // parseSelect parses attribute selection.
 func (p *Parser) parseSelect(expr types.Expr) types.Expr {
+	defer p.untrace(p.trace("parseSelect"))
+
 	p.advance() // consume dot
 
 	path := p.parseAttrPath()
@@ -0,0 +1,155 @@
+package parser
+
+import (
+	"github.com/conneroisu/gix/internal/types"
+	"github.com/conneroisu/gix/pkg/lexer"
+)
+
+// TextEdit describes a single contiguous edit to source text, expressed
+// as byte offsets into the previous version: bytes [Start, End) are
+// replaced with NewText.
+type TextEdit struct {
+	Start   int
+	End     int
+	NewText string
+}
+
+// ParseIncremental re-parses prev's source after applying edit, reusing
+// already-parsed subtrees that lie entirely outside the edited byte
+// range where it can, instead of throwing the whole tree away and
+// re-parsing from scratch.
+//
+// The only case handled without a full re-parse is the common editor
+// scenario of editing inside a single top-level binding of a `let` or
+// attribute set: if edit falls entirely within one binding's value
+// span, every other binding's Expr subtree is reused by reference
+// (O(number of bindings)) and only the edited binding's source text is
+// re-lexed and re-parsed (O(binding size), not O(file size)). Every other
+// case - edits that touch binding boundaries, the let/in/rec/{ }
+// scaffolding itself, or a file that isn't a LetExpr/AttrSetExpr at the
+// top level - falls back to a full re-parse of the whole file, which is
+// always correct, just not incremental.
+//
+// Positions recorded on reused subtrees describe their place in the OLD
+// source text and are not shifted to account for the edit's length
+// delta. That's fine for the common "fix one binding, see the result"
+// editor loop this targets, but chaining many ParseIncremental calls
+// without an occasional full re-parse will accumulate stale positions
+// on the reused bindings.
+func ParseIncremental(prev *types.File, edit TextEdit) *types.File {
+	newSource := prev.Source[:edit.Start] + edit.NewText + prev.Source[edit.End:]
+
+	if reused := tryReuseBindings(prev, edit, newSource); reused != nil {
+		return reused
+	}
+
+	return fullReparse(newSource)
+}
+
+// ParseFile parses source in error-recovery mode and wraps the result
+// together with the source text, giving ParseIncremental something to
+// work from on later edits.
+func ParseFile(source string) *types.File {
+	return fullReparse(source)
+}
+
+// fullReparse re-parses source from scratch in error-recovery mode,
+// since ParseIncremental's callers are editor-style tools that need a
+// best-effort tree even from currently-invalid source.
+func fullReparse(source string) *types.File {
+	p := New(lexer.New(source))
+	p.EnableErrorRecovery()
+	root, _ := p.Parse()
+
+	return &types.File{Source: source, Root: root}
+}
+
+// tryReuseBindings attempts the single-binding reuse path for prev.Root,
+// returning nil if prev.Root's shape or the edit's location don't fit it.
+func tryReuseBindings(prev *types.File, edit TextEdit, newSource string) *types.File {
+	switch root := prev.Root.(type) {
+	case *types.LetExpr:
+		bindings, ok := reuseLetBindings(root.Bindings, edit, newSource)
+		if !ok {
+			return nil
+		}
+
+		reused := *root
+		reused.Bindings = bindings
+
+		return &types.File{Source: newSource, Root: &reused}
+
+	case *types.AttrSetExpr:
+		bindings, ok := reuseAttrBindings(root.Bindings, edit, newSource)
+		if !ok {
+			return nil
+		}
+
+		reused := *root
+		reused.Bindings = bindings
+
+		return &types.File{Source: newSource, Root: &reused}
+
+	default:
+		return nil
+	}
+}
+
+// delta is how much longer (or shorter) newSource is than the source
+// edit was applied to, at every byte offset past edit.End.
+func (e TextEdit) delta() int { return len(e.NewText) - (e.End - e.Start) }
+
+// reuseLetBindings rewrites a LetExpr's Bindings for edit, reusing every
+// binding whose value doesn't overlap the edit and re-parsing only the
+// one that does. ok is false if no single binding's value span fully
+// contains the edit (e.g. the edit crosses binding boundaries).
+func reuseLetBindings(bindings []types.Binding, edit TextEdit, newSource string) ([]types.Binding, bool) {
+	for i, b := range bindings {
+		span := b.Value.Span()
+		if !spanContains(span, edit) {
+			continue
+		}
+
+		newValueSrc := newSource[span.Start.Offset : span.End.Offset+edit.delta()]
+
+		p := New(lexer.New(newValueSrc))
+		p.EnableErrorRecovery()
+		newValue, _ := p.Parse()
+
+		out := append([]types.Binding(nil), bindings...)
+		out[i] = types.Binding{Name: b.Name, Value: newValue, Span: b.Span}
+
+		return out, true
+	}
+
+	return nil, false
+}
+
+// reuseAttrBindings is reuseLetBindings for an AttrSetExpr's Bindings.
+func reuseAttrBindings(bindings []types.AttrBinding, edit TextEdit, newSource string) ([]types.AttrBinding, bool) {
+	for i, b := range bindings {
+		span := b.Value.Span()
+		if !spanContains(span, edit) {
+			continue
+		}
+
+		newValueSrc := newSource[span.Start.Offset : span.End.Offset+edit.delta()]
+
+		p := New(lexer.New(newValueSrc))
+		p.EnableErrorRecovery()
+		newValue, _ := p.Parse()
+
+		out := append([]types.AttrBinding(nil), bindings...)
+		out[i] = types.AttrBinding{Path: b.Path, Value: newValue, Span: b.Span}
+
+		return out, true
+	}
+
+	return nil, false
+}
+
+// spanContains reports whether edit's old byte range falls entirely
+// within span (span describes positions in the source the edit applies to).
+func spanContains(span types.Span, edit TextEdit) bool {
+	return edit.Start >= span.Start.Offset && edit.End <= span.End.Offset
+}
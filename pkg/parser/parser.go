@@ -1,20 +1,69 @@
 package parser
 
 import (
+	"fmt"
+	"io"
 	"strconv"
+	"strings"
 
 	"github.com/conneroisu/gix/internal/types"
 	"github.com/conneroisu/gix/pkg/lexer"
 )
 
+// PrefixParseFn parses an expression that begins with the current token
+// (the "nud", or null denotation, in Pratt parsing terminology). Register
+// one with RegisterPrefix to add a new literal form or prefix operator
+// to the grammar.
+type PrefixParseFn func() types.Expr
+
+// InfixParseFn parses an expression that continues from an already-parsed
+// left-hand side (the "led", or left denotation, in Pratt parsing
+// terminology). Register one with RegisterInfix to add a new binary
+// operator or other infix form to the grammar.
+type InfixParseFn func(left types.Expr) types.Expr
+
 // Parser implements a recursive descent parser with Pratt parsing for Nix expressions.
 // It transforms a stream of tokens from the lexer into an Abstract Syntax Tree (AST).
 // The parser uses lookahead (cur/peek tokens) for disambiguation and precedence handling.
+//
+// Dispatch is table-driven: prefixParseFns and infixParseFns map token types to the
+// function responsible for parsing them, and precedenceMap maps an infix token type to
+// its binding power. RegisterPrefix/RegisterInfix let callers plug in new prefix/infix
+// forms (e.g. a dialect with extra operators, or experimental syntax like a pipe |>)
+// without touching the core parseExpression loop.
 type Parser struct {
 	l      *lexer.Lexer // The lexer providing the token stream
 	cur    lexer.Token  // Current token being processed
 	peek   lexer.Token  // Next token (lookahead for parsing decisions)
 	errors *ParseErrors // Accumulated parsing errors for comprehensive reporting
+
+	// pending holds tokens already pulled from l that haven't been
+	// shifted into cur/peek yet. advance() drains it before reading
+	// from l, so it transparently extends the lookahead window; see
+	// peekAhead.
+	pending []lexer.Token
+
+	prefixParseFns map[lexer.TokenType]PrefixParseFn
+	infixParseFns  map[lexer.TokenType]InfixParseFn
+	precedenceMap  map[lexer.TokenType]int
+
+	// recoverMode, when set via EnableErrorRecovery, makes failed
+	// productions synchronize and emit a types.ErrorExpr instead of
+	// aborting the parse. See recovery.go.
+	recoverMode bool
+
+	// events, once created by Events(), receives EnterNode/LeaveNode/
+	// Error notifications as parsing progresses. Left nil (and thus
+	// free) unless a caller asks for it. See recovery.go.
+	events       chan Event
+	eventsClosed bool
+
+	// traceWriter, once set via EnableTrace, receives an indented log of
+	// every traced parseX call. Left nil (and thus free) unless a caller
+	// asks for it. traceDepth tracks the current nesting depth so the log
+	// indents into a readable call tree. See parser_tracing.go.
+	traceWriter io.Writer
+	traceDepth  int
 }
 
 // New creates a new parser instance from a lexer.
@@ -23,9 +72,14 @@ type Parser struct {
 // for distinguishing ambiguous constructs and implementing operator precedence.
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
-		l:      l,
-		errors: &ParseErrors{}, // Initialize empty error collection
+		l:              l,
+		errors:         &ParseErrors{}, // Initialize empty error collection
+		prefixParseFns: make(map[lexer.TokenType]PrefixParseFn),
+		infixParseFns:  make(map[lexer.TokenType]InfixParseFn),
+		precedenceMap:  make(map[lexer.TokenType]int),
 	}
+	p.registerDefaultParseFns()
+
 	// Prime the parser by reading the first two tokens
 	// This establishes the cur/peek window needed for parsing decisions
 	p.advance() // Sets cur to first token, peek to second
@@ -34,16 +88,122 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
+// NewWithFilename creates a parser like New, but stamps filename onto
+// every ParseError it records (see ParseError.Filename), so errors from a
+// multi-file evaluation - an import chain, say - say which file they came
+// from instead of a bare line and column.
+func NewWithFilename(l *lexer.Lexer, filename string) *Parser {
+	p := New(l)
+	p.errors.Filename = filename
+
+	return p
+}
+
+// RegisterPrefix associates a prefix parse function with a token type,
+// letting an embedder add a new literal form or prefix operator (or
+// override a built-in one) without forking the package. Registering a
+// new function for an existing token type replaces it.
+func (p *Parser) RegisterPrefix(tokenType lexer.TokenType, fn PrefixParseFn) {
+	p.prefixParseFns[tokenType] = fn
+}
+
+// RegisterInfix associates an infix parse function with a token type and
+// records its binding power in precedenceMap, so a newly registered
+// operator (e.g. an experimental pipe |>) participates in
+// parseExpression's precedence climbing the same way a built-in one
+// does. Registering a new function for an existing token type replaces
+// both its parse function and its precedence.
+func (p *Parser) RegisterInfix(tokenType lexer.TokenType, fn InfixParseFn, precedence int) {
+	p.infixParseFns[tokenType] = fn
+	p.precedenceMap[tokenType] = precedence
+}
+
+// registerDefaultParseFns wires up the parse functions for the core Nix grammar.
+// Dialects or tools embedding the parser can call RegisterPrefix/RegisterInfix
+// afterwards to add or override entries without editing this table.
+func (p *Parser) registerDefaultParseFns() {
+	p.RegisterPrefix(lexer.TOKEN_INT, p.parseInt)
+	p.RegisterPrefix(lexer.TOKEN_FLOAT, p.parseFloat)
+	p.RegisterPrefix(lexer.TOKEN_DQUOTE, p.parseStringLiteral)
+	p.RegisterPrefix(lexer.TOKEN_ISTRING_QUOTE, p.parseStringLiteral)
+	p.RegisterPrefix(lexer.TOKEN_PATH, p.parsePath)
+	p.RegisterPrefix(lexer.TOKEN_PATH_PART, p.parsePathLiteral)
+	p.RegisterPrefix(lexer.TOKEN_IDENT, p.parseIdentifierOrFunction)
+	p.RegisterPrefix(lexer.TOKEN_IF, p.parseIf)
+	p.RegisterPrefix(lexer.TOKEN_LET, p.parseLet)
+	p.RegisterPrefix(lexer.TOKEN_WITH, p.parseWith)
+	p.RegisterPrefix(lexer.TOKEN_ASSERT, p.parseAssert)
+	p.RegisterPrefix(lexer.TOKEN_NOT, func() types.Expr { return p.parseUnary(types.OpNot) })
+	p.RegisterPrefix(lexer.TOKEN_MINUS, func() types.Expr { return p.parseUnary(types.OpNeg) })
+	p.RegisterPrefix(lexer.TOKEN_LBRACE, p.parseAttrSetOrPattern)
+	p.RegisterPrefix(lexer.TOKEN_REC, p.parseRecAttrSet)
+	p.RegisterPrefix(lexer.TOKEN_LBRACKET, p.parseList)
+	p.RegisterPrefix(lexer.TOKEN_LPAREN, p.parseGrouped)
+
+	p.RegisterInfix(lexer.TOKEN_PLUS, func(left types.Expr) types.Expr {
+		return p.parseBinary(left, types.OpAdd)
+	}, precedenceSum)
+	p.RegisterInfix(lexer.TOKEN_MINUS, func(left types.Expr) types.Expr {
+		return p.parseBinary(left, types.OpSub)
+	}, precedenceSum)
+	p.RegisterInfix(lexer.TOKEN_MULTIPLY, func(left types.Expr) types.Expr {
+		return p.parseBinary(left, types.OpMul)
+	}, precedenceProduct)
+	p.RegisterInfix(lexer.TOKEN_DIVIDE, func(left types.Expr) types.Expr {
+		return p.parseBinary(left, types.OpDiv)
+	}, precedenceProduct)
+	p.RegisterInfix(lexer.TOKEN_CONCAT, func(left types.Expr) types.Expr {
+		return p.parseBinary(left, types.OpConcat)
+	}, precedenceConcat)
+	p.RegisterInfix(lexer.TOKEN_UPDATE, func(left types.Expr) types.Expr {
+		return p.parseBinary(left, types.OpUpdate)
+	}, precedenceUpdate)
+	p.RegisterInfix(lexer.TOKEN_EQ, func(left types.Expr) types.Expr {
+		return p.parseBinary(left, types.OpEq)
+	}, precedenceEquals)
+	p.RegisterInfix(lexer.TOKEN_NEQ, func(left types.Expr) types.Expr {
+		return p.parseBinary(left, types.OpNEq)
+	}, precedenceEquals)
+	p.RegisterInfix(lexer.TOKEN_LT, func(left types.Expr) types.Expr {
+		return p.parseBinary(left, types.OpLT)
+	}, precedenceCompare)
+	p.RegisterInfix(lexer.TOKEN_GT, func(left types.Expr) types.Expr {
+		return p.parseBinary(left, types.OpGT)
+	}, precedenceCompare)
+	p.RegisterInfix(lexer.TOKEN_LTE, func(left types.Expr) types.Expr {
+		return p.parseBinary(left, types.OpLTE)
+	}, precedenceCompare)
+	p.RegisterInfix(lexer.TOKEN_GTE, func(left types.Expr) types.Expr {
+		return p.parseBinary(left, types.OpGTE)
+	}, precedenceCompare)
+	p.RegisterInfix(lexer.TOKEN_AND, func(left types.Expr) types.Expr {
+		return p.parseBinary(left, types.OpAnd)
+	}, precedenceAnd)
+	p.RegisterInfix(lexer.TOKEN_OR_OP, func(left types.Expr) types.Expr {
+		return p.parseBinary(left, types.OpOr)
+	}, precedenceOr)
+	p.RegisterInfix(lexer.TOKEN_IMPL, func(left types.Expr) types.Expr {
+		return p.parseBinary(left, types.OpImpl)
+	}, precedenceImpl)
+	p.RegisterInfix(lexer.TOKEN_DOT, p.parseSelect, precedenceSelect)
+	p.RegisterInfix(lexer.TOKEN_QUESTION, p.parseHasAttr, precedenceLowest)
+	p.RegisterInfix(lexer.TOKEN_OR, p.parseOrDefault, precedenceLowest)
+}
+
 // Parse is the main entry point for parsing a complete Nix expression.
 // It parses the entire token stream into a single expression AST starting
 // with the lowest precedence level. Returns either the parsed AST or
 // accumulated parsing errors for comprehensive error reporting.
 func (p *Parser) Parse() (types.Expr, error) {
+	defer p.closeEvents()
+
 	// Start parsing with lowest precedence to capture the entire expression
 	expr := p.parseExpression(precedenceLowest)
 
-	// Check if any errors were encountered during parsing
-	if p.errors.HasErrors() {
+	// In recovery mode, a types.ErrorExpr stands in for whatever failed
+	// to parse, so the presence of recorded errors no longer implies the
+	// whole parse should be thrown away.
+	if p.errors.HasErrors() && !p.recoverMode {
 		// Return accumulated errors for detailed error reporting
 		return nil, p.errors
 	}
@@ -74,8 +234,27 @@ func (p *Parser) Errors() []string {
 // This method is called after successfully consuming a token.
 func (p *Parser) advance() {
 	// Shift the lookahead window forward
-	p.cur = p.peek           // Current token becomes previous peek
-	p.peek = p.l.NextToken() // Get next token from lexer
+	p.cur = p.peek // Current token becomes previous peek
+
+	if len(p.pending) > 0 {
+		p.peek = p.pending[0]
+		p.pending = p.pending[1:]
+	} else {
+		p.peek = p.l.NextToken() // Get next token from lexer
+	}
+}
+
+// peekAhead returns the token one past peek, without consuming it or
+// disturbing cur/peek - a third lookahead slot for the rare construct
+// that can't be disambiguated from cur/peek alone. The token is read
+// from the lexer once and buffered in pending, so a later advance()
+// still sees it in order.
+func (p *Parser) peekAhead() lexer.Token {
+	if len(p.pending) == 0 {
+		p.pending = append(p.pending, p.l.NextToken())
+	}
+
+	return p.pending[0]
 }
 
 // parseExpression implements the core Pratt parsing algorithm for expressions.
@@ -87,12 +266,32 @@ func (p *Parser) advance() {
 // The precedence parameter controls how tightly this expression binds,
 // enabling proper handling of complex expressions like: a + b * c && d.
 func (p *Parser) parseExpression(precedence int) types.Expr {
+	defer p.untrace(p.trace("parseExpression"))
+
+	startTok := p.cur
+
 	// Phase 1: Parse the initial prefix expression (required)
-	prefix := p.parsePrefixExpression()
+	prefixFn, ok := p.prefixParseFns[p.cur.Type]
+	if !ok {
+		// No registered prefix parser for this token - record error and fail gracefully
+		msg := fmt.Sprintf("no prefix parse function for %v", p.cur.Type)
+		if p.recoverMode {
+			return p.recover(p.cur.Line, p.cur.Column, msg)
+		}
+		p.errors.Addf(p.cur.Line, p.cur.Column, "%s", msg)
+
+		return nil
+	}
+
+	prefixTrace := p.trace("parsePrefixExpression")
+	prefix := prefixFn()
+	p.untrace(prefixTrace)
 	if prefix == nil {
 		// Prefix parsing failed - this is a parsing error
 		return nil
 	}
+	attachSpan(prefix, startTok, p.cur)
+	p.emit(EnterNode, nodeName(prefix), prefix.Span(), "")
 
 	// Phase 2: Parse infix operations while precedence and tokens allow
 	// Continue until we hit a statement terminator or lower precedence
@@ -104,10 +303,12 @@ func (p *Parser) parseExpression(precedence int) types.Expr {
 		}
 
 		// Determine the type of infix operation to parse
-		if p.isInfixOperator(p.peek.Type) {
-			// Standard binary operator (==, +, &&, etc.)
+		if infixFn, ok := p.infixParseFns[p.peek.Type]; ok {
+			// Standard binary operator (==, +, &&, etc.) or special infix form
 			p.advance()
-			prefix = p.parseInfixExpression(prefix)
+			infixTrace := p.trace("parseInfixExpression")
+			prefix = infixFn(prefix)
+			p.untrace(infixTrace)
 		} else if p.couldBeArgument() && precedence < precedenceCall {
 			// Function application: juxtaposition of expressions
 			// e.g., "f x" where f is a function and x is an argument
@@ -117,130 +318,58 @@ func (p *Parser) parseExpression(precedence int) types.Expr {
 			// No valid infix operation - end expression parsing
 			break
 		}
+
+		if prefix == nil {
+			return nil
+		}
+		attachSpan(prefix, startTok, p.cur)
 	}
 
+	p.emit(LeaveNode, nodeName(prefix), prefix.Span(), "")
+
 	return prefix
 }
 
-// parsePrefixExpression handles expressions that begin with a prefix element.
-// This includes:
-// - Literals (numbers, strings, paths, booleans, null)
-// - Identifiers (variables and function parameters)
-// - Keywords (if, let, with, assert for control flow)
-// - Unary operators (-, ! for negation and logical NOT)
-// - Compound expressions ({...}, [...], (...) for grouping)
-//
-// This is the "nud" (null denotation) function in Pratt parsing terminology.
-func (p *Parser) parsePrefixExpression() types.Expr {
-	switch p.cur.Type {
-	// Literal values - direct value representations
-	case lexer.TOKEN_INT:
-		return p.parseInt() // Integer literals: 42, -10, 0
-	case lexer.TOKEN_FLOAT:
-		return p.parseFloat() // Float literals: 3.14, -0.5
-	case lexer.TOKEN_STRING:
-		return p.parseString() // String literals: "hello", "world"
-	case lexer.TOKEN_PATH:
-		return p.parsePath() // Path literals: ./file, /absolute
-	case lexer.TOKEN_IDENT:
-		// Identifiers or function definitions (x, variable, x: x + 1)
-		return p.parseIdentifierOrFunction()
-
-	// Control flow keywords - complex expressions that modify evaluation
-	case lexer.TOKEN_IF:
-		return p.parseIf() // Conditional expressions: if cond then a else b
-	case lexer.TOKEN_LET:
-		return p.parseLet() // Let bindings: let x = 1; in x + 2
-	case lexer.TOKEN_WITH:
-		return p.parseWith() // Scope extension: with attrs; expr
-	case lexer.TOKEN_ASSERT:
-		return p.parseAssert() // Assertions: assert condition; expr
-
-	// Unary prefix operators - operations on single operands
-	case lexer.TOKEN_NOT:
-		return p.parseUnary(types.OpNot) // Logical negation: !expr
-	case lexer.TOKEN_MINUS:
-		return p.parseUnary(types.OpNeg) // Arithmetic negation: -expr
-
-	// Compound data structures and grouping
-	case lexer.TOKEN_LBRACE:
-		return p.parseAttrSet() // Attribute sets: { x = 1; y = 2; }
-	case lexer.TOKEN_LBRACKET:
-		return p.parseList() // Lists: [1, 2, 3]
-	case lexer.TOKEN_LPAREN:
-		return p.parseGrouped() // Grouped expressions: (expr)
-
-	default:
-		// Unrecognized token - record error and fail gracefully
-		p.errors.Addf(p.cur.Line, p.cur.Column,
-			"no prefix parse function for %v", p.cur.Type)
-
-		return nil
-	}
+// spanSetter is satisfied by every types.Expr (via the pointer-receiver
+// baseNode.SetSpan it embeds), letting the parser attach source spans
+// generically from here instead of every parse function threading a
+// types.Span through its own constructor.
+type spanSetter interface {
+	SetSpan(types.Span)
 }
 
-// parseInfixExpression handles binary operators and special infix operations.
-// This is the "led" (left denotation) function in Pratt parsing terminology.
-// It takes the left operand and parses the right operand according to the
-// operator's precedence and associativity rules.
-//
-// The function handles all binary operators plus special operations like
-// attribute selection (.) and existence testing (?).
-func (p *Parser) parseInfixExpression(left types.Expr) types.Expr {
-	switch p.cur.Type {
-	// Arithmetic operators - mathematical operations on numbers
-	case lexer.TOKEN_PLUS:
-		return p.parseBinary(left, types.OpAdd) // Addition: a + b
-	case lexer.TOKEN_MINUS:
-		return p.parseBinary(left, types.OpSub) // Subtraction: a - b
-	case lexer.TOKEN_MULTIPLY:
-		return p.parseBinary(left, types.OpMul) // Multiplication: a * b
-	case lexer.TOKEN_DIVIDE:
-		return p.parseBinary(left, types.OpDiv) // Division: a / b
-
-	// Concatenation operator - joining sequences
-	case lexer.TOKEN_CONCAT:
-		return p.parseBinary(left, types.OpConcat) // List/string concat: a ++ b
-
-	// Comparison operators - relational comparisons
-	case lexer.TOKEN_EQ:
-		return p.parseBinary(left, types.OpEq) // Equality: a == b
-	case lexer.TOKEN_NEQ:
-		return p.parseBinary(left, types.OpNEq) // Inequality: a != b
-	case lexer.TOKEN_LT:
-		return p.parseBinary(left, types.OpLT) // Less than: a < b
-	case lexer.TOKEN_GT:
-		return p.parseBinary(left, types.OpGT) // Greater than: a > b
-	case lexer.TOKEN_LTE:
-		return p.parseBinary(left, types.OpLTE) // Less/equal: a <= b
-	case lexer.TOKEN_GTE:
-		return p.parseBinary(left, types.OpGTE) // Greater/equal: a >= b
-
-	// Logical operators - boolean operations with short-circuit evaluation
-	case lexer.TOKEN_AND:
-		return p.parseBinary(left, types.OpAnd) // Logical AND: a && b
-	case lexer.TOKEN_OR_OP:
-		return p.parseBinary(left, types.OpOr) // Logical OR: a || b
-	case lexer.TOKEN_IMPL:
-		return p.parseBinary(left, types.OpImpl) // Implication: a -> b
-
-	// Special attribute operations - Nix-specific operators
-	case lexer.TOKEN_DOT:
-		return p.parseSelect(left) // Attribute selection: obj.attr
-	case lexer.TOKEN_QUESTION:
-		return p.parseHasAttr(left) // Existence test: obj ? attr
-	case lexer.TOKEN_OR:
-		return p.parseOrDefault(left) // Default value: obj.attr or default
+// attachSpan records expr's source span as running from start's first
+// byte to end's last byte, if expr supports it. Called after every
+// prefix/infix step in parseExpression, so a node's span always ends up
+// covering exactly the tokens consumed while building it - re-attached
+// (with a growing end) each time an infix operation wraps it in a
+// bigger expression.
+func attachSpan(expr types.Expr, start, end lexer.Token) {
+	ss, ok := expr.(spanSetter)
+	if !ok {
+		return
+	}
 
-	default:
-		// Unrecognized infix operator - record error
-		p.errors.Addf(p.cur.Line, p.cur.Column,
-			"no infix parse function for %v", p.cur.Type)
+	ss.SetSpan(tokenSpan(start, end))
+}
 
-		return nil
+// tokenSpan builds a types.Span running from start's first byte to end's
+// last byte. Used both by attachSpan, for Expr nodes, and directly by
+// parseBinding/parseInherit/parseLet for the non-Expr Binding/AttrBinding/
+// InheritClause structs, which have nowhere to hang a spanSetter.
+func tokenSpan(start, end lexer.Token) types.Span {
+	return types.Span{
+		Start: types.SourcePos{Line: start.Line, Column: start.Column, Offset: start.Offset},
+		End:   types.SourcePos{Line: end.EndLine, Column: end.EndColumn, Offset: end.EndOffset},
 	}
 }
 
+// nodeName returns expr's dynamic Go type name (e.g. "*types.IfExpr"),
+// used to label Events() notifications.
+func nodeName(expr types.Expr) string {
+	return fmt.Sprintf("%T", expr)
+}
+
 // parseInt parses integer literals from token text to AST nodes.
 // Converts the string representation ("42", "-10") to a 64-bit signed integer.
 // Reports parsing errors with precise location information for user feedback.
@@ -277,20 +406,58 @@ func (p *Parser) parseFloat() types.Expr {
 	return &types.FloatExpr{Value: val}
 }
 
-// parseString creates string literal AST nodes from token text.
-// The lexer has already processed escape sequences and removed quotes,
-// so we can directly use the literal value from the token.
-func (p *Parser) parseString() types.Expr {
-	// String literal is ready to use (lexer handled escapes and quotes)
-	return &types.StringExpr{Value: p.cur.Literal}
-}
-
 // parsePath creates path literal AST nodes from token text.
 // Path literals represent file system paths and are used for imports
 // and file references. Examples: ./file.nix, /etc/nixos/configuration.nix.
 func (p *Parser) parsePath() types.Expr {
 	// Path literal is ready to use as-is from lexer
-	return &types.PathExpr{Value: p.cur.Literal}
+	return &types.PathExpr{
+		Value:      p.cur.Literal,
+		IsAbsolute: strings.HasPrefix(p.cur.Literal, "/"),
+	}
+}
+
+// parsePathLiteral parses a path literal that contains at least one
+// "${ ... }" interpolation, assembling the
+// TOKEN_PATH_PART/TOKEN_INTERP_START/TOKEN_INTERP_END stream the lexer
+// produces for it into a *types.InterpPathExpr. Unlike
+// parseStringLiteral, there's no closing delimiter token to loop until:
+// the stream simply ends - with p.cur already past the path's last
+// token - once the lexer has popped its modePath mode frame. Registered
+// as the prefix parse function for lexer.TOKEN_PATH_PART; a path with no
+// interpolation at all lexes as a single TOKEN_PATH instead and goes
+// through parsePath.
+func (p *Parser) parsePathLiteral() types.Expr {
+	defer p.untrace(p.trace("parsePathLiteral"))
+
+	isAbsolute := strings.HasPrefix(p.cur.Literal, "/")
+
+	var parts []types.StringPart
+
+loop:
+	for {
+		switch {
+		case p.curIs(lexer.TOKEN_PATH_PART):
+			parts = append(parts, types.StringPart{Literal: p.cur.Literal})
+			p.advance()
+
+		case p.curIs(lexer.TOKEN_INTERP_START):
+			p.advance() // move onto the interpolated expression's first token
+
+			expr := p.parseExpression(precedenceLowest)
+			if !p.expectPeek(lexer.TOKEN_INTERP_END) {
+				return nil
+			}
+
+			parts = append(parts, types.StringPart{Expr: expr})
+			p.advance() // move past the INTERP_END
+
+		default:
+			break loop
+		}
+	}
+
+	return &types.InterpPathExpr{Parts: parts, IsAbsolute: isAbsolute}
 }
 
 // parseIdentifierOrFunction handles identifiers that might be special values or functions.
@@ -321,6 +488,21 @@ func (p *Parser) parseIdentifierOrFunction() types.Expr {
 		return p.parseFunction()
 	}
 
+	// "identifier @ { ... }" binds the whole argument to name alongside
+	// destructuring it as an attrset pattern.
+	if p.peekIs(lexer.TOKEN_AT) {
+		name := p.cur.Literal
+		doc := p.cur.Doc
+
+		p.advance() // move to '@'
+
+		if !p.expectPeek(lexer.TOKEN_LBRACE) {
+			return nil
+		}
+
+		return p.parsePatternFunction(name, doc)
+	}
+
 	// Regular identifier (variable reference)
 	return &types.IdentExpr{Name: p.cur.Literal}
 }
@@ -362,7 +544,7 @@ func (p *Parser) expectPeek(t lexer.TokenType) bool {
 // and let a higher-precedence operation take over. Returns the lowest precedence
 // for tokens that aren't operators, allowing them to terminate expressions.
 func (p *Parser) peekPrecedence() int {
-	if prec, ok := precedenceMap[p.peek.Type]; ok {
+	if prec, ok := p.precedenceMap[p.peek.Type]; ok {
 		// Token has defined precedence (it's an operator)
 		return prec
 	}
@@ -375,7 +557,7 @@ func (p *Parser) peekPrecedence() int {
 // parsing decisions where we need to know the precedence of the operator
 // we're currently processing.
 func (p *Parser) curPrecedence() int {
-	if prec, ok := precedenceMap[p.cur.Type]; ok {
+	if prec, ok := p.precedenceMap[p.cur.Type]; ok {
 		// Current token has defined precedence
 		return prec
 	}
@@ -383,33 +565,32 @@ func (p *Parser) curPrecedence() int {
 	return precedenceLowest
 }
 
-// isInfixOperator determines if a token type represents a binary/infix operator.
-// This check is used to distinguish between:
-// - Infix operators that need special parsing (=, +, &&, etc.)
-// - Other tokens that might appear between expressions
-// Any token with defined precedence is considered an infix operator.
-func (p *Parser) isInfixOperator(t lexer.TokenType) bool {
-	// Operators have entries in the precedence map
-	_, ok := precedenceMap[t]
-
-	return ok
-}
-
 // couldBeArgument determines if the next token could start a function argument.
 // This is essential for parsing function application (f x) vs other binary operations.
 // Function application in Nix is implicit (no parentheses required), so we need
 // to distinguish "f x" (application) from "f + x" (addition).
 //
 // Returns true for tokens that can begin expressions suitable as function arguments.
+// A token with its own registered infix parser (e.g. TOKEN_MINUS, which is
+// also subtraction) never qualifies, no matter what else matches below -
+// parseExpression's loop tries the infixFn branch first whenever one is
+// registered, so letting couldBeArgument say "true" for such a token can
+// only ever suppress the precedence check's break and wrongly pull an
+// operator into the wrong operand (e.g. "-5 - 1" parsing as -(5 - 1)
+// instead of (-5) - 1), never actually start a new argument.
 func (p *Parser) couldBeArgument() bool {
+	if _, ok := p.infixParseFns[p.peek.Type]; ok {
+		return false
+	}
+
 	switch p.peek.Type {
 	// Literal values that can be function arguments
-	case lexer.TOKEN_INT, lexer.TOKEN_FLOAT, lexer.TOKEN_STRING, lexer.TOKEN_PATH,
+	case lexer.TOKEN_INT, lexer.TOKEN_FLOAT, lexer.TOKEN_DQUOTE, lexer.TOKEN_ISTRING_QUOTE, lexer.TOKEN_PATH, lexer.TOKEN_PATH_PART,
 		// Identifiers and compound expressions
 		lexer.TOKEN_IDENT, lexer.TOKEN_LBRACE, lexer.TOKEN_LBRACKET, lexer.TOKEN_LPAREN,
 		// Unary operators and control flow (can start expressions)
 		lexer.TOKEN_NOT, lexer.TOKEN_MINUS, lexer.TOKEN_IF, lexer.TOKEN_LET,
-		lexer.TOKEN_WITH, lexer.TOKEN_ASSERT:
+		lexer.TOKEN_WITH, lexer.TOKEN_ASSERT, lexer.TOKEN_REC:
 		return true
 	default:
 		// Operators, delimiters, EOF, etc. cannot start arguments
@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const traceIdentPlaceholder = "\t"
+
+// tracer records the message reported by a matching trace/untrace pair.
+type tracer struct {
+	p   *Parser
+	msg string
+}
+
+// trace announces entry into a parse function, emitting a line of the form
+// "BEGIN <msg> cur=<tok> peek=<tok> prec=<n>" so a trace log shows exactly
+// what the parser was looking at when it made a precedence or dispatch
+// decision - the detail that matters when chasing a couldBeArgument bug.
+// It returns a tracer to hand to untrace on exit. Typical usage at the top
+// of a parseX method:
+//
+//	defer p.untrace(p.trace("parseExpression"))
+func (p *Parser) trace(msg string) *tracer {
+	p.traceDepth++
+	if p.traceWriter != nil {
+		p.tracePrint(fmt.Sprintf("BEGIN %s cur=%s peek=%s prec=%d", msg, p.cur.Type, p.peek.Type, p.peekPrecedence()))
+	}
+
+	return &tracer{p: p, msg: msg}
+}
+
+// untrace announces exit from the parse function previously reported by trace.
+func (p *Parser) untrace(t *tracer) {
+	if t.p.traceWriter != nil {
+		t.p.tracePrint("END " + t.msg)
+	}
+	t.p.traceDepth--
+}
+
+// tracePrint writes a single trace line, indented to the current nesting
+// level. Callers must only invoke this when traceWriter is non-nil.
+func (p *Parser) tracePrint(fs string) {
+	fmt.Fprintf(p.traceWriter, "%s%s\n", strings.Repeat(traceIdentPlaceholder, p.traceDepth-1), fs)
+}
+
+// EnableTrace turns on the parser's tracing layer, writing an indented log
+// of every traced parseX call - entry, exit, and the current/lookahead
+// tokens and precedence that drove the decision - to w. Pass nil to disable
+// tracing again. Tracing is off by default, so normal parsing pays nothing
+// for this instrumentation.
+func (p *Parser) EnableTrace(w io.Writer) {
+	p.traceWriter = w
+}
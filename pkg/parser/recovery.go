@@ -0,0 +1,143 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/conneroisu/gix/internal/types"
+	"github.com/conneroisu/gix/pkg/lexer"
+)
+
+// EnableErrorRecovery switches the parser into recovery mode: a failed
+// production synchronizes to the next statement boundary (one of `;`,
+// `}`, `]`, `)`, `then`, `else`, or a top-level keyword) and produces a
+// types.ErrorExpr placeholder instead of aborting the parse. This is
+// off by default - Parse()/Errors() callers expect the existing
+// strict, all-or-nothing behavior - and is meant for editor-style
+// tooling (LSP servers, etc.) that need a best-effort AST even from
+// half-typed source.
+func (p *Parser) EnableErrorRecovery() { p.recoverMode = true }
+
+// synchronize advances the token stream, collecting the literal text of
+// each skipped token into collected (if non-nil), until it reaches a
+// token that plausibly starts a construct the caller can resume parsing
+// from. It returns the last token actually consumed, so callers can
+// build an accurate span for the region they skipped.
+func (p *Parser) synchronize(collected *[]string) lexer.Token {
+	last := p.cur
+
+	for !p.curIs(lexer.TOKEN_EOF) {
+		switch p.cur.Type {
+		case lexer.TOKEN_SEMICOLON, lexer.TOKEN_RBRACE, lexer.TOKEN_RBRACKET, lexer.TOKEN_RPAREN,
+			lexer.TOKEN_LET, lexer.TOKEN_IN, lexer.TOKEN_WITH, lexer.TOKEN_ASSERT, lexer.TOKEN_IF,
+			lexer.TOKEN_THEN, lexer.TOKEN_ELSE:
+			return last
+		}
+
+		if collected != nil {
+			*collected = append(*collected, p.cur.Literal)
+		}
+		last = p.cur
+		p.advance()
+	}
+
+	return last
+}
+
+// recover records msg (at line/column) as a parse error, synchronizes
+// to the next statement boundary, and returns a types.ErrorExpr
+// spanning the tokens that were skipped. Only meaningful in recovery
+// mode; callers check p.recoverMode themselves before calling this, so
+// the strict (non-recovering) path pays nothing extra. Many call sites
+// already have a perfectly good partial result (e.g. a grouped
+// expression missing only its closing paren) and should keep using that
+// instead of the returned ErrorExpr - this is for the cases where
+// nothing valid could be salvaged.
+func (p *Parser) recover(line, column int, msg string) *types.ErrorExpr {
+	p.errors.Addf(line, column, "%s", msg)
+
+	start := p.cur
+	var tokens []string
+	end := p.synchronize(&tokens)
+
+	errExpr := &types.ErrorExpr{Message: msg, Tokens: tokens}
+	attachSpan(errExpr, start, end)
+	p.emit(EventError, "ErrorExpr", errExpr.Span(), msg)
+
+	return errExpr
+}
+
+// EventKind classifies a value sent on Parser.Events().
+type EventKind int
+
+const (
+	// EnterNode is emitted when parseExpression begins building a node
+	// of the given Kind, before any of its children are parsed.
+	EnterNode EventKind = iota
+	// LeaveNode is emitted once that node (and all of its children) has
+	// been fully parsed, carrying its final Span.
+	LeaveNode
+	// EventError is emitted whenever the parser records a syntax error,
+	// in addition to it being appended to Errors().
+	EventError
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EnterNode:
+		return "EnterNode"
+	case LeaveNode:
+		return "LeaveNode"
+	case EventError:
+		return "Error"
+	default:
+		return fmt.Sprintf("EventKind(%d)", int(k))
+	}
+}
+
+// Event is a single notification from the parser's progress, intended
+// for LSP-style tooling that wants to build semantic tokens or a
+// progress indicator without re-walking the finished AST. Events are
+// emitted at parseExpression granularity: one Enter/Leave pair per
+// expression parsed (which, since every production bottoms out through
+// parseExpression, covers every node in the tree), plus one Error event
+// per recorded syntax error.
+type Event struct {
+	Kind    EventKind
+	Node    string // Go type name of the node, e.g. "*types.IfExpr" ("" for EventError)
+	Span    types.Span
+	Message string // populated for EventError
+}
+
+// Events returns a channel of parse progress events, creating it on
+// first call. The channel is closed when Parse (or ParseIncremental)
+// returns. Call Events before calling Parse - events emitted before
+// anyone is listening are dropped rather than blocking the parse.
+func (p *Parser) Events() <-chan Event {
+	if p.events == nil {
+		p.events = make(chan Event, 64)
+	}
+
+	return p.events
+}
+
+// emit sends an event on p.events without blocking the parse if nobody
+// is reading from it (or nobody ever called Events() at all).
+func (p *Parser) emit(kind EventKind, node string, span types.Span, msg string) {
+	if p.events == nil {
+		return
+	}
+
+	select {
+	case p.events <- Event{Kind: kind, Node: node, Span: span, Message: msg}:
+	default:
+	}
+}
+
+// closeEvents closes the events channel if one was ever created. Safe
+// to call multiple times.
+func (p *Parser) closeEvents() {
+	if p.events != nil && !p.eventsClosed {
+		close(p.events)
+		p.eventsClosed = true
+	}
+}
@@ -0,0 +1,230 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/conneroisu/gix/internal/types"
+	"github.com/conneroisu/gix/pkg/lexer"
+)
+
+// parseStringLiteral parses a "..." or ''...'' string literal, assembling
+// the TOKEN_STR_PART/TOKEN_INTERP_START/TOKEN_INTERP_END stream the
+// lexer produces for it into a []types.StringPart, dedenting an indented
+// string's common leading whitespace, and collapsing the result to a
+// plain *types.StringExpr when there turned out to be no interpolation
+// at all. Registered as the prefix parse function for both
+// lexer.TOKEN_DQUOTE and lexer.TOKEN_ISTRING_QUOTE.
+func (p *Parser) parseStringLiteral() types.Expr {
+	defer p.untrace(p.trace("parseStringLiteral"))
+
+	isIndented := p.curIs(lexer.TOKEN_ISTRING_QUOTE)
+	closeType := p.cur.Type
+
+	var parts []types.StringPart
+
+	p.advance() // move past the opening delimiter
+
+	for !p.curIs(closeType) && !p.curIs(lexer.TOKEN_EOF) {
+		switch {
+		case p.curIs(lexer.TOKEN_STR_PART):
+			parts = append(parts, types.StringPart{Literal: p.cur.Literal})
+			p.advance()
+
+		case p.curIs(lexer.TOKEN_INTERP_START):
+			p.advance() // move onto the interpolated expression's first token
+
+			expr := p.parseExpression(precedenceLowest)
+			if !p.expectPeek(lexer.TOKEN_INTERP_END) {
+				return nil
+			}
+
+			parts = append(parts, types.StringPart{Expr: expr})
+			p.advance() // move past the INTERP_END
+
+		default:
+			p.errors.Addf(p.cur.Line, p.cur.Column,
+				"unexpected %v in string literal", p.cur.Type)
+
+			return nil
+		}
+	}
+
+	if !p.curIs(closeType) {
+		p.errors.Addf(p.cur.Line, p.cur.Column, "unterminated string literal")
+
+		return nil
+	}
+
+	if isIndented {
+		parts = dedentStringParts(parts)
+	}
+
+	return collapseStringParts(parts, isIndented)
+}
+
+// collapseStringParts builds a plain *types.StringExpr out of parts when
+// none of them came from an interpolation, since most string literals in
+// practice have none and shouldn't pay for the more general
+// *types.InterpStringExpr representation.
+func collapseStringParts(parts []types.StringPart, isIndented bool) types.Expr {
+	var sb strings.Builder
+
+	for _, part := range parts {
+		if part.Expr != nil {
+			return &types.InterpStringExpr{Parts: parts, IsIndented: isIndented}
+		}
+
+		sb.WriteString(part.Literal)
+	}
+
+	return &types.StringExpr{Value: sb.String(), IsIndented: isIndented}
+}
+
+// dedentStringParts implements Nix's indented-string semantics: the
+// smallest indentation among the literal's non-blank lines (a line that
+// has only whitespace, and no interpolation, doesn't count) is stripped
+// from the start of every line, and a single leading or trailing newline
+// is then dropped so that
+//
+//	''
+//	  foo
+//	  bar
+//	''
+//
+// reads as "foo\nbar\n" rather than carrying the indentation of the
+// source file it's embedded in. This has to run once over the whole
+// assembled literal - which is why it happens here, in the parser, once
+// all of a literal's TOKEN_STR_PART fragments are in hand, rather than in
+// the lexer, which only ever sees one fragment at a time (see
+// Lexer.scanIndentPart).
+func dedentStringParts(parts []types.StringPart) []types.StringPart {
+	parts = stripIndent(parts, minIndent(parts))
+
+	return trimEdges(parts)
+}
+
+// minIndent returns the smallest leading-whitespace run among parts'
+// non-blank lines, or 0 if there are none (an entirely blank literal).
+func minIndent(parts []types.StringPart) int {
+	lowest := -1
+	atLineStart := true
+	lineIndent := 0
+	lineBlank := true
+
+	finishLine := func() {
+		if !lineBlank && (lowest == -1 || lineIndent < lowest) {
+			lowest = lineIndent
+		}
+
+		lineIndent = 0
+		lineBlank = true
+		atLineStart = true
+	}
+
+	for _, part := range parts {
+		if part.Expr != nil {
+			lineBlank = false
+			atLineStart = false
+
+			continue
+		}
+
+		for _, ch := range part.Literal {
+			if ch == '\n' {
+				finishLine()
+
+				continue
+			}
+
+			if atLineStart {
+				if ch == ' ' || ch == '\t' {
+					lineIndent++
+
+					continue
+				}
+
+				atLineStart = false
+			}
+
+			if ch != ' ' && ch != '\t' {
+				lineBlank = false
+			}
+		}
+	}
+	finishLine()
+
+	if lowest == -1 {
+		return 0
+	}
+
+	return lowest
+}
+
+// stripIndent removes up to indent leading space/tab characters from the
+// start of every line across parts, leaving interpolations untouched.
+func stripIndent(parts []types.StringPart, indent int) []types.StringPart {
+	if indent == 0 {
+		return parts
+	}
+
+	out := make([]types.StringPart, len(parts))
+	atLineStart := true
+
+	for i, part := range parts {
+		if part.Expr != nil {
+			out[i] = part
+			atLineStart = false
+
+			continue
+		}
+
+		var sb strings.Builder
+
+		stripped := 0
+		for _, ch := range part.Literal {
+			if ch == '\n' {
+				sb.WriteByte('\n')
+				atLineStart = true
+				stripped = 0
+
+				continue
+			}
+
+			if atLineStart && stripped < indent && (ch == ' ' || ch == '\t') {
+				stripped++
+
+				continue
+			}
+
+			atLineStart = false
+			sb.WriteRune(ch)
+		}
+
+		out[i] = types.StringPart{Literal: sb.String()}
+	}
+
+	return out
+}
+
+// trimEdges drops a single leading newline from the literal's first part
+// and a single trailing newline from its last part, mirroring the Nix
+// manual's "a single initial and trailing newline are ignored" rule -
+// the part of the algorithm that lets the opening and closing ''
+// delimiters live on their own lines without those lines becoming part
+// of the string's value.
+func trimEdges(parts []types.StringPart) []types.StringPart {
+	if len(parts) == 0 {
+		return parts
+	}
+
+	if parts[0].Expr == nil {
+		parts[0].Literal = strings.TrimPrefix(parts[0].Literal, "\n")
+	}
+
+	last := len(parts) - 1
+	if parts[last].Expr == nil {
+		parts[last].Literal = strings.TrimSuffix(parts[last].Literal, "\n")
+	}
+
+	return parts
+}
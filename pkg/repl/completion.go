@@ -0,0 +1,79 @@
+package repl
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/conneroisu/gix/internal/value"
+)
+
+// Completions returns the candidate completions for partial, the text
+// typed so far on the current line: for a bare prefix like "tru", every
+// name visible in the REPL's shared environment (bindings and builtins
+// alike, via value.Env.AllNames) that starts with it; for a dotted
+// prefix like "pkgs.hell", the head ("pkgs") is evaluated quietly and,
+// if it resolves to an attribute set, every key starting with the
+// remainder ("hell") is offered, each returned as the full dotted name
+// ("pkgs.hello") a caller can substitute back into the line wholesale.
+//
+// Evaluation errors and non-attrset heads simply yield no completions -
+// there's no wrong input here, only one that doesn't narrow anything
+// down yet.
+func (r *REPL) Completions(partial string) []string {
+	head, prefix, dotted := splitLastDot(partial)
+	if !dotted {
+		return matching(r.env.AllNames(), prefix, "")
+	}
+
+	val, err := r.quietEvalSrc(head)
+	if err != nil {
+		return nil
+	}
+
+	attrs, ok := val.(*value.Attrs)
+	if !ok {
+		return nil
+	}
+
+	return matching(attrs.Keys(), prefix, head+".")
+}
+
+// splitLastDot splits partial at its last '.', reporting dotted=false if
+// there isn't one (a bare-prefix completion).
+func splitLastDot(partial string) (head, prefix string, dotted bool) {
+	i := strings.LastIndex(partial, ".")
+	if i < 0 {
+		return "", partial, false
+	}
+
+	return partial[:i], partial[i+1:], true
+}
+
+// matching returns the names in candidates that start with prefix,
+// sorted, each qualified with qualifier.
+func matching(candidates []string, prefix, qualifier string) []string {
+	var out []string
+
+	for _, name := range candidates {
+		if strings.HasPrefix(name, prefix) {
+			out = append(out, qualifier+name)
+		}
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+// quietEvalSrc parses and evaluates src like evalSrc, but without
+// printing anything to r.out - used by Completions, where a head
+// expression that doesn't parse or evaluate (most often because the user
+// hasn't finished typing it yet) is an expected, silent dead end rather
+// than an error worth reporting.
+func (r *REPL) quietEvalSrc(src string) (value.Value, error) {
+	ast, err := r.newParser(src).Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.eval.EvalWithEnv(ast, r.env)
+}
@@ -0,0 +1,45 @@
+// Package repl implements an interactive read-eval-print loop for gix.
+//
+// The REPL wraps the lexer/parser/eval pipeline with a line-oriented front
+// end that:
+//   - Buffers input across multiple lines until lexer.IsComplete reports the
+//     expression is complete (balanced brackets, a matching `let ... in`,
+//     and a terminated string literal), so multi-line expressions - and
+//     pasted attrsets and let blocks - can be typed naturally.
+//   - Reuses a single evaluation environment across entries, via the
+//     Extend/WithBindings helpers on value.Env, so later requests for
+//     persistent top-level bindings have somewhere to live.
+//   - Supports a small set of meta-commands (:t/:type, :p, :print, :b,
+//     :vars, :reset, :let, :l/:load, :reload, :lex, :ast, :trace,
+//     :complete, :history, :q) alongside plain expression evaluation.
+//   - Reports parse errors with a caret pointing at the offending column,
+//     using the Line/Column already tracked on parser.ParseError.
+//   - Recognizes `name = expr` at the top level (distinct from
+//     `let name = expr; in ...`) and binds the result into the shared
+//     environment for later entries to reference, mirroring the Nix REPL.
+//     :let name = expr does the same thing explicitly, for parity with
+//     nix repl sessions that spell it out.
+//   - Offers completions (see Completions and the :complete command) for
+//     a bare prefix against every name in scope (value.Env.AllNames) or,
+//     for a dotted prefix, the attribute names of whatever its head
+//     evaluates to.
+//   - Persists submitted entries to ~/.config/gix/history across
+//     sessions (see history.go), printable with :history.
+//   - Tracks files merged in via :load so :reload can re-run them in
+//     order, picking up on-disk edits, and offers :print to force a
+//     result deeply before printing it rather than leaving nested
+//     bindings as unevaluated thunks.
+//
+// What this REPL doesn't do: input is still read a line at a time via
+// bufio.Scanner, not a readline-style line editor, so there's no
+// Tab-triggered completion or Ctrl-R incremental history search - both
+// need raw per-keystroke terminal input, which means a third-party
+// library (chzyer/readline, peterh/liner, or similar); this module has
+// no go.mod/dependency manager to add one through. :complete and
+// :history expose the same underlying data on demand instead.
+//
+// Usage Example:
+//
+//	r := repl.New(os.Stdin, os.Stdout, ".")
+//	r.Run()
+package repl
@@ -0,0 +1,81 @@
+package repl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// historyPath returns the path gix persists REPL history to
+// (~/.config/gix/history), or "" if the user's home directory can't be
+// determined - in which case history simply isn't persisted across
+// sessions, rather than failing the REPL outright.
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".config", "gix", "history")
+}
+
+// loadHistory reads any history left by a previous session into r.history,
+// leaving it empty if none exists yet or the home directory is unknown.
+func (r *REPL) loadHistory() {
+	r.historyPath = historyPath()
+	if r.historyPath == "" {
+		return
+	}
+
+	data, err := os.ReadFile(r.historyPath)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			r.history = append(r.history, line)
+		}
+	}
+}
+
+// recordHistory appends line to r.history and, if a history file is
+// configured, to it as well - written incrementally, one line per entry,
+// so a crash or an unclean exit doesn't lose everything typed so far.
+func (r *REPL) recordHistory(line string) {
+	r.history = append(r.history, line)
+
+	if r.historyPath == "" {
+		return
+	}
+
+	if dir := filepath.Dir(r.historyPath); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return
+		}
+	}
+
+	f, err := os.OpenFile(r.historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	fmt.Fprintln(f, line)
+}
+
+// printHistory lists every entry recorded so far this session, including
+// ones loaded from a previous session's history file, numbered the way a
+// shell's `history` builtin does.
+func (r *REPL) printHistory() {
+	if len(r.history) == 0 {
+		fmt.Fprintln(r.out, "(no history)")
+
+		return
+	}
+
+	for i, line := range r.history {
+		fmt.Fprintf(r.out, "%4d  %s\n", i+1, line)
+	}
+}
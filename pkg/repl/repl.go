@@ -0,0 +1,526 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/conneroisu/gix/internal/value"
+	"github.com/conneroisu/gix/pkg/eval"
+	"github.com/conneroisu/gix/pkg/format"
+	"github.com/conneroisu/gix/pkg/lexer"
+	"github.com/conneroisu/gix/pkg/parser"
+)
+
+// prompt is shown while waiting for a new entry; continuePrompt is shown
+// while an entry is still incomplete (unbalanced brackets, an open
+// `let ... in`, or an unterminated string/path).
+const (
+	prompt         = "nix-repl> "
+	continuePrompt = "      ...> "
+)
+
+// REPL is an interactive Nix expression evaluator. It reads expressions
+// from in, possibly spanning several lines, evaluates them against a
+// shared environment, and writes results and errors to out.
+type REPL struct {
+	in    *bufio.Scanner
+	out   io.Writer
+	eval  *eval.Evaluator
+	env   *value.Env
+	trace bool // when true, every parser created by the REPL logs to out
+
+	history     []string // every entry submitted so far this session, including ones reloaded from historyPath
+	historyPath string   // where recordHistory persists new entries; "" if the home directory is unknown
+
+	loadedFiles []string // paths :load has merged into env, in order, for :reload to re-run
+}
+
+// New creates a REPL reading expressions from in and writing output to out.
+// baseDir is used to resolve relative path literals, matching eval.New.
+// Past history is loaded from ~/.config/gix/history if present; see
+// loadHistory/recordHistory.
+func New(in io.Reader, out io.Writer, baseDir string) *REPL {
+	e := eval.New(baseDir)
+
+	r := &REPL{
+		in:   bufio.NewScanner(in),
+		out:  out,
+		eval: e,
+		env:  e.NewEnv(),
+	}
+	r.loadHistory()
+
+	return r
+}
+
+// Run starts the REPL loop, printing a prompt, reading a complete entry,
+// and evaluating or executing it. It returns when the input is exhausted
+// or the user issues :q/:quit.
+func (r *REPL) Run() {
+	fmt.Fprintln(r.out, "gix repl - Type :q to exit, :help for commands")
+	fmt.Fprintln(r.out)
+
+	for {
+		fmt.Fprint(r.out, prompt)
+
+		src, ok := r.readEntry()
+		if !ok {
+			return
+		}
+
+		trimmed := strings.TrimSpace(src)
+		if trimmed == "" {
+			continue
+		}
+		r.recordHistory(trimmed)
+
+		if strings.HasPrefix(trimmed, ":") {
+			if r.handleCommand(trimmed) {
+				return
+			}
+
+			continue
+		}
+
+		if name, rhs, ok := topLevelBinding(trimmed); ok {
+			r.bindTopLevel(name, rhs)
+
+			continue
+		}
+
+		r.evalAndPrint(trimmed)
+	}
+}
+
+// topLevelBinding detects the Nix REPL's `name = expr` top-level binding
+// syntax, distinguished from a plain expression by a leading identifier
+// followed immediately by '=' (TOKEN_ASSIGN, not the "==" of TOKEN_EQ).
+// This can't be confused with `let name = expr; in ...`, which starts
+// with TOKEN_LET rather than TOKEN_IDENT.
+func topLevelBinding(src string) (name, rhs string, ok bool) {
+	l := lexer.New(src)
+
+	first := l.NextToken()
+	if first.Type != lexer.TOKEN_IDENT {
+		return "", "", false
+	}
+
+	second := l.NextToken()
+	if second.Type != lexer.TOKEN_ASSIGN {
+		return "", "", false
+	}
+
+	return first.Literal, src[second.EndOffset:], true
+}
+
+// bindTopLevel evaluates rhs and binds the result to name in the REPL's
+// shared environment so later entries can refer to it, the way a real
+// Nix REPL session accumulates top-level definitions across prompts.
+func (r *REPL) bindTopLevel(name, rhs string) {
+	val, err := r.evalSrc(rhs)
+	if err != nil {
+		return
+	}
+
+	r.env.Set(name, val)
+}
+
+// handleLet processes a `:let name = expr` meta-command, binding name to
+// expr's value the same way a bare `name = expr` entry does (see
+// topLevelBinding and bindTopLevel) - :let exists for parity with the
+// real Nix REPL, where it predates that plain top-level assignment was
+// ever recognized on its own.
+func (r *REPL) handleLet(src string) {
+	name, rhs, ok := topLevelBinding(src)
+	if !ok {
+		fmt.Fprintln(r.out, "usage: :let name = expr")
+
+		return
+	}
+
+	r.bindTopLevel(name, rhs)
+}
+
+// readEntry reads lines from the input until they form a complete
+// expression (or a meta-command), printing a continuation prompt for
+// every extra line it needs. It reports false once the input is exhausted.
+func (r *REPL) readEntry() (string, bool) {
+	var lines []string
+
+	for {
+		if !r.in.Scan() {
+			return "", false
+		}
+
+		lines = append(lines, r.in.Text())
+		src := strings.Join(lines, "\n")
+
+		trimmed := strings.TrimSpace(src)
+		if trimmed == "" || strings.HasPrefix(trimmed, ":") {
+			return trimmed, true
+		}
+
+		if lexer.IsComplete(src) {
+			return src, true
+		}
+
+		fmt.Fprint(r.out, continuePrompt)
+	}
+}
+
+// handleCommand processes a REPL meta-command. It reports whether the
+// REPL should exit.
+func (r *REPL) handleCommand(cmd string) bool {
+	switch {
+	case cmd == ":q" || cmd == ":quit":
+		return true
+	case cmd == ":help" || cmd == ":h":
+		r.printHelp()
+	case cmd == ":b":
+		r.printBindings()
+	case strings.HasPrefix(cmd, ":t "):
+		r.printType(strings.TrimSpace(cmd[len(":t "):]))
+	case strings.HasPrefix(cmd, ":p "):
+		r.printPretty(strings.TrimSpace(cmd[len(":p "):]))
+	case strings.HasPrefix(cmd, ":doc "):
+		r.printDoc(strings.TrimSpace(cmd[len(":doc "):]))
+	case strings.HasPrefix(cmd, ":type "):
+		r.printType(strings.TrimSpace(cmd[len(":type "):]))
+	case strings.HasPrefix(cmd, ":print "):
+		r.printForced(strings.TrimSpace(cmd[len(":print "):]))
+	case strings.HasPrefix(cmd, ":let "):
+		r.handleLet(strings.TrimSpace(cmd[len(":let "):]))
+	case strings.HasPrefix(cmd, ":load "):
+		r.loadAndMerge(strings.TrimSpace(cmd[len(":load "):]))
+	case strings.HasPrefix(cmd, ":l "):
+		r.loadAndMerge(strings.TrimSpace(cmd[len(":l "):]))
+	case cmd == ":reload":
+		r.reload()
+	case cmd == ":trace":
+		r.trace = !r.trace
+		fmt.Fprintf(r.out, "parser tracing %s\n", onOff(r.trace))
+	case cmd == ":reset":
+		r.env = r.eval.NewEnv()
+		fmt.Fprintln(r.out, "environment reset")
+	case cmd == ":vars":
+		r.printVars()
+	case strings.HasPrefix(cmd, ":lex "):
+		r.printLex(strings.TrimSpace(cmd[len(":lex "):]))
+	case strings.HasPrefix(cmd, ":ast "):
+		r.printAST(strings.TrimSpace(cmd[len(":ast "):]))
+	case cmd == ":history":
+		r.printHistory()
+	case strings.HasPrefix(cmd, ":complete "):
+		r.printCompletions(strings.TrimSpace(cmd[len(":complete "):]))
+	default:
+		fmt.Fprintf(r.out, "Unknown command: %s\n", cmd)
+		fmt.Fprintln(r.out, "Type :help for available commands")
+	}
+
+	return false
+}
+
+// printHelp lists the available meta-commands.
+func (r *REPL) printHelp() {
+	fmt.Fprintln(r.out, "Available commands:")
+	fmt.Fprintln(r.out, "  :t, :type expr  Show the type of expr")
+	fmt.Fprintln(r.out, "  :p expr      Pretty-print the canonical form of expr")
+	fmt.Fprintln(r.out, "  :print expr  Evaluate expr, forcing it deeply, and print the result")
+	fmt.Fprintln(r.out, "  :doc expr    Show the doc comment attached to expr, if any")
+	fmt.Fprintln(r.out, "  :let name = expr  Bind name in the shared environment (same as a bare \"name = expr\")")
+	fmt.Fprintln(r.out, "  :b           List current bindings with their values")
+	fmt.Fprintln(r.out, "  :vars        List current bindings with their types")
+	fmt.Fprintln(r.out, "  :reset       Clear all top-level bindings")
+	fmt.Fprintln(r.out, "  :l, :load path  Evaluate a file and merge its attrset into scope")
+	fmt.Fprintln(r.out, "  :reload      Re-run every :load so far, picking up on-disk edits")
+	fmt.Fprintln(r.out, "  :lex expr    Print the token stream for expr")
+	fmt.Fprintln(r.out, "  :ast expr    Print the parsed AST for expr")
+	fmt.Fprintln(r.out, "  :trace       Toggle parser tracing for subsequent entries")
+	fmt.Fprintln(r.out, "  :complete p  List completions for the prefix p (e.g. \"pkgs.hell\")")
+	fmt.Fprintln(r.out, "  :history     List this session's submitted entries")
+	fmt.Fprintln(r.out, "  :help, :h    Show this help")
+	fmt.Fprintln(r.out, "  :quit, :q    Exit the REPL")
+}
+
+// onOff renders a bool as the "on"/"off" a user expects from a toggle
+// command's confirmation message.
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+
+	return "off"
+}
+
+// printBindings lists the names bound directly in the REPL's shared
+// environment, along with their current values.
+func (r *REPL) printBindings() {
+	names := r.env.Names()
+	if len(names) == 0 {
+		fmt.Fprintln(r.out, "(no bindings)")
+
+		return
+	}
+
+	for _, name := range names {
+		val, _ := r.env.Get(name)
+		fmt.Fprintf(r.out, "%s = %s\n", name, val.String())
+	}
+}
+
+// printVars lists the names bound directly in the REPL's shared
+// environment, along with each one's type - a quicker scan than :b when
+// a value's full printed form would be long (a derivation, a large set).
+func (r *REPL) printVars() {
+	names := r.env.Names()
+	if len(names) == 0 {
+		fmt.Fprintln(r.out, "(no bindings)")
+
+		return
+	}
+
+	for _, name := range names {
+		val, _ := r.env.Get(name)
+		fmt.Fprintf(r.out, "%s :: %s\n", name, val.Type())
+	}
+}
+
+// printType evaluates src and reports the type of its result.
+func (r *REPL) printType(src string) {
+	val, err := r.evalSrc(src)
+	if err != nil {
+		return
+	}
+
+	fmt.Fprintln(r.out, val.Type())
+}
+
+// printPretty parses src and prints its canonical, indentation-aware form
+// via pkg/format, without evaluating it.
+func (r *REPL) printPretty(src string) {
+	ast, err := r.newParser(src).Parse()
+	if err != nil {
+		r.printParseError(src, err)
+
+		return
+	}
+
+	fmt.Fprintln(r.out, format.Format(ast))
+}
+
+// printCompletions prints each completion Completions finds for partial,
+// one per line, or a placeholder if there are none - the on-demand
+// stand-in for the real Tab-triggered completion described in
+// pkg/repl/doc.go, since this REPL reads lines via bufio.Scanner rather
+// than a readline-style editor capable of reacting to a keypress
+// mid-line.
+func (r *REPL) printCompletions(partial string) {
+	completions := r.Completions(partial)
+	if len(completions) == 0 {
+		fmt.Fprintln(r.out, "(no completions)")
+
+		return
+	}
+
+	for _, c := range completions {
+		fmt.Fprintln(r.out, c)
+	}
+}
+
+// printLex tokenizes src and prints the resulting token stream, one token
+// per line, for inspecting how the lexer saw a problematic input.
+func (r *REPL) printLex(src string) {
+	l := lexer.New(src)
+	for {
+		tok := l.NextToken()
+		fmt.Fprintf(r.out, "%-14s %-20q line %d, col %d\n", tok.Type, tok.Literal, tok.Line, tok.Column)
+
+		if tok.Type == lexer.TOKEN_EOF {
+			return
+		}
+	}
+}
+
+// printAST parses src and prints its AST via Expr.String(), without
+// evaluating it - the canonical, fully-parenthesized form described in
+// internal/types/doc.go, not a reproduction of src's own formatting.
+func (r *REPL) printAST(src string) {
+	ast, err := r.newParser(src).Parse()
+	if err != nil {
+		r.printParseError(src, err)
+
+		return
+	}
+
+	fmt.Fprintln(r.out, ast.String())
+}
+
+// newParser builds a parser for src, wiring up tracing to r.out when the
+// REPL's :trace toggle is on so tracing applies uniformly to every command
+// that parses (plain evaluation, :p, :ast), not just direct input.
+func (r *REPL) newParser(src string) *parser.Parser {
+	p := parser.New(lexer.New(src))
+	if r.trace {
+		p.EnableTrace(r.out)
+	}
+
+	return p
+}
+
+// printParseError reports a parse error the way a compiler would: the
+// message, followed by the offending source line with a caret under the
+// column ParseError recorded. Falls back to err.Error() if err isn't the
+// *parser.ParseErrors that Parse() actually returns.
+func (r *REPL) printParseError(src string, err error) {
+	perrs, ok := err.(*parser.ParseErrors)
+	if !ok {
+		fmt.Fprintf(r.out, "Parse error: %v\n", err)
+
+		return
+	}
+
+	lines := strings.Split(src, "\n")
+	for _, pe := range perrs.Errors() {
+		fmt.Fprintf(r.out, "parse error at line %d, column %d: %s\n", pe.Line, pe.Column, pe.Message)
+
+		if pe.Line >= 1 && pe.Line <= len(lines) {
+			fmt.Fprintln(r.out, lines[pe.Line-1])
+			fmt.Fprintln(r.out, strings.Repeat(" ", pe.Column)+"^")
+		}
+	}
+}
+
+// printDoc evaluates src and pretty-prints the documentation attached to
+// it, if src evaluates to a function or built-in (see the doc built-in
+// in pkg/eval/builtins.go, which this mirrors for interactive use).
+func (r *REPL) printDoc(src string) {
+	val, err := r.evalSrc(src)
+	if err != nil {
+		return
+	}
+
+	switch fn := val.(type) {
+	case *value.Function:
+		doc := fn.Doc()
+		if doc == "" {
+			doc = "(no doc comment)"
+		}
+		fmt.Fprintf(r.out, "%s: %s\n\n%s\n", fn.Param(), "<function>", doc)
+	case *value.Builtin:
+		doc := fn.Doc()
+		if doc == "" {
+			doc = "(no documentation)"
+		}
+		fmt.Fprintf(r.out, "%s (built-in)\n\n%s\n", fn.Name(), doc)
+		if example := fn.Example(); example != "" {
+			fmt.Fprintf(r.out, "\nExample:\n  %s\n", example)
+		}
+	default:
+		fmt.Fprintf(r.out, "%s is not a function\n", src)
+	}
+}
+
+// loadAndMerge reads path, evaluates its contents against the REPL's
+// shared environment, and merges the resulting attrset's bindings
+// directly into that environment, so later entries can refer to them
+// unqualified instead of through a `with import "path"; ...` of their
+// own. path is recorded in loadedFiles so :reload can re-run it later.
+func (r *REPL) loadAndMerge(path string) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(r.out, "Error reading file: %v\n", err)
+
+		return
+	}
+
+	val, err := r.evalSrc(string(content))
+	if err != nil {
+		return
+	}
+
+	attrs, ok := val.(*value.Attrs)
+	if !ok {
+		fmt.Fprintf(r.out, "%s does not evaluate to an attribute set, nothing to merge\n", path)
+
+		return
+	}
+
+	for _, name := range attrs.Keys() {
+		v, _ := attrs.Get(name)
+		r.env.Set(name, v)
+	}
+
+	r.loadedFiles = append(r.loadedFiles, path)
+}
+
+// reload re-runs loadAndMerge for every file :load has merged so far, in
+// the order they were loaded, picking up any edits made on disk since.
+func (r *REPL) reload() {
+	if len(r.loadedFiles) == 0 {
+		fmt.Fprintln(r.out, "(no files loaded)")
+
+		return
+	}
+
+	files := r.loadedFiles
+	r.loadedFiles = nil
+
+	for _, path := range files {
+		r.loadAndMerge(path)
+	}
+}
+
+// printForced evaluates src and prints the result after forcing it
+// deeply via value.DeepForce, the way `nix-instantiate --strict` or a
+// real Nix REPL's :print would, instead of leaving nested thunks
+// unevaluated the way plain evaluation does.
+func (r *REPL) printForced(src string) {
+	val, err := r.evalSrc(src)
+	if err != nil {
+		return
+	}
+
+	forced, err := value.DeepForce(val)
+	if err != nil {
+		fmt.Fprintf(r.out, "Evaluation error: %v\n", err)
+
+		return
+	}
+
+	fmt.Fprintln(r.out, forced.String())
+}
+
+// evalAndPrint parses and evaluates src against the REPL's shared
+// environment, printing the result or any parse/evaluation error.
+func (r *REPL) evalAndPrint(src string) {
+	val, err := r.evalSrc(src)
+	if err != nil {
+		return
+	}
+
+	fmt.Fprintln(r.out, val.String())
+}
+
+// evalSrc parses and evaluates src against the REPL's shared environment,
+// printing (and returning) any parse or evaluation error it encounters.
+func (r *REPL) evalSrc(src string) (value.Value, error) {
+	ast, err := r.newParser(src).Parse()
+	if err != nil {
+		r.printParseError(src, err)
+
+		return nil, err
+	}
+
+	val, err := r.eval.EvalWithEnv(ast, r.env)
+	if err != nil {
+		fmt.Fprintf(r.out, "Evaluation error: %v\n", err)
+
+		return nil, err
+	}
+
+	return val, nil
+}
+